@@ -0,0 +1,131 @@
+package bed
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ajgo/coords"
+)
+
+// BED is the UCSC Browser Extensible Data format. Each line is one
+// interval with 3 required tab-separated columns (chrom, chromStart,
+// chromEnd) and up to 9 optional columns, of which we parse name, score
+// and strand. Any further columns (thickStart, thickEnd, itemRgb,
+// blockCount, blockSizes, blockStarts or anything custom) are kept
+// verbatim in Extra. Lines starting with #, track or browser are
+// headers.
+//
+// BED coordinates are 0-based half-open so the first 100 bases of a
+// sequence are 0-100. Record always holds 0-based half-open coordinates.
+// If a BED has a "#format" header that says otherwise, Reader converts
+// as it reads. Writer records the convention in a
+// "#format 0-based half-open" header.
+//
+// See https://genome.ucsc.edu/FAQ/FAQformat.html#format1
+
+// CoordSystem is the coordinate system used by BED.
+var CoordSystem = coords.ZeroBasedHalfOpen
+
+// Record is a single BED line.
+type Record struct {
+	Chrom      string
+	Start      int // 0-based
+	End        int // first base past the interval
+	Name       string
+	Score      string
+	Strand     string
+	Extra      []string // columns 7 onwards
+	LineNumber int      // Line number within the BED file
+}
+
+// NewRecordFromLine parses a single BED line. Columns are separated by
+// tabs but if there are fewer than 3 tab-separated columns, any white
+// space is allowed as a separator.
+func NewRecordFromLine(line string) (*Record, error) {
+	line = strings.TrimRight(line, "\r\n")
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 {
+		fields = strings.Fields(line)
+	}
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("NewRecordFromLine: %d fields supplied - at least 3 are required", len(fields))
+	}
+
+	r := &Record{Chrom: fields[0]}
+	var err error
+	if r.Start, err = strconv.Atoi(fields[1]); err != nil {
+		return nil, fmt.Errorf("NewRecordFromLine: error converting chromStart %s to int: %w", fields[1], err)
+	}
+	if r.End, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, fmt.Errorf("NewRecordFromLine: error converting chromEnd %s to int: %w", fields[2], err)
+	}
+	if r.Start < 0 || r.End < r.Start {
+		return nil, fmt.Errorf("NewRecordFromLine: chromStart %d and chromEnd %d are not a valid interval", r.Start, r.End)
+	}
+	if len(fields) > 3 {
+		r.Name = fields[3]
+	}
+	if len(fields) > 4 {
+		r.Score = fields[4]
+	}
+	if len(fields) > 5 {
+		r.Strand = fields[5]
+	}
+	if len(fields) > 6 {
+		r.Extra = append(r.Extra, fields[6:]...)
+	}
+	return r, nil
+}
+
+// Length is the number of bases in the Record.
+func (r *Record) Length() int {
+	return r.End - r.Start
+}
+
+// String gives the Record as a BED line. Only as many columns as are
+// needed are written, so a Record with no Name, Score, Strand or Extra
+// is written as BED3. Missing values in columns that must be written
+// are set to ".".
+func (r *Record) String() string {
+	fields := []string{r.Chrom, strconv.Itoa(r.Start), strconv.Itoa(r.End),
+		r.Name, r.Score, r.Strand}
+	n := 3
+	switch {
+	case len(r.Extra) > 0:
+		n = 6
+	case r.Strand != ``:
+		n = 6
+	case r.Score != ``:
+		n = 5
+	case r.Name != ``:
+		n = 4
+	}
+	fields = fields[:n]
+	for i := 3; i < n; i++ {
+		if fields[i] == `` {
+			fields[i] = `.`
+		}
+	}
+	return strings.Join(append(fields, r.Extra...), "\t")
+}
+
+// FormatHeader returns the #format header for a coordinate system.
+func FormatHeader(s coords.System) string {
+	return coords.FormatHeader(`#`, s)
+}
+
+// CoordSystemFromHeaders returns the coordinate system recorded in a
+// "#format" header (or "##format" as used in GFF3). If there is no
+// such header, the coordinate system is 0-based half-open as per the
+// BED spec.
+func CoordSystemFromHeaders(headers []string) (coords.System, error) {
+	return coords.FromHeaders(`#`, headers, CoordSystem)
+}
+
+// isHeader returns true if line is a comment, track or browser line.
+func isHeader(line string) bool {
+	return strings.HasPrefix(line, `#`) ||
+		strings.HasPrefix(line, `track`) ||
+		strings.HasPrefix(line, `browser`)
+}
@@ -0,0 +1,140 @@
+package bed
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"ajgo/coords"
+	"ajgo/gff3"
+)
+
+func TestNewRecordFromLine(t *testing.T) {
+	var tests = []struct {
+		line string
+		ok   bool
+		out  string
+	}{
+		{"chr1\t0\t100", true, "chr1\t0\t100"},
+		{"chr1 0 100", true, "chr1\t0\t100"},
+		{"chr1\t0\t100\tgeneA", true, "chr1\t0\t100\tgeneA"},
+		{"chr1\t0\t100\t\t\t-", true, "chr1\t0\t100\t.\t.\t-"},
+		{"chr1\t5\t5\tins\t0\t+", true, "chr1\t5\t5\tins\t0\t+"},
+		{"chr1\t0\t100\ta\t0\t+\t10\t90\t0,0,0", true, "chr1\t0\t100\ta\t0\t+\t10\t90\t0,0,0"},
+		{"chr1\t0", false, ``},
+		{"chr1\tx\t100", false, ``},
+		{"chr1\t100\t0", false, ``},
+		{"chr1\t-1\t10", false, ``},
+	}
+
+	for _, tt := range tests {
+		r, err := NewRecordFromLine(tt.line)
+		if (err == nil) != tt.ok {
+			t.Fatalf("NewRecordFromLine(%q) error should be %v but is %v", tt.line, !tt.ok, err)
+		}
+		if !tt.ok {
+			continue
+		}
+		if r.String() != tt.out {
+			t.Fatalf("NewRecordFromLine(%q) String should be %q but is %q", tt.line, tt.out, r.String())
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	text := "browser position chr1:1-1000\n" +
+		"track name=test\n" +
+		"#format 1-based closed\n" +
+		"chr1\t1\t100\ta\n" +
+		"\n" +
+		"# a comment\n" +
+		"chr1\t200\t200\tb\n"
+
+	r, err := NewReader(strings.NewReader(text))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if r.CoordSystem != coords.OneBasedClosed {
+		t.Fatalf("CoordSystem should be %v but is %v", coords.OneBasedClosed, r.CoordSystem)
+	}
+	recs, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if len(recs) != 2 || len(r.Header) != 4 {
+		t.Fatalf("should be 2 records and 4 headers but are %d and %d", len(recs), len(r.Header))
+	}
+	if recs[0].Start != 0 || recs[0].End != 100 || recs[0].LineNumber != 4 {
+		t.Fatalf("first record should be 0-100 at line 4 but is %d-%d at line %d",
+			recs[0].Start, recs[0].End, recs[0].LineNumber)
+	}
+	if recs[1].Start != 199 || recs[1].End != 200 || recs[1].LineNumber != 7 {
+		t.Fatalf("second record should be 199-200 at line 7 but is %d-%d at line %d",
+			recs[1].Start, recs[1].End, recs[1].LineNumber)
+	}
+
+	if _, err := NewReader(strings.NewReader("#format 2-based\nchr1\t0\t1\n")); err == nil {
+		t.Fatalf("NewReader should fail on an unrecognised #format")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.AddHeaders("##created-by test\n", "track name=x", "created-on here", "#format 1-based closed")
+	if err := w.Write(&Record{Chrom: `chr1`, Start: 0, End: 10}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	exp := "#format 0-based half-open\n" +
+		"##created-by test\n" +
+		"track name=x\n" +
+		"#created-on here\n" +
+		"chr1\t0\t10\n"
+	if buf.String() != exp {
+		t.Fatalf("Writer output should be %q but is %q", exp, buf.String())
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	if r.CoordSystem != CoordSystem {
+		t.Fatalf("written BED should be %v but is %v", CoordSystem, r.CoordSystem)
+	}
+}
+
+func TestFeatureConversion(t *testing.T) {
+	f, err := gff3.NewFeatureFromLine("chr1\tens\tgene\t1\t100\t.\t-\t.\tID=gene:A;Name=A")
+	if err != nil {
+		t.Fatalf("NewFeatureFromLine failed: %v", err)
+	}
+
+	r := FromFeature(f, coords.OneBasedClosed, []string{`Name`, `ID`})
+	if exp := "chr1\t0\t100\tA\t.\t-"; r.String() != exp {
+		t.Fatalf("FromFeature should be %q but is %q", exp, r.String())
+	}
+	r = FromFeature(f, coords.OneBasedHalfOpen, []string{`Other`, `ID`})
+	if exp := "chr1\t0\t99\tgene:A\t.\t-"; r.String() != exp {
+		t.Fatalf("FromFeature half-open should be %q but is %q", exp, r.String())
+	}
+
+	r, _ = NewRecordFromLine("chr2\t9\t20\tB\t500\t.")
+	nf, err := r.Feature(`bed`, `region`)
+	if err != nil {
+		t.Fatalf("Feature failed: %v", err)
+	}
+	if exp := "chr2\tbed\tregion\t10\t20\t500\t.\t.\tName=B"; nf.String() != exp {
+		t.Fatalf("Feature should be %q but is %q", exp, nf.String())
+	}
+	if back := FromFeature(nf, coords.OneBasedClosed, []string{`Name`}); back.String() != r.String() {
+		t.Fatalf("round trip should be %q but is %q", r.String(), back.String())
+	}
+
+	r, _ = NewRecordFromLine("chr2\t9\t9")
+	if _, err := r.Feature(`bed`, `region`); err == nil {
+		t.Fatalf("Feature should fail for a zero-length record")
+	}
+}
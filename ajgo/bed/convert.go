@@ -0,0 +1,59 @@
+package bed
+
+import (
+	"fmt"
+
+	"ajgo/coords"
+	"ajgo/gff3"
+)
+
+// Conversion between BED Records and GFF3 Feature. The only subtle part
+// is the coordinates - a GFF3 Feature 1-100 is the BED Record 0-100.
+// GFF3 Score "." and BED score "." are kept as-is so a round trip does
+// not invent scores, and BED strand "." and GFF3 Strand "." and "?"
+// all mean unknown.
+
+// FromFeature creates a Record from a Feature whose Start and End are
+// in coordinate system sys (see gff3.CoordSystemFromHeaders). The name
+// is the value of the first of nameAttrs that the Feature has, e.g.
+// []string{"Name", "ID"}.
+func FromFeature(f *gff3.Feature, sys coords.System, nameAttrs []string) *Record {
+	r := &Record{Chrom: f.SeqId, Score: f.Score, Strand: f.Strand}
+	r.Start, r.End = sys.Convert(f.Start, f.End, CoordSystem)
+	if r.Strand == `?` {
+		r.Strand = `.`
+	}
+	for _, a := range nameAttrs {
+		if v, ok := f.Attributes[a]; ok && v != `` {
+			r.Name = v
+			break
+		}
+	}
+	return r
+}
+
+// Feature creates a 1-based closed GFF3 Feature from a Record. The
+// Record name, if any, becomes the Name Attribute. BED allows
+// zero-length Records (e.g. insertion points) but GFF3 does not so they
+// return an error. Extra columns are not carried over.
+func (r *Record) Feature(source, typ string) (*gff3.Feature, error) {
+	if r.Length() < 1 {
+		return nil, fmt.Errorf("Feature: zero-length BED record %s:%d-%d cannot be a GFF3 Feature",
+			r.Chrom, r.Start, r.End)
+	}
+	f := gff3.NewFeature()
+	f.SeqId = r.Chrom
+	f.Source = source
+	f.Type = typ
+	f.Start, f.End = CoordSystem.Convert(r.Start, r.End, coords.OneBasedClosed)
+	if r.Score != `` {
+		f.Score = r.Score
+	}
+	if r.Strand == `+` || r.Strand == `-` {
+		f.Strand = r.Strand
+	}
+	if r.Name != `` && r.Name != `.` {
+		f.Attributes[`Name`] = r.Name
+	}
+	return f, nil
+}
@@ -0,0 +1,195 @@
+package bed
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"ajgo/coords"
+)
+
+// Reader reads a BED one Record at a time.
+//
+// The header lines are read when the Reader is created so they are
+// available in Header, and the coordinate system in CoordSystem, before
+// the first call to Read. Any header lines found between records are
+// appended to Header as they are encountered. Blank lines are skipped.
+type Reader struct {
+	File        string
+	Header      []string
+	CoordSystem coords.System // as read - Records are always 0-based half-open
+
+	scanner *bufio.Scanner
+	closers []io.Closer
+	lctr    int
+	next    string // first record line, read while gathering headers
+	nextLn  int
+	err     error
+}
+
+// NewReader creates a *Reader from an io.Reader. It reads the header
+// lines so it will return an error if a #format header cannot be
+// parsed.
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	br := &Reader{scanner: scanner}
+
+	for br.scanner.Scan() {
+		line := strings.TrimRight(br.scanner.Text(), "\r\n")
+		br.lctr++
+		if strings.TrimSpace(line) == `` {
+			continue
+		}
+		if isHeader(line) {
+			br.Header = append(br.Header, line)
+			continue
+		}
+		br.next = line
+		br.nextLn = br.lctr
+		break
+	}
+	if err := br.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("NewReader: error scanning: %w", err)
+	}
+
+	sys, err := CoordSystemFromHeaders(br.Header)
+	if err != nil {
+		return nil, fmt.Errorf("NewReader: %w", err)
+	}
+	br.CoordSystem = sys
+	return br, nil
+}
+
+// NewReaderFromFile opens a file and creates a *Reader. Files with a
+// .gz extension are gunzipped on-the-fly. The caller must call Close
+// when finished with the Reader.
+func NewReaderFromFile(file string) (*Reader, error) {
+	ff, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = ff
+	closers := []io.Closer{ff}
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		ff.Close()
+		return nil, fmt.Errorf("NewReaderFromFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz, err := gzip.NewReader(ff)
+		if err != nil {
+			ff.Close()
+			return nil, fmt.Errorf("NewReaderFromFile: error opening gzip file %s: %w", file, err)
+		}
+		r = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	br, err := NewReader(r)
+	if err != nil {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, fmt.Errorf("NewReaderFromFile: %s: %w", file, err)
+	}
+	br.File = file
+	br.closers = closers
+	return br, nil
+}
+
+// ParseError is returned by Read when a line cannot be parsed into a
+// Record. It is not fatal - Read can be called again to continue with
+// the next line.
+type ParseError struct {
+	LineNumber int
+	Line       string
+	Err        error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error creating Record at line %d: %v", e.LineNumber, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Read returns the next Record. At the end of the input, it returns a
+// nil *Record and io.EOF. If a line cannot be parsed, a *ParseError is
+// returned and reading may continue.
+func (r *Reader) Read() (*Record, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.next != `` {
+		line, ln := r.next, r.nextLn
+		r.next = ``
+		return r.parse(line, ln)
+	}
+
+	for r.scanner.Scan() {
+		line := strings.TrimRight(r.scanner.Text(), "\r\n")
+		r.lctr++
+		if strings.TrimSpace(line) == `` {
+			continue
+		}
+		if isHeader(line) {
+			r.Header = append(r.Header, line)
+			continue
+		}
+		return r.parse(line, r.lctr)
+	}
+	if err := r.scanner.Err(); err != nil {
+		r.err = fmt.Errorf("Read: error scanning: %w", err)
+		return nil, r.err
+	}
+
+	r.err = io.EOF
+	return nil, r.err
+}
+
+// ReadAll reads all of the remaining Records.
+func (r *Reader) ReadAll() ([]*Record, error) {
+	var recs []*Record
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+}
+
+// Close closes any files opened by NewReaderFromFile. It is safe to
+// call Close on a Reader created by NewReader.
+func (r *Reader) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	r.closers = nil
+	return first
+}
+
+// parse creates a Record from a line and converts it to 0-based
+// half-open.
+func (r *Reader) parse(line string, ln int) (*Record, error) {
+	rec, err := NewRecordFromLine(line)
+	if err != nil {
+		return nil, &ParseError{LineNumber: ln, Line: line, Err: err}
+	}
+	rec.Start, rec.End = r.CoordSystem.Convert(rec.Start, rec.End, CoordSystem)
+	rec.LineNumber = ln
+	return rec, nil
+}
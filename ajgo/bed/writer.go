@@ -0,0 +1,147 @@
+package bed
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"ajgo/coords"
+)
+
+// Writer writes a BED one Record at a time. Header lines are held in
+// Header until the first Record is written (or WriteHeader is called).
+// A "#format 0-based half-open" header is always written first so the
+// coordinate convention is recorded in the file. Header lines that do
+// not already start with #, track or browser are prefixed with #.
+type Writer struct {
+	File   string
+	Header []string
+
+	w             *bufio.Writer
+	closers       []io.Closer
+	headerWritten bool
+	count         int
+}
+
+// NewWriter creates a *Writer that writes to an io.Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// NewWriterToFile creates a file and returns a *Writer for it. Files
+// with a .gz extension are gzipped on-the-fly. The caller must call
+// Close to flush the output and close the file.
+func NewWriterToFile(file string) (*Writer, error) {
+	ff, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = ff
+	closers := []io.Closer{ff}
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		ff.Close()
+		return nil, fmt.Errorf("NewWriterToFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz := gzip.NewWriter(ff)
+		w = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	bw := NewWriter(w)
+	bw.File = file
+	bw.closers = closers
+	return bw, nil
+}
+
+// AddHeaders appends header lines. It returns an error if the headers
+// have already been written.
+func (w *Writer) AddHeaders(headers ...string) error {
+	if w.headerWritten {
+		return fmt.Errorf("AddHeaders: headers already written to %s", w.File)
+	}
+	w.Header = append(w.Header, headers...)
+	return nil
+}
+
+// WriteHeader writes the header lines. It is called automatically by
+// the first call to Write. Calling WriteHeader more than once is
+// harmless.
+func (w *Writer) WriteHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+
+	headers := []string{FormatHeader(CoordSystem)}
+	for _, h := range w.Header {
+		for _, line := range strings.Split(h, "\n") {
+			line = strings.TrimRight(line, "\r")
+			if strings.TrimSpace(line) == `` {
+				continue
+			}
+			if coords.IsFormatHeader(`#`, line) {
+				// The Records are 0-based half-open whatever was read
+				continue
+			}
+			if !isHeader(line) {
+				line = `#` + line
+			}
+			headers = append(headers, line)
+		}
+	}
+
+	for _, h := range headers {
+		if _, err := w.w.WriteString(h + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes a single Record, writing the headers first if that has
+// not already been done.
+func (w *Writer) Write(r *Record) error {
+	if !w.headerWritten {
+		if err := w.WriteHeader(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.w.WriteString(r.String() + "\n"); err != nil {
+		return err
+	}
+	w.count++
+	return nil
+}
+
+// Count returns the number of Records written so far.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Flush writes any buffered output to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close writes the headers (if not already written), flushes buffered
+// output and closes any files opened by NewWriterToFile.
+func (w *Writer) Close() error {
+	first := w.WriteHeader()
+	if err := w.Flush(); err != nil && first == nil {
+		first = err
+	}
+	for _, c := range w.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	w.closers = nil
+	return first
+}
@@ -0,0 +1,346 @@
+// Package chain reads UCSC chain files and maps intervals through them
+// to lift coordinates from one genome build to another, e.g. GRCh37 to
+// GRCh38.
+//
+// A chain file is a series of chains, each of which aligns part of a
+// target sequence (the build being lifted from) to part of a query
+// sequence (the build being lifted to). A chain starts with a header
+// line:
+//
+//	chain score tName tSize tStrand tStart tEnd qName qSize qStrand qStart qEnd id
+//
+// followed by one line per ungapped block of the alignment:
+//
+//	size dt dq
+//
+// where size is the length of the block and dt and dq are the bases
+// between this block and the next in the target and query. The last
+// block line has only the size. Chains are separated by blank lines.
+//
+// Chain coordinates are 0-based half-open. If qStrand is -, the query
+// coordinates count from the end of the query sequence, i.e. they are
+// on the reverse complement. Everything in this package, including
+// Segment, is 0-based half-open and Segment coordinates are always on
+// the + strand of the query.
+//
+// See https://genome.ucsc.edu/goldenPath/help/chain.html
+package chain
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ajgo/coords"
+)
+
+// CoordSystem is the coordinate system used by chain files.
+var CoordSystem = coords.ZeroBasedHalfOpen
+
+// Chain is a single alignment between a target and a query sequence.
+type Chain struct {
+	Score      int64
+	TName      string
+	TSize      int
+	TStrand    string
+	TStart     int
+	TEnd       int
+	QName      string
+	QSize      int
+	QStrand    string
+	QStart     int
+	QEnd       int
+	Id         string
+	Blocks     []Block
+	LineNumber int // line number of the header within the chain file
+}
+
+// Block is an ungapped part of a Chain. QStart is on QStrand.
+type Block struct {
+	TStart int
+	QStart int
+	Size   int
+}
+
+// Chains is all of the Chain from a chain file, indexed by TName so
+// intervals can be mapped.
+type Chains struct {
+	File   string
+	Chains []*Chain
+
+	index map[string]*tIndex
+}
+
+// tIndex holds the Chains for one target sequence sorted by TStart.
+// maxEnd[i] is the largest TEnd of chains[0:i+1] so a search can stop
+// looking back as soon as no earlier Chain can reach the interval.
+type tIndex struct {
+	chains []*Chain
+	maxEnd []int
+}
+
+// NewFromFile reads a chain file. Files with a .gz extension are
+// gunzipped on-the-fly.
+func NewFromFile(file string) (*Chains, error) {
+	ff, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer ff.Close()
+
+	var r io.Reader = ff
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		return nil, fmt.Errorf("NewFromFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz, err := gzip.NewReader(ff)
+		if err != nil {
+			return nil, fmt.Errorf("NewFromFile: error opening gzip file %s: %w", file, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	c, err := NewFromReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("NewFromFile: %s: %w", file, err)
+	}
+	c.File = file
+	return c, nil
+}
+
+// NewFromReader reads chains from an io.Reader. Lines starting with #
+// are comments.
+func NewFromReader(r io.Reader) (*Chains, error) {
+	scanner := bufio.NewScanner(r)
+	cs := &Chains{}
+	var c *Chain
+	var t, q, lctr int
+	for scanner.Scan() {
+		lctr++
+		line := strings.TrimSpace(scanner.Text())
+		if line == `` || strings.HasPrefix(line, `#`) {
+			continue
+		}
+		fields := strings.Fields(line)
+
+		if fields[0] == `chain` {
+			if c != nil {
+				return nil, fmt.Errorf("line %d: chain at line %d has no final block", lctr, c.LineNumber)
+			}
+			var err error
+			if c, err = newChain(fields); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lctr, err)
+			}
+			c.LineNumber = lctr
+			t, q = c.TStart, c.QStart
+			continue
+		}
+
+		if c == nil {
+			return nil, fmt.Errorf("line %d: block outside of a chain: %s", lctr, line)
+		}
+		if len(fields) != 1 && len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: block must have 1 or 3 fields: %s", lctr, line)
+		}
+		ints, err := atois(fields)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lctr, err)
+		}
+		c.Blocks = append(c.Blocks, Block{TStart: t, QStart: q, Size: ints[0]})
+		t += ints[0]
+		q += ints[0]
+		if len(ints) == 3 {
+			t += ints[1]
+			q += ints[2]
+			continue
+		}
+
+		// Last block so the chain must end where its header says
+		if t != c.TEnd || q != c.QEnd {
+			return nil, fmt.Errorf("line %d: blocks of chain at line %d end at %d/%d not tEnd/qEnd %d/%d",
+				lctr, c.LineNumber, t, q, c.TEnd, c.QEnd)
+		}
+		cs.Chains = append(cs.Chains, c)
+		c = nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error scanning: %w", err)
+	}
+	if c != nil {
+		return nil, fmt.Errorf("chain at line %d has no final block", c.LineNumber)
+	}
+
+	cs.buildIndex()
+	return cs, nil
+}
+
+// newChain parses the fields of a chain header line.
+func newChain(fields []string) (*Chain, error) {
+	if len(fields) != 12 && len(fields) != 13 {
+		return nil, fmt.Errorf("chain header must have 12 or 13 fields: %s", strings.Join(fields, ` `))
+	}
+	score, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("chain score is not an integer: %s", fields[1])
+	}
+	ints, err := atois([]string{fields[3], fields[5], fields[6], fields[8], fields[10], fields[11]})
+	if err != nil {
+		return nil, fmt.Errorf("chain header: %w", err)
+	}
+	c := &Chain{
+		Score:   score,
+		TName:   fields[2],
+		TSize:   ints[0],
+		TStrand: fields[4],
+		TStart:  ints[1],
+		TEnd:    ints[2],
+		QName:   fields[7],
+		QSize:   ints[3],
+		QStrand: fields[9],
+		QStart:  ints[4],
+		QEnd:    ints[5],
+	}
+	if len(fields) == 13 {
+		c.Id = fields[12]
+	}
+	if c.TStrand != `+` {
+		return nil, fmt.Errorf("chain tStrand must be +: %s", c.TStrand)
+	}
+	if c.QStrand != `+` && c.QStrand != `-` {
+		return nil, fmt.Errorf("chain qStrand must be + or -: %s", c.QStrand)
+	}
+	return c, nil
+}
+
+// atois converts strings to non-negative ints.
+func atois(s []string) ([]int, error) {
+	ints := make([]int, len(s))
+	for i, v := range s {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("not a non-negative integer: %s", v)
+		}
+		ints[i] = n
+	}
+	return ints, nil
+}
+
+// buildIndex sorts the Chains for each target sequence by TStart.
+func (cs *Chains) buildIndex() {
+	cs.index = make(map[string]*tIndex)
+	for _, c := range cs.Chains {
+		ti, ok := cs.index[c.TName]
+		if !ok {
+			ti = &tIndex{}
+			cs.index[c.TName] = ti
+		}
+		ti.chains = append(ti.chains, c)
+	}
+	for _, ti := range cs.index {
+		sort.SliceStable(ti.chains, func(i, j int) bool {
+			return ti.chains[i].TStart < ti.chains[j].TStart
+		})
+		ti.maxEnd = make([]int, len(ti.chains))
+		for i, c := range ti.chains {
+			ti.maxEnd[i] = c.TEnd
+			if i > 0 && ti.maxEnd[i-1] > c.TEnd {
+				ti.maxEnd[i] = ti.maxEnd[i-1]
+			}
+		}
+	}
+}
+
+// Segment is a part of an interval that maps through a single Block.
+// TStart/TEnd are on the target and Start/End on the + strand of the
+// query.
+type Segment struct {
+	TStart int
+	TEnd   int
+	Start  int
+	End    int
+}
+
+// Mapping is an interval mapped through a Chain. Segments are in order
+// of TStart so if the Chain maps to the - strand of the query, they
+// are in descending order of Start.
+type Mapping struct {
+	Chain    *Chain
+	Segments []Segment
+	Mapped   int // bases of the interval that are in a Block
+}
+
+// Name returns the query sequence the interval was mapped to.
+func (m *Mapping) Name() string {
+	return m.Chain.QName
+}
+
+// Reverse returns true if the interval was mapped to the - strand of
+// the query.
+func (m *Mapping) Reverse() bool {
+	return m.Chain.QStrand == `-`
+}
+
+// Map maps an interval on a target sequence through the Chain that
+// aligns the most of its bases, or the Chain with the highest Score if
+// more than one aligns the same number of bases. It returns nil if no
+// Chain aligns any of the interval.
+func (cs *Chains) Map(name string, start, end int) *Mapping {
+	ti, ok := cs.index[name]
+	if !ok {
+		return nil
+	}
+	var best *Mapping
+	i := sort.Search(len(ti.chains), func(i int) bool { return ti.chains[i].TStart >= end })
+	for j := i - 1; j >= 0 && ti.maxEnd[j] > start; j-- {
+		c := ti.chains[j]
+		if c.TEnd <= start {
+			continue
+		}
+		m := c.Map(start, end)
+		if m.Mapped == 0 {
+			continue
+		}
+		if best == nil || m.Mapped > best.Mapped ||
+			(m.Mapped == best.Mapped && c.Score > best.Chain.Score) {
+			best = m
+		}
+	}
+	return best
+}
+
+// Map maps an interval on the target sequence through the Chain. The
+// Mapping has no Segments if the interval is entirely in gaps or
+// outside of the Chain.
+func (c *Chain) Map(start, end int) *Mapping {
+	m := &Mapping{Chain: c}
+	i := sort.Search(len(c.Blocks), func(i int) bool {
+		return c.Blocks[i].TStart+c.Blocks[i].Size > start
+	})
+	for ; i < len(c.Blocks) && c.Blocks[i].TStart < end; i++ {
+		b := c.Blocks[i]
+		s := b.TStart
+		if start > s {
+			s = start
+		}
+		e := b.TStart + b.Size
+		if end < e {
+			e = end
+		}
+		qs := b.QStart + s - b.TStart
+		qe := qs + e - s
+		if c.QStrand == `-` {
+			qs, qe = c.QSize-qe, c.QSize-qs
+		}
+		m.Segments = append(m.Segments, Segment{TStart: s, TEnd: e, Start: qs, End: qe})
+		m.Mapped += e - s
+	}
+	return m
+}
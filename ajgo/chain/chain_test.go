@@ -0,0 +1,71 @@
+package chain
+
+import (
+	"strings"
+	"testing"
+)
+
+// Chain 1 maps chr1:100-200 to 1:1000-1110 with a 10 base deletion
+// (t 150-160) and a 20 base insertion in the query. Chain 2 maps
+// chr1:300-340 to the - strand of 2, which is 1000 bases long.
+const testChains = `# test chains
+chain 5000 chr1 1000 + 100 200 1 2000 + 1000 1110 1
+50 10 20
+40
+
+chain 100 chr1 1000 + 300 340 2 1000 - 10 50 2
+40
+`
+
+func TestChains(t *testing.T) {
+	cs, err := NewFromReader(strings.NewReader(testChains))
+	if err != nil {
+		t.Fatalf("NewFromReader failed: %v", err)
+	}
+	if len(cs.Chains) != 2 || len(cs.Chains[0].Blocks) != 2 || cs.Chains[0].Id != "1" {
+		t.Fatalf("NewFromReader should give 2 chains with 2 and 1 blocks")
+	}
+
+	// Inside the first block
+	m := cs.Map("chr1", 110, 120)
+	if m == nil || m.Name() != "1" || m.Reverse() || len(m.Segments) != 1 ||
+		m.Segments[0].Start != 1010 || m.Segments[0].End != 1020 {
+		t.Fatalf("chr1:110-120 should map to 1:1010-1020 but maps to %+v", m)
+	}
+
+	// Across the gap
+	m = cs.Map("chr1", 140, 170)
+	if m == nil || m.Mapped != 20 || len(m.Segments) != 2 ||
+		m.Segments[0].Start != 1040 || m.Segments[0].End != 1050 ||
+		m.Segments[1].Start != 1070 || m.Segments[1].End != 1080 {
+		t.Fatalf("chr1:140-170 should map to 1:1040-1050 and 1:1070-1080 but maps to %+v", m)
+	}
+
+	// Reverse strand: 300-310 is query 10-20 on -, i.e. 980-990 on +
+	m = cs.Map("chr1", 300, 310)
+	if m == nil || m.Name() != "2" || !m.Reverse() ||
+		m.Segments[0].Start != 980 || m.Segments[0].End != 990 {
+		t.Fatalf("chr1:300-310 should map to 2:980-990 on - but maps to %+v", m)
+	}
+
+	for _, iv := range [][2]int{{150, 160}, {200, 300}, {0, 100}} {
+		if m = cs.Map("chr1", iv[0], iv[1]); m != nil {
+			t.Fatalf("chr1:%d-%d should not map but maps to %+v", iv[0], iv[1], m)
+		}
+	}
+	if m = cs.Map("chr2", 110, 120); m != nil {
+		t.Fatalf("chr2 should not map")
+	}
+
+	bad := []string{
+		"chain 1 chr1 1000 + 0 10 1 1000 + 0 10\n5\n",
+		"chain 1 chr1 1000 + 0 10 1 1000 + 0 10\n5 0 0\n",
+		"chain 1 chr1 1000 - 0 10 1 1000 + 0 10\n10\n",
+		"10\n",
+	}
+	for _, b := range bad {
+		if _, err = NewFromReader(strings.NewReader(b)); err == nil {
+			t.Fatalf("NewFromReader should fail for %q", b)
+		}
+	}
+}
@@ -0,0 +1,19 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// bedCmd is a submode which is a collection of sub-submodes
+var bedCmd = &cobra.Command{
+	Use:   "bed",
+	Short: "Operations on BED files",
+	Long: `
+Operations on BED files. Most of the region operations in ajgo work on
+GFF3 so the modes here are mostly about getting BED files in and out
+of GFF3. See also gff3 > to-bed.`,
+}
+
+func init() {
+	rootCmd.AddCommand(bedCmd)
+}
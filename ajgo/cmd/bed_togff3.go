@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"ajgo/bed"
+	"ajgo/coords"
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode bed > to-gff3
+var bedToGff3Cmd = &cobra.Command{
+	Use:   "to-gff3",
+	Short: "convert a BED to GFF3",
+	Long: `
+Read a BED file and write each record out as a GFF3 Feature with the
+chrom as SeqId, the --source and --type given, the BED score and strand
+as Score and Strand and the BED name, if any, as the Name Attribute.
+Any BED columns after strand are not carried over.
+
+BED coordinates are 0-based half-open and GFF3 coordinates are 1-based
+closed so a BED record 0-100 is written as 1-100. If the BED has a
+"#format" header that coordinate system is used instead of the BED
+spec. The GFF3 has a "##format 1-based closed" header so the convention
+is recorded in the file. Zero-length BED records (e.g. insertion
+points) cannot be represented in GFF3 so they are skipped with a
+warning.
+
+See also gff3 > to-bed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		bedToGff3CmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	bedCmd.AddCommand(bedToGff3Cmd)
+
+	bedToGff3Cmd.Flags().StringVar(&flagInfile, "bed", "",
+		"BED file to be converted")
+	bedToGff3Cmd.MarkFlagRequired("bed")
+
+	bedToGff3Cmd.Flags().StringVar(&flagOutfile, "out-gff3", "",
+		"output file in GFF3")
+	bedToGff3Cmd.MarkFlagRequired("out-gff3")
+
+	bedToGff3Cmd.Flags().StringVar(&flagSource, "source", "ajgo:bed",
+		"Source for the GFF3 Feature")
+	bedToGff3Cmd.Flags().StringVar(&flagType, "type", "region",
+		"Type for the GFF3 Feature")
+	addSeqOrderFlag(bedToGff3Cmd)
+}
+
+func bedToGff3CmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	headers := []string{
+		"##gff-version 3",
+		"##created-by ajgo mode: bed > to-gff3",
+		gff3.FormatHeader(coords.OneBasedClosed),
+	}
+	headers = append(headers, gffHeadersFromRunParameters()...)
+	headers = append(headers, seqOrderHeaders(order)...)
+	headers = append(headers, "##input-bed-file "+flagInfile)
+
+	log.Info("reading BED: ", flagInfile)
+	r, err := bed.NewReaderFromFile(flagInfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer r.Close()
+	log.Info("  coordinate system: ", r.CoordSystem)
+	recs, err := r.ReadAll()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of records: ", len(recs))
+
+	fs := gff3.NewFeatures()
+	var skipped int
+	for _, rec := range recs {
+		f, err := rec.Feature(flagSource, flagType)
+		if err != nil {
+			skipped++
+			log.Warnf("line %d: %v", rec.LineNumber, err)
+			continue
+		}
+		fs.Features = append(fs.Features, f)
+	}
+	if skipped > 0 {
+		log.Warnf("%d zero-length records were skipped", skipped)
+	}
+	if order != nil {
+		fs.SetSeqOrder(order)
+	}
+
+	log.Info("Number of features written: ", fs.Count())
+	if err = writeGff3Features(flagOutfile, headers, fs); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfile)
+}
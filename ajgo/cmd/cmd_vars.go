@@ -33,6 +33,54 @@ var (
 
 	flagSelectors []string
 
+	flagWithGff3Files []string
+	flagSeqOrder      string
+
+	flagDerive             []string
+	flagSpliceWidth        int
+	flagPromoterUpstream   int
+	flagPromoterDownstream int
+	flagIncludeInput       bool
+	flagCanonicalPolicy    []string
+
+	flagNameAttrs []string
+	flagSource    string
+	flagType      string
+
+	flagInfileGtf  string
+	flagOutfileGtf string
+
+	flagSorted        bool
+	flagMergeAdjacent bool
+	flagMaxGap        int
+	flagMinLength     int
+
+	flagGroupBy        []string
+	flagAggregateAttrs bool
+
+	flagOldGff3    string
+	flagNewGff3    string
+	flagSummaryTsv string
+
+	flagOutfileJson   string
+	flagAttributesTsv string
+
+	flagOutfileFasta string
+	flagUpstream     int
+	flagDownstream   int
+	flagSplice       string
+	flagHeader       string
+
+	flagCodonTable       int
+	flagSeqIdCodonTables []string
+
+	flagChainFile      string
+	flagUnmappedGff3   string
+	flagLiftoverPolicy string
+	flagMinMatch       float64
+
+	flagIndex bool
+
 	flagDeleteSeqPatterns []string
 	flagRegexps           []string
 )
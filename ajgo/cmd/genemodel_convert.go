@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"strings"
+
+	"ajgo/coords"
+	"ajgo/gff3"
+	"ajgo/gtf"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genemodel > convert
+var genemodelConvertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "convert a gene model between GTF and GFF3",
+	Long: `
+Convert a gene model from GTF to GFF3 or from GFF3 to GTF. The
+direction is set by which input is given: --in-gtf with --out-gff3 or
+--in-gff3 with --out-gtf.
+
+GTF to GFF3 creates the gene and transcript Feature that GFF3 needs
+but GTF files often lack (e.g. GENCODE) so the output can be used with
+the GFF3 gene model modes such as ensembl-gff3 > panel, select and
+gene-cds. IDs follow the Ensembl GFF3 conventions - gene:<gene_id>,
+transcript:<transcript_id> and CDS:<protein_id> - and transcripts
+with a protein_coding transcript_type or transcript_biotype have Type
+mRNA. The GENCODE UTR Type is resolved to five_prime_UTR or
+three_prime_UTR from the position of the CDS.
+
+GFF3 to GTF flattens the gene/transcript tree so every line carries
+gene_id and transcript_id. Feature that are not part of a gene, e.g.
+chromosome and biological_region in an Ensembl GFF3, cannot be
+represented in GTF and are skipped and counted by Type in the log.
+
+Both formats are 1-based closed so coordinates are not changed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genemodelConvertCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genemodelCmd.AddCommand(genemodelConvertCmd)
+
+	genemodelConvertCmd.Flags().StringVar(&flagInfileGtf, "in-gtf", "",
+		"gene model input - GTF format")
+	genemodelConvertCmd.Flags().StringVar(&flagInfileGeneModel, "in-gff3", "",
+		"gene model input - GFF3 format")
+	genemodelConvertCmd.Flags().StringVar(&flagOutfileGeneModel, "out-gff3", "",
+		"gene model output - GFF3 format (with --in-gtf)")
+	genemodelConvertCmd.Flags().StringVar(&flagOutfileGtf, "out-gtf", "",
+		"gene model output - GTF format (with --in-gff3)")
+	addSeqOrderFlag(genemodelConvertCmd)
+}
+
+func genemodelConvertCmdRun(cmd *cobra.Command, args []string) {
+	switch {
+	case flagInfileGtf != "" && flagInfileGeneModel == "":
+		if flagOutfileGeneModel == "" || flagOutfileGtf != "" {
+			log.Fatal("--in-gtf must be used with --out-gff3")
+		}
+		genemodelGtfToGff3()
+	case flagInfileGeneModel != "" && flagInfileGtf == "":
+		if flagOutfileGtf == "" || flagOutfileGeneModel != "" {
+			log.Fatal("--in-gff3 must be used with --out-gtf")
+		}
+		genemodelGff3ToGtf()
+	default:
+		log.Fatal("exactly one of --in-gtf and --in-gff3 must be specified")
+	}
+}
+
+func genemodelGtfToGff3() {
+	order := mustSeqOrderFromFlag()
+
+	log.Info("reading GTF: ", flagInfileGtf)
+	feats, err := gtf.ParseGtfModelFile(flagInfileGtf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of GTF Features: ", len(feats))
+
+	fs, err := gff3.FromGtf(feats)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tally := make(map[string]int)
+	for _, f := range fs.Features {
+		tally[f.Type]++
+	}
+	log.Infof("  Number of genes: %d, transcripts: %d, mRNAs: %d",
+		tally[`gene`], tally[`transcript`], tally[`mRNA`])
+	if order != nil {
+		fs.SetSeqOrder(order)
+	}
+
+	headers := []string{
+		"##gff-version 3",
+		"##created-by ajgo mode: genemodel > convert",
+		gff3.FormatHeader(coords.OneBasedClosed),
+	}
+	headers = append(headers, gffHeadersFromRunParameters()...)
+	headers = append(headers, seqOrderHeaders(order)...)
+	headers = append(headers, "##input-gtf-file "+flagInfileGtf)
+
+	log.Info("Number of features written: ", fs.Count())
+	if err = writeGff3Features(flagOutfileGeneModel, headers, fs); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfileGeneModel)
+}
+
+func genemodelGff3ToGtf() {
+	order := mustSeqOrderFromFlag()
+
+	log.Info("reading GFF3: ", flagInfileGeneModel)
+	gIn, err := gff3.NewFromFile(flagInfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of Features: ", gIn.FeatureCount())
+	if order != nil {
+		gIn.SetSeqOrder(order)
+	}
+
+	log.Info("creating Gff3Tree")
+	t := gIn.NewTree()
+	log.Info("  Number of Nodes: ", len(t.Nodes))
+
+	feats, skipped := t.Gtf()
+	if len(skipped) > 0 {
+		tally := make(map[string]int)
+		var types []string
+		for _, f := range skipped {
+			if tally[f.Type] == 0 {
+				types = append(types, f.Type)
+			}
+			tally[f.Type]++
+		}
+		log.Warnf("%d Features are not part of a gene and were skipped", len(skipped))
+		for _, typ := range types {
+			log.Infof("  %s: %d", typ, tally[typ])
+		}
+	}
+
+	// GTF has no directives so the GFF3-style headers become comments
+	var headers []string
+	for _, h := range append([]string{"##created-by ajgo mode: genemodel > convert"},
+		gffHeadersFromRunParameters()...) {
+		headers = append(headers, "#!"+strings.TrimPrefix(h, "##"))
+	}
+	for _, h := range seqOrderHeaders(order) {
+		headers = append(headers, "#!"+strings.TrimPrefix(h, "##"))
+	}
+	headers = append(headers, "#!input-gff3-file "+flagInfileGeneModel)
+
+	log.Info("Number of GTF features written: ", len(feats))
+	if err = gtf.WriteFeatures(flagOutfileGtf, headers, feats); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfileGtf)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genemodel > ensembl-gff3 > canonical
+var genemodelEnsemblGff3CanonicalCmd = &cobra.Command{
+	Use:   "canonical",
+	Short: "choose one transcript per gene",
+	Long: `Read a GFF3 gene model, choose a single representative transcript
+for each gene and write out a GFF3 that only contains each gene and
+its chosen transcript with all of the transcript's exons, CDS, UTRs
+etc.
+
+The transcript is chosen by a policy which is a list of criteria that
+are tried in order until one of them leaves a single transcript. A
+criterion that no transcript satisfies is skipped. The criteria are:
+
+  ensembl-canonical   tag Attribute includes Ensembl_canonical
+  mane-select         tag Attribute includes MANE_Select or MANE Select
+  longest-cds         greatest total length of CDS
+  longest-transcript  greatest total length of exons
+
+The default policy is all four criteria in the order above. If the
+transcripts are still tied, the one with the lowest ID is chosen. For
+example, to prefer MANE Select and then fall back to the longest
+transcript:
+
+  --policy mane-select,longest-transcript
+
+If --out-tsv is specified, a tab-separated file is also written with
+one line per gene showing the chosen transcript, the criterion that
+chose it (or only-transcript or first-id), the number of candidate
+transcripts and the CDS and transcript lengths.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genemodelEnsemblGff3CanonicalCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genemodelEnsemblGff3Cmd.AddCommand(genemodelEnsemblGff3CanonicalCmd)
+
+	genemodelEnsemblGff3CanonicalCmd.Flags().StringVar(&flagInfileGeneModel, "in-gff3", "",
+		"gene model input - GFF3 format")
+	genemodelEnsemblGff3CanonicalCmd.MarkFlagRequired("in-gff3")
+	genemodelEnsemblGff3CanonicalCmd.Flags().StringVar(&flagOutfileGeneModel, "out-gff3", "",
+		"gene model with one transcript per gene - GFF3 format")
+	genemodelEnsemblGff3CanonicalCmd.MarkFlagRequired("out-gff3")
+	genemodelEnsemblGff3CanonicalCmd.Flags().StringVar(&flagOutfile, "out-tsv", "",
+		"chosen transcript and reason per gene - TSV format")
+	genemodelEnsemblGff3CanonicalCmd.Flags().StringSliceVar(&flagCanonicalPolicy, "policy",
+		gff3.DefaultCanonicalPolicy(), "criteria for choosing a transcript, in order")
+	addSeqOrderFlag(genemodelEnsemblGff3CanonicalCmd)
+}
+
+func genemodelEnsemblGff3CanonicalCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	// Read source GFF3
+	log.Info("reading GFF3: ", flagInfileGeneModel)
+	gIn, err := gff3.NewFromFile(flagInfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of Features: ", gIn.FeatureCount())
+
+	// Create tree
+	log.Info("creating Gff3Tree")
+	t := gIn.NewTree()
+	log.Info("  Number of Nodes: ", len(t.Nodes))
+	for _, p := range t.Validate() {
+		log.Warn(p)
+	}
+
+	log.Info("choosing transcripts with policy: ", strings.Join(flagCanonicalPolicy, ","))
+	choices, err := t.Canonical(flagCanonicalPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tally := make(map[string]int)
+	for _, c := range choices {
+		tally[c.Reason]++
+	}
+	log.Info("  Number of genes: ", len(choices))
+	reasons := append([]string{gff3.CanonicalOnlyTranscript}, flagCanonicalPolicy...)
+	for _, r := range append(reasons, gff3.CanonicalFirstId) {
+		log.Infof("  chosen by %s: %d", r, tally[r])
+	}
+
+	gOut := gff3.NewGff3()
+	gOut.Header = gIn.Header
+	gOut.Features = gff3.CanonicalFeatures(choices)
+	log.Info("  Number of Features: ", gOut.FeatureCount())
+
+	if order != nil {
+		gOut.SetSeqOrder(order)
+		gOut.Header = append(gOut.Header, seqOrderHeaders(order)...)
+	}
+
+	err = gOut.Write(flagOutfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfileGeneModel)
+
+	if flagOutfile != "" {
+		if err = writeCanonicalTsv(flagOutfile, choices); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("writing complete: %s", flagOutfile)
+	}
+}
+
+// writeCanonicalTsv writes the chosen transcript for each gene as TSV.
+func writeCanonicalTsv(file string, choices []*gff3.CanonicalChoice) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	_, err = w.WriteString(gff3.CanonicalTsvHeader() + "\n")
+	if err != nil {
+		return err
+	}
+	for _, c := range choices {
+		_, err = w.WriteString(c.TsvString() + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genemodel > ensembl-gff3 > derive
+var genemodelEnsemblGff3DeriveCmd = &cobra.Command{
+	Use:   "derive",
+	Short: "derive introns, UTRs, splice sites and promoters",
+	Long: `Read a GFF3 gene model and write out the features that are
+implied by each transcript but are not listed in the gene model:
+
+  Derivation    Type(s)
+  introns       intron
+  utrs          five_prime_UTR, three_prime_UTR
+  splice-sites  five_prime_cis_splice_site (donor),
+                three_prime_cis_splice_site (acceptor)
+  promoters     promoter
+
+A transcript is any Feature that has exon children. Each derived
+Feature has a Parent Attribute with the ID of its transcript. By
+default only the derived Feature are written - use --include-input to
+also write the input Feature so the Parent links resolve.
+
+UTRs are derived from the exons and CDS. Each end is handled on its
+own, so a transcript with explicit five_prime_UTR children but no
+three_prime_UTR children only gets a derived three_prime_UTR.
+
+Splice site windows extend --splice-width bases into both the exon and
+the intron at every exon/intron boundary so the default of 2 covers
+the canonical GT and AG dinucleotides plus 2 exonic bases.
+
+Promoter windows are strand-aware and include the transcription start
+site, --promoter-upstream bases upstream of it and
+--promoter-downstream-1 bases downstream of it. Windows are clipped at
+the start of the sequence. Splice sites and promoters are not derived
+for transcripts with a Strand of . or ?.
+
+Use --derive to choose which features to derive, e.g.
+--derive introns,promoters.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genemodelEnsemblGff3DeriveCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genemodelEnsemblGff3Cmd.AddCommand(genemodelEnsemblGff3DeriveCmd)
+
+	o := gff3.NewDeriveOptions()
+
+	genemodelEnsemblGff3DeriveCmd.Flags().StringVar(&flagInfileGeneModel, "in-gff3", "",
+		"gene model input - GFF3 format")
+	genemodelEnsemblGff3DeriveCmd.MarkFlagRequired("in-gff3")
+	genemodelEnsemblGff3DeriveCmd.Flags().StringVar(&flagOutfileGeneModel, "out-gff3", "",
+		"derived features - GFF3 format")
+	genemodelEnsemblGff3DeriveCmd.MarkFlagRequired("out-gff3")
+
+	genemodelEnsemblGff3DeriveCmd.Flags().StringSliceVar(&flagDerive, "derive", o.Derive,
+		"features to derive - any of "+gff3.DeriveNames())
+	genemodelEnsemblGff3DeriveCmd.Flags().IntVar(&flagSpliceWidth, "splice-width", o.SpliceWidth,
+		"bases into exon and intron for splice site windows")
+	genemodelEnsemblGff3DeriveCmd.Flags().IntVar(&flagPromoterUpstream, "promoter-upstream", o.PromoterUpstream,
+		"promoter window bases upstream of the transcription start site")
+	genemodelEnsemblGff3DeriveCmd.Flags().IntVar(&flagPromoterDownstream, "promoter-downstream", o.PromoterDownstream,
+		"promoter window bases downstream of, and including, the transcription start site")
+	genemodelEnsemblGff3DeriveCmd.Flags().BoolVar(&flagIncludeInput, "include-input", false,
+		"also write the input features")
+	addSeqOrderFlag(genemodelEnsemblGff3DeriveCmd)
+}
+
+func genemodelEnsemblGff3DeriveCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	o := &gff3.DeriveOptions{
+		Derive:             flagDerive,
+		SpliceWidth:        flagSpliceWidth,
+		PromoterUpstream:   flagPromoterUpstream,
+		PromoterDownstream: flagPromoterDownstream,
+	}
+
+	// Read source GFF3
+	log.Info("reading GFF3: ", flagInfileGeneModel)
+	gIn, err := gff3.NewFromFile(flagInfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of Features: ", gIn.FeatureCount())
+
+	// Create tree
+	log.Info("creating Gff3Tree")
+	t := gIn.NewTree()
+	log.Info("  Number of Nodes: ", len(t.Nodes))
+	for _, p := range t.Validate() {
+		log.Warn(p)
+	}
+
+	log.Info("deriving features: ", flagDerive)
+	derived, err := t.Derive(o)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of derived Features: ", derived.Count())
+
+	// Derived Feature are already sorted but the input Feature, if
+	// included, are not.
+	if flagIncludeInput {
+		derived.AddFeatures(gIn.Features.Features...)
+		derived.SeqOrder = order
+		derived.Sort()
+	} else if order != nil {
+		derived.SetSeqOrder(order)
+	}
+
+	gOut := gff3.NewGff3()
+	gOut.Header = gIn.Header
+	gOut.Features = derived
+	if order != nil {
+		gOut.Header = append(gOut.Header, seqOrderHeaders(order)...)
+	}
+	log.Info("  Number of Features: ", gOut.FeatureCount())
+
+	err = gOut.Write(flagOutfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfileGeneModel)
+}
@@ -3,7 +3,11 @@
 package cmd
 
 import (
+	"bufio"
+	"os"
+
 	"ajgo/gff3"
+
 	"github.com/grendeloz/cmdh"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -13,10 +17,26 @@ import (
 var genemodelEnsemblGff3GeneCdsCmd = &cobra.Command{
 	Use:   "gene-cds",
 	Short: "select and consolidate CDS",
-	Long: `Read a GFF3 file, group and only keep those groupings that
-have at least one element with a Type of cds.  Extract the CDS elements
-and consolidate within the groupings.cdsand only keep features related to a list of
-gene names or ids read from a file. Write out the derived GFF3 file.`,
+	Long: `Read a GFF3 file, group Features by gene and only keep those
+genes that have at least one Feature with a Type of CDS. The CDS from
+all of the transcripts of each gene are consolidated into a set of
+non-overlapping blocks and one Feature is written per block, with a
+Type of CDS_region and gene_id and Name Attributes from the gene.
+
+CDS are treated as GFF3 closed intervals so CDS that share any
+position are merged into a single block but CDS that are immediately
+adjacent are not. Blocks from different genes are never merged, even
+if they overlap, so use gff3 > merge on the output if you need a mask
+with no overlaps at all.
+
+Genes are found by following the Parent links from CDS up through the
+transcripts and work for Ensembl (ID=gene:...), RefSeq (ID=gene-...)
+and GENCODE (ID=ENSG...) gene models.
+
+If --out-tsv is specified, a tab-separated file is also written with
+one line per gene showing the coding footprint of the gene: the
+number of transcripts with CDS, the number of CDS, the number of
+blocks and the number of coding bases.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmdh.StartLogging()
 		genemodelEnsemblGff3GeneCdsCmdRun(cmd, args)
@@ -33,9 +53,14 @@ func init() {
 	genemodelEnsemblGff3GeneCdsCmd.Flags().StringVar(&flagOutfileGeneModel, "out-gff3", "",
 		"gene model after consolidation - GFF3 format")
 	genemodelEnsemblGff3GeneCdsCmd.MarkFlagRequired("out-gff3")
+	genemodelEnsemblGff3GeneCdsCmd.Flags().StringVar(&flagOutfile, "out-tsv", "",
+		"coding footprint per gene - TSV format")
+	addSeqOrderFlag(genemodelEnsemblGff3GeneCdsCmd)
 }
 
 func genemodelEnsemblGff3GeneCdsCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
 	// Read source GFF3
 	log.Info("reading GFF3: ", flagInfileGeneModel)
 	gIn, err := gff3.NewFromFile(flagInfileGeneModel)
@@ -44,24 +69,59 @@ func genemodelEnsemblGff3GeneCdsCmdRun(cmd *cobra.Command, args []string) {
 	}
 	log.Info("  Number of Features: ", gIn.FeatureCount())
 
-	// Clone the input Gff3 - we could do this in place but this is
-	// cleaner and leaves gIn unchanged in case we want to use it.
 	log.Info("consolidating CDS by gene")
-	gOut := gIn.Clone()
-
-	// Create tree
-	log.Info("creating Gff3Tree")
-	t := gOut.NewTree()
-	log.Info("  Number of Nodes: ", len(t.Nodes))
-	log.Info("  Number of Orphans: ", len(t.Orphans))
+	if order != nil {
+		gIn.SetSeqOrder(order)
+	}
+	genes := gIn.Features.ConsolidateCdsByGene()
+	log.Info("  Number of genes with CDS: ", len(genes))
 
-	// TO DO - the consolidation!!!
+	gOut := gff3.NewGff3()
+	gOut.Header = gIn.Header
+	gOut.Features = gff3.CdsFeatures(genes)
+	gOut.Features.SeqOrder = gIn.Features.SeqOrder
+	gOut.Features.Sort()
 	log.Info("  Number of Features: ", gOut.FeatureCount())
 
+	if order != nil {
+		gOut.Header = append(gOut.Header, seqOrderHeaders(order)...)
+	}
+
 	// Write out the new post-selection Gff3
 	err = gOut.Write(flagOutfileGeneModel)
 	if err != nil {
 		log.Fatal(err)
 	}
 	log.Infof("writing complete: %s", flagOutfileGeneModel)
+
+	if flagOutfile != "" {
+		if err = writeGeneCdsTsv(flagOutfile, genes); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("writing complete: %s", flagOutfile)
+	}
+}
+
+// writeGeneCdsTsv writes the coding footprint of each gene as TSV.
+func writeGeneCdsTsv(file string, genes []*gff3.GeneCds) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	_, err = w.WriteString(gff3.GeneCdsTsvHeader() + "\n")
+	if err != nil {
+		return err
+	}
+	for _, g := range genes {
+		_, err = w.WriteString(g.TsvString() + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -35,9 +35,12 @@ func init() {
 	genemodelEnsemblGff3PanelCmd.Flags().StringVar(&flagInfile, "genes", "",
 		"plain text file of gene names or ENSG IDs, - one per line")
 	genemodelEnsemblGff3PanelCmd.MarkFlagRequired("genes")
+	addSeqOrderFlag(genemodelEnsemblGff3PanelCmd)
 }
 
 func genemodelEnsemblGff3PanelCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
 	// Get genes and build lookups
 	log.Info("reading genes from: ", flagInfile)
 	rex := regexp.MustCompile(`^ENSG`)
@@ -46,14 +49,14 @@ func genemodelEnsemblGff3PanelCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 	geneNames := make(map[string]int)
-	geneIds := make(map[string]int)
+	geneIds := make(map[string]string)
 	for _, gene := range genes {
 		if rex.Match([]byte(gene)) {
 			// Make key look like an Ensembl ID - gene:ENSG....
-			geneIds["gene:"+gene]++
-			if geneIds[gene] > 1 {
+			if _, ok := geneIds["gene:"+gene]; ok {
 				log.Fatalf("gene ID %s was specified more than once", gene)
 			}
+			geneIds["gene:"+gene] = gene
 		} else {
 			geneNames[gene]++
 			if geneNames[gene] > 1 {
@@ -76,30 +79,30 @@ func genemodelEnsemblGff3PanelCmdRun(cmd *cobra.Command, args []string) {
 	t := gIn.NewTree()
 	log.Info("  Number of Nodes: ", len(t.Nodes))
 	log.Info("  Number of Orphans: ", len(t.Orphans))
+	for _, p := range t.Validate() {
+		log.Warn(p)
+	}
 
-	// For each node, try to match firstly by IdString (ENSG...) and
-	// secondly by Name= Attribute.
+	// Walk the tree and for each node, try to match firstly by IdString
+	// (gene:ENSG...) and secondly by Name= Attribute. The Features of a
+	// matching node include all of its descendants so there is no need
+	// to go any deeper.
 	log.Info("filtering by gene")
 	nfs := gff3.NewFeatures()
 	found := make(map[string]int)
-	for _, n := range t.Nodes {
-		// Try to match IdString as an ENSG
-		if _, ok := geneIds[n.IdString]; ok {
-			found[n.IdString]++
-			feats := n.Features()
-			nfs.Features = append(nfs.Features, feats...)
-		} else {
-			// See if the first Self Feature has a Name= attribute and
-			// if so, try to match on gene Name
-			if _, ok := n.Self[0].Attributes[`Name`]; ok {
-				if _, ok := geneNames[n.Self[0].Attributes[`Name`]]; ok {
-					found[n.Self[0].Attributes[`Name`]]++
-					feats := n.Features()
-					nfs.Features = append(nfs.Features, feats...)
-				}
+	t.Walk(func(n *gff3.TreeNode, depth int) bool {
+		gene, ok := geneIds[n.IdString]
+		if !ok {
+			name, hasName := n.Self[0].Attributes[`Name`]
+			if _, ok = geneNames[name]; !hasName || !ok {
+				return true
 			}
+			gene = name
 		}
-	}
+		found[gene]++
+		nfs.Features = append(nfs.Features, n.Features()...)
+		return false
+	})
 
 	gOut := gff3.NewGff3()
 	gOut.Features = nfs
@@ -112,6 +115,11 @@ func genemodelEnsemblGff3PanelCmdRun(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	if order != nil {
+		gOut.SetSeqOrder(order)
+		gOut.Header = append(gOut.Header, seqOrderHeaders(order)...)
+	}
+
 	// Write out the new post-selection Gff3
 	err = gOut.Write(flagOutfileGeneModel)
 	if err != nil {
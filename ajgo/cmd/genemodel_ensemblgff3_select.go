@@ -1,8 +1,9 @@
 package cmd
 
 import (
+	"io"
+
 	"ajgo/gff3"
-	"ajgo/selector"
 
 	"github.com/grendeloz/cmdh"
 	log "github.com/sirupsen/logrus"
@@ -14,14 +15,39 @@ var genemodelEnsemblGff3SelectCmd = &cobra.Command{
 	Use:   "select",
 	Short: "select features from gene model",
 	Long: `Read a GFF3 file, apply one or more selectors and write out
-the derived GFF3 file out. Currently supported selector subjects and
-operations are:
+the derived GFF3 file out. Currently supported selector subjects are:
+
+  Subject     Matches against
+  seqid       column 1 - SeqId
+  source      column 2 - Source
+  type        column 3 - Type
+  score       column 6 - Score
+  strand      column 7 - Strand
+  phase       column 8 - Phase
+  length      End-Start+1
+  attr.<key>  value(s) of the Attribute <key>
+
+For example, to keep only protein coding genes and drop UTRs:
+
+  --select keep:attr.biotype:^protein_coding$ --select delete:type:_UTR$
+
+or as a single expression:
+
+  --select 'attr.biotype == protein_coding and not type ~ _UTR$'
 
-  Subject    Operation(s)
-  seqid      keep, delete
+Numeric comparisons work against score and length, and against any
+Attribute with a numeric value, e.g. --select 'length >= 100'. A
+Feature with a Score of "." fails every numeric test on score.
 
-Subject seqid matches against the seqid string which is the first column
-of each GFF3 record. 
+An Attribute with multiple values (e.g. tag=basic,CCDS) passes a test
+if any one of the values passes. A record that does not have the
+Attribute at all never matches so it is dropped by keep and retained by
+delete.
+
+Comment lines between records are written out where they were found,
+even if the records around them are dropped. With --seq-order the
+records are reordered so these comments are written after the headers
+instead.
 
 For a general description of selectors, see
 
@@ -45,36 +71,94 @@ func init() {
 	genemodelEnsemblGff3SelectCmd.MarkFlagRequired("out-gff3")
 
 	genemodelEnsemblGff3SelectCmd.Flags().StringArrayVar(&flagSelectors, "select", []string{},
-		"selector statements (operation:subject:pattern) for filtering features")
+		"selector expression or operation:subject:pattern for filtering features")
 	genemodelEnsemblGff3SelectCmd.MarkFlagRequired("select")
+	addSeqOrderFlag(genemodelEnsemblGff3SelectCmd)
 }
 
 func genemodelEnsemblGff3SelectCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	// Get our selectors ready-to-use
+	fsels, err := gff3.NewFeatureSelectorsFromStrings(flagSelectors)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, fsel := range fsels {
+		log.Infof("using selector: %s", fsel)
+	}
+
+	// Features are streamed from input to output so the whole gene
+	// model never needs to be in memory - unless we have to reorder
+	// the SeqIds in which case the kept Feature are held until the end.
 	log.Info("reading GFF3: ", flagInfile)
-	g, err := gff3.NewFromFile(flagInfile)
+	r, err := gff3.NewReaderFromFile(flagInfile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Info("Number of Features: ", g.FeatureCount())
+	defer r.Close()
 
-	// Apply selectors seriatim
-	log.Info("applying selectors")
-	selectors, err := selector.NewFromStrings(flagSelectors)
+	w, err := gff3.NewWriterToFile(flagOutfile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	for _, sel := range selectors {
-		log.Infof("applying selector: %s - %s - %s",
-			sel.Operation, sel.Subject, sel.Pattern)
-		g.ApplySelector(sel)
+	w.AddHeaders(r.Header...)
+	w.AddHeaders(seqOrderHeaders(order)...)
+	kept := gff3.NewFeatures()
+
+	// Comment lines between Feature are appended to r.Header as they
+	// are read so any past the ones already passed on to w are new.
+	passed := len(r.Header)
+	passComments := func() error {
+		comments := r.Header[passed:]
+		passed = len(r.Header)
+		if order != nil {
+			return w.AddHeaders(comments...)
+		}
+		return w.WriteComments(comments...)
 	}
-	log.Info("Number of Features: ", g.FeatureCount())
 
-	// Write out the new post-selection Gff3
-	err = g.Write(flagOutfile)
-	if err != nil {
+	// Apply selectors seriatim to each Feature
+	log.Info("applying selectors")
+	var read int
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = passComments(); err != nil {
+			log.Fatal(err)
+		}
+		read++
+		if !gff3.KeepAll(fsels, f) {
+			continue
+		}
+		if order != nil {
+			kept.Features = append(kept.Features, f)
+		} else if err = w.Write(f); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if read == 0 {
+		log.Fatal(gff3.ErrNoGff3Records)
+	}
+	if err = passComments(); err != nil {
 		log.Fatal(err)
 	}
-	log.Infof("writing complete: %s", flagOutfile)
+	if order != nil {
+		kept.SetSeqOrder(order)
+		if err = w.WriteFeatures(kept); err != nil {
+			log.Fatal(err)
+		}
+	}
+	log.Info("Number of Features read: ", read)
+	log.Info("Number of Features written: ", w.Count())
 
+	if err = w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfile)
 }
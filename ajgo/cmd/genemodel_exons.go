@@ -15,7 +15,17 @@ var genemodelExonsCmd = &cobra.Command{
 	Long: `Prune and consolidate exon features from gene model. Sequences
 that are not chromosomes or GLxxxxx are removed, duplicate exons (from
 multiple transcripts are removed), and where exons overlap they are
-consolidated into a single feature that spans the overlapping exons.`,
+consolidated into a single feature that spans the overlapping exons.
+
+By default exons are consolidated regardless of strand or gene so
+overlapping exons from genes on opposite strands become one feature.
+--group-by only consolidates exons that share values, e.g. --group-by
+strand or --group-by attr.gene_id. Any selector subject can be used, the
+same as for gff3 > merge: seqid, source, type (the GTF feature column),
+score, strand, phase (the GTF frame column), length and attr.<key> for
+any attribute, and more than one can be given. Attributes that differ between consolidated exons are
+normally deleted but with --aggregate-attributes they keep all of their
+values, e.g. gene_name "BRCA1,NBR2".`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmdh.StartLogging()
 		genemodelExonsCmdRun(cmd, args)
@@ -38,6 +48,10 @@ func init() {
 	genemodelExonsCmd.Flags().StringArrayVar(&flagDeleteSeqPatterns,
 		"delete-sequence-pattern", []string{},
 		"regular expression(s) of sequences to be deleted from gene model")
+	genemodelExonsCmd.Flags().StringSliceVar(&flagGroupBy, "group-by", []string{},
+		"only consolidate exons with the same values for these subjects, e.g. strand,attr.gene_id")
+	genemodelExonsCmd.Flags().BoolVar(&flagAggregateAttrs, "aggregate-attributes", false,
+		"keep attributes that differ between consolidated exons, combining their values")
 }
 
 func genemodelExonsCmdRun(cmd *cobra.Command, args []string) {
@@ -99,10 +113,14 @@ func genemodelExonsCmdRun(cmd *cobra.Command, args []string) {
 	}
 	log.Info("Number of features: ", g.FeatureCount())
 
+	opts := &gtf.ConsolidateOptions{GroupBy: flagGroupBy, Aggregate: flagAggregateAttrs}
 	log.Info("consolidating features:")
+	if len(opts.GroupBy) > 0 {
+		log.Info("  grouped by: ", opts.GroupBy)
+	}
 	for _, name := range names {
 		fs := g.SeqFeats[name]
-		i, err := fs.Consolidate()
+		i, err := fs.Consolidate(opts)
 		if err != nil {
 			log.Fatalf("error consolidating SeqFeat %s: %v", name, err)
 		}
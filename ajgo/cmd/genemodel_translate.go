@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"strconv"
+	"strings"
+
+	"ajgo/gff3"
+	"ajgo/seq"
+
+	"github.com/grendeloz/cmdh"
+	"github.com/grendeloz/ngs/genome"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genemodel > translate
+var genemodelTranslateCmd = &cobra.Command{
+	Use:   "translate",
+	Short: "translate and QC the CDS of every transcript",
+	Long: `Translate the spliced CDS of every transcript in a GFF3 gene
+model using the sequences from an ajgo serialised genome, and check
+each CDS for the signs that the gene model and the genome build do not
+match. The problems reported are:
+
+  no_start           the CDS does not start with an initiation codon,
+                     including when the 5' CDS has a Phase of 1 or 2
+  no_stop            the CDS does not end with a stop codon
+  internal_stop      there is a stop codon before the end of the CDS
+  not_multiple_of_3  the CDS length after the 5' Phase is not a
+                     multiple of 3
+  phase_mismatch     the Phase of a CDS does not follow on from the
+                     CDS before it
+
+Translation starts after the Phase of the 5' CDS. stop_codon features
+are added to the CDS if they are not already inside it, as for GENCODE
+gene models where the CDS does not include the stop codon.
+
+--codon-table is the NCBI translation table number used for all
+sequences except those named in --seqid-codon-table, which by default
+uses the vertebrate mitochondrial code (2) for chrM and MT. Supported
+tables are 1-6, 9-14, 16 and 21-23.
+
+--out-tsv has one line per transcript with the problems found and a
+summary is written to the log. --out-fasta writes the proteins with
+headers made by --header (see 'ajgo genome extract --help'). The
+terminal stop codon is left off each protein but internal stops are
+written as *.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genemodelTranslateCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genemodelCmd.AddCommand(genemodelTranslateCmd)
+
+	genemodelTranslateCmd.Flags().StringVar(&flagInfileGenome, "in-genome", "",
+		"ajgo serialised genome")
+	genemodelTranslateCmd.MarkFlagRequired("in-genome")
+	genemodelTranslateCmd.Flags().StringVar(&flagInfileGeneModel, "in-gff3", "",
+		"gene model - GFF3 format")
+	genemodelTranslateCmd.MarkFlagRequired("in-gff3")
+	genemodelTranslateCmd.Flags().StringVar(&flagOutfile, "out-tsv", "",
+		"CDS checks, one line per transcript - TSV format")
+	genemodelTranslateCmd.MarkFlagRequired("out-tsv")
+	genemodelTranslateCmd.Flags().StringVar(&flagOutfileFasta, "out-fasta", "",
+		"proteins - FASTA format, gzipped if the name ends in .gz")
+
+	genemodelTranslateCmd.Flags().IntVar(&flagCodonTable, "codon-table", 1,
+		"NCBI translation table number")
+	genemodelTranslateCmd.Flags().StringSliceVar(&flagSeqIdCodonTables, "seqid-codon-table",
+		[]string{"chrM=2", "MT=2"},
+		"translation table for a sequence as seqid=number")
+	genemodelTranslateCmd.Flags().StringVar(&flagHeader, "header", gff3.DefaultHeaderTemplate,
+		"template for FASTA headers")
+}
+
+func genemodelTranslateCmdRun(cmd *cobra.Command, args []string) {
+	table, err := seq.NewCodonTable(flagCodonTable)
+	if err != nil {
+		log.Fatal(err)
+	}
+	h, err := gff3.ParseHeaderTemplate(flagHeader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Info("reading serialised genome: ", flagInfileGenome)
+	g, err := genome.GenomeFromGob(flagInfileGenome)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tr := gff3.NewTranslator(g, table)
+	tr.Header = h
+	log.Infof("codon table: %d %s", table.Id, table.Name)
+	for _, s := range flagSeqIdCodonTables {
+		seqid, id, ok := strings.Cut(s, "=")
+		if !ok {
+			log.Fatalf("--seqid-codon-table must be seqid=number: %s", s)
+		}
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			log.Fatalf("--seqid-codon-table must be seqid=number: %s", s)
+		}
+		if tr.SeqIdTables[seqid], err = seq.NewCodonTable(n); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("  %s: %d %s", seqid, n, tr.SeqIdTables[seqid].Name)
+	}
+
+	log.Info("reading GFF3: ", flagInfileGeneModel)
+	fs, _, err := readGff3FeaturesClosed(flagInfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of Features: ", fs.Count())
+
+	trs, err := tr.Translate(fs.NewTree())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Tally the problems in the order they are described in the help
+	counts := make(map[string]int)
+	var bad int
+	lines := []string{gff3.TranslationTsvHeader()}
+	for _, x := range trs {
+		ps := x.Problems()
+		if len(ps) > 0 {
+			bad++
+		}
+		for _, p := range ps {
+			counts[p]++
+		}
+		lines = append(lines, x.TsvString())
+	}
+	log.Info("Transcripts with CDS: ", len(trs))
+	log.Info("  with problems: ", bad)
+	for _, p := range []string{gff3.TranslationNoStart, gff3.TranslationNoStop,
+		gff3.TranslationInternalStop, gff3.TranslationNotMultiple,
+		gff3.TranslationPhaseMismatch} {
+		log.Infof("  %s: %d", p, counts[p])
+	}
+
+	if err = writeLines(flagOutfile, lines); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfile)
+
+	if flagOutfileFasta != "" {
+		w, err := seq.NewWriterToFile(flagOutfileFasta)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, x := range trs {
+			if err = w.Write(x.Protein); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err = w.Close(); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("writing complete: %s", flagOutfileFasta)
+	}
+}
@@ -13,7 +13,11 @@ var createGenomeCmd = &cobra.Command{
 	Short: "create binary genome from FASTA",
 	Long: `Read genome as FASTA file(s) and serialise as an ajgo genome in
 go encoding/gob binary format. This binary format is required by most other
-ajgo modes that use a genome.`,
+ajgo modes that use a genome.
+
+With --index, a samtools FASTA index is also written alongside each
+FASTA file (see genome > faidx) and a SAM sequence dictionary is written
+alongside the genome as <out-genome>.dict (see genome > dict).`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmdh.StartLogging()
 		createGenomeCmdRun(cmd, args)
@@ -35,6 +39,9 @@ func init() {
 	createGenomeCmd.Flags().StringVar(&flagName, "name", "",
 		"name to be embedded in serialised genome")
 	createGenomeCmd.MarkFlagRequired("name")
+
+	createGenomeCmd.Flags().BoolVar(&flagIndex, "index", false,
+		"also write a .fai for each FASTA and a .dict for the genome")
 }
 
 func createGenomeCmdRun(cmd *cobra.Command, args []string) {
@@ -72,4 +79,17 @@ func createGenomeCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 	log.Info("writing completed: ", file)
+
+	if flagIndex {
+		for _, fasta := range flagFastaFiles {
+			log.Info("indexing FASTA file: ", fasta)
+			if _, err = writeFai(fasta); err != nil {
+				log.Fatal(err)
+			}
+		}
+		log.Info("writing sequence dictionary")
+		if err = writeDict(flagOutfileGenome+".dict", gn, true); err != nil {
+			log.Fatal(err)
+		}
+	}
 }
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"ajgo/seq"
+
+	"github.com/grendeloz/cmdh"
+	"github.com/grendeloz/ngs/genome"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genome > dict
+var genomeDictCmd = &cobra.Command{
+	Use:   "dict",
+	Short: "write a SAM sequence dictionary (.dict)",
+	Long: `Write a SAM-style sequence dictionary for an ajgo serialised
+genome, as made by Picard CreateSequenceDictionary or samtools dict.
+There is an @SQ line for each sequence with its name (SN), length (LN)
+and the MD5 of the uppercased sequence (M5). If the genome was created
+from a FASTA file with an absolute path, the file is given as the URI
+(UR).
+
+The M5 tags are the same as those in the @SQ lines of a BAM header so
+comparing them shows whether a BAM was aligned against the same
+sequences as the genome, even if the sequences are in a different
+order or the FASTA files are formatted differently.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genomeDictCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genomeCmd.AddCommand(genomeDictCmd)
+
+	genomeDictCmd.Flags().StringVar(&flagInfileGenome, "in-genome", "",
+		"ajgo serialised genome")
+	genomeDictCmd.MarkFlagRequired("in-genome")
+	genomeDictCmd.Flags().StringVar(&flagOutfile, "out-dict", "",
+		"sequence dictionary")
+	genomeDictCmd.MarkFlagRequired("out-dict")
+}
+
+func genomeDictCmdRun(cmd *cobra.Command, args []string) {
+	log.Info("reading serialised genome: ", flagInfileGenome)
+	g, err := genome.GenomeFromGob(flagInfileGenome)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = writeDict(flagOutfile, g, false); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// writeDict writes a sequence dictionary for a genome. FASTA file paths
+// are only used as URIs if they are absolute, unless makeAbs is true,
+// which is only safe if the paths are relative to the current
+// directory, e.g. when the genome has just been created.
+func writeDict(file string, g *genome.Genome, makeAbs bool) error {
+	lines := []string{seq.DictHeader}
+	for _, s := range g.Sequences {
+		var fasta string
+		if s.FastaFile != nil && (makeAbs || filepath.IsAbs(s.FastaFile.Filepath)) {
+			fasta = s.FastaFile.Filepath
+		}
+		r, err := seq.NewDictRecord(s.Name, s.Sequence, fasta)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, r.String())
+	}
+	if err := writeLines(file, lines); err != nil {
+		return err
+	}
+	log.Info("  Number of sequences: ", len(g.Sequences))
+	log.Infof("writing complete: %s", file)
+	return nil
+}
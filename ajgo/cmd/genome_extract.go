@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"ajgo/gff3"
+	"ajgo/seq"
+
+	"github.com/grendeloz/cmdh"
+	"github.com/grendeloz/ngs/genome"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genome > extract
+var genomeExtractCmd = &cobra.Command{
+	Use:   "extract",
+	Short: "write FASTA for the features in a GFF3",
+	Long: `Write the sequence of every feature in a GFF3 as FASTA, taking
+the sequences from an ajgo serialised genome. Sequences of features on
+the - strand are reverse complemented so every sequence reads 5' to 3'.
+
+--upstream and --downstream add flanking bases in the direction of the
+feature, so for a feature on the - strand, the upstream bases come
+from after its end. Flanks are clipped at the ends of the sequence.
+
+--splice writes one sequence per transcript (or any other feature with
+an ID) by joining its child features of the given type, usually exon
+or CDS, in order. Parents and children are found the same way as in
+the genemodel modes. Flanks are added to the spliced sequence, not to
+each exon.
+
+--header is a template for the FASTA headers. Fields in braces are
+replaced by values from the feature (the transcript for --splice):
+
+  {id}          ID attribute, or Name, or seqid:start-end if neither
+  {start}       start of the feature
+  {end}         end of the feature
+  {location}    extracted region as seqid:start-end(strand) including
+                any flanks
+  {seqid} {source} {type} {score} {strand} {phase} {length}
+                the feature fields
+  {attr.<key>}  value of an attribute, e.g. {attr.gene_name}
+
+For example:
+
+  --header '{attr.gene_name}|{attr.transcript_id} {location}'
+
+Every SeqId in the GFF3 must be a sequence name in the genome.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genomeExtractCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genomeCmd.AddCommand(genomeExtractCmd)
+
+	genomeExtractCmd.Flags().StringVar(&flagInfileGenome, "in-genome", "",
+		"ajgo serialised genome")
+	genomeExtractCmd.MarkFlagRequired("in-genome")
+	genomeExtractCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file of features to extract")
+	genomeExtractCmd.MarkFlagRequired("gff3")
+	genomeExtractCmd.Flags().StringVar(&flagOutfileFasta, "out-fasta", "",
+		"FASTA output file, gzipped if the name ends in .gz")
+	genomeExtractCmd.MarkFlagRequired("out-fasta")
+
+	genomeExtractCmd.Flags().IntVar(&flagUpstream, "upstream", 0,
+		"bases to add before each feature")
+	genomeExtractCmd.Flags().IntVar(&flagDownstream, "downstream", 0,
+		"bases to add after each feature")
+	genomeExtractCmd.Flags().StringVar(&flagSplice, "splice", "",
+		"join child features of this type, e.g. exon or CDS, per transcript")
+	genomeExtractCmd.Flags().StringVar(&flagHeader, "header", gff3.DefaultHeaderTemplate,
+		"template for FASTA headers")
+}
+
+func genomeExtractCmdRun(cmd *cobra.Command, args []string) {
+	if flagUpstream < 0 || flagDownstream < 0 {
+		log.Fatal("--upstream and --downstream cannot be negative")
+	}
+	h, err := gff3.ParseHeaderTemplate(flagHeader)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Info("reading serialised genome: ", flagInfileGenome)
+	g, err := genome.GenomeFromGob(flagInfileGenome)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Info("reading: ", flagInfile)
+	fs, _, err := readGff3FeaturesClosed(flagInfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	e := gff3.NewExtractor(g)
+	e.Upstream = flagUpstream
+	e.Downstream = flagDownstream
+	e.Header = h
+
+	var recs []*seq.Record
+	if flagSplice != "" {
+		log.Infof("splicing %s features per transcript", flagSplice)
+		if recs, err = e.Spliced(fs.NewTree(), flagSplice); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		for _, f := range fs.Features {
+			r, err := e.Feature(f)
+			if err != nil {
+				log.Fatal(err)
+			}
+			recs = append(recs, r)
+		}
+	}
+	if e.Clipped > 0 {
+		log.Infof("sequences clipped at a sequence end: %d", e.Clipped)
+	}
+
+	w, err := seq.NewWriterToFile(flagOutfileFasta)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, r := range recs {
+		if err = w.Write(r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("sequences written: %d", w.Count())
+	log.Infof("writing complete: %s", flagOutfileFasta)
+}
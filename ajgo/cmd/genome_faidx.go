@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"ajgo/seq"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode genome > faidx
+var genomeFaidxCmd = &cobra.Command{
+	Use:   "faidx",
+	Short: "write a samtools FASTA index (.fai)",
+	Long: `Index FASTA files in the same way as samtools faidx. For each
+FASTA file, the index is written alongside it with a .fai extension,
+e.g. GRCh38.fa.fai. Gzipped FASTA cannot be indexed.
+
+The sequence name is the first word of each header and, as for
+samtools, every line of a sequence except the last must be the same
+length. The .fai holds the name, length, byte offset, bases per line
+and bytes per line for each sequence.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		genomeFaidxCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	genomeCmd.AddCommand(genomeFaidxCmd)
+
+	genomeFaidxCmd.Flags().StringSliceVar(&flagFastaFiles, "fasta", []string{},
+		"FASTA file to be indexed")
+	genomeFaidxCmd.MarkFlagRequired("fasta")
+}
+
+func genomeFaidxCmdRun(cmd *cobra.Command, args []string) {
+	for _, file := range flagFastaFiles {
+		log.Info("indexing FASTA file: ", file)
+		if _, err := writeFai(file); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// writeFai indexes a FASTA file and writes the index to file.fai. It
+// returns the name of the index.
+func writeFai(file string) (string, error) {
+	recs, err := seq.IndexFastaFile(file)
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for _, r := range recs {
+		lines = append(lines, r.String())
+	}
+	fai := file + ".fai"
+	if err = writeLines(fai, lines); err != nil {
+		return "", err
+	}
+	log.Info("  Number of sequences: ", len(recs))
+	log.Infof("writing complete: %s", fai)
+	return fai, nil
+}
@@ -6,6 +6,9 @@ import (
 	"strconv"
 	"strings"
 
+	"ajgo/coords"
+	"ajgo/gff3"
+
 	"github.com/grendeloz/cmdh"
 	"github.com/grendeloz/ngs/genome"
 	log "github.com/sirupsen/logrus"
@@ -38,9 +41,12 @@ func init() {
 	genomeHomopolymerCmd.Flags().StringVar(&flagOutfile, "gff3", "",
 		"gff3 file of homopolymer regions")
 	genomeHomopolymerCmd.MarkFlagRequired("gff3")
+	addSeqOrderFlag(genomeHomopolymerCmd)
 }
 
 func genomeHomopolymerCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
 	// Read in base genome
 	log.Info("reading serialised genome: ", flagInfileGenome)
 	g, err := genome.GenomeFromGob(flagInfileGenome)
@@ -48,13 +54,13 @@ func genomeHomopolymerCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	err = identifyHomopolymerRegions(g)
+	err = identifyHomopolymerRegions(g, order)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func identifyHomopolymerRegions(g *genome.Genome) error {
+func identifyHomopolymerRegions(g *genome.Genome, order *gff3.SeqOrder) error {
 	log.Info("identifying homopolymers")
 
 	f, err := os.Create(flagOutfile)
@@ -70,9 +76,12 @@ func identifyHomopolymerRegions(g *genome.Genome) error {
 	header := "##gff-version 3\n"
 	header += "##content homopolymer regions\n"
 	header += "##min-length " + strconv.Itoa(flagThreshold) + "\n"
-	header += "##format 1-based half-open\n"
+	header += gff3.FormatHeader(coords.OneBasedClosed) + "\n"
 	header += "##genome " + flagInfileGenome + "\n"
 	header += gffHeaderFromRunParameters()
+	for _, h := range seqOrderHeaders(order) {
+		header += h + "\n"
+	}
 	_, err = w.WriteString(header)
 	if err != nil {
 		return err
@@ -80,7 +89,7 @@ func identifyHomopolymerRegions(g *genome.Genome) error {
 
 	// Traverse sequences searching for homopolymers
 	rctr := 0
-	for _, s := range g.Sequences {
+	for _, s := range orderedSequences(g.Sequences, order) {
 		var inRepeat bool
 		var this, prev string
 		var repeatCtr int
@@ -134,16 +143,17 @@ func identifyHomopolymerRegions(g *genome.Genome) error {
 // Note that what is passed in is the current base in the sequence, not
 // the start - it's only when process the first base PAST the
 // homopolymer that we know it has ended. So we need to do the math
-// based on the current location and the repeat length and remembering
-// that we are using half-open numbering and that GFF3 coordinates are
-// supposed to be is 1-based and we are working from a 0-based loop.
+// based on the current location and the repeat length. current is a
+// 0-based index so the homopolymer is the 0-based half-open interval
+// current-length to current which is current-length+1 to current in
+// 1-based closed GFF3 coordinates.
 func makeHomopolymerGffRecord(seq, base string, current, length, ctr int) string {
 	gff3fields := []string{
 		seq,
 		`ajgo:homopolymer`,
 		`remark`,
 		strconv.Itoa(current + 1 - length),
-		strconv.Itoa(current + 1),
+		strconv.Itoa(current),
 		`.`,
 		`.`,
 		`.`,
@@ -60,7 +60,11 @@ func logGenomeInfo(g *genome.Genome) error {
 	}
 	log.Info("Sequences:")
 	for i, s := range g.Sequences {
-		log.Infof("  %d  %s %d %s", i, s.Header, s.Length, s.FastaFile)
+		var fasta string
+		if s.FastaFile != nil {
+			fasta = s.FastaFile.Filepath
+		}
+		log.Infof("  %d  %s %d %s", i, s.Header, s.Length(), fasta)
 	}
 
 	return nil
@@ -13,6 +13,9 @@ import (
 	"strconv"
 	"strings"
 
+	"ajgo/coords"
+	"ajgo/gff3"
+
 	"github.com/grendeloz/cmdh"
 	"github.com/grendeloz/ngs/genome"
 	log "github.com/sirupsen/logrus"
@@ -42,9 +45,12 @@ func init() {
 	genomeNregionsCmd.Flags().StringVar(&flagOutfile, "gff3", "",
 		"output file in GFF3")
 	genomeNregionsCmd.MarkFlagRequired("gff3")
+	addSeqOrderFlag(genomeNregionsCmd)
 }
 
 func genomeNregionsCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
 	// Read in base genome
 	log.Info("reading serialised genome: ", flagInfileGenome)
 	g, err := genome.GenomeFromGob(flagInfileGenome)
@@ -52,13 +58,13 @@ func genomeNregionsCmdRun(cmd *cobra.Command, args []string) {
 		log.Fatal(err)
 	}
 
-	err = identifyNRegions(g, flagOutfile)
+	err = identifyNRegions(g, flagOutfile, order)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func identifyNRegions(g *genome.Genome, file string) error {
+func identifyNRegions(g *genome.Genome, file string, order *gff3.SeqOrder) error {
 	log.Info("searching for contiguous runs of N")
 
 	f, err := os.Create(file)
@@ -73,20 +79,23 @@ func identifyNRegions(g *genome.Genome, file string) error {
 	// Write GFF3 header
 	header := "##gff-version 3\n"
 	header += "##content genomic N regions\n"
-	header += "##format 1-based half-open\n"
+	header += gff3.FormatHeader(coords.OneBasedClosed) + "\n"
 	header += "##genome " + flagInfileGenome + "\n"
 	header += gffHeaderFromRunParameters()
+	for _, h := range seqOrderHeaders(order) {
+		header += h + "\n"
+	}
 	_, err = w.WriteString(header)
 	if err != nil {
 		return err
 	}
 
-	// Inner slice is seqName, start, end, length
+	// Inner slice is seqName, 0-based start, length
 	var nregions [][]string
 
 	// Traverse sequences
 	// See also for dev: https://go.dev/play/p/vbJLvvY7I7v
-	for _, s := range g.Sequences {
+	for _, s := range orderedSequences(g.Sequences, order) {
 		var inRepeat bool
 		var this, prev string
 		var repeatCtr int
@@ -149,7 +158,7 @@ func identifyNRegions(g *genome.Genome, file string) error {
 			seq,
 			`ajgo:n-regions`,
 			`N_region`,
-			n[1],
+			strconv.Itoa(start + 1),
 			strconv.Itoa(start + length),
 			`.`,
 			`.`,
@@ -1,7 +1,7 @@
 package cmd
 
 import (
-	"regexp"
+	"strconv"
 
 	"ajgo/selector"
 
@@ -16,7 +16,26 @@ var genomeSelectCmd = &cobra.Command{
 	Use:   "select",
 	Short: "create new genome with subset of sequences",
 	Long: `Use selector statements to keep and delete sequences from an
-existing genome to create a new genome.`,
+existing genome to create a new genome. Currently supported selector
+subjects are:
+
+  Subject     Matches against
+  header      the whole FASTA header line including the leading >
+  name        the sequence name, i.e. the first word of the header
+  info        the rest of the header after the name
+  length      length of the sequence
+
+For example, to keep the primary chromosomes and drop short contigs:
+
+  --select 'name ~ "^chr([1-9]|1[0-9]|2[0-2]|X|Y|M)$"'
+  --select 'length >= 1000000 or name in @keep.txt'
+
+Selectors are applied in order and a sequence must survive all of them
+to be kept. The operation:subject:pattern form from older versions of
+ajgo still works and, as before, a subject other than those above (e.g.
+keep:seqid:^chr) matches against the header, with a warning. For a general description of selectors, see
+
+    ajgo selector --help`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmdh.StartLogging()
 		genomeSelectCmdRun(cmd, args)
@@ -32,7 +51,7 @@ func init() {
 	genomeSelectCmd.MarkFlagRequired("in-genome")
 
 	genomeSelectCmd.Flags().StringArrayVar(&flagSelectors, "select", []string{},
-		"selector statements (operation:subject:pattern) for filtering sequences")
+		"selector expression or operation:subject:pattern for filtering sequences")
 	genomeSelectCmd.MarkFlagRequired("select")
 
 	genomeSelectCmd.Flags().StringVar(&flagOutfileGenome, "out-genome", "",
@@ -49,40 +68,31 @@ func genomeSelectCmdRun(cmd *cobra.Command, args []string) {
 	}
 
 	// Get our selectors ready-to-use
-	selectors, err := selector.NewFromStrings(flagSelectors)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	// Apply selectors seriatim
-	var keepers []*genome.Sequence
-	for _, sel := range selectors {
-		log.Infof("applying selector: %s - %s - %s",
-			sel.Operation, sel.Subject, sel.Pattern)
-		// Set up regex for matching
-		rex, err := regexp.Compile(sel.Pattern)
+	var exprs []selector.Expr
+	for _, s := range flagSelectors {
+		e, err := parseSequenceSelector(s)
 		if err != nil {
 			log.Fatal(err)
 		}
-		for _, seq := range g.Sequences {
-			match := rex.Match([]byte(seq.Header))
-			switch sel.Operation {
-			case `keep`:
-				if match {
-					keepers = append(keepers, seq)
-				} else {
-					log.Infof("  deleting sequence: %s", seq.Header)
-				}
-			case `delete`:
-				if match {
-					log.Infof("  deleting sequence: %s", seq.Header)
-				} else {
-					keepers = append(keepers, seq)
-				}
-			default:
-				log.Fatalf("selector operation not recognised: %s", sel.Operation)
+		if err = selector.CheckSubjects(e, isSequenceSubject); err != nil {
+			log.Fatal(err)
+		}
+		exprs = append(exprs, e)
+	}
+
+	// Apply selectors seriatim
+	keepers := g.Sequences
+	for _, e := range exprs {
+		log.Infof("applying selector: %s", e)
+		var kept []*genome.Sequence
+		for _, seq := range keepers {
+			if e.Eval(sequenceRecord{seq}) {
+				kept = append(kept, seq)
+			} else {
+				log.Infof("  deleting sequence: %s", seq.Header)
 			}
 		}
+		keepers = kept
 	}
 
 	// Swap in the list of Sequence to keep
@@ -98,3 +108,53 @@ func genomeSelectCmdRun(cmd *cobra.Command, args []string) {
 	}
 	log.Infof("writing complete: %s", file)
 }
+
+// parseSequenceSelector parses a selector for genome sequences. Older
+// versions of ajgo ignored the subject of a three-part selector and
+// matched the pattern against the header so, for the three-part
+// shorthand, any subject that is not a sequence subject is read as
+// header, with a warning.
+func parseSequenceSelector(s string) (selector.Expr, error) {
+	if !selector.IsShorthand(s) {
+		return selector.Parse(s)
+	}
+	sel, err := selector.NewFromString(s)
+	if err != nil {
+		return nil, err
+	}
+	if !isSequenceSubject(sel.Subject) {
+		log.Warnf("selector %s: subject %q is deprecated and is read as header - use %s:header:%s",
+			s, sel.Subject, sel.Operation, sel.Pattern)
+		sel.Subject = `header`
+	}
+	return sel.Expr()
+}
+
+// sequenceRecord makes a genome.Sequence into a selector.Record.
+type sequenceRecord struct {
+	seq *genome.Sequence
+}
+
+// isSequenceSubject returns true for the selector subjects that
+// sequenceRecord understands.
+func isSequenceSubject(s string) bool {
+	switch s {
+	case `header`, `name`, `info`, `length`:
+		return true
+	}
+	return false
+}
+
+func (r sequenceRecord) SubjectValues(subject string) []string {
+	switch subject {
+	case `header`:
+		return []string{r.seq.Header}
+	case `name`:
+		return []string{r.seq.Name}
+	case `info`:
+		return []string{r.seq.Info}
+	case `length`:
+		return []string{strconv.Itoa(r.seq.Length())}
+	}
+	return nil
+}
@@ -17,7 +17,13 @@ properties of those datasets.
 For example under genemodel > ensembl-gff3 there are modes that account
 for the relationship, in Ensembl GFF3-format gene model files, between 
 genes and transcripts and can create appropriate subsets of the gene
-models, e.g. gene-by-exon or gene-by-CDS.`,
+models, e.g. gene-by-exon or gene-by-CDS.
+
+Start and End are read as 1-based closed, as per the GFF3 spec, unless
+the GFF3 has a ##format header that says otherwise, e.g. the
+"##format 1-based half-open" written by older versions of ajgo. The
+modes that compare intervals (closest, complement, intersect, merge and
+subtract) always write 1-based closed and say so in a ##format header.`,
 }
 
 func init() {
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"ajgo/coords"
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > closest
+var gff3ClosestCmd = &cobra.Command{
+	Use:   "closest",
+	Short: "find the closest Feature in other GFF3s",
+	Long: `
+Read a GFF3 file and one or more "with" GFF3 files and, for each Feature
+in the first GFF3, find the closest Feature in the "with" GFF3s. Every
+Feature from the first GFF3 is written out with two extra Attributes:
+
+  Closest          ID of the closest Feature, or SeqId:Start-End if
+                   it has no ID. If there are ties, all are listed.
+  ClosestDistance  0 if the Feature overlap, 1 if they meet, and so on.
+
+Feature on SeqIds that have no Feature in the "with" GFF3s are written
+without the extra Attributes. Strand is ignored.
+
+Feature that meet, e.g. 100-199 and 200-299, have a ClosestDistance
+of 1. Start and End are read and written as described in ajgo gff3 --help.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3SetOpCmdRun(`closest`, func(fs, with *gff3.Features) *gff3.Features {
+			// The output is converted back to 1-based closed
+			return fs.Closest(with, coords.OneBasedClosed)
+		})
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3ClosestCmd)
+
+	gff3ClosestCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file with Feature to be annotated")
+	gff3ClosestCmd.MarkFlagRequired("gff3")
+
+	gff3ClosestCmd.Flags().StringSliceVar(&flagWithGff3Files, "with-gff3", []string{},
+		"GFF3 files to search for closest Feature")
+	gff3ClosestCmd.MarkFlagRequired("with-gff3")
+
+	gff3ClosestCmd.Flags().StringVar(&flagOutfile, "out-gff3", "",
+		"output file in GFF3")
+	gff3ClosestCmd.MarkFlagRequired("out-gff3")
+	addSeqOrderFlag(gff3ClosestCmd)
+}
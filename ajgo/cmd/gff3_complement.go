@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"ajgo/coords"
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	"github.com/grendeloz/ngs/genome"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > complement
+var gff3ComplementCmd = &cobra.Command{
+	Use:   "complement",
+	Short: "find the regions not covered by a GFF3",
+	Long: `
+Read a GFF3 file and write out new Feature covering every part of each
+sequence that is not covered by a Feature in the GFF3. For example, the
+complement of an uncallable mask is the callable genome.
+
+The sequences and their lengths come from an ajgo serialised genome
+(--in-genome) if one is given, otherwise from the ##sequence-region
+headers in the GFF3. Feature on sequences that are not in the genome
+or headers are ignored. Output Feature are in the same order as the
+sequences in the genome or headers.
+
+The complement of a sequence of length 100 with no Feature is a single
+Feature 1-100. Start and End are read and written as described in
+ajgo gff3 --help.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3ComplementCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3ComplementCmd)
+
+	gff3ComplementCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file to be complemented")
+	gff3ComplementCmd.MarkFlagRequired("gff3")
+
+	gff3ComplementCmd.Flags().StringVar(&flagInfileGenome, "in-genome", "",
+		"ajgo serialised genome with sequence lengths")
+
+	gff3ComplementCmd.Flags().StringVar(&flagOutfile, "out-gff3", "",
+		"output file in GFF3")
+	gff3ComplementCmd.MarkFlagRequired("out-gff3")
+	addSeqOrderFlag(gff3ComplementCmd)
+}
+
+func gff3ComplementCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	headers := []string{
+		"##gff-version 3",
+		"##created-by ajgo mode: gff3 > complement",
+		gff3.FormatHeader(coords.OneBasedClosed),
+	}
+	headers = append(headers, gffHeadersFromRunParameters()...)
+	headers = append(headers, seqOrderHeaders(order)...)
+
+	log.Info("reading GFF3: ", flagInfile)
+	fs, fh, err := readGff3FeaturesHalfOpen(flagInfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("  this GFF3 file contains %d features from %d SeqIds",
+		fs.Count(), len(fs.SeqIds()))
+	headers = append(headers, "##input-gff3-file "+flagInfile)
+
+	var regions []*gff3.SequenceRegion
+	if flagInfileGenome != "" {
+		log.Info("reading serialised genome: ", flagInfileGenome)
+		g, err := genome.GenomeFromGob(flagInfileGenome)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range g.Sequences {
+			regions = append(regions, &gff3.SequenceRegion{
+				SeqId: s.Name,
+				Start: 1,
+				End:   s.Length()})
+		}
+		headers = append(headers, "##genome "+flagInfileGenome)
+	} else {
+		regions = gff3.SequenceRegionsFromHeaders(fh)
+	}
+	if len(regions) == 0 {
+		log.Fatal("no sequence lengths - supply --in-genome or a GFF3 with ##sequence-region headers")
+	}
+	log.Info("Number of sequences: ", len(regions))
+
+	known := make(map[string]bool)
+	for _, r := range regions {
+		known[r.SeqId] = true
+		headers = append(headers, r.String())
+	}
+	for _, seqid := range fs.SeqIds() {
+		if !known[seqid] {
+			log.Warnf("SeqId %s has no sequence length and will be ignored", seqid)
+		}
+	}
+
+	out := fs.Complement(regions)
+	out.ConvertCoords(coords.OneBasedHalfOpen, coords.OneBasedClosed)
+	if order != nil {
+		out.SetSeqOrder(order)
+	}
+	log.Info("Number of features written: ", out.Count())
+	if err = writeGff3Features(flagOutfile, headers, out); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfile)
+}
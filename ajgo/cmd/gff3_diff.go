@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > diff
+var gff3DiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "report the differences between two releases of a GFF3",
+	Long: `
+Compare an old and a new release of a GFF3 gene model, e.g. two Ensembl
+releases, and report every Feature that was added, removed, moved or
+changed.
+
+Feature with an ID are matched by ID. A Feature whose ID is spread over
+several lines, e.g. CDS, is matched line by line. Feature without an ID,
+e.g. Ensembl exons, are matched by SeqId, Type, Start, End and Strand
+(preferring the same Parent) so a change to their coordinates shows as
+one removed and one added Feature. A matched Feature is:
+
+  moved    if its SeqId, Start, End or Strand changed
+  changed  if its Source, Type, Score, Phase or any Attribute changed
+
+and a Feature can be both. Each change is written to --out-tsv with the
+columns:
+
+  GeneId   gene that the Feature is part of, so a change to a transcript
+           or exon is listed under its gene
+  Change   added, removed, moved or changed
+  Type     Type of the Feature
+  ID       ID of the Feature, or Name if it has no ID
+  SeqId    SeqId of the Feature
+  Old      location in the old release as seqid:start-end:strand
+  New      location in the new release
+  Details  what moved or changed, e.g. end=900>950 or attr.Name=A>B
+
+Changes are grouped by gene, in the order the genes appear in the new
+release, followed by genes that were removed and then changes to
+Feature that are not part of a gene.
+
+--summary-tsv writes the number of added, removed, moved, changed and
+unchanged Feature for each Type and for each SeqId. The totals are also
+written to the log.
+
+To look at a panel of genes, run genemodel > ensembl-gff3 > panel on
+both releases first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3DiffCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3DiffCmd)
+
+	gff3DiffCmd.Flags().StringVar(&flagOldGff3, "old-gff3", "",
+		"old release of the GFF3")
+	gff3DiffCmd.MarkFlagRequired("old-gff3")
+	gff3DiffCmd.Flags().StringVar(&flagNewGff3, "new-gff3", "",
+		"new release of the GFF3")
+	gff3DiffCmd.MarkFlagRequired("new-gff3")
+	gff3DiffCmd.Flags().StringVar(&flagOutfile, "out-tsv", "",
+		"one line per change - TSV format")
+	gff3DiffCmd.MarkFlagRequired("out-tsv")
+	gff3DiffCmd.Flags().StringVar(&flagSummaryTsv, "summary-tsv", "",
+		"number of changes per Type and per SeqId - TSV format")
+	addSeqOrderFlag(gff3DiffCmd)
+}
+
+func gff3DiffCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	var releases []*gff3.Features
+	for _, file := range []string{flagOldGff3, flagNewGff3} {
+		log.Info("reading GFF3: ", file)
+		// Compare both releases in the same coordinate system
+		fs, _, err := readGff3FeaturesClosed(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fs.SeqOrder = order
+		log.Info("  Number of Features: ", fs.Count())
+		releases = append(releases, fs)
+	}
+
+	log.Info("comparing releases")
+	r := gff3.Diff(releases[0], releases[1])
+	tally := make(map[string]int)
+	var unchanged int
+	for _, c := range r.ByType {
+		tally[gff3.DiffAdded] += c.Added
+		tally[gff3.DiffRemoved] += c.Removed
+		tally[gff3.DiffMoved] += c.Moved
+		tally[gff3.DiffChanged] += c.Changed
+		unchanged += c.Unchanged
+	}
+	for _, k := range []string{gff3.DiffAdded, gff3.DiffRemoved, gff3.DiffMoved, gff3.DiffChanged} {
+		log.Infof("  %s: %d", k, tally[k])
+	}
+	log.Infof("  unchanged: %d", unchanged)
+
+	if err := writeDiffTsv(flagOutfile, r); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfile)
+
+	if flagSummaryTsv != "" {
+		if err := writeDiffSummaryTsv(flagSummaryTsv, r); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("writing complete: %s", flagSummaryTsv)
+	}
+}
+
+// writeDiffTsv writes one line per change as TSV.
+func writeDiffTsv(file string, r *gff3.DiffReport) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	_, err = w.WriteString(gff3.DiffTsvHeader() + "\n")
+	if err != nil {
+		return err
+	}
+	for _, c := range r.Changes {
+		_, err = w.WriteString(c.TsvString() + "\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDiffSummaryTsv writes the counts per Type and then per SeqId as
+// TSV.
+func writeDiffSummaryTsv(file string, r *gff3.DiffReport) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	_, err = w.WriteString(gff3.DiffCountTsvHeader() + "\n")
+	if err != nil {
+		return err
+	}
+	for _, c := range append(r.ByType, r.BySeqId...) {
+		_, err = w.WriteString(c.TsvString() + "\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+
+	"ajgo/coords"
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > intersect
+var gff3IntersectCmd = &cobra.Command{
+	Use:   "intersect",
+	Short: "keep the parts of Feature that overlap other GFF3s",
+	Long: `
+Read a GFF3 file and one or more "with" GFF3 files and write out the
+parts of each Feature from the first GFF3 that are covered by any
+Feature in the "with" GFF3s. A Feature that is covered in several
+places is split into several Feature. Each output Feature keeps the
+SeqId, Source, Type, Strand and Attributes of the original Feature.
+
+Feature that meet, e.g. 100-199 and 200-299, do not overlap.
+Start and End are read and written as described in ajgo gff3 --help.
+
+See also gff3 > subtract, gff3 > complement and gff3 > closest.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3SetOpCmdRun(`intersect`, (*gff3.Features).Intersect)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3IntersectCmd)
+
+	gff3IntersectCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file with Feature to be intersected")
+	gff3IntersectCmd.MarkFlagRequired("gff3")
+
+	gff3IntersectCmd.Flags().StringSliceVar(&flagWithGff3Files, "with-gff3", []string{},
+		"GFF3 files to intersect with")
+	gff3IntersectCmd.MarkFlagRequired("with-gff3")
+
+	gff3IntersectCmd.Flags().StringVar(&flagOutfile, "out-gff3", "",
+		"output file in GFF3")
+	gff3IntersectCmd.MarkFlagRequired("out-gff3")
+	addSeqOrderFlag(gff3IntersectCmd)
+}
+
+// gff3SetOpCmdRun does the work for the gff3 modes that combine the
+// Feature from --gff3 with the Feature from --with-gff3.
+func gff3SetOpCmdRun(mode string, op func(*gff3.Features, *gff3.Features) *gff3.Features) {
+	order := mustSeqOrderFromFlag()
+
+	headers := []string{
+		"##gff-version 3",
+		"##created-by ajgo mode: gff3 > " + mode,
+		gff3.FormatHeader(coords.OneBasedClosed),
+	}
+	headers = append(headers, gffHeadersFromRunParameters()...)
+	headers = append(headers, seqOrderHeaders(order)...)
+
+	log.Info("reading GFF3: ", flagInfile)
+	fs, fh, err := readGff3FeaturesHalfOpen(flagInfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("  this GFF3 file contains %d features from %d SeqIds",
+		fs.Count(), len(fs.SeqIds()))
+	headers = append(headers, "##input-gff3-file "+flagInfile)
+
+	with := gff3.NewFeatures()
+	for i, file := range flagWithGff3Files {
+		log.Infof("reading with-GFF3 file %d: %s", i, file)
+		wfs, _, err := readGff3FeaturesHalfOpen(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("  this GFF3 file contains %d features from %d SeqIds",
+			wfs.Count(), len(wfs.SeqIds()))
+		with.AddFeatures(wfs.Features...)
+		headers = append(headers, fmt.Sprintf("##with-gff3-file %d %s", i, file))
+	}
+
+	// The input sequence regions still apply to the output
+	for _, r := range gff3.SequenceRegionsFromHeaders(fh) {
+		headers = append(headers, r.String())
+	}
+
+	out := op(fs, with)
+	out.ConvertCoords(coords.OneBasedHalfOpen, coords.OneBasedClosed)
+	if order != nil {
+		out.SetSeqOrder(order)
+	}
+	log.Info("Number of features written: ", out.Count())
+	if err = writeGff3Features(flagOutfile, headers, out); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfile)
+}
+
+// writeGff3Features uses a gff3.Writer to write headers and Feature to
+// a GFF3 file.
+func writeGff3Features(file string, headers []string, fs *gff3.Features) error {
+	w, err := gff3.NewWriterToFile(file)
+	if err != nil {
+		return err
+	}
+	w.AddHeaders(headers...)
+	if err = w.WriteFeatures(fs); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
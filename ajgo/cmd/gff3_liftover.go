@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"fmt"
+
+	"ajgo/chain"
+	"ajgo/coords"
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > liftover
+var gff3LiftoverCmd = &cobra.Command{
+	Use:   "liftover",
+	Short: "map GFF3 features to another genome build",
+	Long: `
+Map the features in a GFF3 from one genome build to another, e.g.
+GRCh37 to GRCh38, using a UCSC chain file. Chain files for the common
+builds are available from the UCSC download site, e.g.
+hg19ToHg38.over.chain.gz, and can be read gzipped. Note that the
+sequence names in the chain file must match the SeqIds of the GFF3 so
+a UCSC chain file (chr1) needs its names changed to lift an Ensembl
+GFF3 (1).
+
+Each feature is mapped through the chain that aligns the most of its
+bases and features mapped to the reverse strand of the new build have
+their strand flipped. A feature that is not entirely inside a single
+ungapped block of the chain is handled according to --policy:
+
+  drop   the feature is not mapped
+  split  one feature is written for each block, all with the same
+         attributes (and ID), as for a multi-line GFF3 feature
+  span   a single feature is written from the first mapped base to
+         the last
+
+For split and span, at least --min-match (a fraction) of the bases of
+the feature must be mapped, as for the UCSC liftOver tool. Phase is
+copied unchanged so it may not be right for a split CDS, which
+genemodel > translate will find.
+
+Features that cannot be mapped are written unchanged to --unmapped-gff3
+with an Unmapped attribute that gives the reason:
+
+  no_chain  no chain aligns any base of the feature
+  gap       the feature spans a chain gap and --policy is drop
+  partial   less than --min-match of the feature was mapped
+
+Both output files record the chain file and its MD5 in ##liftover-chain
+headers. The lifted features are sorted as they may have moved between
+sequences.
+
+Start and End are read as 1-based closed unless the GFF3 has a
+##format header that says otherwise. The outputs are always 1-based
+closed and say so in a ##format header.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3LiftoverCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3LiftoverCmd)
+
+	gff3LiftoverCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file to be lifted")
+	gff3LiftoverCmd.MarkFlagRequired("gff3")
+	gff3LiftoverCmd.Flags().StringVar(&flagChainFile, "chain", "",
+		"UCSC chain file from the build of the GFF3 to the new build")
+	gff3LiftoverCmd.MarkFlagRequired("chain")
+	gff3LiftoverCmd.Flags().StringVar(&flagOutfileGeneModel, "out-gff3", "",
+		"lifted features - GFF3 format")
+	gff3LiftoverCmd.MarkFlagRequired("out-gff3")
+	gff3LiftoverCmd.Flags().StringVar(&flagUnmappedGff3, "unmapped-gff3", "",
+		"features that could not be lifted - GFF3 format")
+	gff3LiftoverCmd.MarkFlagRequired("unmapped-gff3")
+
+	gff3LiftoverCmd.Flags().StringVar(&flagLiftoverPolicy, "policy", gff3.LiftoverSplit,
+		"features that span chain gaps: drop, split or span")
+	gff3LiftoverCmd.Flags().Float64Var(&flagMinMatch, "min-match", 0.95,
+		"fraction of bases that must be mapped for split and span")
+	addSeqOrderFlag(gff3LiftoverCmd)
+}
+
+func gff3LiftoverCmdRun(cmd *cobra.Command, args []string) {
+	if err := gff3.CheckPolicy(flagLiftoverPolicy); err != nil {
+		log.Fatal(err)
+	}
+	if flagMinMatch < 0 || flagMinMatch > 1 {
+		log.Fatal("--min-match must be between 0 and 1")
+	}
+	order := mustSeqOrderFromFlag()
+
+	log.Info("reading chain file: ", flagChainFile)
+	md5, err := md5sum(flagChainFile)
+	if err != nil {
+		log.Fatalf("error calculating md5sum: %v", err)
+	}
+	log.Info("  MD5 checksum: ", md5)
+	cs, err := chain.NewFromFile(flagChainFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of chains: ", len(cs.Chains))
+
+	log.Info("reading: ", flagInfile)
+	fs, _, err := readGff3FeaturesClosed(flagInfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info("  Number of Features: ", fs.Count())
+
+	l := gff3.NewLifter(cs)
+	l.Policy = flagLiftoverPolicy
+	l.MinMatch = flagMinMatch
+
+	lifted := gff3.NewFeatures()
+	unmapped := gff3.NewFeatures()
+	reasons := make(map[string]int)
+	var split int
+	for _, f := range fs.Features {
+		nfs, reason := l.Lift(f)
+		if reason != "" {
+			reasons[reason]++
+			u := f.Clone()
+			u.SetAttributeValues(`Unmapped`, reason)
+			unmapped.Features = append(unmapped.Features, u)
+			continue
+		}
+		if len(nfs) > 1 {
+			split++
+		}
+		lifted.Features = append(lifted.Features, nfs...)
+	}
+	lifted.SeqOrder = order
+	lifted.Sort()
+
+	log.Info("Features lifted: ", fs.Count()-unmapped.Count())
+	if flagLiftoverPolicy == gff3.LiftoverSplit {
+		log.Info("  split across chain gaps: ", split)
+	}
+	log.Info("Features unmapped: ", unmapped.Count())
+	for _, r := range []string{gff3.UnmappedNoChain, gff3.UnmappedGap, gff3.UnmappedPartial} {
+		log.Infof("  %s: %d", r, reasons[r])
+	}
+
+	headers := []string{
+		"##gff-version 3",
+		"##created-by ajgo mode: gff3 > liftover",
+		gff3.FormatHeader(coords.OneBasedClosed),
+	}
+	headers = append(headers, gffHeadersFromRunParameters()...)
+	headers = append(headers,
+		"##liftover-source-gff3 "+flagInfile,
+		"##liftover-chain-file "+flagChainFile,
+		"##liftover-chain-md5 "+md5,
+		fmt.Sprintf("##liftover-options policy=%s min-match=%g", l.Policy, l.MinMatch))
+
+	if err := writeGff3Features(flagOutfileGeneModel, append(headers, seqOrderHeaders(order)...), lifted); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagOutfileGeneModel)
+	if err := writeGff3Features(flagUnmappedGff3, headers, unmapped); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("writing complete: %s", flagUnmappedGff3)
+}
@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
 
+	"ajgo/coords"
 	"ajgo/gff3"
 
 	"github.com/grendeloz/cmdh"
@@ -16,13 +19,14 @@ var gff3MergeCmd = &cobra.Command{
 	Use:   "merge",
 	Short: "merge and consolidate multiple GFF3 files",
 	Long: `
-Read two or more GFF3 files, consolidate records within each GFF3 and
-sequentially merge them in the order they were specified on the command
-line, i.e. the second GFF3 is merged onto the first and then any
-subsequent GFF3s are merged one at a time onto the new composite.
+Read two or more GFF3 files and prudently merge all of their records
+in a single pass. Records from the same GFF3 are merged with each other
+in exactly the same way as records from different GFF3s so the result
+does not depend on the order of the files.
 
 This mode does not make use of any information from the individual
-Feature records apart from the SeqId, Type, Start and End. The Start
+Feature records apart from the SeqId, Type, Start and End (unless
+--group-by or --aggregate-attributes is used, see below). The Start
 and End are used to determine the Allen Relationships between intervals
 to determine when merging is appropriate. Merging only happens within
 Feature of the same SeqId because that's the nature of genomes - regions
@@ -42,6 +46,38 @@ For a more detailed explanation of both simple and prudent merging, run
 
  ajgo merge-gff3
 
+Start and End are read and written as described in ajgo gff3 --help.
+
+Prudent merging never joins records that meet or are close to each
+other, so records 1-100 and 101-200 stay separate. With --merge-adjacent
+records that meet are joined, and with --max-gap N, records with up to
+N bases between them are joined (which includes records that meet).
+A group of joined records is written as a single ajgo-merge record
+whose Sources, Types and IDs list every record in the group and whose
+Joined Attribute says how many records were joined. With --min-length
+N, any merged record shorter than N bases is dropped, which is useful
+for getting rid of slivers when building masks. The records that a
+prudent merge makes from records that overlap meet each other and are
+measured together, so a short overlap between two long records is kept.
+
+--group-by only merges records that share values, e.g. --group-by strand
+keeps overlapping records on opposite strands apart and --group-by
+attr.gene_id keeps each gene separate. Any selector subject can be used
+(see gff3 > select), the same as for genemodel > exons: seqid, source,
+type, score, strand, phase, length and attr.<key> for any attribute, and
+more than one can be given. Merged records keep the Strand of their
+inputs if they all share it. Merged records normally only have the
+Sources, Types and IDs Attributes but with --aggregate-attributes they
+also get the values of every other Attribute of their inputs, e.g.
+Name=BRCA1,NBR2.
+
+By default each GFF3 is read into memory and sorted before merging. If
+the GFF3s are already sorted by SeqId (in the --seq-order ordering,
+lexical if not set) and then Start, --sorted streams the records from
+each file so memory use does not depend on the size of the files. The
+merge will stop with an error if a file turns out not to be sorted.
+--group-by reads every record into memory even with --sorted.
+
 Depending on your use case, the gff3 > select mode may be helpful
 before or after the merge.`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -61,38 +97,62 @@ func init() {
 	gff3MergeCmd.Flags().StringVar(&flagOutfileGeneModel, "out-gff3", "",
 		"gene model after consolidation - GFF3 format")
 	gff3MergeCmd.MarkFlagRequired("out-gff3")
+	gff3MergeCmd.Flags().BoolVar(&flagSorted, "sorted", false,
+		"GFF3 files are already sorted so stream instead of reading into memory")
+	gff3MergeCmd.Flags().BoolVar(&flagMergeAdjacent, "merge-adjacent", false,
+		"join records that meet, i.e. with no bases between them")
+	gff3MergeCmd.Flags().IntVar(&flagMaxGap, "max-gap", 0,
+		"join records with up to this many bases between them")
+	gff3MergeCmd.Flags().IntVar(&flagMinLength, "min-length", 0,
+		"drop merged records shorter than this")
+	gff3MergeCmd.Flags().StringSliceVar(&flagGroupBy, "group-by", []string{},
+		"only merge records with the same values for these subjects, e.g. strand,attr.gene_id")
+	gff3MergeCmd.Flags().BoolVar(&flagAggregateAttrs, "aggregate-attributes", false,
+		"keep the Attributes of merged records, combining any values that differ")
+	addSeqOrderFlag(gff3MergeCmd)
 }
 
 func gff3MergeCmdRun(cmd *cobra.Command, args []string) {
 	if len(flagGff3Files) < 2 {
 		log.Fatal("must supply at least 2 GFF3 files to merge")
 	}
+	order := mustSeqOrderFromFlag()
 
-	// TO DO - we need to create a clean GFF3 with new headers
-	// etc and then modify the loop so all flagGff3Files are merged onto
-	// the clean GFF3. We also need to update the headers from the
-	// merged GFF3 so they all get a `-1` suffix to each key, e.g.
-	//  ##gff-version 3  -> ##gff-version-1 3
-	// This will let is keep all of the headers from the merged GFF3s in
-	// the new GFF3.
-
-	gMerged := gff3.NewGff3()
 	headers := []string{
 		"##gff-version 3",
 		"##created-by ajgo mode: gff3 > merge",
+		gff3.FormatHeader(coords.OneBasedClosed),
 	}
-	rpHeaders := gffHeadersFromRunParameters()
-	gMerged.Header = append(gMerged.Header, headers...)
-	gMerged.Header = append(gMerged.Header, rpHeaders...)
+	headers = append(headers, gffHeadersFromRunParameters()...)
+	headers = append(headers, seqOrderHeaders(order)...)
 
-	var vHeaders []string
+	var opts *gff3.MergeOptions
+	if flagMergeAdjacent || flagMaxGap > 0 || flagMinLength > 0 ||
+		len(flagGroupBy) > 0 || flagAggregateAttrs {
+		if flagMaxGap < 0 || flagMinLength < 0 {
+			log.Fatal("--max-gap and --min-length cannot be negative")
+		}
+		for _, s := range flagGroupBy {
+			if !gff3.IsFeatureSubject(s) {
+				log.Fatalf("--group-by subject not recognised: %s", s)
+			}
+		}
+		if flagSorted && len(flagGroupBy) > 0 {
+			log.Warn("--group-by reads all records into memory so --sorted will not stream")
+		}
+		opts = &gff3.MergeOptions{Adjacent: flagMergeAdjacent, MaxGap: flagMaxGap, MinLength: flagMinLength,
+			GroupBy: flagGroupBy, Aggregate: flagAggregateAttrs}
+		headers = append(headers, fmt.Sprintf("##merge-options merge-adjacent=%t max-gap=%d min-length=%d group-by=%s aggregate-attributes=%t",
+			opts.Adjacent, opts.MaxGap, opts.MinLength, strings.Join(opts.GroupBy, ","), opts.Aggregate))
+	}
 
-	// Now merge in the files
-	for i := 0; i < len(flagGff3Files); i++ {
-		file := flagGff3Files[i]
-		log.Infof("merging GFF3 file %d: %s", i, file)
-		header := fmt.Sprintf("##merged-gff3-file %d %s", i, file)
-		gMerged.Header = append(gMerged.Header, header)
+	// Gather an input for each file. Headers from the merged GFF3s are
+	// kept with a suffix on each key, e.g. ##gff-version-1 3
+	var vHeaders []string
+	var inputs []gff3.FeatureReader
+	for i, file := range flagGff3Files {
+		log.Infof("reading GFF3 file %d: %s", i, file)
+		headers = append(headers, fmt.Sprintf("##merged-gff3-file %d %s", i, file))
 
 		// log MD5 before processing
 		md5, err := md5sum(file)
@@ -101,49 +161,182 @@ func gff3MergeCmdRun(cmd *cobra.Command, args []string) {
 		}
 		log.Info("  MD5 checksum: ", md5)
 
-		g, err := gff3.NewFromFile(file)
-		if err != nil {
-			log.Fatal(err)
+		var in gff3.FeatureReader
+		var fh []string
+		if flagSorted {
+			in, fh, err = newHalfOpenReader(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			var fs *gff3.Features
+			fs, fh, err = readGff3FeaturesHalfOpen(file)
+			if err != nil {
+				log.Fatal(err)
+			}
+			log.Infof("  this GFF3 file contains %d features from %d SeqIds",
+				fs.Count(), len(fs.SeqIds()))
+			fs.SeqOrder = order
+			fs.Sort()
+			in = gff3.NewFeaturesReader(fs)
 		}
-		log.Infof("  this GFF3 file contains %d features from %d SeqIds",
-			g.FeatureCount(), len(g.SeqIds()))
-
-		// Merge Features & Headers
-		fs1 := gff3.MergeFeatures(gMerged.Features, g.Features)
-		gMerged.Features = fs1
+		inputs = append(inputs, in)
 		vHeaders = append(vHeaders, "###") // visual separator
-		vHeaders = append(vHeaders, g.VersionedHeaders(strconv.Itoa(i))...)
+		vHeaders = append(vHeaders, gff3.VersionHeaders(fh, strconv.Itoa(i))...)
+	}
+	headers = append(headers, vHeaders...)
+
+	// Merge all of the files in one pass, writing as we go
+	log.Info("merging GFF3 files: ", len(flagGff3Files))
+	w, err := gff3.NewWriterToFile(flagOutfileGeneModel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.AddHeaders(headers...)
 
-		log.Infof("  merged GFF3 file contains %d features from %d SeqIds",
-			gMerged.FeatureCount(), len(gMerged.SeqIds()))
+	type seqStats struct{ count, overlaps, sumIntvl int }
+	stats := make(map[string]*seqStats)
+	var seqids []string
+	m := gff3.NewMerger(order, inputs...)
+	m.Options = opts
+	for {
+		f, err := m.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		s, ok := stats[f.SeqId]
+		if !ok {
+			s = &seqStats{}
+			stats[f.SeqId] = s
+			seqids = append(seqids, f.SeqId)
+		}
+		s.count++
+		s.sumIntvl += f.End - f.Start
+		if f.Source == `ajgo-merge` {
+			s.overlaps++
+		}
+		f.ConvertCoords(coords.OneBasedHalfOpen, coords.OneBasedClosed)
+		if err = w.Write(f); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		log.Fatal(err)
 	}
-	gMerged.Header = append(gMerged.Header, vHeaders...)
 
 	log.Info("Number of GFF3 files merged: ", len(flagGff3Files))
-	log.Info("Number of Sequences with features: ", len(gMerged.SeqIds()))
+	log.Info("Number of Sequences with features: ", len(seqids))
 	log.Info("Sequences:")
-	log.Info("  Name\tCount\tSumIntvl\tConsIntvl\tIsSorted")
-	seqs := gMerged.Features.BySeqId()
-	seqids := gMerged.SeqIds()
+	log.Info("  Name\tCount\tOverlaps\tSumIntvl")
 	for _, seqid := range seqids {
-		fs := seqs[seqid]
-		fs.Sort() // This is a requirement for consolidation
-		sci, err := fs.SumConsolidatedIntervals()
+		s := stats[seqid]
+		log.Infof("  %s\t%d\t%d\t%d", seqid, s.count, s.overlaps, s.sumIntvl)
+	}
+	if flagMinLength > 0 {
+		log.Infof("Number of features shorter than %d dropped: %d", flagMinLength, m.Dropped())
+	}
+	log.Info("Number of features: ", w.Count())
+	log.Infof("writing complete: %s", flagOutfileGeneModel)
+}
+
+// readGff3Features uses a gff3.Reader to read all of the Feature from
+// a GFF3 file. It returns the Feature and the header lines.
+func readGff3Features(file string) (*gff3.Features, []string, error) {
+	r, err := gff3.NewReaderFromFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	fs := gff3.NewFeatures()
+	fs.Key = `file`
+	fs.Value = file
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			log.Warning(err)
+			return nil, nil, err
 		}
-		log.Infof("  %s\t%d\t%d\t%d\t%t", seqid,
-			fs.Count(),
-			fs.SumIntervals(),
-			sci,
-			fs.IsSorted)
+		fs.Features = append(fs.Features, f)
 	}
-	log.Info("Number of features: ", gMerged.FeatureCount())
+	if fs.Count() == 0 {
+		return nil, nil, fmt.Errorf("%s: %w", file, gff3.ErrNoGff3Records)
+	}
+
+	return fs, r.Header, nil
+}
 
-	// Write out the merged GFF3
-	err := gMerged.Write(flagOutfileGeneModel)
+// readGff3FeaturesHalfOpen is readGff3Features for the modes that treat
+// Feature as regions. Feature are converted from the coordinate system
+// in the ##format header (1-based closed if there is no ##format) to
+// 1-based half-open which is what merging and the set algebra expect.
+// Use Features.ConvertCoords to convert back before writing.
+func readGff3FeaturesHalfOpen(file string) (*gff3.Features, []string, error) {
+	fs, headers, err := readGff3Features(file)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
-	log.Infof("writing complete: %s", flagOutfileGeneModel)
+	sys, err := gff3.CoordSystemFromHeaders(headers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	fs.ConvertCoords(sys, coords.OneBasedHalfOpen)
+	return fs, headers, nil
+}
+
+// readGff3FeaturesClosed is readGff3Features for the modes that need
+// every GFF3 in the 1-based closed system of the spec, e.g. to compare
+// or measure Feature from files with different ##format headers.
+func readGff3FeaturesClosed(file string) (*gff3.Features, []string, error) {
+	fs, headers, err := readGff3Features(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	sys, err := gff3.CoordSystemFromHeaders(headers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	fs.ConvertCoords(sys, coords.OneBasedClosed)
+	return fs, headers, nil
+}
+
+// halfOpenReader streams Feature from a GFF3 file, converting each
+// one to 1-based half-open as readGff3FeaturesHalfOpen does for a
+// whole file. The underlying file is closed when Read reaches the end.
+type halfOpenReader struct {
+	r   *gff3.Reader
+	sys coords.System
+}
+
+// newHalfOpenReader opens a GFF3 file for streaming and returns the
+// header lines.
+func newHalfOpenReader(file string) (*halfOpenReader, []string, error) {
+	r, err := gff3.NewReaderFromFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	sys, err := gff3.CoordSystemFromHeaders(r.Header)
+	if err != nil {
+		r.Close()
+		return nil, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return &halfOpenReader{r: r, sys: sys}, r.Header, nil
+}
+
+func (h *halfOpenReader) Read() (*gff3.Feature, error) {
+	f, err := h.r.Read()
+	if err == io.EOF {
+		h.r.Close()
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", h.r.File, err)
+	}
+	f.ConvertCoords(h.sys, coords.OneBasedHalfOpen)
+	return f, nil
 }
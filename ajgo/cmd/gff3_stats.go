@@ -1,7 +1,10 @@
 package cmd
 
 import (
-	"ajgo/gff3"
+	"bufio"
+	"encoding/json"
+	"os"
+	"strings"
 
 	"github.com/grendeloz/cmdh"
 	log "github.com/sirupsen/logrus"
@@ -11,8 +14,35 @@ import (
 // submode gff3 > stats
 var gff3StatsCmd = &cobra.Command{
 	Use:   "stats",
-	Short: "basic by-SeqId stats",
-	Long:  `Simple summary statistics by-SeqId for a GFF3.`,
+	Short: "summary statistics for a GFF3",
+	Long: `
+Summary statistics for a GFF3, e.g. to track releases of a mask file
+over time. Statistics are given for the whole file, for each SeqId and
+for each combination of SeqId, Type and Source:
+
+  Count         number of Feature
+  Bases         sum of the Feature lengths
+  Covered       bases covered by at least one Feature, so Bases minus
+                Covered is the number of bases in overlaps
+  MinLength     shortest Feature
+  MedianLength  median Feature length (the lower median for an even
+                number of Feature)
+  N50           length such that Feature of that length or longer
+                hold at least half of the bases
+  MaxLength     longest Feature
+  Plus          Feature on the + strand
+  Minus         Feature on the - strand
+  Unstranded    Feature with a strand of . or ?
+
+--out-tsv writes one line per group with a Level column of all, seqid
+or group (for SeqId x Type x Source) and . for the columns that do not
+apply to the level. --attributes-tsv writes how many Feature have each
+Attribute key. --out-json writes all of the statistics, including the
+Attribute tallies, as a single JSON object.
+
+Start and End are read as 1-based closed unless the GFF3 has a
+##format header that says otherwise. The per-SeqId Count, Bases and
+Covered are also written to the log.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		cmdh.StartLogging()
 		gff3StatsCmdRun(cmd, args)
@@ -25,37 +55,70 @@ func init() {
 
 	gff3StatsCmd.Flags().StringVar(&flagInfile, "gff3", "",
 		"GFF3 file")
-	gff3MergeCmd.MarkFlagRequired("gff3")
+	gff3StatsCmd.MarkFlagRequired("gff3")
+	gff3StatsCmd.Flags().StringVar(&flagOutfile, "out-tsv", "",
+		"statistics by SeqId, Type and Source - TSV format")
+	gff3StatsCmd.Flags().StringVar(&flagOutfileJson, "out-json", "",
+		"all statistics - JSON format")
+	gff3StatsCmd.Flags().StringVar(&flagAttributesTsv, "attributes-tsv", "",
+		"Attribute key tallies - TSV format")
+	addSeqOrderFlag(gff3StatsCmd)
 }
 
 func gff3StatsCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
 	log.Info("reading: ", flagInfile)
-	g, err := gff3.NewFromFile(flagInfile)
+	fs, _, err := readGff3FeaturesClosed(flagInfile)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Info("Total number of features: ", g.FeatureCount())
+	fs.SeqOrder = order
+
+	s := fs.Stats()
+	log.Info("Total number of features: ", s.All.Count)
 	log.Info("Sequences:")
-	log.Info("  Name\tCount\tSumIntvl\tConsIntvl\tIsSorted")
-	seqs := g.Features.BySeqId()
-	seqids := g.SeqIds()
-	var totalSi, totalSci int
-	for _, seqid := range seqids {
-		fs := seqs[seqid]
-		sorted := fs.IsSorted
-		fs.Sort() // This is a requirement for consolidation
-		si := fs.SumIntervals()
-		sci, err := fs.SumConsolidatedIntervals()
+	log.Info("  Name\tCount\tBases\tCovered")
+	for _, g := range s.BySeqId {
+		log.Infof("  %s\t%d\t%d\t%d", g.SeqId, g.Count, g.Bases, g.Covered)
+	}
+	log.Infof("  Totals\t%d\t%d\t%d", s.All.Count, s.All.Bases, s.All.Covered)
+
+	if flagOutfile != "" {
+		if err = writeLines(flagOutfile, s.TsvLines()); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("writing complete: %s", flagOutfile)
+	}
+	if flagAttributesTsv != "" {
+		if err = writeLines(flagAttributesTsv, s.AttributeTsvLines()); err != nil {
+			log.Fatal(err)
+		}
+		log.Infof("writing complete: %s", flagAttributesTsv)
+	}
+	if flagOutfileJson != "" {
+		j, err := json.MarshalIndent(s, "", "  ")
 		if err != nil {
-			log.Warning(err)
+			log.Fatal(err)
+		}
+		if err = os.WriteFile(flagOutfileJson, append(j, '\n'), 0644); err != nil {
+			log.Fatal(err)
 		}
-		log.Infof("  %s\t%d\t%d\t%d\t%t", seqid,
-			fs.Count(),
-			si,
-			sci,
-			sorted)
-		totalSi += si
-		totalSci += sci
+		log.Infof("writing complete: %s", flagOutfileJson)
 	}
-	log.Infof("  Totals\t%d\t%d\t%d", g.FeatureCount, totalSi, totalSci)
+}
+
+// writeLines writes lines of text to a file.
+func writeLines(file string, lines []string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	defer w.Flush()
+
+	_, err = w.WriteString(strings.Join(lines, "\n") + "\n")
+	return err
 }
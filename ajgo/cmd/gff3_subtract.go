@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > subtract
+var gff3SubtractCmd = &cobra.Command{
+	Use:   "subtract",
+	Short: "remove the parts of Feature that overlap other GFF3s",
+	Long: `
+Read a GFF3 file and one or more "with" GFF3 files and write out the
+parts of each Feature from the first GFF3 that are NOT covered by any
+Feature in the "with" GFF3s. A Feature with a covered section in the
+middle is split into two Feature and a Feature that is completely
+covered is dropped. Each output Feature keeps the SeqId, Source, Type,
+Strand and Attributes of the original Feature.
+
+Feature that meet, e.g. 100-199 and 200-299, do not overlap.
+Start and End are read and written as described in ajgo gff3 --help.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3SetOpCmdRun(`subtract`, (*gff3.Features).Subtract)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3SubtractCmd)
+
+	gff3SubtractCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file with Feature to be subtracted from")
+	gff3SubtractCmd.MarkFlagRequired("gff3")
+
+	gff3SubtractCmd.Flags().StringSliceVar(&flagWithGff3Files, "with-gff3", []string{},
+		"GFF3 files to subtract")
+	gff3SubtractCmd.MarkFlagRequired("with-gff3")
+
+	gff3SubtractCmd.Flags().StringVar(&flagOutfile, "out-gff3", "",
+		"output file in GFF3")
+	gff3SubtractCmd.MarkFlagRequired("out-gff3")
+	addSeqOrderFlag(gff3SubtractCmd)
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"ajgo/bed"
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > to-bed
+var gff3ToBedCmd = &cobra.Command{
+	Use:   "to-bed",
+	Short: "convert a GFF3 to BED",
+	Long: `
+Read a GFF3 file and write each Feature out as a BED6 record with the
+SeqId as chrom, the value of the first of the --name-attr Attributes
+that the Feature has as name, and the Score and Strand.
+
+GFF3 coordinates are 1-based closed and BED coordinates are 0-based
+half-open so a Feature 1-100 is written as 0-100. If the GFF3 has a
+##format header (e.g. "##format 1-based half-open" from older versions
+of ajgo) that coordinate system is used instead of the GFF3 spec. The
+BED starts with a "#format 0-based half-open" header so the
+convention is recorded in the file.
+
+See also bed > to-gff3.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		gff3ToBedCmdRun(cmd, args)
+		cmdh.FinishLogging()
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3ToBedCmd)
+
+	gff3ToBedCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file to be converted")
+	gff3ToBedCmd.MarkFlagRequired("gff3")
+
+	gff3ToBedCmd.Flags().StringVar(&flagOutfile, "out-bed", "",
+		"output file in BED")
+	gff3ToBedCmd.MarkFlagRequired("out-bed")
+
+	gff3ToBedCmd.Flags().StringSliceVar(&flagNameAttrs, "name-attr", []string{`Name`, `ID`},
+		"Attributes to use for the BED name, in order of preference")
+	addSeqOrderFlag(gff3ToBedCmd)
+}
+
+func gff3ToBedCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
+
+	log.Info("reading GFF3: ", flagInfile)
+	fs, fh, err := readGff3Features(flagInfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sys, err := gff3.CoordSystemFromHeaders(fh)
+	if err != nil {
+		log.Fatalf("%s: %v", flagInfile, err)
+	}
+	log.Infof("  this GFF3 file contains %d features from %d SeqIds",
+		fs.Count(), len(fs.SeqIds()))
+	log.Info("  coordinate system: ", sys)
+	if order != nil {
+		fs.SetSeqOrder(order)
+	}
+
+	w, err := bed.NewWriterToFile(flagOutfile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	w.AddHeaders("##created-by ajgo mode: gff3 > to-bed")
+	w.AddHeaders(gffHeadersFromRunParameters()...)
+	w.AddHeaders(seqOrderHeaders(order)...)
+	w.AddHeaders("##input-gff3-file " + flagInfile)
+	for _, f := range fs.Features {
+		if err = w.Write(bed.FromFeature(f, sys, flagNameAttrs)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Info("Number of records written: ", w.Count())
+	log.Infof("writing complete: %s", flagOutfile)
+}
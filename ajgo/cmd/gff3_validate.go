@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+
+	"ajgo/gff3"
+
+	"github.com/grendeloz/cmdh"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// submode gff3 > validate
+var gff3ValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "check a GFF3 against the spec",
+	Long: `
+Read a GFF3 file and check each Feature against the GFF3 spec. Every
+problem is reported with the line number of the offending record.
+The checks are:
+
+  Code                     Severity  Check
+  unparseable-line         ERROR     line could not be parsed as a Feature
+  start-after-end          ERROR     Start is greater than End
+  illegal-strand           ERROR     Strand is not one of + - . ?
+  illegal-phase            ERROR     Phase is not one of 0 1 2 .
+  cds-missing-phase        ERROR     CDS Feature has a Phase of .
+  duplicate-id             ERROR     ID reused by a Feature that is not
+                                     part of the same discontiguous
+                                     Feature (same SeqId, Type, Strand)
+  unknown-parent           ERROR     Parent does not match any ID
+  outside-sequence-region  ERROR     Feature extends outside the
+                                     ##sequence-region for its SeqId
+  no-sequence-region       WARNING   file has ##sequence-region
+                                     directives but none for this SeqId
+
+Problems are written to the log in human-readable form and, if --out-tsv
+is specified, to a tab-separated file with columns LineNumber, Severity,
+Code, SeqId, ID and Message.
+
+The exit code is set by the most severe problem found:
+
+  0 - no problems
+  1 - warnings only
+  2 - at least one error
+  3 - validation could not be done, e.g. the GFF3 could not be read
+      or the --out-tsv file could not be written`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cmdh.StartLogging()
+		sev, err := gff3ValidateCmdRun(cmd, args)
+		if err != nil {
+			log.Error(err)
+			cmdh.FinishLogging()
+			os.Exit(validateExitFailed)
+		}
+		cmdh.FinishLogging()
+		os.Exit(int(sev))
+	},
+}
+
+func init() {
+	gff3Cmd.AddCommand(gff3ValidateCmd)
+
+	gff3ValidateCmd.Flags().StringVar(&flagInfile, "gff3", "",
+		"GFF3 file to be validated")
+	gff3ValidateCmd.MarkFlagRequired("gff3")
+
+	gff3ValidateCmd.Flags().StringVar(&flagOutfile, "out-tsv", "",
+		"tab-separated report of problems")
+}
+
+// validateExitFailed is the exit code when validation could not be
+// done. It is distinct from the gff3.Severity exit codes so a CI gate
+// does not mistake a missing file for a file with warnings.
+const validateExitFailed = 3
+
+// gff3ValidateCmdRun returns the most severe problem found or an error
+// if the GFF3 could not be validated.
+func gff3ValidateCmdRun(cmd *cobra.Command, args []string) (gff3.Severity, error) {
+	log.Info("reading GFF3: ", flagInfile)
+	r, err := gff3.NewReaderFromFile(flagInfile)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	// Feature are validated as they are read so the file is never
+	// held in memory.
+	v := gff3.NewValidator(r.Header)
+	var count int
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var pe *gff3.ParseError
+		if errors.As(err, &pe) {
+			v.AddParseError(pe)
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		v.Add(f)
+		count++
+	}
+	problems := v.Finish()
+	log.Info("Number of Features checked: ", count)
+
+	tally := make(map[gff3.Severity]int)
+	for _, p := range problems {
+		tally[p.Severity]++
+		if p.Severity == gff3.SeverityError {
+			log.Error(p)
+		} else {
+			log.Warn(p)
+		}
+	}
+	log.Info("Number of errors: ", tally[gff3.SeverityError])
+	log.Info("Number of warnings: ", tally[gff3.SeverityWarning])
+
+	if flagOutfile != "" {
+		err = writeProblemsTsv(problems, flagOutfile)
+		if err != nil {
+			return 0, err
+		}
+		log.Infof("writing complete: %s", flagOutfile)
+	}
+
+	return gff3.MaxSeverity(problems), nil
+}
+
+func writeProblemsTsv(problems []*gff3.Problem, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	_, err = w.WriteString(gff3.ProblemsTsvHeader() + "\n")
+	if err != nil {
+		return err
+	}
+	for _, p := range problems {
+		_, err = w.WriteString(p.TsvString() + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
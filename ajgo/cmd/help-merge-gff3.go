@@ -29,9 +29,12 @@ format. The columns are:
  8  Phase
  9  Attributes
 
-Note that Start and End assume that the coordinate system is 1-based,
+Note that the merge logic assumes that the coordinate system is 1-based
 and half-open, i.e. the Start position is within the feature but the End
-position is the first base past the feature.
+position is the first base past the feature. The GFF3 spec is 1-based
+and closed so gff3 > merge converts Feature to half-open as they are
+read (using the ##format header if there is one) and back to closed as
+they are written.
 
 In general, merging GFF3 records must, at a minimum, take account of the
 SeqID and the Start and End columns. In some cases additional logic may
@@ -164,7 +167,30 @@ Relationship 8, AIsContainedByB would result in 3 intervals post-merge.
 The first (8-11) and third (18-20) intervals were in interval B but did
 not overlap with A whereas the second interval (11-18) shows the overlap
 which is the entirety of interval A because it was entirely contained
-within interval B.`,
+within interval B.
+
+Merging many intervals
+
+gff3 > merge does not merge pairs of intervals one at a time. Instead
+it sweeps along each sequence once, across all of the GFF3 files at
+the same time, and every time an interval starts or ends it writes out
+an interval for the positions since the last change. Positions covered
+by a single interval get a copy of that interval and positions covered
+by two or more intervals get an overlap interval whose Sources, Types
+and IDs Attributes list all of the intervals that cover them. This is
+exactly what repeated prudent merging of pairs would produce but it
+only needs to look at each interval once.
+
+Adjacency, gaps and minimum length
+
+gff3 > merge can also join intervals that meet (--merge-adjacent) or
+that have up to N positions between them (--max-gap N). Because the
+sub-intervals from prudent merging always meet, joining collapses a
+run of prudent sub-intervals back into one interval, i.e. it becomes
+simple merging with adjacent intervals merged. The joined interval
+lists all of its intervals in Sources, Types and IDs and the number of
+intervals in Joined. --min-length N drops merged intervals shorter
+than N positions.`,
 }
 
 func init() {
@@ -8,35 +8,58 @@ var selectorCmd = &cobra.Command{
 	Use:   "selector",
 	Short: "help on selectors and their use",
 	Long: `
-A Selector is a string of the form operation:subject:pattern which can
-be used to select/filter records in the ajgo system. For example, valid
-selectors that can be used against GFF3 records include:
+A Selector is an expression that is used to select/filter records in
+the ajgo system. Each record is tested against the expression and is
+kept if the expression is true and dropped if it is false. For
+example, valid selectors that can be used against GFF3 records include:
 
-  keep:seqid:^GL
-  delete:type:.*_UTR
+  seqid ~ ^GL
+  not type ~ .*_UTR
+  attr.biotype == protein_coding and length >= 100
+  (type == gene or type == pseudogene) and attr.gene_id in @genes.txt
 
-In general, the effect of all selectors is to drop records from some
-data structure. Selectors with delete operations drop any record that
-matches the pattern while keep operations drop any record that does
-not match the pattern - in both cases records are dropped.
+A condition is subject operator value and the operators are:
 
-As a general rule, a keep operation is a blunter tool than a delete
-operation because every record you wish to keep must match the keep
-pattern. Because delete only drops matching records, multiple delete 
-selectors, applied sequentially, with tight patterns can be used to
-selectively prune away records that you don't wish to retain.
+  ~  !~          regex matches, regex does not match
+  == !=          string equal, not equal
+  <  <= >  >=    numeric comparison
+  in             set membership - either @file where the file has one
+                 value per line, or a list such as [X, Y, MT]
+
+Conditions can be combined with and, or and not (or &&, || and !) and
+grouped with brackets. not binds tighter than and, which binds tighter
+than or. Values can be quoted with ' or " and must be quoted if they
+contain whitespace or any of ( ) [ ] , < > = ! ~ & | - this is often
+the case for regex patterns. Remember that the whole expression will
+usually also need to be quoted on the command line.
+
+A subject that has multiple values (e.g. a GFF3 Attribute such as
+tag=basic,CCDS) passes a test if any one of its values passes and !~
+and != are true only if no value matches. A subject with no value
+(e.g. a missing Attribute) fails every test except !~ and !=. A value
+that is not a number fails every numeric comparison.
+
+The original three-part form operation:subject:pattern is still
+accepted as a shorthand where the operation is keep or delete and the
+pattern is a regex, e.g.:
+
+  keep:seqid:^GL              same as   seqid ~ ^GL
+  delete:type:.*_UTR          same as   not type ~ .*_UTR
+
+The colon character ':' must not be used in the subject or operation
+of a three-part selector - it is reserved as a separator.
 
 In cases where multiple selectors are allowed, they are applied
-sequentially in the order in which they appeared on the command line.
+sequentially in the order in which they appeared on the command line
+so a record must survive all of them, i.e. multiple selectors are the
+same as a single expression with the selectors joined by and.
 
-Every ajgo mode that uses selectors has its own list of valid operations
-and subjects but pattern is always a regex. The colon character ':' must
-not be used in the subject, operation or pattern of a selector - it is
-strictly reserved as a separator for the selector. ajgo will exit with
-an error if an invalid subject or operation is specified. Selectors are
-used in modes including:
+Every ajgo mode that uses selectors has its own list of valid subjects.
+ajgo will exit with an error if an invalid subject or operation is
+specified. Selectors are used in modes including:
 
-  ajgo genemodel select`,
+  ajgo genemodel ensembl-gff3 select
+  ajgo genome select`,
 }
 
 func init() {
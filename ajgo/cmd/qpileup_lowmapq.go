@@ -11,6 +11,8 @@
 package cmd
 
 import (
+	"ajgo/coords"
+	"ajgo/gff3"
 	"ajgo/qpv1"
 	"bufio"
 	"compress/gzip"
@@ -68,9 +70,11 @@ func init() {
 	qpileupLowmapqCmd.Flags().StringVar(&flagOutfile, "gff3", "",
 		"output GFF3 file containing regions")
 	qpileupLowmapqCmd.MarkFlagRequired("gff3")
+	addSeqOrderFlag(qpileupLowmapqCmd)
 }
 
 func qpileupLowmapqCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
 
 	// log key parameters
 	log.Infof("  --threshold %v", flagThresholdy)
@@ -98,7 +102,7 @@ func qpileupLowmapqCmdRun(cmd *cobra.Command, args []string) {
 	}
 	log.Info("Number of view files checked: ", len(ViewFiles))
 
-	err = ProcessLowmapq(ViewFiles)
+	err = ProcessLowmapq(ViewFiles, order)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -107,8 +111,9 @@ func qpileupLowmapqCmdRun(cmd *cobra.Command, args []string) {
 // ProcessLowmapq is a beast and way bigger than I'd like but there it is.
 // Because the files are so huge and there are potentially so many regions,
 // we can't afford to save up "hits" and report at the end - we must report
-// as we go.
-func ProcessLowmapq(files []string) error {
+// as we go. The exception is if the SeqIds have to be reordered in which
+// case the regions (but not the hits) are held until the end.
+func ProcessLowmapq(files []string, order *gff3.SeqOrder) error {
 	// Open GFF3 files for reporting
 	log.Info("writing low-mapq GFF3 file: ", flagOutfile)
 	of, err := os.Create(flagOutfile)
@@ -118,13 +123,18 @@ func ProcessLowmapq(files []string) error {
 	defer of.Close()
 	ow := bufio.NewWriter(of)
 	defer ow.Flush()
+	rw := newSeqOrderWriter(ow, order)
 
 	// Write GFF3 header including report files
 	header := "##gff-version 3\n"
 	header += "##content low average mapping quality report from qpileup view file(s)\n"
 	header += "##threshold " + strconv.Itoa(flagThresholdy) + "\n"
 	header += "##region-min " + strconv.Itoa(flagRegionLength) + "\n"
+	header += gff3.FormatHeader(coords.OneBasedClosed) + "\n"
 	header += gffHeaderFromRunParameters()
+	for _, h := range seqOrderHeaders(order) {
+		header += h + "\n"
+	}
 	_, err = ow.WriteString(header)
 	if err != nil {
 		return err
@@ -318,7 +328,7 @@ func ProcessLowmapq(files []string) error {
 					if pos-lowMapQStart > flagRegionLength {
 						rctr++
 						_, err =
-							ow.WriteString(makeLowMapqGffRecord(fields[qpv1.Reference],
+							rw.WriteString(makeLowMapqGffRecord(fields[qpv1.Reference],
 								lowMapQStart, pos, rctr, lowMapQTotal) + "\n")
 						if err != nil {
 							return err
@@ -355,7 +365,7 @@ func ProcessLowmapq(files []string) error {
 					if pos-lowMapQStart > flagRegionLength {
 						rctr++
 						_, err =
-							ow.WriteString(makeLowMapqGffRecord(fields[qpv1.Reference],
+							rw.WriteString(makeLowMapqGffRecord(fields[qpv1.Reference],
 								lowMapQStart, pos, rctr, lowMapQTotal) + "\n")
 						if err != nil {
 							return err
@@ -385,10 +395,13 @@ func ProcessLowmapq(files []string) error {
 				return fmt.Errorf("error converting Position: %s",
 					fields[qpv1.Position])
 			}
+			// The last position is part of the region so the first
+			// position past the region is one further on
+			pos++
 			if pos-lowMapQStart > flagRegionLength {
 				rctr++
 				_, err =
-					ow.WriteString(makeLowMapqGffRecord(fields[qpv1.Reference],
+					rw.WriteString(makeLowMapqGffRecord(fields[qpv1.Reference],
 						lowMapQStart, pos, rctr, lowMapQTotal) + "\n")
 				if err != nil {
 					return err
@@ -401,6 +414,12 @@ func ProcessLowmapq(files []string) error {
 		}
 	}
 
+	if order != nil {
+		if err = rw.Flush(); err != nil {
+			return err
+		}
+	}
+
 	// Log Mapq tallys
 	var sorted []int
 	var total int
@@ -418,12 +437,10 @@ func ProcessLowmapq(files []string) error {
 	return nil
 }
 
-// Note that what is passed in is the current base in the sequence, not
-// the start - it's only when process the first base PAST the
-// homopolymer that we know it has ended. So we need to do the math
-// based on the current location and the repeat length and remembering
-// that we are using half-open numbering and that GFF3 coordinates are
-// supposed to be is 1-based and we are working from a 0-based loop.
+// Note that high is the first position past the region - it's only
+// when we process that position that we know the region has ended. So
+// low-high is 1-based half-open and the GFF3 End, which is 1-based
+// closed, is high-1.
 func makeLowMapqGffRecord(seq string, low, high, ctr, mapqTotal int) string {
 	avgmapq := mapqTotal / (high - low)
 	gff3fields := []string{
@@ -431,7 +448,7 @@ func makeLowMapqGffRecord(seq string, low, high, ctr, mapqTotal int) string {
 		`ajgo:low-mapq`,
 		`remark`,
 		strconv.Itoa(low),
-		strconv.Itoa(high),
+		strconv.Itoa(high - 1),
 		`.`,
 		`.`,
 		`.`,
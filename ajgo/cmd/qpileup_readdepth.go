@@ -11,6 +11,8 @@
 package cmd
 
 import (
+	"ajgo/coords"
+	"ajgo/gff3"
 	"ajgo/qpv1"
 	"bufio"
 	"compress/gzip"
@@ -75,9 +77,11 @@ func init() {
 
 	qpileupReaddepthCmd.Flags().StringVar(&flagOutfile, "gff3", "",
 		"output GFF3 file containing regions")
+	addSeqOrderFlag(qpileupReaddepthCmd)
 }
 
 func qpileupReaddepthCmdRun(cmd *cobra.Command, args []string) {
+	order := mustSeqOrderFromFlag()
 
 	// One and only one of --above and --below
 	if flagAbove && flagBelow {
@@ -118,7 +122,7 @@ func qpileupReaddepthCmdRun(cmd *cobra.Command, args []string) {
 	}
 	log.Info("Number of view files checked: ", len(ViewFiles))
 
-	err = ProcessReaddepth(ViewFiles)
+	err = ProcessReaddepth(ViewFiles, order)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -128,7 +132,7 @@ func qpileupReaddepthCmdRun(cmd *cobra.Command, args []string) {
 // Because the files are so huge and there are potentially so many regions,
 // we can't afford to save up "hits" and report at the end - we must report
 // as we go.
-func ProcessReaddepth(files []string) error {
+func ProcessReaddepth(files []string, order *gff3.SeqOrder) error {
 	// Open GFF3 files for reporting
 	log.Info("writing read-depth GFF3 file: ", flagOutfile)
 	of, err := os.Create(flagOutfile)
@@ -138,6 +142,7 @@ func ProcessReaddepth(files []string) error {
 	defer of.Close()
 	ow := bufio.NewWriter(of)
 	defer ow.Flush()
+	rw := newSeqOrderWriter(ow, order)
 
 	// Setup our test function
 	testFunc := func(rd int) bool { return float64(rd/flagBamCount) > float64(flagThreshold) }
@@ -155,7 +160,11 @@ func ProcessReaddepth(files []string) error {
 	header += "##threshold " + strconv.Itoa(flagThreshold) + "\n"
 	header += "##region-min " + strconv.Itoa(flagRegionLength) + "\n"
 	header += "##bam-count " + strconv.Itoa(flagBamCount) + "\n"
+	header += gff3.FormatHeader(coords.OneBasedClosed) + "\n"
 	header += gffHeaderFromRunParameters()
+	for _, h := range seqOrderHeaders(order) {
+		header += h + "\n"
+	}
 	_, err = ow.WriteString(header)
 	if err != nil {
 		return err
@@ -310,7 +319,7 @@ func ProcessReaddepth(files []string) error {
 					if pos-regionStart > flagRegionLength {
 						rctr++
 						_, err =
-							ow.WriteString(makeReaddepthGffRecord(fields[qpv1.Reference],
+							rw.WriteString(makeReaddepthGffRecord(fields[qpv1.Reference],
 								regionStart, pos, rctr, regionDepth) + "\n")
 						if err != nil {
 							return err
@@ -345,7 +354,7 @@ func ProcessReaddepth(files []string) error {
 					if pos-regionStart > flagRegionLength {
 						rctr++
 						_, err =
-							ow.WriteString(makeReaddepthGffRecord(fields[qpv1.Reference],
+							rw.WriteString(makeReaddepthGffRecord(fields[qpv1.Reference],
 								regionStart, pos, rctr, regionDepth) + "\n")
 						if err != nil {
 							return err
@@ -375,10 +384,13 @@ func ProcessReaddepth(files []string) error {
 				return fmt.Errorf("error converting Position: %s",
 					fields[qpv1.Position])
 			}
+			// The last position is part of the region so the first
+			// position past the region is one further on
+			pos++
 			if pos-regionStart > flagRegionLength {
 				rctr++
 				_, err =
-					ow.WriteString(makeReaddepthGffRecord(fields[qpv1.Reference],
+					rw.WriteString(makeReaddepthGffRecord(fields[qpv1.Reference],
 						regionStart, pos, rctr, regionDepth) + "\n")
 				if err != nil {
 					return err
@@ -391,11 +403,18 @@ func ProcessReaddepth(files []string) error {
 		}
 	}
 
+	if order != nil {
+		if err = rw.Flush(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// Remember that we are using half-open numbering and that GFF3 coords
-// are supposed to be 1-based and we are working from a 0-based loop.
+// Note that high is the first position past the region so low-high is
+// 1-based half-open and the GFF3 End, which is 1-based closed, is
+// high-1.
 func makeReaddepthGffRecord(seq string, low, high, ctr, depth int) string {
 	test := ""
 	if flagAbove {
@@ -413,7 +432,7 @@ func makeReaddepthGffRecord(seq string, low, high, ctr, depth int) string {
 		`ajgo:read-depth`,
 		`remark`,
 		strconv.Itoa(low),
-		strconv.Itoa(high),
+		strconv.Itoa(high - 1),
 		`.`,
 		`.`,
 		`.`,
@@ -7,10 +7,16 @@ import (
 	"github.com/google/uuid"
 	"io"
 	"os"
+	"regexp"
+	"sort"
 	"strings"
 
+	"ajgo/gff3"
+
 	"github.com/grendeloz/cmdh"
+	"github.com/grendeloz/ngs/genome"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
 )
 
 // md5sum returns the MD5 hash of a file.  The MD5 provides a signature
@@ -117,3 +123,117 @@ func reverseBytes(b []byte) []byte {
 	}
 	return b
 }
+
+// addSeqOrderFlag adds the --seq-order flag to a GFF3-writing command.
+func addSeqOrderFlag(c *cobra.Command) {
+	c.Flags().StringVar(&flagSeqOrder, "seq-order", "",
+		"SeqId order: lexical, natural, ajgo serialised genome or .dict file")
+}
+
+// seqOrderFromFlag returns the *gff3.SeqOrder for --seq-order. If the
+// flag was not set it returns nil and the command should keep its
+// usual ordering.
+func seqOrderFromFlag() (*gff3.SeqOrder, error) {
+	switch flagSeqOrder {
+	case ``:
+		return nil, nil
+	case `lexical`:
+		return gff3.LexicalSeqOrder(), nil
+	case `natural`:
+		return gff3.NaturalSeqOrder(), nil
+	}
+
+	found, err := regexp.MatchString(`\.dict$`, flagSeqOrder)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return gff3.SeqOrderFromDict(flagSeqOrder)
+	}
+	g, err := genome.GenomeFromGob(flagSeqOrder)
+	if err != nil {
+		return nil, fmt.Errorf("--seq-order %s is not lexical, natural, a .dict file or an ajgo serialised genome: %w",
+			flagSeqOrder, err)
+	}
+	return gff3.SeqOrderFromGenome(g), nil
+}
+
+// mustSeqOrderFromFlag calls seqOrderFromFlag and exits on error.
+// It also logs the ordering if one was set.
+func mustSeqOrderFromFlag() *gff3.SeqOrder {
+	o, err := seqOrderFromFlag()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if o != nil {
+		log.Info("SeqId ordering: ", o)
+	}
+	return o
+}
+
+// seqOrderHeaders returns the header lines that record the SeqId
+// ordering, if one was set.
+func seqOrderHeaders(o *gff3.SeqOrder) []string {
+	if o == nil {
+		return nil
+	}
+	return []string{"##seq-order " + o.String()}
+}
+
+// orderedSequences returns the sequences in the order set by a
+// *gff3.SeqOrder. A nil SeqOrder leaves the sequences in genome order.
+func orderedSequences(seqs []*genome.Sequence, o *gff3.SeqOrder) []*genome.Sequence {
+	if o == nil {
+		return seqs
+	}
+	sorted := append([]*genome.Sequence(nil), seqs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return o.Less(sorted[i].Name, sorted[j].Name)
+	})
+	return sorted
+}
+
+// seqOrderWriter sits in front of a *bufio.Writer for modes that write
+// GFF3 records as text. With a nil SeqOrder, records go straight to the
+// bufio.Writer. Otherwise records are held in memory, grouped by SeqId,
+// until Flush writes them out in SeqOrder.
+type seqOrderWriter struct {
+	w       *bufio.Writer
+	order   *gff3.SeqOrder
+	records map[string][]string
+}
+
+func newSeqOrderWriter(w *bufio.Writer, o *gff3.SeqOrder) *seqOrderWriter {
+	return &seqOrderWriter{w: w, order: o, records: make(map[string][]string)}
+}
+
+// WriteString writes (or holds) a single GFF3 record.
+func (s *seqOrderWriter) WriteString(rec string) (int, error) {
+	if s.order == nil {
+		return s.w.WriteString(rec)
+	}
+	seqid := rec
+	if i := strings.Index(rec, "\t"); i >= 0 {
+		seqid = rec[:i]
+	}
+	s.records[seqid] = append(s.records[seqid], rec)
+	return len(rec), nil
+}
+
+// Flush writes any held records in SeqOrder.
+func (s *seqOrderWriter) Flush() error {
+	var seqids []string
+	for seqid := range s.records {
+		seqids = append(seqids, seqid)
+	}
+	s.order.Sort(seqids)
+	for _, seqid := range seqids {
+		for _, rec := range s.records[seqid] {
+			if _, err := s.w.WriteString(rec); err != nil {
+				return err
+			}
+		}
+	}
+	s.records = make(map[string][]string)
+	return nil
+}
@@ -0,0 +1,145 @@
+package coords
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Genomic file formats disagree on how to number positions. GFF3, GTF,
+// SAM and VCF are 1-based and closed - the first base of a sequence is
+// 1 and the End is the last base in the interval. BED and BAM are
+// 0-based and half-open - the first base is 0 and the End is the first
+// base after the interval. So the first 100 bases of a sequence are
+// 1-100 in GFF3 and 0-100 in BED.
+//
+// System describes one of the four possible combinations and Convert
+// moves a Start/End pair between them. Files written by ajgo record the
+// System they use in a header line, e.g. "##format 1-based closed" in a
+// GFF3 or "#format 0-based half-open" in a BED.
+
+// System is a coordinate system. The zero value is 1-based closed,
+// the system used by the GFF3 spec.
+type System struct {
+	ZeroBased bool
+	HalfOpen  bool
+}
+
+// The four coordinate systems.
+var (
+	OneBasedClosed    = System{}
+	OneBasedHalfOpen  = System{HalfOpen: true}
+	ZeroBasedClosed   = System{ZeroBased: true}
+	ZeroBasedHalfOpen = System{ZeroBased: true, HalfOpen: true}
+)
+
+// String gives the System in the form used in headers, e.g.
+// "1-based closed".
+func (s System) String() string {
+	base := `1-based`
+	if s.ZeroBased {
+		base = `0-based`
+	}
+	end := `closed`
+	if s.HalfOpen {
+		end = `half-open`
+	}
+	return base + ` ` + end
+}
+
+// Parse is the inverse of String. It is case-insensitive and accepts
+// "half open" and "halfopen" as well as "half-open".
+func Parse(s string) (System, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 3 && fields[1] == `half` && fields[2] == `open` {
+		fields = []string{fields[0], `half-open`}
+	}
+	if len(fields) != 2 {
+		return System{}, fmt.Errorf("coordinate system not recognised: %s", s)
+	}
+
+	var sys System
+	switch fields[0] {
+	case `1-based`:
+	case `0-based`:
+		sys.ZeroBased = true
+	default:
+		return System{}, fmt.Errorf("coordinate system not recognised: %s", s)
+	}
+	switch fields[1] {
+	case `closed`:
+	case `half-open`, `halfopen`:
+		sys.HalfOpen = true
+	default:
+		return System{}, fmt.Errorf("coordinate system not recognised: %s", s)
+	}
+	return sys, nil
+}
+
+// Convert takes a Start and End in System s and returns them in System
+// to. Converting 1-100 from 1-based closed to 0-based half-open gives
+// 0-100.
+func (s System) Convert(start, end int, to System) (int, int) {
+	// Go via 0-based half-open
+	if !s.ZeroBased {
+		start--
+		end--
+	}
+	if !s.HalfOpen {
+		end++
+	}
+	if !to.ZeroBased {
+		start++
+		end++
+	}
+	if !to.HalfOpen {
+		end--
+	}
+	return start, end
+}
+
+// Length returns the number of bases in the interval start-end.
+func (s System) Length(start, end int) int {
+	if s.HalfOpen {
+		return end - start
+	}
+	return end - start + 1
+}
+
+// FormatHeader returns the header line that records System s, e.g.
+// "##format 1-based closed" for a prefix of ## (GFF3) or "#format
+// 0-based half-open" for a prefix of # (BED).
+func FormatHeader(prefix string, s System) string {
+	return prefix + `format ` + s.String()
+}
+
+// IsFormatHeader returns true if line is a format header as written by
+// FormatHeader. The line must start with prefix but any further # are
+// allowed so a BED prefix of # also finds a GFF3-style ##format.
+func IsFormatHeader(prefix, line string) bool {
+	_, ok := formatValue(prefix, line)
+	return ok
+}
+
+// FromHeaders returns the System recorded in the first format header
+// in headers. If there is no format header, it returns def, the System
+// given by the spec of the file format.
+func FromHeaders(prefix string, headers []string, def System) (System, error) {
+	for _, h := range headers {
+		if v, ok := formatValue(prefix, h); ok {
+			return Parse(v)
+		}
+	}
+	return def, nil
+}
+
+// formatValue returns the value of a format header.
+func formatValue(prefix, line string) (string, bool) {
+	if !strings.HasPrefix(line, prefix) {
+		return ``, false
+	}
+	fields := strings.Fields(strings.TrimLeft(line, `#`))
+	if len(fields) == 0 || fields[0] != `format` {
+		return ``, false
+	}
+	return strings.Join(fields[1:], ` `), true
+}
@@ -0,0 +1,93 @@
+package coords
+
+import "testing"
+
+func TestParseString(t *testing.T) {
+	var tests = []struct {
+		s   string
+		sys System
+		ok  bool
+	}{
+		{`1-based closed`, OneBasedClosed, true},
+		{`1-based half-open`, OneBasedHalfOpen, true},
+		{`0-based closed`, ZeroBasedClosed, true},
+		{`0-based half-open`, ZeroBasedHalfOpen, true},
+		{`0-Based  Half Open`, ZeroBasedHalfOpen, true},
+		{`1-based halfopen`, OneBasedHalfOpen, true},
+		{`2-based closed`, System{}, false},
+		{`1-based`, System{}, false},
+		{``, System{}, false},
+	}
+
+	for _, tt := range tests {
+		sys, err := Parse(tt.s)
+		if (err == nil) != tt.ok {
+			t.Fatalf("Parse(%q) error should be %v but is %v", tt.s, !tt.ok, err)
+		}
+		if sys != tt.sys {
+			t.Fatalf("Parse(%q) should be %v but is %v", tt.s, tt.sys, sys)
+		}
+		if !tt.ok {
+			continue
+		}
+		if back, _ := Parse(sys.String()); back != sys {
+			t.Fatalf("Parse(%q) did not round-trip: %v", sys.String(), back)
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	// The first 100 bases of a sequence in each System
+	spans := map[System][2]int{
+		OneBasedClosed:    {1, 100},
+		OneBasedHalfOpen:  {1, 101},
+		ZeroBasedClosed:   {0, 99},
+		ZeroBasedHalfOpen: {0, 100},
+	}
+
+	for from, fs := range spans {
+		for to, ts := range spans {
+			start, end := from.Convert(fs[0], fs[1], to)
+			if start != ts[0] || end != ts[1] {
+				t.Fatalf("%v %d-%d to %v should be %d-%d but is %d-%d",
+					from, fs[0], fs[1], to, ts[0], ts[1], start, end)
+			}
+		}
+		if l := from.Length(fs[0], fs[1]); l != 100 {
+			t.Fatalf("%v Length(%d, %d) should be 100 but is %d", from, fs[0], fs[1], l)
+		}
+	}
+}
+
+func TestFromHeaders(t *testing.T) {
+	var tests = []struct {
+		prefix  string
+		headers []string
+		sys     System
+		ok      bool
+	}{
+		{`##`, []string{`##gff-version 3`}, OneBasedClosed, true},
+		{`##`, []string{`##gff-version 3`, `##format 1-based half-open`}, OneBasedHalfOpen, true},
+		{`##`, []string{`##gff-version 3`, FormatHeader(`##`, ZeroBasedHalfOpen)}, ZeroBasedHalfOpen, true},
+		{`##`, []string{`##gff-version 3`, `#format 0-based half-open`}, OneBasedClosed, true},
+		{`##`, []string{`##gff-version 3`, `##format-0 1-based half-open`}, OneBasedClosed, true},
+		{`##`, []string{`##gff-version 3`, `##format sideways`}, OneBasedClosed, false},
+		{`#`, []string{`track name=x`}, OneBasedClosed, true},
+		{`#`, []string{FormatHeader(`#`, ZeroBasedClosed)}, ZeroBasedClosed, true},
+		{`#`, []string{`##format 1-based half-open`}, OneBasedHalfOpen, true},
+	}
+
+	for _, tt := range tests {
+		sys, err := FromHeaders(tt.prefix, tt.headers, OneBasedClosed)
+		if (err == nil) != tt.ok {
+			t.Fatalf("FromHeaders(%q, %q) error should be %v but is %v", tt.prefix, tt.headers, !tt.ok, err)
+		}
+		if tt.ok && sys != tt.sys {
+			t.Fatalf("FromHeaders(%q, %q) should be %v but is %v", tt.prefix, tt.headers, tt.sys, sys)
+		}
+	}
+
+	if !IsFormatHeader(`#`, `##format 1-based closed`) || IsFormatHeader(`##`, `#format 1-based closed`) {
+		t.Fatalf("IsFormatHeader should allow extra # but not fewer")
+	}
+}
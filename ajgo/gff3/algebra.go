@@ -0,0 +1,249 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"ajgo/coords"
+)
+
+// Region set algebra on Features. These operations treat Features as
+// sets of genomic positions, for example when combining the GFF3s from
+// genome n-regions, genome homopolymer, qpileup low-mapq and qpileup
+// read-depth into a single uncallable mask.
+//
+// Start and End are treated as a half-open interval, the same as
+// Consolidate, PrudentMerge and Merger do, so a Feature 100-200 and a
+// Feature 200-300 meet but do not overlap. GFF3s are
+// 1-based closed so Feature should be converted to 1-based half-open
+// with Features.ConvertCoords before they are combined and converted
+// back afterwards.
+//
+// Apart from Complement, each operation returns new Feature that are
+// Clones of the Feature in the receiver so Attributes are preserved and
+// neither input is modified. The output is in the same order as the
+// receiver.
+
+// Intersect returns the parts of each Feature in fs that are covered
+// by any Feature in other. A Feature that is partly covered in several
+// places will be split into several Feature.
+func (fs *Features) Intersect(other *Features) *Features {
+	x := NewIndex(other)
+	nfs := NewFeatures()
+	for _, f := range fs.Features {
+		for _, span := range coveredSpans(x, f) {
+			nf := f.Clone()
+			nf.Start = span[0]
+			nf.End = span[1]
+			nfs.Features = append(nfs.Features, nf)
+		}
+	}
+	return nfs
+}
+
+// Subtract returns the parts of each Feature in fs that are not covered
+// by any Feature in other. A Feature that has a covered section in the
+// middle will be split into two Feature and a Feature that is completely
+// covered will be dropped.
+func (fs *Features) Subtract(other *Features) *Features {
+	x := NewIndex(other)
+	nfs := NewFeatures()
+	for _, f := range fs.Features {
+		start := f.Start
+		for _, span := range coveredSpans(x, f) {
+			if span[0] > start {
+				nf := f.Clone()
+				nf.Start = start
+				nf.End = span[0]
+				nfs.Features = append(nfs.Features, nf)
+			}
+			start = span[1]
+		}
+		if start < f.End {
+			nf := f.Clone()
+			nf.Start = start
+			nf.End = f.End
+			nfs.Features = append(nfs.Features, nf)
+		}
+	}
+	return nfs
+}
+
+// Complement returns new Feature covering every part of the supplied
+// sequence regions that is not covered by a Feature in fs. Regions are
+// 1-based closed as per ##sequence-region so the sequence region 1 1 100
+// is the half-open interval 1-101. The new Feature are in the same order
+// as regions and have Source ajgo:complement. Feature on SeqIds that are
+// not in regions are ignored.
+func (fs *Features) Complement(regions []*SequenceRegion) *Features {
+	spans := make(map[string][][2]int)
+	for seqid, sfs := range fs.BySeqId() {
+		spans[seqid] = unionSpans(sfs.Features)
+	}
+
+	nfs := NewFeatures()
+	ctr := 0
+	add := func(seqid string, start, end int) {
+		ctr++
+		nf := NewFeature()
+		nf.SeqId = seqid
+		nf.Source = `ajgo:complement`
+		nf.Start = start
+		nf.End = end
+		nf.Attributes[`ID`] = `complement` + strconv.Itoa(ctr)
+		nfs.Features = append(nfs.Features, nf)
+	}
+	for _, r := range regions {
+		start, end := r.Start, r.End+1
+		for _, span := range spans[r.SeqId] {
+			if span[1] <= start {
+				continue
+			}
+			if span[0] >= end {
+				break
+			}
+			if span[0] > start {
+				add(r.SeqId, start, span[0])
+			}
+			start = span[1]
+		}
+		if start < end {
+			add(r.SeqId, start, end)
+		}
+	}
+	return nfs
+}
+
+// Closest returns a Clone of each Feature in fs with two new Attributes
+// describing the closest Feature in other. Closest holds the ID of the
+// closest Feature (or SeqId:Start-End if it has no ID) and
+// ClosestDistance is 0 for overlapping Feature, 1 for Feature that meet,
+// and so on. If there are ties, Closest lists all of them. Feature on
+// SeqIds with no Feature in other do not get the new Attributes.
+// Strand is ignored.
+//
+// labels is the coordinate system that SeqId:Start-End is given in,
+// e.g. coords.OneBasedClosed if the result will be converted back to
+// 1-based closed and written as a GFF3, so the label matches the
+// Feature in the GFF3 it came from.
+func (fs *Features) Closest(other *Features, labels coords.System) *Features {
+	x := NewIndex(other)
+	nfs := NewFeatures()
+	for _, f := range fs.Features {
+		nf := f.Clone()
+		nfs.Features = append(nfs.Features, nf)
+
+		closest, dist := closestFeatures(x, f)
+		if len(closest) == 0 {
+			continue
+		}
+		var ids []string
+		for _, c := range closest {
+			l := &Feature{SeqId: c.SeqId, Attributes: c.Attributes}
+			l.Start, l.End = coords.OneBasedHalfOpen.Convert(c.Start, c.End, labels)
+			ids = append(ids, featureLabel(l))
+		}
+		nf.SetAttributeValues(`Closest`, ids...)
+		nf.Attributes[`ClosestDistance`] = strconv.Itoa(dist)
+	}
+	return nfs
+}
+
+// overlappingHalfOpen returns the Feature in the Index that overlap the
+// half-open interval start-end. Index works in closed coordinates so
+// the query is narrowed by one base at each end.
+func overlappingHalfOpen(x *Index, seqid string, start, end int) []*Feature {
+	return x.Overlapping(seqid, start+1, end-1)
+}
+
+// coveredSpans returns the non-overlapping spans within f that are
+// covered by Feature in the Index, in Start order.
+func coveredSpans(x *Index, f *Feature) [][2]int {
+	hits := overlappingHalfOpen(x, f.SeqId, f.Start, f.End)
+	var spans [][2]int
+	for _, span := range unionSpans(hits) {
+		if span[0] < f.Start {
+			span[0] = f.Start
+		}
+		if span[1] > f.End {
+			span[1] = f.End
+		}
+		if span[0] < span[1] {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// unionSpans returns the union of the Feature as a sorted list of
+// disjoint half-open spans. Feature that meet are joined. The Feature
+// must all have the same SeqId.
+func unionSpans(fs []*Feature) [][2]int {
+	sorted := append([]*Feature(nil), fs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+	var spans [][2]int
+	for _, f := range sorted {
+		last := len(spans) - 1
+		if last >= 0 && f.Start <= spans[last][1] {
+			if f.End > spans[last][1] {
+				spans[last][1] = f.End
+			}
+			continue
+		}
+		spans = append(spans, [2]int{f.Start, f.End})
+	}
+	return spans
+}
+
+// closestFeatures returns the Feature in the Index that are closest to
+// f and the distance to them. Overlapping Feature have distance 0.
+func closestFeatures(x *Index, f *Feature) ([]*Feature, int) {
+	si, ok := x.seqs[f.SeqId]
+	if !ok {
+		return nil, 0
+	}
+	if hits := overlappingHalfOpen(x, f.SeqId, f.Start, f.End); len(hits) > 0 {
+		return hits, 0
+	}
+
+	best := -1
+	var found []*Feature
+
+	// Feature that end at or before f.Start, closest last
+	i := sort.Search(len(si.byEnd), func(i int) bool { return si.byEnd[i].End > f.Start })
+	for i--; i >= 0; i-- {
+		d := f.Start - si.byEnd[i].End + 1
+		if best >= 0 && d > best {
+			break
+		}
+		best = d
+		found = append([]*Feature{si.byEnd[i]}, found...)
+	}
+
+	// Feature that start at or after f.End, closest first
+	j := sort.Search(len(si.byStart), func(i int) bool { return si.byStart[i].Start >= f.End })
+	for ; j < len(si.byStart); j++ {
+		d := si.byStart[j].Start - f.End + 1
+		if best >= 0 && d > best {
+			break
+		}
+		if best < 0 || d < best {
+			best = d
+			found = nil
+		}
+		found = append(found, si.byStart[j])
+	}
+	return found, best
+}
+
+// featureLabel returns the ID of a Feature or, if it has no ID, its
+// location.
+func featureLabel(f *Feature) string {
+	if id, ok := f.Attributes[`ID`]; ok && id != `` {
+		return id
+	}
+	return fmt.Sprintf("%s:%d-%d", f.SeqId, f.Start, f.End)
+}
@@ -0,0 +1,83 @@
+package gff3
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"ajgo/coords"
+)
+
+func featuresFromText(t *testing.T, text string) *Features {
+	g, err := NewFromScanner(bufio.NewScanner(strings.NewReader("##gff-version 3\n" + text)))
+	if err != nil {
+		t.Fatalf("NewFromScanner failed: %v", err)
+	}
+	return g.Features
+}
+
+func spansString(fs *Features) string {
+	var s []string
+	for _, f := range fs.Features {
+		s = append(s, featureLabel(&Feature{SeqId: f.SeqId, Start: f.Start, End: f.End}))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestAlgebra(t *testing.T) {
+	a := featuresFromText(t,
+		"1\tajgo\tgene\t100\t200\t.\t+\t.\tID=a1\n"+
+			"1\tajgo\tgene\t300\t400\t.\t+\t.\tID=a2\n"+
+			"2\tajgo\tgene\t100\t200\t.\t+\t.\tID=a3\n")
+	b := featuresFromText(t,
+		"1\tajgo\tN_region\t120\t140\t.\t.\t.\tID=b1\n"+
+			"1\tajgo\tN_region\t130\t150\t.\t.\t.\tID=b2\n"+
+			"1\tajgo\tN_region\t190\t310\t.\t.\t.\tID=b3\n"+
+			"1\tajgo\tN_region\t400\t450\t.\t.\t.\tID=b4\n")
+
+	var tests = []struct {
+		name string
+		fs   *Features
+		e    string
+	}{
+		{`Intersect`, a.Intersect(b), `1:120-150 1:190-200 1:300-310`},
+		{`Subtract`, a.Subtract(b), `1:100-120 1:150-190 1:310-400 2:100-200`},
+		{`Complement`, b.Complement([]*SequenceRegion{
+			{SeqId: `1`, Start: 1, End: 500},
+			{SeqId: `2`, Start: 1, End: 50}}),
+			`1:1-120 1:150-190 1:310-400 1:450-501 2:1-51`},
+	}
+	for _, tt := range tests {
+		g := spansString(tt.fs)
+		if tt.e != g {
+			t.Fatalf("%s should be %q but is %q", tt.name, tt.e, g)
+		}
+	}
+
+	// Attributes are kept
+	e1 := `a1`
+	g1 := a.Intersect(b).Features[0].Attributes[`ID`]
+	if e1 != g1 {
+		t.Fatalf("Intersect ID should be %s but is %s", e1, g1)
+	}
+
+	c := a.Closest(b.Subtract(featuresFromText(t, "1\tajgo\tgene\t300\t400\t.\t.\t.\tID=x\n")), coords.OneBasedHalfOpen)
+	var closest []string
+	for _, f := range c.Features {
+		closest = append(closest, f.Attributes[`Closest`]+`/`+f.Attributes[`ClosestDistance`])
+	}
+	e2 := `b1,b2,b3/0 b3,b4/1 /`
+	g2 := strings.Join(closest, ` `)
+	if e2 != g2 {
+		t.Fatalf("Closest should be %q but is %q", e2, g2)
+	}
+
+	// A Feature without an ID is labelled in the coordinate system asked for
+	noId := featuresFromText(t, "1\tajgo\tgene\t250\t300\t.\t.\t.\tName=y\n")
+	if g3 := a.Closest(noId, coords.OneBasedHalfOpen).Features[0].Attributes[`Closest`]; g3 != `1:250-300` {
+		t.Fatalf("Closest should be 1:250-300 but is %s", g3)
+	}
+	if g4 := a.Closest(noId, coords.OneBasedClosed).Features[0].Attributes[`Closest`]; g4 != `1:250-299` {
+		t.Fatalf("Closest should be 1:250-299 but is %s", g4)
+	}
+}
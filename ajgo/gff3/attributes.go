@@ -0,0 +1,218 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Column 9 of a GFF3 record holds the Attributes. The GFF3 spec says
+// that the characters ; = & , tab, newline, carriage return, % and any
+// control characters have reserved meanings in column 9 and must be
+// percent-encoded (URL escaped) when they appear in a value.  An
+// Attribute may hold multiple values separated by unescaped commas, as
+// is commonly seen for Parent, Dbxref, Alias and Ontology_term.
+//
+// Values in Feature.Attributes are stored percent-decoded with one
+// exception - an escaped comma (%2C) is left escaped. This lets a
+// comma in a stored value keep its spec meaning as a separator between
+// multiple values while still being able to carry a literal comma
+// inside a single value. Use AttributeValues and SetAttributeValues
+// when you want the individual, fully-decoded values.
+//
+// To allow an unmodified GFF3 to round-trip byte-for-byte, each Feature
+// remembers the order of its Attributes and the exact encoded text of
+// each value as it was read. When the Feature is written, any Attribute
+// whose value has not changed is written exactly as it was read, and in
+// the original order. Attributes added after reading are written after
+// the original Attributes, sorted by key.
+//
+// There are two exceptions to the byte-for-byte round-trip. Empty
+// Attributes, including the trailing ; that many files have, are not
+// kept so they are not written. And as Feature.Attributes is a map, a
+// key that appears more than once in column 9 (which the spec does not
+// allow - use multiple values instead) keeps only its last value,
+// which is written where the key first appeared.
+
+// rawAttr records an Attribute exactly as it appeared in column 9.
+type rawAttr struct {
+	Key   string
+	Value string // still percent-encoded
+}
+
+// The GFF3 multi-value separator and its escaped form.
+const (
+	attrSep        = `,`
+	attrSepEscaped = `%2C`
+)
+
+// AttributeValues returns the individual values of a (potentially)
+// multi-valued Attribute, fully decoded. It returns nil if the
+// Attribute is not present.
+func (f *Feature) AttributeValues(key string) []string {
+	v, ok := f.Attributes[key]
+	if !ok {
+		return nil
+	}
+	vals := strings.Split(v, attrSep)
+	for i := range vals {
+		vals[i] = unescapeComma(vals[i])
+	}
+	return vals
+}
+
+// SetAttributeValues sets an Attribute to one or more values. Any
+// commas within the values are escaped so they will not be mistaken
+// for value separators.
+func (f *Feature) SetAttributeValues(key string, vals ...string) {
+	escaped := make([]string, len(vals))
+	for i, v := range vals {
+		escaped[i] = strings.ReplaceAll(v, attrSep, attrSepEscaped)
+	}
+	if f.Attributes == nil {
+		f.Attributes = make(map[string]string)
+	}
+	f.Attributes[key] = strings.Join(escaped, attrSep)
+}
+
+// AddAttributeValue adds a value to a multi-valued Attribute unless the
+// value is already present.
+func (f *Feature) AddAttributeValue(key, val string) {
+	vals := f.AttributeValues(key)
+	for _, v := range vals {
+		if v == val {
+			return
+		}
+	}
+	f.SetAttributeValues(key, append(vals, val)...)
+}
+
+// Parents returns the values of the Parent Attribute.
+func (f *Feature) Parents() []string {
+	return f.AttributeValues(`Parent`)
+}
+
+// Dbxrefs returns the values of the Dbxref Attribute.
+func (f *Feature) Dbxrefs() []string {
+	return f.AttributeValues(`Dbxref`)
+}
+
+// Aliases returns the values of the Alias Attribute.
+func (f *Feature) Aliases() []string {
+	return f.AttributeValues(`Alias`)
+}
+
+// OntologyTerms returns the values of the Ontology_term Attribute.
+func (f *Feature) OntologyTerms() []string {
+	return f.AttributeValues(`Ontology_term`)
+}
+
+// AttributeKeys returns the keys of the Attributes in the order they
+// will be written - original order first and then any added keys sorted.
+func (f *Feature) AttributeKeys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, ra := range f.attrRaw {
+		if _, ok := f.Attributes[ra.Key]; ok && !seen[ra.Key] {
+			keys = append(keys, ra.Key)
+			seen[ra.Key] = true
+		}
+	}
+	var added []string
+	for k := range f.Attributes {
+		if !seen[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+	return append(keys, added...)
+}
+
+// attributeString returns the encoded key=value string for a single
+// Attribute. If the value is unchanged since the Feature was read, the
+// original text is used.
+func (f *Feature) attributeString(key string) string {
+	v := f.Attributes[key]
+	for _, ra := range f.attrRaw {
+		if ra.Key == key {
+			if decodeAttribute(ra.Value) == v {
+				return key + `=` + ra.Value
+			}
+			break
+		}
+	}
+	return key + `=` + encodeAttribute(v)
+}
+
+// unescapeComma turns %2C back into a comma.
+func unescapeComma(s string) string {
+	if !strings.Contains(s, `%`) {
+		return s
+	}
+	s = strings.ReplaceAll(s, attrSepEscaped, attrSep)
+	return strings.ReplaceAll(s, `%2c`, attrSep)
+}
+
+// decodeAttribute percent-decodes a column 9 value except for %2C
+// which is left escaped - see the discussion at the top of this file.
+// Malformed escapes are left as-is.
+func decodeAttribute(s string) string {
+	if !strings.Contains(s, `%`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if c != ',' {
+				b.WriteByte(c)
+				i += 2
+				continue
+			}
+			// Keep %2C escaped but normalise the case
+			b.WriteString(attrSepEscaped)
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// encodeAttribute percent-encodes the reserved characters in a value.
+// Commas are not encoded because they separate multiple values and an
+// existing %2C is not re-encoded because it is an escaped comma.
+func encodeAttribute(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%':
+			if strings.HasPrefix(s[i:], attrSepEscaped) || strings.HasPrefix(s[i:], `%2c`) {
+				b.WriteByte(c)
+			} else {
+				b.WriteString(`%25`)
+			}
+		case c == ';' || c == '=' || c == '&' || c < 0x20 || c == 0x7f:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,137 @@
+package gff3
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAttributesDecoding(t *testing.T) {
+	line := "1\tensembl\tgene\t1\t10\t.\t+\t.\tID=g1;Note=a%3Bb%3Dc%09d;Alias=x%2Cy,z;Parent=t1,t2"
+	f, err := NewFeatureFromLine(line)
+	if err != nil {
+		t.Fatalf("Error calling NewFeatureFromLine on %s: %v", line, err)
+	}
+
+	e1 := "a;b=c\td"
+	g1 := f.Attributes[`Note`]
+	if e1 != g1 {
+		t.Fatalf("Note should be %q but is %q", e1, g1)
+	}
+
+	// Escaped comma stays escaped in the map ...
+	e2 := `x%2Cy,z`
+	g2 := f.Attributes[`Alias`]
+	if e2 != g2 {
+		t.Fatalf("Alias should be %q but is %q", e2, g2)
+	}
+
+	// ... but not in the values
+	g3 := f.Aliases()
+	if len(g3) != 2 || g3[0] != `x,y` || g3[1] != `z` {
+		t.Fatalf("Aliases should be [x,y z] but is %v", g3)
+	}
+
+	g4 := f.Parents()
+	if len(g4) != 2 || g4[0] != `t1` || g4[1] != `t2` {
+		t.Fatalf("Parents should be [t1 t2] but is %v", g4)
+	}
+
+	if f.Dbxrefs() != nil {
+		t.Fatalf("Dbxrefs should be nil but is %v", f.Dbxrefs())
+	}
+
+	// Unchanged Feature round-trips exactly
+	if line != f.String() {
+		t.Fatalf("Feature should round-trip as\n%s\nbut is\n%s", line, f.String())
+	}
+}
+
+func TestAttributesEncoding(t *testing.T) {
+	line := "1\tensembl\tgene\t1\t10\t.\t+\t.\tID=g1;Parent=t1"
+	f, err := NewFeatureFromLine(line)
+	if err != nil {
+		t.Fatalf("Error calling NewFeatureFromLine on %s: %v", line, err)
+	}
+
+	f.Attributes[`Note`] = "50% a;b"
+	f.AddAttributeValue(`Parent`, `t2`)
+	f.AddAttributeValue(`Parent`, `t1`)
+	f.SetAttributeValues(`Dbxref`, `a,b`, `c`)
+
+	e1 := "ID=g1;Parent=t1,t2;Dbxref=a%2Cb,c;Note=50%25 a%3Bb"
+	g1 := f.AttributesString()
+	if e1 != g1 {
+		t.Fatalf("AttributesString should be %q but is %q", e1, g1)
+	}
+
+	// And the encoded string decodes back to the same values
+	nf, err := NewFeatureFromLine(f.String())
+	if err != nil {
+		t.Fatalf("Error calling NewFeatureFromLine on %s: %v", f.String(), err)
+	}
+	problems := HelperCompareFeatures(f, nf)
+	if problems != "" {
+		t.Fatal(problems)
+	}
+}
+
+func TestAttributesRoundTrip(t *testing.T) {
+	f1 := `testdata/test1.gff3.gz`
+	ff, err := os.Open(f1)
+	if err != nil {
+		t.Fatalf("error opening %s: %v", f1, err)
+	}
+	defer ff.Close()
+	gz, err := gzip.NewReader(ff)
+	if err != nil {
+		t.Fatalf("error opening %s: %v", f1, err)
+	}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, `#`) {
+			continue
+		}
+		f, err := NewFeatureFromLine(line)
+		if err != nil {
+			t.Fatalf("Error calling NewFeatureFromLine on %s: %v", line, err)
+		}
+		if line != f.String() {
+			t.Fatalf("Feature should round-trip as\n%s\nbut is\n%s", line, f.String())
+		}
+		if line != f.Clone().String() {
+			t.Fatalf("Clone'd Feature should round-trip as\n%s\nbut is\n%s", line, f.Clone().String())
+		}
+	}
+}
+
+func TestAttributesRoundTripExceptions(t *testing.T) {
+	// A trailing ; (and any other empty Attribute) is not kept
+	line := "1\tensembl\tgene\t1\t10\t.\t+\t.\tID=g1;;Name=n%3B1;"
+	f, err := NewFeatureFromLine(line)
+	if err != nil {
+		t.Fatalf("Error calling NewFeatureFromLine on %s: %v", line, err)
+	}
+	e1 := "1\tensembl\tgene\t1\t10\t.\t+\t.\tID=g1;Name=n%3B1"
+	if e1 != f.String() {
+		t.Fatalf("Feature should be written as\n%s\nbut is\n%s", e1, f.String())
+	}
+
+	// A repeated key keeps the last value, as read, where the key
+	// first appeared
+	line = "1\tensembl\tgene\t1\t10\t.\t+\t.\tNote=a;ID=g1;Note=b%3Bc"
+	f, err = NewFeatureFromLine(line)
+	if err != nil {
+		t.Fatalf("Error calling NewFeatureFromLine on %s: %v", line, err)
+	}
+	if g := f.Attributes[`Note`]; g != `b;c` {
+		t.Fatalf("Note should be %q but is %q", `b;c`, g)
+	}
+	e2 := "1\tensembl\tgene\t1\t10\t.\t+\t.\tNote=b%3Bc;ID=g1"
+	if e2 != f.String() {
+		t.Fatalf("Feature should be written as\n%s\nbut is\n%s", e2, f.String())
+	}
+}
@@ -0,0 +1,213 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// For reporting it is often necessary to pick a single representative
+// transcript for each gene. Tree.Canonical does this by applying a
+// policy - a list of criteria that are tried in order. Each criterion
+// narrows the candidate transcripts and the first criterion that leaves
+// a single candidate is the reason that transcript was chosen. A
+// criterion that no candidate satisfies (e.g. none of the transcripts
+// are tagged Ensembl_canonical) is skipped. The criteria are:
+//
+//   ensembl-canonical   tag Attribute includes Ensembl_canonical
+//   mane-select         tag Attribute includes MANE_Select (Ensembl,
+//                       GENCODE) or MANE Select (RefSeq)
+//   longest-cds         greatest total length of CDS
+//   longest-transcript  greatest total length of exons, or of the
+//                       transcript itself if it has no exons
+//
+// If candidates are still tied after all criteria, the transcript with
+// the lowest ID is chosen and the reason is first-id. A gene with a
+// single transcript has the reason only-transcript. Genes are found as
+// for Features.ByAttrIdGene and the transcripts of a gene are its child
+// TreeNodes.
+
+// Canonical transcript criteria.
+const (
+	CanonicalEnsembl           = `ensembl-canonical`
+	CanonicalManeSelect        = `mane-select`
+	CanonicalLongestCds        = `longest-cds`
+	CanonicalLongestTranscript = `longest-transcript`
+
+	// Reasons that are not criteria
+	CanonicalOnlyTranscript = `only-transcript`
+	CanonicalFirstId        = `first-id`
+)
+
+// DefaultCanonicalPolicy returns the default order of criteria.
+func DefaultCanonicalPolicy() []string {
+	return []string{CanonicalEnsembl, CanonicalManeSelect, CanonicalLongestCds, CanonicalLongestTranscript}
+}
+
+// CanonicalChoice records the transcript chosen for a gene and why.
+type CanonicalChoice struct {
+	Gene       *TreeNode
+	Transcript *TreeNode
+	Reason     string
+	Candidates int
+}
+
+// CanonicalTsvHeader is the header line for the TSV written by
+// TsvString.
+func CanonicalTsvHeader() string {
+	return strings.Join([]string{`GeneId`, `GeneName`, `TranscriptId`, `TranscriptName`,
+		`Reason`, `Candidates`, `CdsLength`, `TranscriptLength`}, "\t")
+}
+
+// TsvString gives a tab-separated version of a CanonicalChoice. See
+// CanonicalTsvHeader for the columns.
+func (c *CanonicalChoice) TsvString() string {
+	var geneName string
+	if !c.Gene.IsPhantom() {
+		geneName = c.Gene.Self[0].Attributes[`Name`]
+	}
+	return strings.Join([]string{
+		c.Gene.IdString,
+		geneName,
+		c.Transcript.IdString,
+		c.Transcript.Self[0].Attributes[`Name`],
+		c.Reason,
+		strconv.Itoa(c.Candidates),
+		strconv.Itoa(cdsLength(c.Transcript)),
+		strconv.Itoa(transcriptLength(c.Transcript))}, "\t")
+}
+
+// Features returns the gene Feature and all of the Feature of the
+// chosen transcript.
+func (c *CanonicalChoice) Features() []*Feature {
+	feats := append([]*Feature{}, c.Gene.Self...)
+	return append(feats, c.Transcript.Features()...)
+}
+
+// Canonical chooses one transcript for every gene in the Tree using the
+// criteria in policy, in order. The choices are in the order the genes
+// appeared in the Gff3 and genes without transcripts are skipped.
+func (t *Tree) Canonical(policy []string) ([]*CanonicalChoice, error) {
+	for _, p := range policy {
+		switch p {
+		case CanonicalEnsembl, CanonicalManeSelect, CanonicalLongestCds, CanonicalLongestTranscript:
+		default:
+			return nil, fmt.Errorf("Canonical: criterion not recognised: %s", p)
+		}
+	}
+
+	// Phantom genes (gene Feature missing) are checked after the rest
+	var choices []*CanonicalChoice
+	for _, g := range t.geneNodes() {
+		var txs []*TreeNode
+		for _, c := range g.ChildNodes {
+			if !c.IsPhantom() {
+				txs = append(txs, c)
+			}
+		}
+		if len(txs) == 0 {
+			continue
+		}
+		c := &CanonicalChoice{Gene: g, Candidates: len(txs)}
+		c.Transcript, c.Reason = chooseTranscript(txs, policy)
+		choices = append(choices, c)
+	}
+	return choices, nil
+}
+
+// CanonicalFeatures returns the Feature for a list of CanonicalChoice.
+func CanonicalFeatures(choices []*CanonicalChoice) *Features {
+	fs := NewFeatures()
+	for _, c := range choices {
+		fs.Features = append(fs.Features, c.Features()...)
+	}
+	return fs
+}
+
+// chooseTranscript applies the policy to a list of transcripts.
+func chooseTranscript(txs []*TreeNode, policy []string) (*TreeNode, string) {
+	if len(txs) == 1 {
+		return txs[0], CanonicalOnlyTranscript
+	}
+	for _, p := range policy {
+		var keep []*TreeNode
+		switch p {
+		case CanonicalEnsembl:
+			keep = transcriptsWithTag(txs, `Ensembl_canonical`)
+		case CanonicalManeSelect:
+			keep = transcriptsWithTag(txs, `MANE_Select`, `MANE Select`)
+		case CanonicalLongestCds:
+			keep = longestTranscripts(txs, cdsLength)
+		case CanonicalLongestTranscript:
+			keep = longestTranscripts(txs, transcriptLength)
+		}
+		if len(keep) == 1 {
+			return keep[0], p
+		}
+		if len(keep) > 1 {
+			txs = keep
+		}
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		return txs[i].IdString < txs[j].IdString
+	})
+	return txs[0], CanonicalFirstId
+}
+
+// transcriptsWithTag returns the transcripts that have any of the tags.
+func transcriptsWithTag(txs []*TreeNode, tags ...string) []*TreeNode {
+	var keep []*TreeNode
+	for _, tx := range txs {
+	next:
+		for _, v := range tx.Self[0].AttributeValues(`tag`) {
+			for _, tag := range tags {
+				if v == tag {
+					keep = append(keep, tx)
+					break next
+				}
+			}
+		}
+	}
+	return keep
+}
+
+// longestTranscripts returns the transcripts with the greatest length
+// or nil if every length is 0.
+func longestTranscripts(txs []*TreeNode, length func(*TreeNode) int) []*TreeNode {
+	var keep []*TreeNode
+	var best int
+	for _, tx := range txs {
+		l := length(tx)
+		switch {
+		case l == 0 || l < best:
+		case l > best:
+			best = l
+			keep = []*TreeNode{tx}
+		default:
+			keep = append(keep, tx)
+		}
+	}
+	return keep
+}
+
+func cdsLength(tx *TreeNode) int {
+	var l int
+	for _, f := range tx.Descendants(`CDS`) {
+		l += f.Length()
+	}
+	return l
+}
+
+func transcriptLength(tx *TreeNode) int {
+	var l int
+	for _, f := range tx.Descendants(`exon`) {
+		l += f.Length()
+	}
+	if l == 0 {
+		for _, f := range tx.Self {
+			l += f.Length()
+		}
+	}
+	return l
+}
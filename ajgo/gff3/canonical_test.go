@@ -0,0 +1,77 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tr := treeFromText(t,
+		// g1 - Ensembl_canonical wins over longer CDS
+		"1\tajgo\tgene\t100\t900\t.\t+\t.\tID=gene:g1;Name=ONE\n"+
+			"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=t1a;Parent=gene:g1;tag=basic,Ensembl_canonical\n"+
+			"1\tajgo\texon\t100\t200\t.\t+\t.\tParent=t1a\n"+
+			"1\tajgo\tCDS\t150\t200\t.\t+\t0\tID=c1a;Parent=t1a\n"+
+			"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=t1b;Parent=gene:g1;tag=basic\n"+
+			"1\tajgo\texon\t100\t900\t.\t+\t.\tParent=t1b\n"+
+			"1\tajgo\tCDS\t150\t800\t.\t+\t0\tID=c1b;Parent=t1b\n"+
+			// g2 - RefSeq MANE Select
+			"1\tajgo\tgene\t1000\t1900\t.\t+\t.\tID=gene-g2\n"+
+			"1\tajgo\tmRNA\t1000\t1900\t.\t+\t.\tID=rna-t2a;Parent=gene-g2\n"+
+			"1\tajgo\tmRNA\t1000\t1900\t.\t+\t.\tID=rna-t2b;Parent=gene-g2;tag=MANE Select\n"+
+			// g3 - longest CDS then longest transcript
+			"1\tajgo\tgene\t2000\t2900\t.\t+\t.\tID=gene:g3\n"+
+			"1\tajgo\tmRNA\t2000\t2900\t.\t+\t.\tID=t3a;Parent=gene:g3\n"+
+			"1\tajgo\texon\t2000\t2100\t.\t+\t.\tParent=t3a\n"+
+			"1\tajgo\tCDS\t2050\t2100\t.\t+\t0\tID=c3a;Parent=t3a\n"+
+			"1\tajgo\tmRNA\t2000\t2900\t.\t+\t.\tID=t3b;Parent=gene:g3\n"+
+			"1\tajgo\texon\t2000\t2200\t.\t+\t.\tParent=t3b\n"+
+			"1\tajgo\tCDS\t2050\t2100\t.\t+\t0\tID=c3b;Parent=t3b\n"+
+			"1\tajgo\tmRNA\t2000\t2900\t.\t+\t.\tID=t3c;Parent=gene:g3\n"+
+			"1\tajgo\texon\t2000\t2800\t.\t+\t.\tParent=t3c\n"+
+			// g4 - a tie
+			"1\tajgo\tgene\t3000\t3900\t.\t+\t.\tID=gene:g4\n"+
+			"1\tajgo\tmRNA\t3000\t3900\t.\t+\t.\tID=t4b;Parent=gene:g4\n"+
+			"1\tajgo\tmRNA\t3000\t3900\t.\t+\t.\tID=t4a;Parent=gene:g4\n"+
+			// g5 - only one transcript
+			"1\tajgo\tgene\t4000\t4900\t.\t+\t.\tID=gene:g5\n"+
+			"1\tajgo\tmRNA\t4000\t4900\t.\t+\t.\tID=t5;Parent=gene:g5\n")
+
+	choices, err := tr.Canonical(DefaultCanonicalPolicy())
+	if err != nil {
+		t.Fatalf("Canonical failed: %v", err)
+	}
+	var got []string
+	for _, c := range choices {
+		got = append(got, c.Transcript.IdString+":"+c.Reason)
+	}
+	e := []string{
+		`t1a:` + CanonicalEnsembl,
+		`rna-t2b:` + CanonicalManeSelect,
+		`t3b:` + CanonicalLongestTranscript,
+		`t4a:` + CanonicalFirstId,
+		`t5:` + CanonicalOnlyTranscript,
+	}
+	if strings.Join(got, " ") != strings.Join(e, " ") {
+		t.Fatalf("Canonical should choose %v but chose %v", e, got)
+	}
+
+	// A different policy changes the choice for g1
+	choices, err = tr.Canonical([]string{CanonicalLongestCds})
+	if err != nil {
+		t.Fatalf("Canonical failed: %v", err)
+	}
+	if g := choices[0].Transcript.IdString; g != `t1b` {
+		t.Fatalf("Canonical with longest-cds should choose t1b but chose %s", g)
+	}
+	if g := len(choices[0].Features()); g != 4 {
+		t.Fatalf("gene and transcript t1b should have 4 Feature but have %d", g)
+	}
+	if g := choices[0].TsvString(); g != "gene:g1\tONE\tt1b\t\tlongest-cds\t2\t651\t801" {
+		t.Fatalf("TsvString is wrong: %s", g)
+	}
+
+	if _, err = tr.Canonical([]string{`shortest`}); err == nil {
+		t.Fatalf("Canonical with unknown criterion should have failed")
+	}
+}
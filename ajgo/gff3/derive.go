@@ -0,0 +1,239 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A gene model GFF3 only contains some of the features that a
+// transcript implies. Introns, the splice sites at the exon/intron
+// boundaries and the promoter upstream of the transcription start site
+// are never listed, and UTRs are often left out because they can be
+// worked out from the exons and CDS. Tree.Derive creates these
+// features for every transcript in a Tree.
+//
+// A transcript is any TreeNode that has exon children. Derived Feature
+// have a Source of ajgo, a Parent Attribute that is the ID of their
+// transcript and no ID. They use Sequence Ontology Types:
+//
+//   intron                        gap between consecutive exons
+//   five_prime_UTR                exonic sequence before the CDS
+//   three_prime_UTR               exonic sequence after the CDS
+//   five_prime_cis_splice_site    donor - exon end / intron start
+//   three_prime_cis_splice_site   acceptor - intron end / exon start
+//   promoter                      window around the transcript start
+//
+// "Before" and "start" are strand-aware so on the - strand the
+// five_prime_UTR is at the high-coordinate end of the transcript.
+// Splice sites and promoters need to know the strand so they are not
+// derived for transcripts with a Strand of . or ?.
+
+// Derivations that can be requested in DeriveOptions.Derive.
+const (
+	DeriveIntrons     = `introns`
+	DeriveUtrs        = `utrs`
+	DeriveSpliceSites = `splice-sites`
+	DerivePromoters   = `promoters`
+)
+
+// DeriveOptions control Tree.Derive.
+type DeriveOptions struct {
+	// Derive lists the features to derive. See the Derive* constants.
+	Derive []string
+	// SpliceWidth is how far a splice site window extends into both
+	// the exon and the intron, so each window is 2*SpliceWidth long.
+	SpliceWidth int
+	// PromoterUpstream and PromoterDownstream set the promoter window
+	// relative to the transcription start site. The window includes
+	// the TSS and extends PromoterUpstream bases upstream and
+	// PromoterDownstream-1 bases downstream.
+	PromoterUpstream   int
+	PromoterDownstream int
+}
+
+// NewDeriveOptions returns DeriveOptions that derive everything with
+// a SpliceWidth of 2 (the canonical GT/AG dinucleotides and the last
+// and first 2 bases of the exons) and a promoter of 2000 bases upstream
+// and 200 bases downstream.
+func NewDeriveOptions() *DeriveOptions {
+	return &DeriveOptions{
+		Derive:             []string{DeriveIntrons, DeriveUtrs, DeriveSpliceSites, DerivePromoters},
+		SpliceWidth:        2,
+		PromoterUpstream:   2000,
+		PromoterDownstream: 200,
+	}
+}
+
+// check returns an error if the options are not usable.
+func (o *DeriveOptions) check() error {
+	for _, d := range o.Derive {
+		switch d {
+		case DeriveIntrons, DeriveUtrs, DeriveSpliceSites, DerivePromoters:
+		default:
+			return fmt.Errorf("derivation not recognised: %s", d)
+		}
+	}
+	if o.wants(DeriveSpliceSites) && o.SpliceWidth < 1 {
+		return fmt.Errorf("splice site width must be at least 1 but is %d", o.SpliceWidth)
+	}
+	if o.wants(DerivePromoters) && (o.PromoterUpstream < 0 || o.PromoterDownstream < 0 ||
+		o.PromoterUpstream+o.PromoterDownstream == 0) {
+		return fmt.Errorf("promoter window %d upstream and %d downstream is not valid",
+			o.PromoterUpstream, o.PromoterDownstream)
+	}
+	return nil
+}
+
+func (o *DeriveOptions) wants(d string) bool {
+	for _, x := range o.Derive {
+		if x == d {
+			return true
+		}
+	}
+	return false
+}
+
+// Derive creates introns, UTRs, splice sites and promoters for every
+// transcript in the Tree. UTRs are only derived for transcripts that
+// have CDS, and each of five_prime_UTR and three_prime_UTR is only
+// derived if the transcript does not already have children of that
+// type.
+// The derived Feature are returned sorted.
+func (t *Tree) Derive(o *DeriveOptions) (*Features, error) {
+	if err := o.check(); err != nil {
+		return nil, fmt.Errorf("Derive: %w", err)
+	}
+
+	// Every TreeNode is checked, not just those below Roots, so that
+	// transcripts whose gene is missing are not skipped.
+	fs := NewFeatures()
+	for _, id := range t.ids {
+		n := t.Nodes[id]
+		if exons := n.children(`exon`); len(exons) > 0 {
+			fs.Features = append(fs.Features, n.derive(exons, o)...)
+		}
+	}
+	fs.Sort()
+	return fs, nil
+}
+
+// children returns the Feature of a given Type that are direct children
+// of the TreeNode, sorted by Start. An empty typ returns the children of
+// every Type.
+func (n *TreeNode) children(typ string) []*Feature {
+	var fs []*Feature
+	for _, f := range n.ChildLeaves {
+		if typ == `` || f.Type == typ {
+			fs = append(fs, f)
+		}
+	}
+	for _, c := range n.ChildNodes {
+		for _, f := range c.Self {
+			if typ == `` || f.Type == typ {
+				fs = append(fs, f)
+			}
+		}
+	}
+	sort.SliceStable(fs, func(i, j int) bool {
+		return fs[i].Start < fs[j].Start
+	})
+	return fs
+}
+
+// derive does the work of Derive for a single transcript.
+func (n *TreeNode) derive(exons []*Feature, o *DeriveOptions) []*Feature {
+	tx := n.Self[0]
+	stranded := tx.Strand == `+` || tx.Strand == `-`
+	var fs []*Feature
+	add := func(typ string, start, end int) {
+		if start < 1 {
+			start = 1
+		}
+		if start > end {
+			return
+		}
+		fs = append(fs, &Feature{
+			SeqId:      tx.SeqId,
+			Source:     `ajgo`,
+			Type:       typ,
+			Start:      start,
+			End:        end,
+			Score:      `.`,
+			Strand:     tx.Strand,
+			Phase:      `.`,
+			Attributes: map[string]string{`Parent`: n.IdString},
+		})
+	}
+
+	for i := 1; i < len(exons); i++ {
+		start, end := exons[i-1].End+1, exons[i].Start-1
+		if start > end {
+			continue
+		}
+		if o.wants(DeriveIntrons) {
+			add(`intron`, start, end)
+		}
+		if o.wants(DeriveSpliceSites) && stranded {
+			w := o.SpliceWidth
+			donor, acceptor := `five_prime_cis_splice_site`, `three_prime_cis_splice_site`
+			if tx.Strand == `-` {
+				donor, acceptor = acceptor, donor
+			}
+			add(donor, start-w, start+w-1)
+			add(acceptor, end-w+1, end+w)
+		}
+	}
+
+	if o.wants(DeriveUtrs) {
+		cds := n.children(`CDS`)
+		if len(cds) > 0 {
+			cdsStart, cdsEnd := cds[0].Start, cds[0].End
+			for _, c := range cds {
+				if c.End > cdsEnd {
+					cdsEnd = c.End
+				}
+			}
+			low, high := `five_prime_UTR`, `three_prime_UTR`
+			if tx.Strand == `-` {
+				low, high = high, low
+			}
+			// Each end is only derived if the transcript does not
+			// already have explicit UTRs of that type.
+			wantLow := len(n.children(low)) == 0
+			wantHigh := len(n.children(high)) == 0
+			for _, e := range exons {
+				if wantLow && e.Start < cdsStart {
+					end := e.End
+					if end >= cdsStart {
+						end = cdsStart - 1
+					}
+					add(low, e.Start, end)
+				}
+				if wantHigh && e.End > cdsEnd {
+					start := e.Start
+					if start <= cdsEnd {
+						start = cdsEnd + 1
+					}
+					add(high, start, e.End)
+				}
+			}
+		}
+	}
+
+	if o.wants(DerivePromoters) && stranded {
+		if tx.Strand == `+` {
+			add(`promoter`, tx.Start-o.PromoterUpstream, tx.Start+o.PromoterDownstream-1)
+		} else {
+			add(`promoter`, tx.End-o.PromoterDownstream+1, tx.End+o.PromoterUpstream)
+		}
+	}
+
+	return fs
+}
+
+// DeriveNames returns the list of valid derivations for use in help
+// and error messages.
+func DeriveNames() string {
+	return strings.Join([]string{DeriveIntrons, DeriveUtrs, DeriveSpliceSites, DerivePromoters}, `,`)
+}
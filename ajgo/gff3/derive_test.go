@@ -0,0 +1,77 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDerive(t *testing.T) {
+	tr := treeFromText(t,
+		"1\tajgo\tgene\t1000\t2000\t.\t+\t.\tID=g1\n"+
+			"1\tajgo\tmRNA\t1000\t2000\t.\t+\t.\tID=t1;Parent=g1\n"+
+			"1\tajgo\texon\t1000\t1100\t.\t+\t.\tParent=t1\n"+
+			"1\tajgo\texon\t1500\t1600\t.\t+\t.\tParent=t1\n"+
+			"1\tajgo\texon\t1900\t2000\t.\t+\t.\tParent=t1\n"+
+			"1\tajgo\tCDS\t1050\t1100\t.\t+\t0\tID=c1;Parent=t1\n"+
+			"1\tajgo\tCDS\t1500\t1600\t.\t+\t0\tID=c1;Parent=t1\n"+
+			"1\tajgo\tCDS\t1900\t1950\t.\t+\t2\tID=c1;Parent=t1\n"+
+			"1\tajgo\tmRNA\t100\t500\t.\t-\t.\tID=t2;Parent=g2\n"+
+			"1\tajgo\texon\t100\t200\t.\t-\t.\tParent=t2\n"+
+			"1\tajgo\texon\t400\t500\t.\t-\t.\tParent=t2\n"+
+			"1\tajgo\tCDS\t150\t200\t.\t-\t0\tID=c2;Parent=t2\n"+
+			"1\tajgo\tCDS\t400\t450\t.\t-\t0\tID=c2;Parent=t2\n"+
+			"1\tajgo\tfive_prime_UTR\t451\t500\t.\t-\t.\tParent=t2\n")
+
+	o := NewDeriveOptions()
+	o.PromoterUpstream = 1000
+	o.PromoterDownstream = 100
+	fs, err := tr.Derive(o)
+	if err != nil {
+		t.Fatalf("Derive failed: %v", err)
+	}
+
+	var got []string
+	for _, f := range fs.Features {
+		got = append(got, f.Type+":"+featureLabel(f)+":"+f.Attributes[`Parent`])
+	}
+	e := []string{
+		// t1 promoter is clipped at 1, t2 is on the - strand so its
+		// promoter is above the TSS and only its 5' UTR is explicit
+		`promoter:1:1-1099:t1`,
+		`three_prime_UTR:1:100-149:t2`,
+		`three_prime_cis_splice_site:1:199-202:t2`,
+		`intron:1:201-399:t2`,
+		`five_prime_cis_splice_site:1:398-401:t2`,
+		`promoter:1:401-1500:t2`,
+		`five_prime_UTR:1:1000-1049:t1`,
+		`five_prime_cis_splice_site:1:1099-1102:t1`,
+		`intron:1:1101-1499:t1`,
+		`three_prime_cis_splice_site:1:1498-1501:t1`,
+		`five_prime_cis_splice_site:1:1599-1602:t1`,
+		`intron:1:1601-1899:t1`,
+		`three_prime_cis_splice_site:1:1898-1901:t1`,
+		`three_prime_UTR:1:1951-2000:t1`,
+	}
+	if strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Fatalf("Derive should give:\n%s\nbut gave:\n%s", strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+
+	o = &DeriveOptions{Derive: []string{DeriveIntrons}}
+	fs, err = tr.Derive(o)
+	if err != nil {
+		t.Fatalf("Derive introns failed: %v", err)
+	}
+	if fs.Count() != 3 {
+		t.Fatalf("Derive introns should give 3 Feature but gave %d", fs.Count())
+	}
+
+	for _, o := range []*DeriveOptions{
+		{Derive: []string{`exons`}},
+		{Derive: []string{DeriveSpliceSites}},
+		{Derive: []string{DerivePromoters}},
+	} {
+		if _, err = tr.Derive(o); err == nil {
+			t.Fatalf("Derive(%+v) should have failed", o)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package gff3
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Diff compares two releases of a gene model, e.g. consecutive Ensembl
+// releases, and reports what changed. The Feature of the old and new
+// Features are matched and each match is compared:
+//
+//   - Feature with an ID are matched by ID. A Feature whose ID is spread
+//     over several lines (e.g. CDS) is matched line by line - lines with
+//     the same Start and End first and then the remaining lines in
+//     order.
+//   - Feature without an ID (e.g. Ensembl exons) are matched by SeqId,
+//     Type, Start, End and Strand, preferring a Feature with the same
+//     Parent. Because their coordinates are part of the match, they are
+//     added or removed rather than moved.
+//
+// A matched Feature is moved if its SeqId, Start, End or Strand differ
+// and changed if any of the other fields or any Attribute differ. A
+// Feature can be both moved and changed and then has a DiffChange for
+// each. Feature that are not matched are added or removed.
+//
+// Each DiffChange records the gene the Feature belongs to (see
+// Features.ByAttrIdGene) so changes can be reported by gene - a change
+// to a transcript or exon is listed under its gene. Changes are
+// ordered by gene, in the order the genes appear in the new Features
+// followed by genes that were removed, and changes to Feature that are
+// not part of a gene come last.
+
+// Kinds of DiffChange.
+const (
+	DiffAdded   = `added`
+	DiffRemoved = `removed`
+	DiffMoved   = `moved`
+	DiffChanged = `changed`
+)
+
+// DiffChange is one difference between an old and new Feature.
+type DiffChange struct {
+	Kind    string
+	Gene    string   // ID of the gene the Feature is part of, if any
+	Old     *Feature // nil if added
+	New     *Feature // nil if removed
+	Details []string // what moved or changed, e.g. start=100>120
+}
+
+// DiffCount tallies the DiffChange kinds for a Type or a SeqId.
+// Unchanged counts the matched Feature that neither moved nor changed.
+type DiffCount struct {
+	By        string // type or seqid
+	Name      string
+	Added     int
+	Removed   int
+	Moved     int
+	Changed   int
+	Unchanged int
+}
+
+// DiffReport is the result of Diff.
+type DiffReport struct {
+	Changes []*DiffChange
+	ByType  []*DiffCount // sorted by Type
+	BySeqId []*DiffCount // sorted by the SeqOrder of the new release
+}
+
+// Diff compares the Feature of from, the old release, with those of to,
+// the new release. Neither is changed.
+func Diff(from, to *Features) *DiffReport {
+	pairs, removed, added := matchFeatures(from.Features, to.Features)
+
+	oldGenes := featureGenes(from.NewTree())
+	newTree := to.NewTree()
+	newGenes := featureGenes(newTree)
+
+	r := &DiffReport{}
+	byType := make(map[string]*DiffCount)
+	bySeqId := make(map[string]*DiffCount)
+	tally := func(f *Feature, kind string) {
+		for _, c := range []*DiffCount{diffCount(byType, `type`, f.Type), diffCount(bySeqId, `seqid`, f.SeqId)} {
+			switch kind {
+			case DiffAdded:
+				c.Added++
+			case DiffRemoved:
+				c.Removed++
+			case DiffMoved:
+				c.Moved++
+			case DiffChanged:
+				c.Changed++
+			default:
+				c.Unchanged++
+			}
+		}
+	}
+
+	for _, p := range pairs {
+		gene := newGenes[p[1]]
+		if gene == `` {
+			gene = oldGenes[p[0]]
+		}
+		moved, changed := compareFeatures(p[0], p[1])
+		if len(moved) > 0 {
+			r.Changes = append(r.Changes, &DiffChange{Kind: DiffMoved, Gene: gene, Old: p[0], New: p[1], Details: moved})
+			tally(p[1], DiffMoved)
+		}
+		if len(changed) > 0 {
+			r.Changes = append(r.Changes, &DiffChange{Kind: DiffChanged, Gene: gene, Old: p[0], New: p[1], Details: changed})
+			tally(p[1], DiffChanged)
+		}
+		if len(moved) == 0 && len(changed) == 0 {
+			tally(p[1], ``)
+		}
+	}
+	for _, f := range removed {
+		r.Changes = append(r.Changes, &DiffChange{Kind: DiffRemoved, Gene: oldGenes[f], Old: f})
+		tally(f, DiffRemoved)
+	}
+	for _, f := range added {
+		r.Changes = append(r.Changes, &DiffChange{Kind: DiffAdded, Gene: newGenes[f], New: f})
+		tally(f, DiffAdded)
+	}
+
+	// Order the changes by gene
+	rank := make(map[string]int)
+	for _, g := range newTree.geneNodes() {
+		rank[g.IdString] = len(rank) + 1
+	}
+	for _, c := range r.Changes {
+		if _, ok := rank[c.Gene]; !ok && c.Gene != `` {
+			rank[c.Gene] = len(rank) + 1
+		}
+	}
+	geneRank := func(g string) int {
+		if g == `` {
+			return len(rank) + 1
+		}
+		return rank[g]
+	}
+	sort.SliceStable(r.Changes, func(i, j int) bool {
+		return geneRank(r.Changes[i].Gene) < geneRank(r.Changes[j].Gene)
+	})
+
+	for _, c := range byType {
+		r.ByType = append(r.ByType, c)
+	}
+	sort.Slice(r.ByType, func(i, j int) bool { return r.ByType[i].Name < r.ByType[j].Name })
+	for _, c := range bySeqId {
+		r.BySeqId = append(r.BySeqId, c)
+	}
+	sort.Slice(r.BySeqId, func(i, j int) bool {
+		return to.SeqOrder.Less(r.BySeqId[i].Name, r.BySeqId[j].Name)
+	})
+	return r
+}
+
+// diffCount returns the DiffCount for a name, creating it if needed.
+func diffCount(counts map[string]*DiffCount, by, name string) *DiffCount {
+	c, ok := counts[name]
+	if !ok {
+		c = &DiffCount{By: by, Name: name}
+		counts[name] = c
+	}
+	return c
+}
+
+// featureGenes maps every Feature that is part of a gene to the ID of
+// the gene.
+func featureGenes(t *Tree) map[*Feature]string {
+	genes := make(map[*Feature]string)
+	for _, g := range t.geneNodes() {
+		for _, f := range g.Features() {
+			if _, ok := genes[f]; !ok {
+				genes[f] = g.IdString
+			}
+		}
+	}
+	return genes
+}
+
+// matchFeatures pairs the old (from) and new (to) Feature and returns
+// the pairs, old first, and the old and new Feature that were not
+// matched, in their original order.
+func matchFeatures(from, to []*Feature) ([][2]*Feature, []*Feature, []*Feature) {
+	var pairs [][2]*Feature
+	matched := make(map[*Feature]bool)
+	match := func(o, n *Feature) {
+		pairs = append(pairs, [2]*Feature{o, n})
+		matched[o] = true
+		matched[n] = true
+	}
+
+	// By ID, line by line for an ID that has several lines
+	newById := make(map[string][]*Feature)
+	for _, f := range to {
+		if id := f.Attributes[`ID`]; id != `` {
+			newById[id] = append(newById[id], f)
+		}
+	}
+	oldById := make(map[string][]*Feature)
+	var ids []string
+	for _, f := range from {
+		if id := f.Attributes[`ID`]; id != `` {
+			if _, ok := oldById[id]; !ok {
+				ids = append(ids, id)
+			}
+			oldById[id] = append(oldById[id], f)
+		}
+	}
+	for _, id := range ids {
+		olds, news := oldById[id], newById[id]
+		for _, o := range olds {
+			for _, n := range news {
+				if !matched[n] && n.SeqId == o.SeqId && n.Start == o.Start && n.End == o.End {
+					match(o, n)
+					break
+				}
+			}
+		}
+		var i int
+		for _, o := range olds {
+			if matched[o] {
+				continue
+			}
+			for i < len(news) && matched[news[i]] {
+				i++
+			}
+			if i == len(news) {
+				break
+			}
+			match(o, news[i])
+		}
+	}
+
+	// By position for Feature without an ID, same Parent first
+	newByPos := make(map[string][]*Feature)
+	for _, f := range to {
+		if f.Attributes[`ID`] == `` {
+			k := positionKey(f)
+			newByPos[k] = append(newByPos[k], f)
+		}
+	}
+	for _, sameParent := range []bool{true, false} {
+		for _, o := range from {
+			if matched[o] || o.Attributes[`ID`] != `` {
+				continue
+			}
+			for _, n := range newByPos[positionKey(o)] {
+				if !matched[n] && (!sameParent || n.Attributes[`Parent`] == o.Attributes[`Parent`]) {
+					match(o, n)
+					break
+				}
+			}
+		}
+	}
+
+	// Keep the pairs in the order of the old Feature
+	index := make(map[*Feature]int)
+	for i, f := range from {
+		index[f] = i
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return index[pairs[i][0]] < index[pairs[j][0]] })
+
+	var removed, added []*Feature
+	for _, f := range from {
+		if !matched[f] {
+			removed = append(removed, f)
+		}
+	}
+	for _, f := range to {
+		if !matched[f] {
+			added = append(added, f)
+		}
+	}
+	return pairs, removed, added
+}
+
+// positionKey is used to match Feature that do not have an ID.
+func positionKey(f *Feature) string {
+	return strings.Join([]string{f.SeqId, f.Type, strconv.Itoa(f.Start), strconv.Itoa(f.End), f.Strand}, "\t")
+}
+
+// compareFeatures returns the differences between an old and new
+// Feature that make it moved and changed. Each difference is given as
+// subject=old>new using the selector subjects, e.g. start=100>120 or
+// attr.Name=BRCA1>BRCA2, with . for a missing Attribute.
+func compareFeatures(o, n *Feature) ([]string, []string) {
+	var moved, changed []string
+	diff := func(list *[]string, subject, a, b string) {
+		if a != b {
+			*list = append(*list, subject+`=`+a+`>`+b)
+		}
+	}
+	diff(&moved, `seqid`, o.SeqId, n.SeqId)
+	diff(&moved, `start`, strconv.Itoa(o.Start), strconv.Itoa(n.Start))
+	diff(&moved, `end`, strconv.Itoa(o.End), strconv.Itoa(n.End))
+	diff(&moved, `strand`, o.Strand, n.Strand)
+	diff(&changed, `source`, o.Source, n.Source)
+	diff(&changed, `type`, o.Type, n.Type)
+	diff(&changed, `score`, o.Score, n.Score)
+	diff(&changed, `phase`, o.Phase, n.Phase)
+
+	keys := o.AttributeKeys()
+	for _, k := range n.AttributeKeys() {
+		if _, ok := o.Attributes[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		a, ok := o.Attributes[k]
+		if !ok {
+			a = `.`
+		}
+		b, ok := n.Attributes[k]
+		if !ok {
+			b = `.`
+		}
+		diff(&changed, attrSubjectPrefix+k, a, b)
+	}
+	return moved, changed
+}
+
+// DiffTsvHeader is the header line for the TSV written by
+// DiffChange.TsvString.
+func DiffTsvHeader() string {
+	return strings.Join([]string{`GeneId`, `Change`, `Type`, `ID`, `SeqId`, `Old`, `New`, `Details`}, "\t")
+}
+
+// TsvString gives a tab-separated version of a DiffChange. ID is the
+// ID Attribute, or Name if there is no ID. Old and New are the
+// locations as seqid:start-end:strand. See DiffTsvHeader for the
+// columns.
+func (c *DiffChange) TsvString() string {
+	f := c.New
+	if f == nil {
+		f = c.Old
+	}
+	id := f.Attributes[`ID`]
+	if id == `` {
+		id = f.Attributes[`Name`]
+	}
+	details := strings.Join(c.Details, `;`)
+	return strings.Join([]string{
+		orMissing(c.Gene),
+		c.Kind,
+		f.Type,
+		orMissing(id),
+		f.SeqId,
+		diffLocation(c.Old),
+		diffLocation(c.New),
+		orMissing(details)}, "\t")
+}
+
+// diffLocation gives the location of a Feature or . for nil.
+func diffLocation(f *Feature) string {
+	if f == nil {
+		return `.`
+	}
+	return f.SeqId + `:` + strconv.Itoa(f.Start) + `-` + strconv.Itoa(f.End) + `:` + f.Strand
+}
+
+func orMissing(s string) string {
+	if s == `` {
+		return `.`
+	}
+	return s
+}
+
+// DiffCountTsvHeader is the header line for the TSV written by
+// DiffCount.TsvString.
+func DiffCountTsvHeader() string {
+	return strings.Join([]string{`By`, `Name`, `Added`, `Removed`, `Moved`, `Changed`, `Unchanged`}, "\t")
+}
+
+// TsvString gives a tab-separated version of a DiffCount. See
+// DiffCountTsvHeader for the columns.
+func (c *DiffCount) TsvString() string {
+	return strings.Join([]string{
+		c.By,
+		c.Name,
+		strconv.Itoa(c.Added),
+		strconv.Itoa(c.Removed),
+		strconv.Itoa(c.Moved),
+		strconv.Itoa(c.Changed),
+		strconv.Itoa(c.Unchanged)}, "\t")
+}
@@ -0,0 +1,70 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	from := featuresFromText(t,
+		"1\te\tgene\t100\t900\t.\t+\t.\tID=gene:g1;Name=ONE\n"+
+			"1\te\tmRNA\t100\t900\t.\t+\t.\tID=transcript:t1;Parent=gene:g1\n"+
+			"1\te\texon\t100\t200\t.\t+\t.\tParent=transcript:t1;Name=ex1\n"+
+			"1\te\tCDS\t150\t200\t.\t+\t0\tID=CDS:p1;Parent=transcript:t1\n"+
+			"1\te\texon\t300\t400\t.\t+\t.\tParent=transcript:t1;Name=ex2\n"+
+			"1\te\tCDS\t300\t350\t.\t+\t1\tID=CDS:p1;Parent=transcript:t1\n"+
+			"1\te\tgene\t2000\t3000\t.\t-\t.\tID=gene:g2;Name=TWO\n"+
+			"2\te\tbiological_region\t10\t20\t.\t.\t.\tlogic_name=x\n")
+	to := featuresFromText(t,
+		"1\te\tgene\t100\t950\t.\t+\t.\tID=gene:g1;Name=ONE\n"+
+			"1\te\tmRNA\t100\t950\t.\t+\t.\tID=transcript:t1;Parent=gene:g1;tag=basic\n"+
+			"1\te\texon\t100\t200\t.\t+\t.\tParent=transcript:t1;Name=ex1\n"+
+			"1\te\tCDS\t150\t200\t.\t+\t0\tID=CDS:p1;Parent=transcript:t1\n"+
+			"1\te\texon\t300\t450\t.\t+\t.\tParent=transcript:t1;Name=ex2b\n"+
+			"1\te\tCDS\t300\t360\t.\t+\t1\tID=CDS:p1;Parent=transcript:t1\n"+
+			"1\te\tgene\t5000\t6000\t.\t+\t.\tID=gene:g3;Name=THREE\n"+
+			"2\te\tbiological_region\t10\t20\t.\t.\t.\tlogic_name=y\n")
+
+	r := Diff(from, to)
+	var got []string
+	for _, c := range r.Changes {
+		got = append(got, c.TsvString())
+	}
+	e := []string{
+		"gene:g1\tmoved\tgene\tgene:g1\t1\t1:100-900:+\t1:100-950:+\tend=900>950",
+		"gene:g1\tmoved\tmRNA\ttranscript:t1\t1\t1:100-900:+\t1:100-950:+\tend=900>950",
+		"gene:g1\tchanged\tmRNA\ttranscript:t1\t1\t1:100-900:+\t1:100-950:+\tattr.tag=.>basic",
+		"gene:g1\tmoved\tCDS\tCDS:p1\t1\t1:300-350:+\t1:300-360:+\tend=350>360",
+		"gene:g1\tremoved\texon\tex2\t1\t1:300-400:+\t.\t.",
+		"gene:g1\tadded\texon\tex2b\t1\t.\t1:300-450:+\t.",
+		"gene:g3\tadded\tgene\tgene:g3\t1\t.\t1:5000-6000:+\t.",
+		"gene:g2\tremoved\tgene\tgene:g2\t1\t1:2000-3000:-\t.\t.",
+		".\tchanged\tbiological_region\t.\t2\t2:10-20:.\t2:10-20:.\tattr.logic_name=x>y",
+	}
+	if strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Fatalf("Diff should give:\n%s\nbut gave:\n%s", strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+
+	var counts []string
+	for _, c := range append(r.ByType, r.BySeqId...) {
+		counts = append(counts, c.TsvString())
+	}
+	ec := []string{
+		"type\tCDS\t0\t0\t1\t0\t1",
+		"type\tbiological_region\t0\t0\t0\t1\t0",
+		"type\texon\t1\t1\t0\t0\t1",
+		"type\tgene\t1\t1\t1\t0\t0",
+		"type\tmRNA\t0\t0\t1\t1\t0",
+		"seqid\t1\t2\t2\t3\t1\t2",
+		"seqid\t2\t0\t0\t0\t1\t0",
+	}
+	if strings.Join(counts, "\n") != strings.Join(ec, "\n") {
+		t.Fatalf("Diff counts should be:\n%s\nbut are:\n%s", strings.Join(ec, "\n"), strings.Join(counts, "\n"))
+	}
+
+	// No changes
+	r = Diff(from, from)
+	if len(r.Changes) != 0 {
+		t.Fatalf("Diff of a Features with itself should give no changes but gave %d", len(r.Changes))
+	}
+}
@@ -0,0 +1,141 @@
+package gff3
+
+import (
+	"strconv"
+	"strings"
+
+	"ajgo/coords"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+// The GFF3 spec defines a number of directives - header lines that
+// start with ## and carry structured information about the file. We
+// keep the raw lines in Gff3.Header (so they can be written back out
+// unchanged) and parse them on demand. The directives that change how
+// a file is read are handled by Reader:
+//
+//  ###      all forward references have been resolved. This is kept
+//           as a flag on the Feature that precedes it so it can be
+//           written back out in the same place.
+//  ##FASTA  everything that follows is FASTA sequence, not Feature.
+//           A line starting with > also starts the FASTA section.
+//
+// Note that operations that reorder Feature (e.g. Sort) do not move
+// the ### barriers so they are only meaningful for Feature in their
+// original order.
+
+// Directive is a ## header line split into its name and value, e.g.
+// "##sequence-region 1 1 249250621" has a Name of sequence-region and
+// a Value of "1 1 249250621".
+type Directive struct {
+	Name  string
+	Value string
+}
+
+// SequenceRegion is a ##sequence-region directive which gives the
+// extent of a sequence. Coordinates are 1-based closed as per the spec.
+type SequenceRegion struct {
+	SeqId string
+	Start int
+	End   int
+}
+
+// ParseDirective parses a single header line. The bool is false if
+// the line is not a directive, i.e. it does not start with ## or it is
+// a ### barrier.
+func ParseDirective(line string) (*Directive, bool) {
+	if !strings.HasPrefix(line, `##`) || strings.HasPrefix(line, `###`) {
+		return nil, false
+	}
+	line = strings.TrimRight(line[2:], " \t\r\n")
+	// Ensembl uses multiple spaces as separators, e.g. "##gff-version   3"
+	i := strings.IndexAny(line, " \t")
+	if i < 0 {
+		return &Directive{Name: line}, true
+	}
+	return &Directive{Name: line[:i], Value: strings.TrimSpace(line[i:])}, true
+}
+
+// Directives returns the parsed ## header lines.
+func (g *Gff3) Directives() []*Directive {
+	return directivesFromHeaders(g.Header)
+}
+
+// SequenceRegions returns the ##sequence-region directives in the order
+// they appear in the headers. Directives that do not parse are skipped.
+func (g *Gff3) SequenceRegions() []*SequenceRegion {
+	return SequenceRegionsFromHeaders(g.Header)
+}
+
+// SequenceRegionsFromHeaders parses the ##sequence-region directives
+// from a list of header lines, e.g. Reader.Header.
+func SequenceRegionsFromHeaders(headers []string) []*SequenceRegion {
+	var regions []*SequenceRegion
+	for _, d := range directivesFromHeaders(headers) {
+		if d.Name != `sequence-region` {
+			continue
+		}
+		fields := strings.Fields(d.Value)
+		if len(fields) != 3 {
+			continue
+		}
+		start, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		end, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		regions = append(regions, &SequenceRegion{SeqId: fields[0], Start: start, End: end})
+	}
+	return regions
+}
+
+// String returns the SequenceRegion as a header line.
+func (r *SequenceRegion) String() string {
+	return `##sequence-region ` + r.SeqId + ` ` +
+		strconv.Itoa(r.Start) + ` ` + strconv.Itoa(r.End)
+}
+
+// CoordSystem returns the coordinate system of the Feature as recorded
+// by a ##format directive. See CoordSystemFromHeaders.
+func (g *Gff3) CoordSystem() (coords.System, error) {
+	return CoordSystemFromHeaders(g.Header)
+}
+
+// CoordSystemFromHeaders returns the coordinate system recorded in a
+// ##format directive, e.g. "##format 1-based closed". ##format is not
+// part of the GFF3 spec - it was added by ajgo because older versions
+// of the region modes wrote 1-based half-open GFF3s. If there is no
+// ##format directive, the Feature are 1-based closed as per the spec.
+func CoordSystemFromHeaders(headers []string) (coords.System, error) {
+	return coords.FromHeaders(`##`, headers, coords.OneBasedClosed)
+}
+
+// FormatHeader returns the ##format directive for a coordinate system.
+func FormatHeader(s coords.System) string {
+	return coords.FormatHeader(`##`, s)
+}
+
+// FastaGenome returns the sequences from the ##FASTA section of the
+// Gff3 as a *genome.Genome. It returns nil if the Gff3 had no FASTA.
+func (g *Gff3) FastaGenome(name string) *genome.Genome {
+	if len(g.Sequences) == 0 {
+		return nil
+	}
+	gn := genome.NewGenome(name)
+	gn.Sequences = append(gn.Sequences, g.Sequences...)
+	return gn
+}
+
+func directivesFromHeaders(headers []string) []*Directive {
+	var ds []*Directive
+	for _, h := range headers {
+		if d, ok := ParseDirective(h); ok {
+			ds = append(ds, d)
+		}
+	}
+	return ds
+}
@@ -0,0 +1,198 @@
+package gff3
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ajgo/coords"
+)
+
+func TestParseDirective(t *testing.T) {
+	var tests = []struct {
+		line  string
+		ok    bool
+		name  string
+		value string
+	}{
+		{"##gff-version   3", true, `gff-version`, `3`},
+		{"##sequence-region\t1 1 249250621", true, `sequence-region`, `1 1 249250621`},
+		{"##FASTA", true, `FASTA`, ``},
+		{"###", false, ``, ``},
+		{"#!genome-build  GRCh37.p13", false, ``, ``},
+		{"1\tajgo\tgene\t1\t10\t.\t+\t.\tID=a", false, ``, ``},
+	}
+
+	for _, tt := range tests {
+		d, ok := ParseDirective(tt.line)
+		if ok != tt.ok {
+			t.Fatalf("ParseDirective(%q) ok should be %v but is %v", tt.line, tt.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		if d.Name != tt.name || d.Value != tt.value {
+			t.Fatalf("ParseDirective(%q) should be %q %q but is %q %q",
+				tt.line, tt.name, tt.value, d.Name, d.Value)
+		}
+	}
+}
+
+func TestSequenceRegions(t *testing.T) {
+	g, err := NewFromFile(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+	rs := g.SequenceRegions()
+
+	e1 := 9
+	g1 := len(rs)
+	if e1 != g1 {
+		t.Fatalf("number of SequenceRegions should be %d but is %d", e1, g1)
+	}
+
+	e2 := SequenceRegion{SeqId: `Y`, Start: 2649521, End: 59034049}
+	g2 := *rs[8]
+	if e2 != g2 {
+		t.Fatalf("last SequenceRegion should be %v but is %v", e2, g2)
+	}
+
+	e3 := `##sequence-region Y 2649521 59034049`
+	g3 := rs[8].String()
+	if e3 != g3 {
+		t.Fatalf("String should be %q but is %q", e3, g3)
+	}
+}
+
+func TestCoordSystemFromHeaders(t *testing.T) {
+	var tests = []struct {
+		headers []string
+		sys     coords.System
+		ok      bool
+	}{
+		{[]string{`##gff-version 3`}, coords.OneBasedClosed, true},
+		{[]string{`##gff-version 3`, `##format 1-based half-open`}, coords.OneBasedHalfOpen, true},
+		{[]string{`##gff-version 3`, FormatHeader(coords.ZeroBasedHalfOpen)}, coords.ZeroBasedHalfOpen, true},
+		{[]string{`##gff-version 3`, `##format-0 1-based half-open`}, coords.OneBasedClosed, true},
+		{[]string{`##gff-version 3`, `##format sideways`}, coords.OneBasedClosed, false},
+	}
+
+	for _, tt := range tests {
+		sys, err := CoordSystemFromHeaders(tt.headers)
+		if (err == nil) != tt.ok {
+			t.Fatalf("CoordSystemFromHeaders(%q) error should be %v but is %v", tt.headers, !tt.ok, err)
+		}
+		if tt.ok && sys != tt.sys {
+			t.Fatalf("CoordSystemFromHeaders(%q) should be %v but is %v", tt.headers, tt.sys, sys)
+		}
+	}
+
+	// An old-style region GFF3 converted to the spec
+	fs := featuresFromText(t, "1\tajgo:n-regions\tN_region\t1\t101\t.\t.\t.\tID=n1\n")
+	fs.ConvertCoords(coords.OneBasedHalfOpen, coords.OneBasedClosed)
+	if f := fs.Features[0]; f.Start != 1 || f.End != 100 || f.Length() != 100 {
+		t.Fatalf("converted Feature should be 1-100 but is %d-%d", f.Start, f.End)
+	}
+}
+
+const fastaGff3 = "##gff-version 3\n" +
+	"##sequence-region ctg1 1 20\n" +
+	"ctg1\tajgo\tgene\t1\t10\t.\t+\t.\tID=g1\n" +
+	"###\n" +
+	"ctg1\tajgo\tgene\t12\t20\t.\t-\t.\tID=g2\n" +
+	"###\n" +
+	"##FASTA\n" +
+	">ctg1 test contig\n" +
+	"ACGTACGTAC\n" +
+	"GTACGTACGT\n" +
+	">ctg2\n" +
+	"NNNN\n"
+
+func TestFasta(t *testing.T) {
+	scanner := bufio.NewScanner(strings.NewReader(fastaGff3))
+	g, err := NewFromScanner(scanner)
+	if err != nil {
+		t.Fatalf("NewFromScanner failed: %v", err)
+	}
+
+	e1 := 2
+	g1 := g.FeatureCount()
+	if e1 != g1 {
+		t.Fatalf("FeatureCount should be %d but is %d", e1, g1)
+	}
+
+	gn := g.FastaGenome(`test`)
+	if gn == nil {
+		t.Fatalf("FastaGenome should not be nil")
+	}
+	s, err := gn.GetSequence(`ctg1`)
+	if err != nil {
+		t.Fatalf("GetSequence failed: %v", err)
+	}
+	e2 := `ACGTACGTACGTACGTACGT`
+	g2 := s.Sequence
+	if e2 != g2 {
+		t.Fatalf("sequence should be %s but is %s", e2, g2)
+	}
+	e3 := `test contig`
+	g3 := s.Info
+	if e3 != g3 {
+		t.Fatalf("Info should be %s but is %s", e3, g3)
+	}
+
+	// FASTA lines are rewrapped at 60 bases so this input round-trips
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	w.AddHeaders(g.Header...)
+	if err := w.WriteFeatures(g.Features); err != nil {
+		t.Fatalf("WriteFeatures failed: %v", err)
+	}
+	if err := w.WriteFasta(g.Sequences); err != nil {
+		t.Fatalf("WriteFasta failed: %v", err)
+	}
+	w.Close()
+	e4 := strings.Replace(fastaGff3, "ACGTACGTAC\nGTACGTACGT\n", "ACGTACGTACGTACGTACGT\n", 1)
+	g4 := b.String()
+	if e4 != g4 {
+		t.Fatalf("output should be\n%v\nbut is\n%v", e4, g4)
+	}
+}
+
+// TestRoundTrip checks that an unmodified Ensembl GFF3, including the
+// ### directives, is written back out byte-for-byte.
+func TestRoundTrip(t *testing.T) {
+	g, err := NewFromFile(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), `test1.gff3`)
+	if err := g.Write(out); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	ff, err := os.Open(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer ff.Close()
+	gz, err := gzip.NewReader(ff)
+	if err != nil {
+		t.Fatalf("gzip.NewReader failed: %v", err)
+	}
+	e1, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	g1, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if !bytes.Equal(e1, g1) {
+		t.Fatalf("written GFF3 does not match the original")
+	}
+}
@@ -0,0 +1,206 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ajgo/seq"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+// Extractor gets the sequences behind Feature from a genome, either
+// one Feature at a time or spliced, i.e. joining the exons or CDS of
+// each transcript. Feature must be 1-based closed, as read from a GFF3
+// without a ##format header, and sequences from the - strand are
+// reverse complemented so every sequence reads 5' to 3'.
+//
+// Upstream and Downstream add flanking bases in the direction of the
+// Feature, so for a Feature on the - strand, Upstream bases are added
+// after its End. Flanks are clipped at the ends of the sequence and
+// Clipped counts how many sequences were clipped.
+//
+// The FASTA header of each sequence is made by Header - see
+// ParseHeaderTemplate.
+type Extractor struct {
+	Upstream   int
+	Downstream int
+	Header     *HeaderTemplate // nil uses DefaultHeaderTemplate
+	Clipped    int
+
+	seqs map[string]*genome.Sequence
+}
+
+// NewExtractor returns an *Extractor for the sequences of a genome.
+func NewExtractor(g *genome.Genome) *Extractor {
+	e := &Extractor{seqs: make(map[string]*genome.Sequence)}
+	for _, s := range g.Sequences {
+		e.seqs[s.Name] = s
+	}
+	return e
+}
+
+// Feature returns the sequence of a single Feature.
+func (e *Extractor) Feature(f *Feature) (*seq.Record, error) {
+	return e.extract(f, []*Feature{f})
+}
+
+// Spliced returns a spliced sequence for every TreeNode in t that has
+// child Feature with Type typ, e.g. exon or CDS. The child Feature are
+// joined in order of Start and the Self Feature of the TreeNode (e.g.
+// the transcript) gives the Strand and the header. The sequences are in
+// the order the TreeNodes appeared in the Gff3 and an exon shared by
+// several transcripts is part of the sequence of each of them.
+func (e *Extractor) Spliced(t *Tree, typ string) ([]*seq.Record, error) {
+	var recs []*seq.Record
+	for _, id := range t.ids {
+		n := t.Nodes[id]
+		parts := n.children(typ)
+		if len(parts) == 0 {
+			continue
+		}
+		r, err := e.extract(n.Self[0], parts)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, r)
+	}
+	return recs, nil
+}
+
+// extract joins the sequences of parts, adding flanks and reverse
+// complementing according to f.
+func (e *Extractor) extract(f *Feature, parts []*Feature) (*seq.Record, error) {
+	s, ok := e.seqs[f.SeqId]
+	if !ok {
+		return nil, fmt.Errorf("Extractor: SeqId %s of %s is not in the genome", f.SeqId, featureLabel(f))
+	}
+
+	spans := make([][2]int, len(parts))
+	for i, p := range parts {
+		if p.SeqId != f.SeqId {
+			return nil, fmt.Errorf("Extractor: %s and %s have different SeqIds", featureLabel(f), featureLabel(p))
+		}
+		spans[i] = [2]int{p.Start, p.End}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	before, after := e.Upstream, e.Downstream
+	if f.Strand == `-` {
+		before, after = after, before
+	}
+	first, last := &spans[0], &spans[len(spans)-1]
+	first[0] -= before
+	last[1] += after
+	if first[0] < 1 || last[1] > s.Length() {
+		e.Clipped++
+		if first[0] < 1 {
+			first[0] = 1
+		}
+		if last[1] > s.Length() {
+			last[1] = s.Length()
+		}
+	}
+
+	var b strings.Builder
+	for _, sp := range spans {
+		sub, err := s.SubSequence(sp[0], sp[1])
+		if err != nil {
+			return nil, fmt.Errorf("Extractor: %s: %w", featureLabel(f), err)
+		}
+		b.WriteString(sub)
+	}
+	sequence := b.String()
+	if f.Strand == `-` {
+		sequence = seq.ReverseComplement(sequence)
+	}
+
+	h := e.Header
+	if h == nil {
+		h, _ = ParseHeaderTemplate(DefaultHeaderTemplate)
+	}
+	location := f.SeqId + `:` + strconv.Itoa(spans[0][0]) + `-` +
+		strconv.Itoa(spans[len(spans)-1][1]) + `(` + f.Strand + `)`
+	return &seq.Record{Header: h.Format(f, location), Sequence: sequence}, nil
+}
+
+// DefaultHeaderTemplate gives FASTA headers such as
+// ">gene:ENSG00000012048 17:43044295-43125483(-)".
+const DefaultHeaderTemplate = `{id} {location}`
+
+// HeaderTemplate makes FASTA headers from Feature. A template is text
+// with fields in braces that are replaced by values from the Feature.
+// The fields are any selector subject (see IsFeatureSubject), e.g.
+// {type} or {attr.Name}, and:
+//
+//	id        ID Attribute, or Name, or seqid:start-end if neither
+//	start     Start of the Feature
+//	end       End of the Feature
+//	location  extracted region as seqid:start-end(strand), including
+//	          any flanks, e.g. 17:43044295-43125483(-)
+//
+// Fields with more than one value are comma-separated and missing
+// Attributes are empty.
+type HeaderTemplate struct {
+	text   []string // text before each field and after the last
+	fields []string
+}
+
+// ParseHeaderTemplate parses a template such as "{attr.Name} {location}".
+func ParseHeaderTemplate(s string) (*HeaderTemplate, error) {
+	h := &HeaderTemplate{}
+	for {
+		open := strings.Index(s, `{`)
+		if open < 0 {
+			break
+		}
+		end := strings.Index(s[open:], `}`)
+		if end < 0 {
+			return nil, fmt.Errorf("ParseHeaderTemplate: unterminated field in %q", s)
+		}
+		field := s[open+1 : open+end]
+		switch {
+		case field == `id`, field == `start`, field == `end`, field == `location`:
+		case IsFeatureSubject(field):
+		default:
+			return nil, fmt.Errorf("ParseHeaderTemplate: field not recognised: {%s}", field)
+		}
+		h.text = append(h.text, s[:open])
+		h.fields = append(h.fields, field)
+		s = s[open+end+1:]
+	}
+	h.text = append(h.text, s)
+	return h, nil
+}
+
+// Format makes the header for a Feature. location is the region that
+// was extracted.
+func (h *HeaderTemplate) Format(f *Feature, location string) string {
+	var b strings.Builder
+	for i, field := range h.fields {
+		b.WriteString(h.text[i])
+		switch field {
+		case `id`:
+			id := f.Attributes[`ID`]
+			if id == `` {
+				id = f.Attributes[`Name`]
+			}
+			if id == `` {
+				id = fmt.Sprintf("%s:%d-%d", f.SeqId, f.Start, f.End)
+			}
+			b.WriteString(id)
+		case `start`:
+			b.WriteString(strconv.Itoa(f.Start))
+		case `end`:
+			b.WriteString(strconv.Itoa(f.End))
+		case `location`:
+			b.WriteString(location)
+		default:
+			b.WriteString(strings.Join(f.SubjectValues(field), `,`))
+		}
+	}
+	b.WriteString(h.text[len(h.text)-1])
+	return b.String()
+}
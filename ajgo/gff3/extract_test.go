@@ -0,0 +1,67 @@
+package gff3
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+func TestExtract(t *testing.T) {
+	g := genome.NewGenome("test")
+	g.Sequences = append(g.Sequences, &genome.Sequence{Name: "1", Sequence: "AACCGGTTAAACCCGGGTTT"})
+	e := NewExtractor(g)
+
+	f := &Feature{SeqId: "1", Type: "gene", Start: 3, End: 6, Strand: "+",
+		Attributes: map[string]string{"ID": "g1", "Name": "A1"}}
+	r, err := e.Feature(f)
+	if err != nil {
+		t.Fatalf("Feature failed: %v", err)
+	}
+	if r.Sequence != "CCGG" || r.Header != "g1 1:3-6(+)" {
+		t.Fatalf("Feature should be g1 1:3-6(+) CCGG but is %s %s", r.Header, r.Sequence)
+	}
+
+	// Upstream is after End on the - strand and flanks are clipped.
+	f.Strand = "-"
+	e.Upstream, e.Downstream = 2, 5
+	e.Header, err = ParseHeaderTemplate("{attr.Name}|{type}|{start}-{end}|{location}")
+	if err != nil {
+		t.Fatalf("ParseHeaderTemplate failed: %v", err)
+	}
+	if r, err = e.Feature(f); err != nil {
+		t.Fatalf("Feature failed: %v", err)
+	}
+	if r.Sequence != "AACCGGTT" || r.Header != "A1|gene|3-6|1:1-8(-)" || e.Clipped != 1 {
+		t.Fatalf("Feature should be A1|gene|3-6|1:1-8(-) AACCGGTT with 1 clipped but is %s %s with %d",
+			r.Header, r.Sequence, e.Clipped)
+	}
+
+	if _, err = e.Feature(&Feature{SeqId: "2", Start: 1, End: 2}); err == nil {
+		t.Fatalf("Feature should fail for a SeqId not in the genome")
+	}
+	if _, err = ParseHeaderTemplate("{bogus}"); err == nil {
+		t.Fatalf("ParseHeaderTemplate should fail for an unknown field")
+	}
+	if _, err = ParseHeaderTemplate("{id"); err == nil {
+		t.Fatalf("ParseHeaderTemplate should fail for an unterminated field")
+	}
+
+	gff, err := NewFromScanner(bufio.NewScanner(strings.NewReader("##gff-version 3\n" +
+		"1\ta\tmRNA\t1\t20\t.\t-\t.\tID=t1\n" +
+		"1\ta\texon\t15\t20\t.\t-\t.\tParent=t1\n" +
+		"1\ta\texon\t1\t4\t.\t-\t.\tParent=t1\n" +
+		"1\ta\tCDS\t3\t4\t.\t-\t0\tParent=t1\n")))
+	if err != nil {
+		t.Fatalf("NewFromScanner failed: %v", err)
+	}
+	e = NewExtractor(g)
+	recs, err := e.Spliced(gff.NewTree(), "exon")
+	if err != nil {
+		t.Fatalf("Spliced failed: %v", err)
+	}
+	if len(recs) != 1 || recs[0].Sequence != "AAACCCGGTT" || recs[0].Header != "t1 1:1-20(-)" {
+		t.Fatalf("Spliced should be t1 1:1-20(-) AAACCCGGTT but is %+v", recs)
+	}
+}
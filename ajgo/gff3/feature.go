@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 
+	"ajgo/coords"
+
 	"github.com/grendeloz/interval"
 )
 
@@ -22,6 +24,24 @@ type Feature struct {
 	Phase      string // should be int but missing is "."
 	Attributes map[string]string
 	LineNumber int // Line number within the Gff3 file
+
+	attrRaw      []rawAttr // Attributes as read - see attributes.go
+	barrierAfter bool      // followed by ### - see directives.go
+}
+
+// Length is the number of bases covered by the Feature. GFF3
+// coordinates are 1-based and closed so this is End-Start+1.
+func (f *Feature) Length() int {
+	return f.End - f.Start + 1
+}
+
+// ConvertCoords changes Start and End from coordinate system from to
+// coordinate system to. Feature read from a GFF3 are in whatever system
+// the file used (see CoordSystemFromHeaders) and Length and the Allen
+// Relationship-based operations assume that system has been taken into
+// account.
+func (f *Feature) ConvertCoords(from, to coords.System) {
+	f.Start, f.End = from.Convert(f.Start, f.End, to)
 }
 
 // Satisfy interval.Interval interface
@@ -51,6 +71,8 @@ func NewFeature() *Feature {
 
 // NewFeatureFromLine takes a single line of text, strips the line
 // endings, if any, creates a GFF3Feature, and returns a pointer to it.
+// See attributes.go for how column 9 is read and the two cases where
+// writing the Feature does not give back the original line.
 func NewFeatureFromLine(line string) (*Feature, error) {
 	var feat Feature
 
@@ -80,32 +102,40 @@ func NewFeatureFromLine(line string) (*Feature, error) {
 	if len(fields) == 9 {
 		// Attributes is full of leading/trailing spaces which upsets splitting
 		// on space so brace yourself for profligate use of TrimSpace.
+		// Values are percent-decoded as they are stored but we keep the
+		// original text so unchanged values can be written back verbatim.
 		feat.Attributes = make(map[string]string)
 		splitable := strings.TrimSpace(fields[8])
 		if splitable != "" {
 			attributes := strings.Split(splitable, ";")
 			var key, val string
 			for _, a := range attributes {
+				// Much more space trimming required here
+				a := strings.TrimSpace(a)
 				// There is often (always?) a trailing empty attribute and we don't
 				// want empty stuff in the map so skip any empty attributes
 				if a == "" {
 					continue
 				}
-				// Much more space trimming required here
-				a := strings.TrimSpace(a)
 				subs := strings.SplitN(a, "=", 2)
 				// The split doesn't remove white space
+				key = strings.TrimSpace(subs[0])
+				val = ""
 				if len(subs) == 2 {
-					key = strings.TrimSpace(subs[0])
 					val = strings.TrimSpace(subs[1])
-					feat.Attributes[key] = val
-				} else if len(subs) == 1 {
-					key = strings.TrimSpace(subs[0])
-					feat.Attributes[key] = ""
+				}
+				// A repeated key keeps its first position but the
+				// last value, the same as the map
+				if _, ok := feat.Attributes[key]; !ok {
+					feat.attrRaw = append(feat.attrRaw, rawAttr{Key: key, Value: val})
 				} else {
-					// This should be impossible because we already checked
-					// that field[8] exists and != ""
+					for i := range feat.attrRaw {
+						if feat.attrRaw[i].Key == key {
+							feat.attrRaw[i].Value = val
+						}
+					}
 				}
+				feat.Attributes[key] = decodeAttribute(val)
 			}
 		}
 	}
@@ -128,7 +158,8 @@ func NewFeatureFromLine(line string) (*Feature, error) {
 // will stay set to the value that the Features share.
 //
 // Attributes are deleted unless they are present and identical in
-// both Features.
+// both Features. To keep the Attributes that differ, see MergeOptions
+// Aggregate.
 //
 // Merge is destructive - it directly modifies the Feature (a) it is
 // called against.
@@ -152,6 +183,51 @@ func (a *Feature) Merge(b *Feature) error {
 // are needed, you can implement those in public wrapper functions  -
 // see the code for Merge as an example.
 func (a *Feature) merge(b *Feature) {
+	a.mergeFields(b)
+
+	var attrs []string
+	for k, _ := range a.Attributes {
+		attrs = append(attrs, k)
+	}
+	for _, attr := range attrs {
+		if _, ok := b.Attributes[attr]; ok {
+			if a.Attributes[attr] != b.Attributes[attr] {
+				// a and b have different values for attr so delete
+				delete(a.Attributes, attr)
+			}
+		} else {
+			// attr is not in b so delete
+			delete(a.Attributes, attr)
+		}
+	}
+}
+
+// aggregate is merge except that Attributes are combined rather than
+// deleted - every value from b that a does not have is added to a, so
+// Name=BRCA1 and Name=NBR2 become Name=BRCA1,NBR2. ID cannot have more
+// than one value so if the IDs differ, ID is deleted and the IDs are
+// kept in an IDs Attribute, as for the Feature made by PrudentMerge.
+func (a *Feature) aggregate(b *Feature) {
+	a.mergeFields(b)
+
+	if id, ok := a.Attributes[`ID`]; ok && id != b.Attributes[`ID`] {
+		delete(a.Attributes, `ID`)
+		a.AddAttributeValue(`IDs`, id)
+	}
+	for _, k := range b.AttributeKeys() {
+		for _, v := range b.AttributeValues(k) {
+			if k != `ID` {
+				a.AddAttributeValue(k, v)
+			} else if v != a.Attributes[`ID`] {
+				a.AddAttributeValue(`IDs`, v)
+			}
+		}
+	}
+}
+
+// mergeFields sets the interval of a to cover a and b and sets the
+// other fields to missing where they differ.
+func (a *Feature) mergeFields(b *Feature) {
 	// Set outer limits for the merged interval
 	if b.Start < a.Start {
 		a.Start = b.Start
@@ -176,22 +252,6 @@ func (a *Feature) merge(b *Feature) {
 	if a.Phase != b.Phase {
 		a.Phase = `.`
 	}
-
-	var attrs []string
-	for k, _ := range a.Attributes {
-		attrs = append(attrs, k)
-	}
-	for _, attr := range attrs {
-		if _, ok := b.Attributes[attr]; ok {
-			if a.Attributes[attr] != b.Attributes[attr] {
-				// a and b have different values for attr so delete
-				delete(a.Attributes, attr)
-			}
-		} else {
-			// attr is not in b so delete
-			delete(a.Attributes, attr)
-		}
-	}
 }
 
 func (f *Feature) String() string {
@@ -237,6 +297,9 @@ func (f *Feature) Clone() *Feature {
 		Phase:      f.Phase,
 		Attributes: make(map[string]string, len(f.Attributes)),
 		LineNumber: f.LineNumber,
+		attrRaw:    append([]rawAttr(nil), f.attrRaw...),
+
+		barrierAfter: f.barrierAfter,
 	}
 	for k, v := range f.Attributes {
 		n.Attributes[k] = v
@@ -279,7 +342,7 @@ func (f *Feature) SelectedAttributesString(attrs []string) string {
 	var attrStrings []string
 	for _, s := range attrs {
 		if _, ok := f.Attributes[s]; ok {
-			attrStrings = append(attrStrings, s+"="+f.Attributes[s])
+			attrStrings = append(attrStrings, f.attributeString(s))
 		}
 	}
 	// Attributes are ;-separated
@@ -288,28 +351,53 @@ func (f *Feature) SelectedAttributesString(attrs []string) string {
 	return attrString
 }
 
-// AttributesString will create a ;-separated string of the key:value
-// Attributes sorted by key name. This may not match the original order
-// of attributes from the gene model file.
+// AttributesString will create a ;-separated string of the key=value
+// Attributes with values percent-encoded as required by the GFF3 spec.
+// Attributes that were read from a GFF3 are written in their original
+// order and any unchanged values are written exactly as they were read.
+// Attributes added since the Feature was read (or all Attributes for a
+// Feature created with NewFeature) follow, sorted by key name.
 func (f *Feature) AttributesString() string {
-	// Sort keys
-	var keys []string
-	for k, _ := range f.Attributes {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
+	return f.SelectedAttributesString(f.AttributeKeys())
+}
 
-	// Assemble attributes string
-	var attrStrings []string
-	for _, k := range keys {
-		attrStrings = append(attrStrings, k+"="+f.Attributes[k])
+// compareHalfOpen returns the Allen Relationship between a and b with
+// Start and End treated as a half-open interval, so a Feature 100-200
+// meets a Feature 200-300 rather than overlapping it by a base.
+// Consolidate and PrudentMerge use it instead of interval.Compare so
+// they give the same result as Merger, which also works in half-open
+// coordinates, whichever way interval.Compare treats End.
+func compareHalfOpen(a, b *Feature) interval.AllenRelationship {
+	switch {
+	case a.End < b.Start:
+		return interval.PrecedesB
+	case a.End == b.Start:
+		return interval.MeetsB
+	case b.End < a.Start:
+		return interval.IsPrecededByB
+	case b.End == a.Start:
+		return interval.IsMetByB
+	case a.Start == b.Start && a.End == b.End:
+		return interval.EqualsB
+	case a.Start == b.Start && a.End < b.End:
+		return interval.StartsB
+	case a.Start == b.Start:
+		return interval.IsStartedByB
+	case a.End == b.End && a.Start > b.Start:
+		return interval.FinishesB
+	case a.End == b.End:
+		return interval.IsFinishedByB
+	case a.Start < b.Start && a.End > b.End:
+		return interval.ContainsB
+	case a.Start > b.Start && a.End < b.End:
+		return interval.IsContainedByB
+	case a.Start < b.Start:
+		return interval.OverlapsB
 	}
-	attrString := strings.Join(attrStrings, ";")
-
-	return attrString
+	return interval.IsOverlappedByB
 }
 
-// PrudentMerge does an interval.Compare on a pair of sorted *Feature
+// PrudentMerge does a compareHalfOpen on a pair of sorted *Feature
 // and returns a slice of non-overlapping *Feature that cover the
 // same bases as A and B but with any overlap represented as a separate
 // Feature. A and B must be sorted so that A.Start <= B.Start.
@@ -324,7 +412,7 @@ func PrudentMerge(a, b *Feature) ([]*Feature, error) {
 	var nfs []*Feature
 	A := a.Clone()
 	B := b.Clone()
-	allen := interval.Compare(A, B)
+	allen := compareHalfOpen(A, B)
 
 	// We do not need to handle all Allen Relationships - given that A and B
 	// are sorted by Start and we have already enforced that requirement at
@@ -346,14 +434,14 @@ func PrudentMerge(a, b *Feature) ([]*Feature, error) {
 		O := newOverlapFeature(A, B)
 		O.Start = B.Start
 		O.End = A.End
-		A.End = B.Start
-		B.Start = A.End
+		A.End = O.Start
+		B.Start = O.End
 		nfs = append(nfs, A, O, B)
 	} else if allen == interval.StartsB {
 		// 2 Features - overlap, 'B
 		O := newOverlapFeature(A, B)
 		O.Start = A.Start
-		O.End = B.Start
+		O.End = A.End
 		B.Start = A.End
 		nfs = append(nfs, O, B)
 	} else if allen == interval.ContainsB {
@@ -394,103 +482,64 @@ func PrudentMerge(a, b *Feature) ([]*Feature, error) {
 	return nfs, nil
 }
 
-// newOverlapFeature uses A and B as the basis for a new *Feature.
-// It uses two Attributes - Sources and Types - to track the Source and
-// Type fields of all Feature that contributed to the merged Feature.
+// newOverlapFeature uses two or more Feature as the basis for a new
+// *Feature. It uses three Attributes - Sources, Types and IDs - to track
+// the Source, Type and ID fields of all Feature that contributed to the
+// merged Feature. If a contributing Feature is itself the result of a
+// merge, its Sources, Types and IDs are used instead.
 //
 // Values of the overlap Feature are:
 //
-//  SeqId - taken from A with no check that A and B have the same SeqId
+//  SeqId - taken from the first Feature with no check that all of the
+//          Feature have the same SeqId
 //  Source  - set to `ajgo-merge`
-//  Attributes - Sources and Types set as noted above
+//  Attributes - Sources, Types and IDs set as noted above
 //
 // All other fields are set to whatever value is supplied by
 // NewFeature().
 
-func newOverlapFeature(A, B *Feature) *Feature {
+func newOverlapFeature(feats ...*Feature) *Feature {
 	C := NewFeature()
-	C.SeqId = A.SeqId
+	C.SeqId = feats[0].SeqId
 	C.Source = `ajgo-merge`
 	sep := `,`
 
-	// Create non-redundant map of Source
+	// Create non-redundant maps of Source, Type and ID
 	sources := make(map[string]int)
-	if A.Source == `ajgo-merge` {
-		srcs := strings.Split(A.Attributes[`Sources`], sep)
-		for _, s := range srcs {
-			sources[s]++
-		}
-	} else {
-		sources[A.Source]++
-	}
-	if B.Source == `ajgo-merge` {
-		srcs := strings.Split(B.Attributes[`Sources`], sep)
-		for _, s := range srcs {
-			sources[s]++
-		}
-	} else {
-		sources[B.Source]++
-	}
-	var finalSources []string
-	for k, _ := range sources {
-		finalSources = append(finalSources, k)
-	}
-	sort.Strings(finalSources)
-
-	// Create non-redundant map of Type
 	types := make(map[string]int)
-	if A.Source == `ajgo-merge` {
-		typs := strings.Split(A.Attributes[`Types`], sep)
-		for _, t := range typs {
-			types[t]++
-		}
-	} else {
-		types[A.Type]++
-	}
-	if B.Source == `ajgo-merge` {
-		typs := strings.Split(B.Attributes[`Types`], sep)
-		for _, t := range typs {
-			types[t]++
-		}
-	} else {
-		types[B.Type]++
-	}
-	var finalTypes []string
-	for k, _ := range types {
-		finalTypes = append(finalTypes, k)
-	}
-	sort.Strings(finalTypes)
-
-	// Create non-redundant map of ID
 	ids := make(map[string]int)
-	if A.Source == `ajgo-merge` {
-		tids := strings.Split(A.Attributes[`IDs`], sep)
-		for _, i := range tids {
-			ids[i]++
-		}
-	} else {
-		if _, ok := A.Attributes[`ID`]; ok {
-			ids[A.Attributes[`ID`]]++
-		}
-	}
-	if B.Source == `ajgo-merge` {
-		tids := strings.Split(B.Attributes[`IDs`], sep)
-		for _, i := range tids {
-			ids[i]++
-		}
-	} else {
-		if _, ok := B.Attributes[`ID`]; ok {
-			ids[B.Attributes[`ID`]]++
+	for _, f := range feats {
+		if f.Source == `ajgo-merge` {
+			for _, s := range strings.Split(f.Attributes[`Sources`], sep) {
+				sources[s]++
+			}
+			for _, t := range strings.Split(f.Attributes[`Types`], sep) {
+				types[t]++
+			}
+			for _, i := range strings.Split(f.Attributes[`IDs`], sep) {
+				ids[i]++
+			}
+		} else {
+			sources[f.Source]++
+			types[f.Type]++
+			if _, ok := f.Attributes[`ID`]; ok {
+				ids[f.Attributes[`ID`]]++
+			}
 		}
 	}
-	var finalIds []string
-	for k, _ := range ids {
-		finalIds = append(finalIds, k)
-	}
-	sort.Strings(finalIds)
 
-	C.Attributes[`IDs`] = strings.Join(finalIds, sep)
-	C.Attributes[`Sources`] = strings.Join(finalSources, sep)
-	C.Attributes[`Types`] = strings.Join(finalTypes, sep)
+	C.Attributes[`IDs`] = strings.Join(sortedKeys(ids), sep)
+	C.Attributes[`Sources`] = strings.Join(sortedKeys(sources), sep)
+	C.Attributes[`Types`] = strings.Join(sortedKeys(types), sep)
 	return C
 }
+
+// sortedKeys returns the keys of a tally in sorted order.
+func sortedKeys(tally map[string]int) []string {
+	var keys []string
+	for k := range tally {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
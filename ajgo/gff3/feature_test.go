@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"strings"
 	"testing"
+
+	"github.com/grendeloz/interval"
 )
 
 // 2 SeqIds, 5 identical Feature each
@@ -111,3 +113,55 @@ func TestFeaturePrivateNewOverlapFeature(t *testing.T) {
 		t.Fatalf("Attributes[Sources] should be %v but is %v", e11, g11)
 	}
 }
+
+func TestPrudentMerge(t *testing.T) {
+	var tests = []struct {
+		name string
+		a, b string
+		e    string
+	}{
+		{"OverlapsB", "100\t200", "150\t300", "1:100-150 1:150-200 1:200-300"},
+		{"StartsB", "100\t150", "100\t300", "1:100-150 1:150-300"},
+		{"ContainsB", "100\t300", "150\t200", "1:100-150 1:150-200 1:200-300"},
+		{"MeetsB", "100\t200", "200\t300", "1:100-200 1:200-300"},
+	}
+	for _, tt := range tests {
+		fs := featuresFromText(t, "1\ta\tr\t"+tt.a+"\t.\t.\t.\tID=a\n"+
+			"1\tb\tr\t"+tt.b+"\t.\t.\t.\tID=b\n")
+		nfs, err := PrudentMerge(fs.Features[0], fs.Features[1])
+		if err != nil {
+			t.Fatalf("%s: PrudentMerge failed: %v", tt.name, err)
+		}
+		if g := spansString(&Features{Features: nfs}); g != tt.e {
+			t.Fatalf("%s: PrudentMerge should give %s but gave %s", tt.name, tt.e, g)
+		}
+	}
+}
+
+func TestCompareHalfOpen(t *testing.T) {
+	a := &Feature{Start: 100, End: 200}
+	var tests = []struct {
+		start, end int
+		e          interval.AllenRelationship
+	}{
+		{250, 300, interval.PrecedesB},
+		{200, 300, interval.MeetsB},
+		{150, 300, interval.OverlapsB},
+		{100, 300, interval.StartsB},
+		{50, 200, interval.FinishesB},
+		{120, 180, interval.ContainsB},
+		{100, 200, interval.EqualsB},
+		{50, 300, interval.IsContainedByB},
+		{150, 200, interval.IsFinishedByB},
+		{100, 150, interval.IsStartedByB},
+		{50, 150, interval.IsOverlappedByB},
+		{50, 100, interval.IsMetByB},
+		{10, 50, interval.IsPrecededByB},
+	}
+	for _, tt := range tests {
+		b := &Feature{Start: tt.start, End: tt.end}
+		if g := compareHalfOpen(a, b); g != tt.e {
+			t.Fatalf("100-200 vs %d-%d should be %v but is %v", tt.start, tt.end, tt.e, g)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package gff3
 
 import (
+	"ajgo/coords"
 	"ajgo/selector"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/grendeloz/interval"
@@ -22,6 +24,7 @@ type Features struct {
 	Value    string
 	Features []*Feature
 	IsSorted bool
+	SeqOrder *SeqOrder // nil is lexical - see seqorder.go
 }
 
 // NewFeatures creates a pointer to a new instance of type Features.
@@ -69,7 +72,7 @@ func (fs *Features) Sort() {
 		sfs.simpleSort()
 		seqids = append(seqids, seqid)
 	}
-	sort.Strings(seqids)
+	fs.SeqOrder.Sort(seqids)
 
 	// Put humpty dumpty back together again
 	var feats []*Feature
@@ -96,16 +99,32 @@ func (fs *Features) Id() string {
 // This process is destructive! Genes with many transcripts often share
 // exons across multiple transcripts so removal of the duplicates means it
 // is no longer possible to work on transcripts. It will also merge
-// overlapping exons from different genes on opposite strands.
+// overlapping exons from different genes on opposite strands unless
+// o.GroupBy includes strand or a gene Attribute.
 //
 // Despite the caveats, there are use cases where this behaviour is
 // exactly what is needed, for example if you are constructing a mask to
 // work out which genomic positions are within the exons of a gene
 // or set of genes.
-func (fs *Features) Consolidate() error {
+//
+// o may be nil. If o.MaxGap is set, Feature with up to MaxGap bases
+// between them are also consolidated and if o.MinLength is set, the
+// consolidated Feature that are shorter are deleted. When o.Adjacent or
+// o.MaxGap is set, consolidated Feature made from more than one Feature
+// get a Joined Attribute - see MergeOptions. If o.GroupBy is set, each group is
+// consolidated separately and the groups are then put back together in
+// Start order. If o.Aggregate is set, Attributes that differ are
+// combined rather than deleted.
+func (fs *Features) Consolidate(o *MergeOptions) error {
 	if !fs.IsSorted {
 		return fmt.Errorf("Consolidate: cannot call on an unsorted Features")
 	}
+	if err := o.check(); err != nil {
+		return fmt.Errorf("Consolidate: %w", err)
+	}
+	if o.grouped() {
+		return fs.consolidateGroups(o)
+	}
 
 	// Consolidating an empty list of Features is legal but obviously
 	// there are no records to be consolidated so let's not waste time.
@@ -122,6 +141,7 @@ func (fs *Features) Consolidate() error {
 
 	var keepers []*Feature
 	keepers = append(keepers, fs.Features[0])
+	joined := []int{joinedCount(fs.Features[0])}
 
 	for i := 1; i < len(fs.Features); i++ {
 		keepidx := len(keepers) - 1
@@ -131,7 +151,7 @@ func (fs *Features) Consolidate() error {
 			return fmt.Errorf("Consolidate: cannot call on a Features with mixed SeqId")
 		}
 
-		allen := interval.Compare(keepers[keepidx], fs.Features[i])
+		allen := compareHalfOpen(keepers[keepidx], fs.Features[i])
 
 		// 1. Return error on AllenR of Unknown
 		// 2. Return error if b starts before a because that means that
@@ -148,18 +168,70 @@ func (fs *Features) Consolidate() error {
 			allen == interval.IsPrecededByB {
 			return fmt.Errorf("Consolidate: cannot call on an unsorted Features: {%+v} vs {%+v} for %s",
 				keepers[keepidx], fs.Features[i], fs.Id())
-		} else if allen == interval.PrecedesB {
+		} else if allen == interval.PrecedesB &&
+			fs.Features[i].Start-keepers[keepidx].End > o.gap() {
 			keepers = append(keepers, fs.Features[i])
+			joined = append(joined, joinedCount(fs.Features[i]))
+		} else if o.aggregates() {
+			keepers[keepidx].aggregate(fs.Features[i])
+			joined[keepidx] += joinedCount(fs.Features[i])
 		} else {
 			keepers[keepidx].Merge(fs.Features[i])
+			joined[keepidx] += joinedCount(fs.Features[i])
 		}
 	}
 
+	if !o.isZero() {
+		var kept []*Feature
+		for i, f := range keepers {
+			if o.tooShort(f) {
+				continue
+			}
+			if joined[i] > 1 && o.gap() >= 0 {
+				f.Attributes[`Joined`] = strconv.Itoa(joined[i])
+			}
+			kept = append(kept, f)
+		}
+		keepers = kept
+	}
+
 	// Attach the list of keepers
 	fs.Features = keepers
 	return nil
 }
 
+// consolidateGroups does Consolidate for each of the o.GroupBy groups.
+func (fs *Features) consolidateGroups(o *MergeOptions) error {
+	var keys []string
+	groups := make(map[string]*Features)
+	for _, f := range fs.Features {
+		k := o.groupKey(f)
+		if _, ok := groups[k]; !ok {
+			groups[k] = &Features{SeqOrder: fs.SeqOrder, IsSorted: true}
+			keys = append(keys, k)
+		}
+		groups[k].Features = append(groups[k].Features, f)
+	}
+
+	ungrouped := *o
+	ungrouped.GroupBy = nil
+	var feats []*Feature
+	for _, k := range keys {
+		if err := groups[k].Consolidate(&ungrouped); err != nil {
+			return err
+		}
+		feats = append(feats, groups[k].Features...)
+	}
+	sort.SliceStable(feats, func(i, j int) bool {
+		if feats[i].Start != feats[j].Start {
+			return feats[i].Start < feats[j].Start
+		}
+		return feats[i].End < feats[j].End
+	})
+	fs.Features = feats
+	return nil
+}
+
 // KeepByType takes a list of strings and Feature in the Features
 // will be deleted unless Feature.Type *exactly* matches one of the
 // supplied strings. An example use case would be to only keep Feature
@@ -189,6 +261,18 @@ func (fs *Features) Count() int {
 	return len(fs.Features)
 }
 
+// ConvertCoords changes the Start and End of every Feature from
+// coordinate system from to coordinate system to. See
+// Feature.ConvertCoords.
+func (fs *Features) ConvertCoords(from, to coords.System) {
+	if from == to {
+		return
+	}
+	for _, f := range fs.Features {
+		f.ConvertCoords(from, to)
+	}
+}
+
 // Clone creates a deep copy of a Features, i.e. the new Features shares
 // no pointers with the original Features. After calling Clone you can
 // change the original Features or the copy without any concern that the
@@ -198,6 +282,7 @@ func (fs *Features) Clone() *Features {
 	nfs.Key = fs.Key
 	nfs.Value = fs.Value
 	nfs.IsSorted = fs.IsSorted
+	nfs.SeqOrder = fs.SeqOrder
 
 	for _, ogf := range fs.Features {
         // I did try a gob encode/decode here and it was 7x slower!
@@ -285,9 +370,9 @@ func (fs *Features) KeepBySeqId(pattern string) ([]string, error) {
 }
 
 // SeqIds returns a sorted list of SeqId strings. This is
-// useful anywhere that you want consistent ordering. Note that since
-// SeqId is always a string the ordering is by string so chromosome
-// names may not sort as you'd expect/hope.
+// useful anywhere that you want consistent ordering. The ordering is
+// set by SeqOrder and by default is by string so chromosome names may
+// not sort as you'd expect/hope - see SetSeqOrder.
 func (fs *Features) SeqIds() []string {
 	seqids := make(map[string]int)
 	for _, f := range fs.Features {
@@ -300,11 +385,25 @@ func (fs *Features) SeqIds() []string {
 	for k, _ := range seqids {
 		names = append(names, k)
 	}
-	sort.Strings(names)
+	fs.SeqOrder.Sort(names)
 
 	return names
 }
 
+// SetSeqOrder sets the SeqOrder used by Sort and SeqIds and moves the
+// Feature so the SeqIds are in the new order. The order of the Feature
+// within each SeqId is not changed so a sorted Features stays sorted
+// and a gene model keeps its parents ahead of their children.
+func (fs *Features) SetSeqOrder(o *SeqOrder) {
+	fs.SeqOrder = o
+	seqs := fs.BySeqId()
+	var feats []*Feature
+	for _, seqid := range fs.SeqIds() {
+		feats = append(feats, seqs[seqid].Features...)
+	}
+	fs.Features = feats
+}
+
 // Attributes will look at all Features and tally which attributes are
 // present and how often.
 func (fs *Features) Attributes() map[string]int {
@@ -319,42 +418,40 @@ func (fs *Features) Attributes() map[string]int {
 	return tally
 }
 
+// ApplySelector drops any Feature that do not survive the Selector.
+// See FeatureSelector for the subjects that can be used. This process
+// is destructive - it modifies the source Features.
 func (fs *Features) ApplySelector(sel *selector.Selector) error {
-	switch sel.Subject {
-	case `seqid`:
-		_, err := fs.selectBySeqId(sel)
-		if err != nil {
-			fmt.Errorf("ApplySelector: %w", err)
-		}
-	default:
-		return fmt.Errorf("ApplySelector: selector subject not recognised in: %s", sel)
+	fsel, err := NewFeatureSelector(sel)
+	if err != nil {
+		return fmt.Errorf("ApplySelector: %w", err)
 	}
-
+	fs.Select(fsel)
 	return nil
 }
 
-// selectBySeqId applies a Selector to modify the Features list.
-// It returns a list of strings that are the identifiers of the Feature
-// that are lost in the process.  This process is destructive - it
+// ApplySelectorString drops any Feature that do not survive a selector
+// expression or three-part selector. This process is destructive - it
 // modifies the source Features.
-func (fs *Features) selectBySeqId(sel *selector.Selector) ([]string, error) {
-	var results []string
-	switch sel.Operation {
-	case `keep`:
-		results, err := fs.KeepBySeqId(sel.Pattern)
-		if err != nil {
-			return results, err
-		}
-	case `delete`:
-		results, err := fs.DeleteBySeqId(sel.Pattern)
-		if err != nil {
-			return results, err
-		}
-	default:
-		return results, fmt.Errorf("selectByColumnSeqid: selector operation not recognised in: %s", sel)
+func (fs *Features) ApplySelectorString(s string) error {
+	fsel, err := NewFeatureSelectorFromString(s)
+	if err != nil {
+		return fmt.Errorf("ApplySelectorString: %w", err)
 	}
+	fs.Select(fsel)
+	return nil
+}
 
-	return results, nil
+// Select drops any Feature that do not survive the FeatureSelector.
+// This process is destructive - it modifies the source Features.
+func (fs *Features) Select(fsel *FeatureSelector) {
+	var kept []*Feature
+	for _, f := range fs.Features {
+		if fsel.Keep(f) {
+			kept = append(kept, f)
+		}
+	}
+	fs.Features = kept
 }
 
 // BySeqId creates a map of Features structs where each Features
@@ -378,12 +475,13 @@ func (fs *Features) BySeqId() map[string]*Features {
 	return feats
 }
 
-// ByAttributeIdGene is designed specifically for GFF3 files in the
-// format used by Ensembl for gene models. It relies on information
-// being present in a particular format within the Attributes field
-// of the Feature in the GFF3. Here are some edited Feature examples
-// from an Ensembl gene model GFF3 showing the Type field and a
-// truncated version of the Attribute field:
+// ByAttrIdGene creates a map of Features types where each Features
+// collects all of the Feature that relate to a single gene. The map key
+// is the ID of the gene Feature. It relies on information being present
+// in a particular format within the Attributes field of the Feature in
+// the GFF3. Here are some edited Feature examples from an Ensembl gene
+// model GFF3 showing the Type field and a truncated version of the
+// Attribute field:
 //
 //   Type         Attributes
 //   pseudogene   ID=gene:ENSG00000223972;Name=DDX11L1;biotype=pseudogene;...
@@ -391,21 +489,48 @@ func (fs *Features) BySeqId() map[string]*Features {
 //   snRNA_gene   ID=gene:ENSG00000222623;Name=RNU6-1100P;biotype=snRNA;...
 //   gene         ID=gene:ENSG00000187634;Name=SAMD11;biotype=protein_coding;...
 //
+// The same approach works for the other common gene model conventions:
+//
+//   RefSeq       gene       ID=gene-SAMD11;Name=SAMD11;gene_biotype=protein_coding;...
+//                mRNA       ID=rna-NM_152486.4;Parent=gene-SAMD11;...
+//   GENCODE      gene       ID=ENSG00000187634.13;gene_id=ENSG00000187634.13;...
+//                transcript ID=ENST00000420190.7;Parent=ENSG00000187634.13;...
 //
-// ByAttributeIdGene creates a map of Features types where each
-// Features collects all of the Feature that relate to a single gene ID.
-// It makes a set of assumptions that are specific to records in the
-// format of Ensembl gene model GFF3 files:
+// A gene is a Feature with an ID and either a Type of gene or
+// pseudogene (or a Type ending in _gene) or an ID that starts with
+// gene: or gene-. All of the Feature below a gene in the Tree (see
+// NewTree) are grouped with it, following Parent links through as many
+// levels as there are (gene > mRNA > exon, CDS etc.). A Feature with
+// Parents in more than one gene appears in the Features for each gene.
+// If the gene Feature itself is missing (e.g. it was dropped by a
+// Selector) but the transcripts still name it as Parent, the group is
+// still created as long as the Parent ID looks like a gene ID, i.e. it
+// starts with gene:, gene- or an Ensembl gene ID such as ENSG. Feature
+// that are not below any gene (e.g. biological_region) are not in any
+// of the Features.
 //
-//   1. All genes will have a single Feature with a Type of an Attribute
-//      of the form ID=gene:... AND a Type field with value gene
-//   2. ID=gene Feature have no parents
-//   2. The only child nodes of ID=gene are transcripts so one level of
-//      following parent-child relationships will capture all relevant
-//      Feature.
+// Within each Features, the Feature are in the same order as in the
+// source Features. As for BySeqId, the new Features use pointers to the
+// Feature from the source Features.
 func (fs *Features) ByAttrIdGene() map[string]*Features {
 	feats := make(map[string]*Features)
-	// TO DO - there is no logic in this function yet.
+
+	order := make(map[*Feature]int)
+	for i, f := range fs.Features {
+		order[f] = i
+	}
+
+	t := fs.NewTree()
+	for id, n := range t.Nodes {
+		if !isGeneNode(n) {
+			continue
+		}
+		gfs := n.Features()
+		sort.SliceStable(gfs, func(i, j int) bool {
+			return order[gfs[i]] < order[gfs[j]]
+		})
+		feats[id] = &Features{Key: `gene`, Value: id, Features: gfs}
+	}
 	return feats
 }
 
@@ -419,10 +544,25 @@ func (fs *Features) ByAttrIdGene() map[string]*Features {
 // This process is destructive - it changes and deletes Feature from
 // Features. For cases where this is undesirable, use Clone to make a
 // copy of Features and call PrudentMergeByType on the copy.
-func (fs *Features) PrudentMergeByType() error {
+//
+// o may be nil. If it is not the zero value, the merge is done by a
+// Merger with o as its Options so Feature can also be joined across
+// gaps, short Feature dropped, merging kept within groups and
+// Attributes aggregated - see MergeOptions.
+func (fs *Features) PrudentMergeByType(o *MergeOptions) error {
 	if !fs.IsSorted {
 		return fmt.Errorf("PrudentMergeByType: cannot call on an unsorted Features")
 	}
+	if !o.isZero() {
+		m := NewMerger(fs.SeqOrder, NewFeaturesReader(fs))
+		m.Options = o
+		nfs, err := m.ReadAll()
+		if err != nil {
+			return fmt.Errorf("PrudentMergeByType: %w", err)
+		}
+		fs.Features = nfs.Features
+		return nil
+	}
 
 	// We will maintain 2 lists of Feature - Keepers and Candidates.
 	// Keepers starts empty and Candidate starts with all of the Feature
@@ -464,16 +604,23 @@ func (fs *Features) PrudentMergeByType() error {
 		// 2. If 2 or more Feature returned then there was some sort of
 		//    partial overlap
 		//    - consume A and B from Candidates
-		//    - append the first result feature to Keepers
 		//    - insert the rest of the result Feature into Candidates
+		//    - append the first result feature to Keepers unless it
+		//      overlaps the next Candidate, e.g. StartsB makes an
+		//      overlap Feature with the End of A which may be after the
+		//      Start of C, in which case it goes back into Candidates
 
 		if len(nfs) == 1 {
 			candidates = candidates[2:]
 			candidates = insertFeatures(candidates, nfs[0])
 		} else if len(nfs) == 2 || len(nfs) == 3 {
 			candidates = candidates[2:]
-			keepers = append(keepers, nfs[0])
 			candidates = insertFeatures(candidates, nfs[1:]...)
+			if nfs[0].End <= candidates[0].Start {
+				keepers = append(keepers, nfs[0])
+			} else {
+				candidates = insertFeatures(candidates, nfs[0])
+			}
 		} else {
 			return fmt.Errorf("PrudentMergeByType: big problem - should be impossible to fall through to here: {%+v} vs {%+v}", A, B)
 		}
@@ -506,7 +653,8 @@ func (fs *Features) AddFeaturesWithSort(fs2 ...*Feature) {
 	fs.Sort()
 }
 
-// MergeFeatures merges two *Features.
+// MergeFeatures merges two *Features. To merge more than two, MergeAll
+// is much faster than repeated calls to MergeFeatures.
 //
 // Under the hood, it uses PrudentMergeByType in a SeqId-safe fashion.
 // The returned *Features contains only new and cloned *Feature so it
@@ -528,7 +676,7 @@ func MergeFeatures(f1, f2 *Features) *Features {
 	var seqids []string
 	for seqid, fs := range seqs {
 		fs.Sort()
-		fs.PrudentMergeByType()
+		fs.PrudentMergeByType(nil)
 		seqids = append(seqids, seqid)
 	}
 
@@ -558,7 +706,9 @@ func insertFeatures(fs1 []*Feature, fs2 ...*Feature) []*Feature {
 	fs = append(fs, fs1...)
 	for _, f := range fs2 {
 		// We are looking for the first element in fs1 that has the same
-		// or later Start value than f.
+		// or later Start value than f. If there isn't one, f goes on
+		// the end.
+		inserted := false
 		for i, _ := range fs {
 			if fs[i].Start >= f.Start {
 				// tmp slices x,y stop tricksy indexing problems with
@@ -568,9 +718,13 @@ func insertFeatures(fs1 []*Feature, fs2 ...*Feature) []*Feature {
 				x = append(fs[:i], f)
 				x = append(x, y...)
 				fs = x
+				inserted = true
 				break // move on to splicing in next item from fs2
 			}
 		}
+		if !inserted {
+			fs = append(fs, f)
+		}
 	}
 	return fs
 }
@@ -619,7 +773,7 @@ func (fs *Features) simpleSort() {
 			endSorter := make(map[int][]*Feature)
 			for _, f := range sorter[start] {
 				if _, ok := endSorter[f.End]; !ok {
-					endSorter[f.End] = make([]*Feature, 2)
+					endSorter[f.End] = []*Feature{}
 				}
 				endSorter[f.End] = append(endSorter[f.End], f)
 			}
@@ -657,7 +811,7 @@ func (fs *Features) SumIntervals() int {
 // operation is non-destructive.
 func (fs *Features) SumConsolidatedIntervals() (int, error) {
 	nfs := fs.Clone()
-	err := nfs.Consolidate()
+	err := nfs.Consolidate(nil)
 	if err != nil {
 		return 0, fmt.Errorf("SumConsolidatedIntervals: %w", err)
 	}
@@ -34,6 +34,16 @@ var fs2 = `##gff-version	3
 1	ensembl	exon	30	40	.	.	.	ID=5
 `
 
+func TestFeaturesSortSameStart(t *testing.T) {
+	fs := featuresFromText(t, "1\ta\tr\t100\t200\t.\t.\t.\tID=a\n"+
+		"1\ta\tr\t100\t150\t.\t.\t.\tID=b\n")
+	fs.Sort()
+	e := "1:100-150 1:100-200"
+	if g := spansString(fs); g != e {
+		t.Fatalf("Sort should give %s but gave %s", e, g)
+	}
+}
+
 func TestFeaturesPrivateSort(t *testing.T) {
 	s := strings.NewReader(fs1)
 	b := bufio.NewScanner(s)
@@ -69,3 +79,84 @@ func TestFeaturesSort(t *testing.T) {
 
 	// TO DO - add some actual tests!
 }
+
+func TestFeaturesByAttrIdGene(t *testing.T) {
+	// Ensembl
+	g, err := NewFromFile(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+	genes := g.FeaturesByAttrIdGene()
+	if e := 19; len(genes) != e {
+		t.Fatalf("test1 should have %d genes but has %d", e, len(genes))
+	}
+	seen := make(map[*Feature]bool)
+	for id, fs := range genes {
+		if fs.Key != `gene` || fs.Value != id {
+			t.Fatalf("Features for %s should have Key gene and Value %s but have %s %s", id, id, fs.Key, fs.Value)
+		}
+		if fs.Features[0].Attributes[`ID`] != id {
+			t.Fatalf("first Feature for %s should be the gene but is %s", id, fs.Features[0].Type)
+		}
+		for _, f := range fs.Features {
+			seen[f] = true
+		}
+	}
+	// everything except biological_region and chromosome is in a gene
+	if e := g.FeatureCount() - 56 - 3; len(seen) != e {
+		t.Fatalf("test1 should have %d Feature in genes but has %d", e, len(seen))
+	}
+
+	// RefSeq and GENCODE, and a gene that has been dropped
+	fs := featuresFromText(t,
+		"1\tRefSeq\tgene\t100\t900\t.\t+\t.\tID=gene-SAMD11;Name=SAMD11\n"+
+			"1\tBestRefSeq\tmRNA\t100\t900\t.\t+\t.\tID=rna-NM_152486.4;Parent=gene-SAMD11\n"+
+			"1\tBestRefSeq\texon\t100\t200\t.\t+\t.\tID=exon-NM_152486.4-1;Parent=rna-NM_152486.4\n"+
+			"1\tBestRefSeq\tCDS\t150\t200\t.\t+\t0\tID=cds-NP_689699.3;Parent=rna-NM_152486.4\n"+
+			"1\tHAVANA\tgene\t1000\t2000\t.\t-\t.\tID=ENSG00000187634.13;gene_id=ENSG00000187634.13\n"+
+			"1\tHAVANA\ttranscript\t1000\t2000\t.\t-\t.\tID=ENST00000420190.7;Parent=ENSG00000187634.13\n"+
+			"1\tHAVANA\texon\t1000\t1100\t.\t-\t.\tID=exon:ENST00000420190.7:1;Parent=ENST00000420190.7\n"+
+			"1\tensembl\tmRNA\t3000\t4000\t.\t+\t.\tID=transcript:ENST00000001;Parent=gene:ENSG00000001\n"+
+			"1\tensembl\texon\t3000\t3100\t.\t+\t.\tParent=transcript:ENST00000001\n"+
+			"1\tensembl\tbiological_region\t5000\t5001\t.\t.\t.\tlogic_name=x\n")
+	genes = fs.ByAttrIdGene()
+	for id, e := range map[string]int{`gene-SAMD11`: 4, `ENSG00000187634.13`: 3, `gene:ENSG00000001`: 2} {
+		if _, ok := genes[id]; !ok {
+			t.Fatalf("gene %s was not found", id)
+		}
+		if g := genes[id].Count(); g != e {
+			t.Fatalf("gene %s should have %d Feature but has %d", id, e, g)
+		}
+	}
+	if len(genes) != 3 {
+		t.Fatalf("there should be 3 genes but there are %d", len(genes))
+	}
+}
+
+func TestInsertFeatures(t *testing.T) {
+	fs := featuresFromText(t, "1\ta\tr\t100\t200\t.\t.\t.\tID=a\n"+
+		"1\ta\tr\t300\t400\t.\t.\t.\tID=b\n"+
+		"1\ta\tr\t200\t300\t.\t.\t.\tID=c\n"+
+		"1\ta\tr\t500\t600\t.\t.\t.\tID=d\n")
+	got := insertFeatures(fs.Features[:2], fs.Features[2:]...)
+	e := "1:100-200 1:200-300 1:300-400 1:500-600"
+	if g := spansString(&Features{Features: got}); g != e {
+		t.Fatalf("insertFeatures should give %s but gave %s", e, g)
+	}
+}
+
+func TestPrudentMergeByTypeOverlapsNext(t *testing.T) {
+	// A and B start together so their overlap ends with A, after C
+	// starts, and must be merged with C rather than kept.
+	fs := featuresFromText(t, "1\ta\tr\t100\t150\t.\t.\t.\tID=a\n"+
+		"1\tb\tr\t100\t300\t.\t.\t.\tID=b\n"+
+		"1\tc\tr\t120\t130\t.\t.\t.\tID=c\n")
+	fs.Sort()
+	if err := fs.PrudentMergeByType(nil); err != nil {
+		t.Fatalf("PrudentMergeByType failed: %v", err)
+	}
+	e := "1:100-120 1:120-130 1:130-150 1:150-300"
+	if g := spansString(fs); g != e {
+		t.Fatalf("PrudentMergeByType should give %s but gave %s", e, g)
+	}
+}
@@ -0,0 +1,166 @@
+package gff3
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GeneCds is the coding footprint of a single gene - the CDS from all of
+// the gene's transcripts collapsed into a set of non-overlapping blocks.
+// Blocks are new Feature with a Type of CDS_region (SO:0000851) and
+// carry gene_id and Name Attributes from the gene so they can be traced
+// back. Blocks do not have a Phase because a block may contain CDS from
+// transcripts with different reading frames.
+type GeneCds struct {
+	GeneId      string
+	Name        string
+	SeqId       string
+	Strand      string
+	Transcripts int // number of transcripts with at least one CDS
+	CdsCount    int // number of CDS Feature before consolidation
+	Blocks      []*Feature
+}
+
+// Start returns the Start of the first block.
+func (g *GeneCds) Start() int {
+	return g.Blocks[0].Start
+}
+
+// End returns the End of the last block.
+func (g *GeneCds) End() int {
+	return g.Blocks[len(g.Blocks)-1].End
+}
+
+// CodingLength returns the number of positions covered by the blocks.
+func (g *GeneCds) CodingLength() int {
+	var l int
+	for _, b := range g.Blocks {
+		l += b.Length()
+	}
+	return l
+}
+
+// GeneCdsTsvHeader is the header line for the TSV written by TsvString.
+func GeneCdsTsvHeader() string {
+	return strings.Join([]string{`GeneId`, `Name`, `SeqId`, `Start`, `End`, `Strand`,
+		`Transcripts`, `CDS`, `Blocks`, `CodingLength`}, "\t")
+}
+
+// TsvString gives a tab-separated version of a GeneCds. See
+// GeneCdsTsvHeader for the columns.
+func (g *GeneCds) TsvString() string {
+	return strings.Join([]string{
+		g.GeneId,
+		g.Name,
+		g.SeqId,
+		strconv.Itoa(g.Start()),
+		strconv.Itoa(g.End()),
+		g.Strand,
+		strconv.Itoa(g.Transcripts),
+		strconv.Itoa(g.CdsCount),
+		strconv.Itoa(len(g.Blocks)),
+		strconv.Itoa(g.CodingLength())}, "\t")
+}
+
+// ConsolidateCdsByGene groups Feature by gene (see ByAttrIdGene) and
+// for each gene that has at least one CDS, collapses the CDS from all
+// of its transcripts into non-overlapping blocks. Start and End are
+// treated as GFF3 closed intervals so CDS that share even a single
+// position are merged but CDS that are immediately adjacent are not.
+// Genes without CDS are dropped. The GeneCds are returned in the
+// genomic order given by the SeqOrder of the Features.
+//
+// The source Features are not changed.
+func (fs *Features) ConsolidateCdsByGene() []*GeneCds {
+	var genes []*GeneCds
+	for id, gfs := range fs.ByAttrIdGene() {
+		var cds []*Feature
+		transcripts := make(map[string]bool)
+		for _, f := range gfs.Features {
+			if f.Type != `CDS` {
+				continue
+			}
+			cds = append(cds, f)
+			for _, p := range f.Parents() {
+				transcripts[p] = true
+			}
+		}
+		if len(cds) == 0 {
+			continue
+		}
+
+		g := &GeneCds{GeneId: id, CdsCount: len(cds), Transcripts: len(transcripts)}
+		if gf := gfs.Features[0]; gf.Attributes[`ID`] == id {
+			g.Name = gf.Attributes[`Name`]
+			g.SeqId = gf.SeqId
+			g.Strand = gf.Strand
+		} else {
+			// The gene Feature is missing so use the CDS
+			g.SeqId = cds[0].SeqId
+			g.Strand = cds[0].Strand
+		}
+		g.Blocks = cdsBlocks(g, cds)
+		genes = append(genes, g)
+	}
+
+	sort.Slice(genes, func(i, j int) bool {
+		a, b := genes[i], genes[j]
+		if a.SeqId != b.SeqId {
+			return fs.SeqOrder.Less(a.SeqId, b.SeqId)
+		}
+		if a.Start() != b.Start() {
+			return a.Start() < b.Start()
+		}
+		return a.GeneId < b.GeneId
+	})
+	return genes
+}
+
+// CdsFeatures returns the blocks from a list of GeneCds as a Features.
+func CdsFeatures(genes []*GeneCds) *Features {
+	fs := NewFeatures()
+	for _, g := range genes {
+		fs.Features = append(fs.Features, g.Blocks...)
+	}
+	return fs
+}
+
+// cdsBlocks sorts a gene's CDS and merges any that overlap into new
+// CDS_region Feature.
+func cdsBlocks(g *GeneCds, cds []*Feature) []*Feature {
+	sorted := make([]*Feature, len(cds))
+	copy(sorted, cds)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].SeqId != sorted[j].SeqId {
+			return sorted[i].SeqId < sorted[j].SeqId
+		}
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	var blocks []*Feature
+	for _, c := range sorted {
+		if n := len(blocks); n > 0 && blocks[n-1].SeqId == c.SeqId && c.Start <= blocks[n-1].End {
+			if c.End > blocks[n-1].End {
+				blocks[n-1].End = c.End
+			}
+			continue
+		}
+		b := &Feature{
+			SeqId:      c.SeqId,
+			Source:     `ajgo`,
+			Type:       `CDS_region`,
+			Start:      c.Start,
+			End:        c.End,
+			Score:      `.`,
+			Strand:     g.Strand,
+			Phase:      `.`,
+			Attributes: map[string]string{`gene_id`: g.GeneId},
+		}
+		if g.Name != `` {
+			b.Attributes[`Name`] = g.Name
+		}
+		blocks = append(blocks, b)
+	}
+	return blocks
+}
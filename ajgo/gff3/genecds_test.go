@@ -0,0 +1,59 @@
+package gff3
+
+import (
+	"testing"
+)
+
+func TestConsolidateCdsByGene(t *testing.T) {
+	fs := featuresFromText(t,
+		"1\tajgo\tgene\t100\t900\t.\t+\t.\tID=gene:g1;Name=ONE\n"+
+			"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=t1;Parent=gene:g1\n"+
+			"1\tajgo\tCDS\t150\t200\t.\t+\t0\tID=c1;Parent=t1\n"+
+			"1\tajgo\tCDS\t300\t400\t.\t+\t1\tID=c1;Parent=t1\n"+
+			"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=t2;Parent=gene:g1\n"+
+			"1\tajgo\tCDS\t180\t250\t.\t+\t0\tID=c2;Parent=t2\n"+
+			"1\tajgo\tCDS\t400\t500\t.\t+\t2\tID=c2;Parent=t2\n"+
+			"1\tajgo\tCDS\t501\t600\t.\t+\t0\tID=c2;Parent=t2\n"+
+			"1\tajgo\tgene\t50\t90\t.\t-\t.\tID=gene:g2\n"+
+			"1\tajgo\tncRNA\t50\t90\t.\t-\t.\tID=t3;Parent=gene:g2\n"+
+			"1\tajgo\texon\t50\t90\t.\t-\t.\tParent=t3\n"+
+			"1\tajgo\tmRNA\t10\t40\t.\t-\t.\tID=t4;Parent=gene:g3\n"+
+			"1\tajgo\tCDS\t10\t40\t.\t-\t0\tID=c4;Parent=t4\n")
+
+	genes := fs.ConsolidateCdsByGene()
+	if len(genes) != 2 {
+		t.Fatalf("there should be 2 genes with CDS but there are %d", len(genes))
+	}
+
+	// g3 has no gene Feature but still has CDS and sorts first
+	g := genes[0]
+	if g.GeneId != `gene:g3` || g.Name != `` || g.Strand != `-` || len(g.Blocks) != 1 {
+		t.Fatalf("first gene should be gene:g3 with 1 block but is %s with %d", g.GeneId, len(g.Blocks))
+	}
+
+	g = genes[1]
+	if g.GeneId != `gene:g1` || g.Name != `ONE` || g.Transcripts != 2 || g.CdsCount != 5 {
+		t.Fatalf("second gene is wrong: %s", g.TsvString())
+	}
+	// 150-250 and 300-400 overlap 400-500 and 501-600 is adjacent
+	e := []string{`1:150-250`, `1:300-500`, `1:501-600`}
+	if len(g.Blocks) != len(e) {
+		t.Fatalf("gene:g1 should have %d blocks but has %d", len(e), len(g.Blocks))
+	}
+	for i, b := range g.Blocks {
+		if featureLabel(b) != e[i] {
+			t.Fatalf("block %d should be %s but is %s", i, e[i], featureLabel(b))
+		}
+		if b.Type != `CDS_region` || b.Attributes[`gene_id`] != `gene:g1` || b.Attributes[`Name`] != `ONE` {
+			t.Fatalf("block %d has wrong Type or Attributes: %s %s", i, b.Type, b.AttributesString())
+		}
+	}
+	if e := 101 + 201 + 100; g.CodingLength() != e {
+		t.Fatalf("gene:g1 CodingLength should be %d but is %d", e, g.CodingLength())
+	}
+
+	// The source Features must be unchanged
+	if fs.Count() != 13 || fs.Features[2].End != 200 {
+		t.Fatalf("ConsolidateCdsByGene must not change the source Features")
+	}
+}
@@ -2,14 +2,14 @@ package gff3
 
 import (
 	"bufio"
-	"compress/gzip"
 	"errors"
 	"fmt"
-	"os"
+	"io"
 	"regexp"
-	"strings"
 
 	"ajgo/selector"
+
+	"github.com/grendeloz/ngs/genome"
 )
 
 var (
@@ -17,6 +17,11 @@ var (
 	ErrNoGff3Records = errors.New("File has no records - is this really what you wanted?")
 )
 
+// rexGffVersion matches the mandatory first header line. Some folks
+// (Ensembl at least) use an arbitrary amount of whitespace as the
+// separator so we need a pattern rather than a string comparison.
+var rexGffVersion = regexp.MustCompile(`^##gff-version\s+3`)
+
 // A GFF3 file is serialised in text as a list of features.  GFF3
 // is designed to capture relationships between features by explicitly
 // defining parent-child relationships via the Attributes column.
@@ -41,12 +46,17 @@ var (
 //
 // In order to work with the relationships between features, we have the
 // Tree type and the Gff3 function NewTree.
+//
+// The ## directives are kept as raw lines in Header - see directives.go
+// for the functions that parse them. Any sequences from a ##FASTA
+// section at the end of the file are in Sequences.
 type Gff3 struct {
 	Name   string
 	File   string
 	Header []string
 	//Features []*Feature
-	Features *Features
+	Features  *Features
+	Sequences []*genome.Sequence
 }
 
 func NewGff3() *Gff3 {
@@ -66,6 +76,10 @@ func (g *Gff3) Clone() *Gff3 {
 	ng.Name = g.Name
 	ng.File = g.File
 	ng.Header = append(ng.Header, g.Header...)
+	for _, s := range g.Sequences {
+		ns := *s
+		ng.Sequences = append(ng.Sequences, &ns)
+	}
 	nfs := g.Features.Clone()
 	ng.Features = nfs
 	return ng
@@ -97,39 +111,19 @@ func (g *Gff3) KeepBySeqId(pattern string) ([]string, error) {
 	return kept, nil
 }
 
-// NewFromFile reads from a file and returns a pointer to a Gff3.
+// NewFromFile reads from a file and returns a pointer to a Gff3. All
+// of the Feature are held in memory so for very large files you should
+// consider whether a Reader would be more appropriate.
 func NewFromFile(file string) (*Gff3, error) {
-	// Open file
-	ff, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer ff.Close()
-
-	// We need to define this before we handle gzip
-	var scanner *bufio.Scanner
-
-	// Based on file extension, handle gzip files
-	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	r, err := NewReaderFromFile(file)
 	if err != nil {
-		return nil, fmt.Errorf("NewFromFile: error matching gzip file pattern against %s: %w", file, err)
-	}
-	if found {
-		// For gzip files, put a gzip.Reader into the chain
-		reader, err := gzip.NewReader(ff)
-		if err != nil {
-			return nil, fmt.Errorf("NewFromFile: error opening gzip file %s: %w", file, err)
-		}
-		defer reader.Close()
-		scanner = bufio.NewScanner(reader)
-	} else {
-		// For non gzip files, go straight to bufio.Reader
-		scanner = bufio.NewScanner(ff)
+		return nil, fmt.Errorf("NewFromFile: %w", err)
 	}
+	defer r.Close()
 
-	gff3, err := NewFromScanner(scanner)
+	gff3, err := newFromReader(r)
 	if err != nil {
-		return gff3, fmt.Errorf("NewFromFile: error scanning: %w", err)
+		return gff3, fmt.Errorf("NewFromFile: error reading: %w", err)
 	}
 	gff3.File = file
 	gff3.Features.Key = `file`
@@ -141,46 +135,34 @@ func NewFromFile(file string) (*Gff3, error) {
 // to a Gff3. It is an alternative to NewFromFile and is useful when
 // you have Gff3 records as a block of text in memory.
 func NewFromScanner(scanner *bufio.Scanner) (*Gff3, error) {
-	gff3 := NewGff3()
+	r, err := newReaderFromScanner(scanner)
+	if err != nil {
+		return nil, fmt.Errorf("NewFromScanner: %w", err)
+	}
+
+	gff3, err := newFromReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("NewFromScanner: %w", err)
+	}
 	gff3.Features.Key = `source`
 	gff3.Features.Value = `gff3.NewFromScanner()`
+	return gff3, nil
+}
 
-	// Unnecessary but explicit
-	scanner.Split(bufio.ScanLines)
-
-	// Pattern for track lines
-	rex := regexp.MustCompile(`^#`)
+// newFromReader reads all of the Feature from a Reader into a new Gff3.
+func newFromReader(r *Reader) (*Gff3, error) {
+	gff3 := NewGff3()
 
-	// Read the file
-	lctr := 0
 	fs := NewFeatures()
-	for scanner.Scan() {
-		line := strings.TrimSuffix(scanner.Text(), "\n")
-		lctr++
-		if rex.MatchString(line) {
-			// Ensembl seems to use ### as a visual divider line in
-			// GFF3 files so we are going to drop these lines.
-			if line != `###` {
-				gff3.Header = append(gff3.Header, line)
-			}
-		} else {
-			f, err := NewFeatureFromLine(line)
-			if err != nil {
-				return nil, fmt.Errorf("NewFromScanner: error creating Feature: %w", err)
-			}
-			f.LineNumber = lctr
-			//if _, ok := gff3.Seqs[f.SeqId]; !ok {
-			//	gff3.Seqs[f.SeqId] = &FeatureCollection{Id: f.SeqId}
-			//}
-			//gff3.Seqs[f.SeqId].Features = append(gff3.Seqs[f.SeqId].Features, f)
-			fs.Features = append(fs.Features, f)
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			break
 		}
-	}
-
-	// If there are no Headers then it can't be a gff3 because the
-	// gff-version on the first line is mandatory.
-	if len(gff3.Header) == 0 {
-		return nil, ErrNoGff3Headers
+		if err != nil {
+			return nil, err
+		}
+		fs.Features = append(fs.Features, f)
 	}
 
 	// If there are no Features then something is wrong
@@ -188,62 +170,46 @@ func NewFromScanner(scanner *bufio.Scanner) (*Gff3, error) {
 		return nil, ErrNoGff3Records
 	}
 
-	// If the very first line is not the gff3 identifier then we exit
-	// immediately. Unfortunately, some folks (Ensembl at least) seem to
-	// put an arbitrary number of spaces in as separator so we will need
-	// to use a pattern rather than a simple string equality test.
-	ok, err := regexp.Match(`^##gff-version\s+3`, []byte(gff3.Header[0]))
-	if err != nil {
-		return nil, fmt.Errorf("NewFromScanner: error pattern matching gff-version line: %w", err)
-	}
-	if !ok {
-		return nil, fmt.Errorf("NewFromScanner: file is not a gff3, first line is: %s", gff3.Header[0])
-	}
-
+	gff3.Header = r.Header
 	gff3.Features = fs
+	gff3.Sequences = r.Sequences
 	return gff3, nil
 }
 
+// Write writes the Gff3 to a file. Files with a .gz extension are
+// gzipped.
 func (g *Gff3) Write(file string) error {
-	f, err := os.Create(file)
+	w, err := NewWriterToFile(file)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	w := bufio.NewWriter(f)
-	defer w.Flush()
-
-	// Write Headers (remember they still have their ##/#! prefixes)
-	for _, h := range g.Header {
-		_, err = w.WriteString(h + "\n")
-		if err != nil {
-			return err
-		}
+	w.AddHeaders(g.Header...)
+	if err = w.WriteFeatures(g.Features); err != nil {
+		w.Close()
+		return err
 	}
-
-	// TO DO - Features should probably have a Write() of its own - this
-	//         is a pretty dirty way tot do this.
-
-	// Write Features
-	for _, f := range g.Features.Features {
-		_, err = w.WriteString(f.String() + "\n")
-		if err != nil {
-			return err
-		}
+	if err = w.WriteFasta(g.Sequences); err != nil {
+		w.Close()
+		return err
 	}
 
-	return nil
+	return w.Close()
 }
 
 // SeqIds returns a sorted list of SeqId strings. This is
-// useful anywhere that you want consistent ordering. Note that since
-// SeqId is always a string the ordering is by string so chromosome
-// names may not sort as you'd expect/hope.
+// useful anywhere that you want consistent ordering. The ordering is
+// set by Features.SeqOrder - see SetSeqOrder.
 func (g *Gff3) SeqIds() []string {
 	return g.Features.SeqIds()
 }
 
+// SetSeqOrder sets the ordering of SeqIds and reorders the Feature to
+// match. See Features.SetSeqOrder.
+func (g *Gff3) SetSeqOrder(o *SeqOrder) {
+	g.Features.SetSeqOrder(o)
+}
+
 // FeatureAttributes will look at all Features across all Seqs and
 // tally which attributes are present and how often.
 func (g *Gff3) FeatureAttributes() map[string]int {
@@ -254,6 +220,12 @@ func (g *Gff3) ApplySelector(sel *selector.Selector) error {
 	return g.Features.ApplySelector(sel)
 }
 
+// ApplySelectorString drops any Feature that do not survive a selector
+// expression or three-part selector. See Features.ApplySelectorString.
+func (g *Gff3) ApplySelectorString(s string) error {
+	return g.Features.ApplySelectorString(s)
+}
+
 // FeaturesBySeqId creates a map of Features structs where each Features
 // contain Feature with the same SeqId. This can simplify a lot of other
 // operations such as Merge and Consolidate because it removes the
@@ -262,6 +234,13 @@ func (g *Gff3) FeaturesBySeqId() map[string]*Features {
 	return g.Features.BySeqId()
 }
 
+// FeaturesByAttrIdGene creates a map of Features structs where each
+// Features contains the Feature that belong to a single gene. See
+// Features.ByAttrIdGene.
+func (g *Gff3) FeaturesByAttrIdGene() map[string]*Features {
+	return g.Features.ByAttrIdGene()
+}
+
 // VersionedHeaders returns the header lines from a GFF3 but with
 // an identifier string inserted into to each header line. This allows us
 // to merge headers from multiple GFF3 files while retaining information
@@ -284,13 +263,19 @@ func (g *Gff3) FeaturesBySeqId() map[string]*Features {
 //  #!genome-version-2 GRCh37
 //  #-2 An ensemble gene model
 func (g *Gff3) VersionedHeaders(suffix string) []string {
+	return VersionHeaders(g.Header, suffix)
+}
+
+// VersionHeaders does the work for Gff3.VersionedHeaders but operates
+// on any list of header lines, e.g. the Header from a Reader.
+func VersionHeaders(headers []string, suffix string) []string {
 	var versioned []string
 
 	// We will be splitting on the first white space but we must capture
 	// the whitespace and newline so we can remake the line accurately.
 	re := regexp.MustCompile("(?s)(^#[^\t ]*)(.*)")
 
-	for _, h := range g.Header {
+	for _, h := range headers {
 		submatches := re.FindStringSubmatch(h)
 		if len(submatches) == 3 {
 			versioned = append(versioned, submatches[1]+`-`+suffix+submatches[2])
@@ -210,7 +210,7 @@ func TestGff3FeaturesBySeqId(t *testing.T) {
 	}
 
 	// Blunt test for whole-of-Feature
-	e11 := `1~GRCh37~chromosome~1~249250621~.~.~.~Alias=CM000663.1,NC_000001.10;ID=chromosome:1`
+	e11 := `1~GRCh37~chromosome~1~249250621~.~.~.~ID=chromosome:1;Alias=CM000663.1,NC_000001.10`
 	g11 := feat.debugString()
 	if e11 != g11 {
 		t.Fatalf("seq `%s` feature `%d` in %s should be %v but is %v", seq, fidx, f1, e11, g11)
@@ -240,7 +240,7 @@ func TestGff3FeaturesBySeqId(t *testing.T) {
 	feat = feats[seq].Features[fidx]
 
 	// Blunt test for whole-of-Feature
-	e31 := `3~ensembl~exon~324372~324475~.~-~.~Name=ENSE00002088485;Parent=transcript:ENST00000516208;constitutive=1;ensembl_end_phase=-1;ensembl_phase=-1;exon_id=ENSE00002088485;rank=1;version=1`
+	e31 := `3~ensembl~exon~324372~324475~.~-~.~Parent=transcript:ENST00000516208;Name=ENSE00002088485;constitutive=1;ensembl_end_phase=-1;ensembl_phase=-1;exon_id=ENSE00002088485;rank=1;version=1`
 	g31 := feat.debugString()
 	if e31 != g31 {
 		t.Fatalf("seq `%s` feature `%d` in %s should be %v but is %v", seq, fidx, f1, e31, g31)
@@ -305,7 +305,7 @@ func TestGff3Feature(t *testing.T) {
 	}
 
 	// Blunt test for whole-of-Feature
-	e11 := `1~GRCh37~chromosome~1~249250621~.~.~.~Alias=CM000663.1,NC_000001.10;ID=chromosome:1`
+	e11 := `1~GRCh37~chromosome~1~249250621~.~.~.~ID=chromosome:1;Alias=CM000663.1,NC_000001.10`
 	g11 := feat.debugString()
 	if e11 != g11 {
 		t.Fatalf("Feature `%d` in %s should be %v but is %v", fidx, f1, e11, g11)
@@ -333,7 +333,7 @@ func TestGff3Feature(t *testing.T) {
 	feat = gff3.Features.Features[fidx]
 
 	// Blunt test for whole-of-Feature
-	e31 := `3~ensembl~exon~324372~324475~.~-~.~Name=ENSE00002088485;Parent=transcript:ENST00000516208;constitutive=1;ensembl_end_phase=-1;ensembl_phase=-1;exon_id=ENSE00002088485;rank=1;version=1`
+	e31 := `3~ensembl~exon~324372~324475~.~-~.~Parent=transcript:ENST00000516208;Name=ENSE00002088485;constitutive=1;ensembl_end_phase=-1;ensembl_phase=-1;exon_id=ENSE00002088485;rank=1;version=1`
 	g31 := feat.debugString()
 	if e31 != g31 {
 		t.Fatalf("Feature `%d` in %s should be %v but is %v", fidx, f1, e31, g31)
@@ -1,6 +1,9 @@
 package gff3
 
 import (
+	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -53,10 +56,22 @@ import (
 // (probably) going to be quicker (and simpler to code) to
 // find nodes than a tree search/walk.
 
+// Phantom nodes, cycles and multiple roots.
+//
+// Because NodeById creates a TreeNode for any Parent that it has not
+// yet seen, a Parent ID that is never defined by a Feature leaves a
+// "phantom" TreeNode that has children but no Self. The Parent links
+// can also form a cycle (A is the Parent of B which is the Parent of A)
+// or join subtrees that should be separate, e.g. a transcript that
+// lists genes on different strands as its Parents. Tree.Validate
+// reports all three and the traversal functions (Roots, Ancestors,
+// Descendants and Walk) are safe to use on a Tree that has them.
+
 // Tree is a representation of the Features from a Gff3.
 type Tree struct {
 	Nodes   map[string]*TreeNode
 	Orphans []*Feature
+	ids     []string // IDs in the order their first Self Feature was added
 }
 
 // TreeNode is a collection of Features with the same IdString. Child
@@ -91,41 +106,371 @@ func NewTree() *Tree {
 // fill in the Self and Parent pointers.
 func (t *Tree) NodeById(id string) *TreeNode {
 	if _, ok := t.Nodes[id]; !ok {
-		n := &TreeNode{}
+		n := &TreeNode{IdString: id}
 		t.Nodes[id] = n
 	}
 	return t.Nodes[id]
 }
 
-// Features recursively extracts a list of all Features and sub-Features
-// of a TreeNode.
+// Features extracts a list of all Features and sub-Features of a
+// TreeNode. Each Feature appears once even if it is reachable by more
+// than one path, e.g. an exon shared by two transcripts.
 func (n *TreeNode) Features() []*Feature {
 	var feats []*Feature
-	// Self
-	feats = append(feats, n.Self...)
-	// ChildLeaves
-	feats = append(feats, n.ChildLeaves...)
-	// ChildNodes - recursive
+	seen := make(map[*Feature]bool)
+	add := func(fs []*Feature) {
+		for _, f := range fs {
+			if !seen[f] {
+				seen[f] = true
+				feats = append(feats, f)
+			}
+		}
+	}
+	n.Walk(func(c *TreeNode, depth int) bool {
+		add(c.Self)
+		add(c.ChildLeaves)
+		return true
+	})
+	return feats
+}
+
+// IsPhantom returns true if no Feature has the ID of the TreeNode, i.e.
+// it only exists because it was named as a Parent.
+func (n *TreeNode) IsPhantom() bool {
+	return len(n.Self) == 0
+}
+
+// Type returns the Type of the TreeNode which is the Type of its first
+// Self Feature or an empty string for a phantom TreeNode.
+func (n *TreeNode) Type() string {
+	if n.IsPhantom() {
+		return ``
+	}
+	return n.Self[0].Type
+}
+
+// rexGeneId matches IDs that follow a gene ID convention: the Ensembl
+// gene: prefix, the RefSeq gene- prefix or an Ensembl stable gene ID
+// (ENSG, ENSMUSG etc.) as used in GENCODE.
+var rexGeneId = regexp.MustCompile(`^(gene[:-]|ENS[A-Z]*G\d)`)
+
+// isGeneNode returns true if a TreeNode is a gene. See
+// Features.ByAttrIdGene.
+func isGeneNode(n *TreeNode) bool {
+	if n.IsPhantom() {
+		return rexGeneId.MatchString(n.IdString)
+	}
+	switch t := n.Type(); {
+	case t == `gene`, t == `pseudogene`, strings.HasSuffix(t, `_gene`):
+		return true
+	}
+	return strings.HasPrefix(n.IdString, `gene:`) || strings.HasPrefix(n.IdString, `gene-`)
+}
+
+// geneNodes returns the gene TreeNodes in the order the genes appeared
+// in the Gff3 followed by any phantom genes sorted by ID.
+func (t *Tree) geneNodes() []*TreeNode {
+	var genes []*TreeNode
+	for _, id := range t.nodeIds() {
+		if n := t.Nodes[id]; isGeneNode(n) {
+			genes = append(genes, n)
+		}
+	}
+	return genes
+}
+
+// nodeIds returns the IDs of all of the TreeNodes in the order their
+// Features appeared in the Gff3 followed by any phantoms sorted by ID,
+// so anything that walks the whole Tree can do so in a stable order.
+func (t *Tree) nodeIds() []string {
+	ids := append([]string{}, t.ids...)
+	var phantoms []string
+	for id, n := range t.Nodes {
+		if n.IsPhantom() {
+			phantoms = append(phantoms, id)
+		}
+	}
+	sort.Strings(phantoms)
+	return append(ids, phantoms...)
+}
+
+// Roots returns the TreeNodes that have no Parents, in the order their
+// Features appeared in the Gff3. Phantom TreeNodes are not included.
+func (t *Tree) Roots() []*TreeNode {
+	var roots []*TreeNode
+	for _, id := range t.ids {
+		if n := t.Nodes[id]; len(n.Parents) == 0 {
+			roots = append(roots, n)
+		}
+	}
+	return roots
+}
+
+// Walk calls fn on every TreeNode reachable from the Roots, depth-first
+// with each parent before its children. See TreeNode.Walk.
+func (t *Tree) Walk(fn func(n *TreeNode, depth int) bool) {
+	seen := make(map[*TreeNode]bool)
+	for _, r := range t.Roots() {
+		r.walk(fn, 0, seen)
+	}
+}
+
+// Walk calls fn on the TreeNode and then, depth-first, on all of its
+// descendants. depth is 0 for n, 1 for its children and so on. If fn
+// returns false, the children of that TreeNode are not visited. A
+// TreeNode with multiple Parents is only visited once and cycles are
+// not followed.
+func (n *TreeNode) Walk(fn func(n *TreeNode, depth int) bool) {
+	n.walk(fn, 0, make(map[*TreeNode]bool))
+}
+
+func (n *TreeNode) walk(fn func(*TreeNode, int) bool, depth int, seen map[*TreeNode]bool) {
+	if seen[n] {
+		return
+	}
+	seen[n] = true
+	if !fn(n, depth) {
+		return
+	}
 	for _, c := range n.ChildNodes {
-		feats = append(feats, c.Features()...)
+		c.walk(fn, depth+1, seen)
+	}
+}
+
+// Ancestors returns all of the TreeNodes above n, nearest first. Each
+// ancestor appears once even if it can be reached by more than one
+// path.
+func (n *TreeNode) Ancestors() []*TreeNode {
+	var ancs []*TreeNode
+	seen := map[*TreeNode]bool{n: true}
+	todo := n.Parents
+	for len(todo) > 0 {
+		var next []*TreeNode
+		for _, p := range todo {
+			if seen[p] {
+				continue
+			}
+			seen[p] = true
+			ancs = append(ancs, p)
+			next = append(next, p.Parents...)
+		}
+		todo = next
+	}
+	return ancs
+}
+
+// Descendants returns the Features below n that have the given Type,
+// e.g. Descendants(`CDS`) on a gene returns the CDS of all of its
+// transcripts. Both child Nodes and child Leaves are searched. If typ
+// is an empty string, all Features below n are returned. The Self
+// Features of n are never included.
+func (n *TreeNode) Descendants(typ string) []*Feature {
+	self := make(map[*Feature]bool)
+	for _, f := range n.Self {
+		self[f] = true
+	}
+	var feats []*Feature
+	for _, f := range n.Features() {
+		if self[f] {
+			continue
+		}
+		if typ == `` || f.Type == typ {
+			feats = append(feats, f)
+		}
 	}
 	return feats
 }
 
+// Validate checks the structure of the Tree and returns any Problems
+// found. It reports:
+//
+//   - phantom TreeNodes, i.e. a Parent ID that no Feature has
+//   - cycles in the Parent links
+//   - TreeNodes and Leaves whose Parents lead back to more than one
+//     root, i.e. subtrees that are joined when they probably should
+//     not be. Only the topmost TreeNode or Leaf where the roots join is
+//     reported.
+//
+// Phantoms and cycles are errors and multiple roots are warnings.
+// Problems are sorted by LineNumber.
+func (t *Tree) Validate() []*Problem {
+	var problems []*Problem
+	add := func(f *Feature, sev Severity, code, id, format string, a ...interface{}) {
+		p := &Problem{Severity: sev, Code: code, Id: id, Message: fmt.Sprintf(format, a...)}
+		if f != nil {
+			p.LineNumber = f.LineNumber
+			p.SeqId = f.SeqId
+		}
+		problems = append(problems, p)
+	}
+
+	var ids []string
+	for id := range t.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	// Phantoms
+	for _, id := range ids {
+		n := t.Nodes[id]
+		if !n.IsPhantom() {
+			continue
+		}
+		var first *Feature
+		var children []string
+		for _, c := range n.ChildNodes {
+			children = append(children, c.IdString)
+			if first == nil && !c.IsPhantom() {
+				first = c.Self[0]
+			}
+		}
+		for _, f := range n.ChildLeaves {
+			children = append(children, fmt.Sprintf("line %d", f.LineNumber))
+			if first == nil {
+				first = f
+			}
+		}
+		add(first, SeverityError, ProblemPhantomNode, id,
+			"Parent %s does not match the ID of any Feature but has %d children: %s",
+			id, len(children), strings.Join(children, `,`))
+	}
+
+	// Cycles - a depth-first search where a child that is still on the
+	// stack closes a cycle.
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make(map[*TreeNode]int)
+	var stack []*TreeNode
+	var visit func(n *TreeNode)
+	visit = func(n *TreeNode) {
+		state[n] = onStack
+		stack = append(stack, n)
+		for _, c := range n.ChildNodes {
+			switch state[c] {
+			case unvisited:
+				visit(c)
+			case onStack:
+				var cycle []string
+				for i := len(stack) - 1; i >= 0; i-- {
+					cycle = append([]string{stack[i].IdString}, cycle...)
+					if stack[i] == c {
+						break
+					}
+				}
+				cycle = append(cycle, c.IdString)
+				var f *Feature
+				if !c.IsPhantom() {
+					f = c.Self[0]
+				}
+				add(f, SeverityError, ProblemParentCycle, c.IdString,
+					"Parent links form a cycle: %s", strings.Join(cycle, ` > `))
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+	}
+	for _, id := range ids {
+		if n := t.Nodes[id]; state[n] == unvisited {
+			visit(n)
+		}
+	}
+
+	// Multiple roots
+	rootCache := make(map[*TreeNode][]string)
+	for _, id := range ids {
+		n := t.Nodes[id]
+		if n.IsPhantom() || len(n.Parents) < 2 {
+			continue
+		}
+		if roots := t.joinedRoots(n.Parents, rootCache); roots != nil {
+			add(n.Self[0], SeverityWarning, ProblemMultipleRoots, id,
+				"Parents lead to %d roots: %s", len(roots), strings.Join(roots, `,`))
+		}
+	}
+	for _, id := range ids {
+		for _, f := range t.Nodes[id].ChildLeaves {
+			// A Leaf is listed under every one of its Parents so only
+			// check it from the first
+			ps := f.Parents()
+			if len(ps) < 2 || ps[0] != id {
+				continue
+			}
+			var parents []*TreeNode
+			for _, p := range ps {
+				parents = append(parents, t.Nodes[p])
+			}
+			if roots := t.joinedRoots(parents, rootCache); roots != nil {
+				add(f, SeverityWarning, ProblemMultipleRoots, ``,
+					"Parents lead to %d roots: %s", len(roots), strings.Join(roots, `,`))
+			}
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].LineNumber < problems[j].LineNumber
+	})
+	return problems
+}
+
+// joinedRoots returns the IDs of the roots above a list of Parents if
+// there is more than one root overall but no single Parent leads to
+// more than one root. Otherwise it returns nil.
+func (t *Tree) joinedRoots(parents []*TreeNode, cache map[*TreeNode][]string) []string {
+	set := make(map[string]bool)
+	for _, p := range parents {
+		roots := nodeRoots(p, cache)
+		if len(roots) > 1 {
+			return nil
+		}
+		for _, r := range roots {
+			set[r] = true
+		}
+	}
+	if len(set) < 2 {
+		return nil
+	}
+	var roots []string
+	for r := range set {
+		roots = append(roots, r)
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// nodeRoots returns the IDs of the TreeNodes without Parents that are
+// at or above n. Phantom TreeNodes count as roots here.
+func nodeRoots(n *TreeNode, cache map[*TreeNode][]string) []string {
+	if roots, ok := cache[n]; ok {
+		return roots
+	}
+	var roots []string
+	for _, a := range append([]*TreeNode{n}, n.Ancestors()...) {
+		if len(a.Parents) == 0 {
+			roots = append(roots, a.IdString)
+		}
+	}
+	sort.Strings(roots)
+	cache[n] = roots
+	return roots
+}
+
 // NewGffTree builds a tree structure from a Gff3. Note that we will
 // link TreeNodes so they point to their child nodes as well as their
 // parent. This will let us go gene->transcript as well as
 // gene<-transcript.
 func (g *Gff3) NewTree() *Tree {
-	t := NewTree()
+	return g.Features.NewTree()
+}
 
-	// TO DO
-	// This entire function should probably move to features.go and
-	// become a receiver on *Features. This function can be kept but it
-	// becomes a minimalist wrapper.
+// NewTree builds a tree structure from Features. See Gff3.NewTree.
+func (fs *Features) NewTree() *Tree {
+	t := NewTree()
 
 	//var ctr int = 0
-	for _, f := range g.Features.Features {
+	for _, f := range fs.Features {
 		//if ctr > 50 {
 		//	log.Fatal("I'm goin'")
 		//}
@@ -137,20 +482,19 @@ func (g *Gff3) NewTree() *Tree {
 			//log.Info("node:  ", f.AttributesString())
 			// Has ID: Node
 			n := t.NodeById(f.Attributes[`ID`])
+			if n.IsPhantom() {
+				t.ids = append(t.ids, n.IdString)
+			}
 			n.Self = append(n.Self, f)
 			if _, ok := f.Attributes[`Parent`]; ok {
-				parents := strings.Split(f.Attributes[`Parent`], `,`)
-				for _, parent := range parents {
-					p := t.NodeById(parent)
-					n.Parents = append(n.Parents, p)
-					p.ChildNodes = append(p.ChildNodes, n)
+				for _, parent := range f.Parents() {
+					linkNodes(t.NodeById(parent), n)
 				}
 			}
 		} else if _, ok := f.Attributes[`Parent`]; ok {
 			//log.Info("leaf:  ", f.AttributesString())
 			// No ID but has Parent: Leaf
-			parents := strings.Split(f.Attributes[`Parent`], `,`)
-			for _, parent := range parents {
+			for _, parent := range f.Parents() {
 				p := t.NodeById(parent)
 				p.ChildLeaves = append(p.ChildLeaves, f)
 			}
@@ -162,3 +506,16 @@ func (g *Gff3) NewTree() *Tree {
 	}
 	return t
 }
+
+// linkNodes makes c a child of p. The Feature of a discontiguous
+// Feature such as a CDS all list the same Parent so the link is only
+// made once.
+func linkNodes(p, c *TreeNode) {
+	for _, x := range c.Parents {
+		if x == p {
+			return
+		}
+	}
+	c.Parents = append(c.Parents, p)
+	p.ChildNodes = append(p.ChildNodes, c)
+}
@@ -0,0 +1,115 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+)
+
+func treeFromText(t *testing.T, text string) *Tree {
+	g := NewGff3()
+	g.Features = featuresFromText(t, text)
+	return g.NewTree()
+}
+
+func nodeIds(ns []*TreeNode) string {
+	var ids []string
+	for _, n := range ns {
+		ids = append(ids, n.IdString)
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestTreeTraversal(t *testing.T) {
+	tr := treeFromText(t,
+		"1\tajgo\tgene\t100\t900\t.\t+\t.\tID=g1\n"+
+			"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=t1;Parent=g1\n"+
+			"1\tajgo\texon\t100\t200\t.\t+\t.\tParent=t1,t2\n"+
+			"1\tajgo\tCDS\t150\t200\t.\t+\t0\tID=c1;Parent=t1\n"+
+			"1\tajgo\tCDS\t300\t400\t.\t+\t1\tID=c1;Parent=t1\n"+
+			"1\tajgo\tmRNA\t100\t500\t.\t+\t.\tID=t2;Parent=g1\n"+
+			"1\tajgo\tgene\t2000\t3000\t.\t-\t.\tID=g2\n"+
+			"1\tajgo\tbiological_region\t5000\t5001\t.\t.\t.\tlogic_name=x\n")
+
+	if len(tr.Nodes[`g1`].IdString) == 0 {
+		t.Fatalf("NodeById should set IdString")
+	}
+	if g, e := nodeIds(tr.Roots()), `g1,g2`; g != e {
+		t.Fatalf("Roots should be %s but are %s", e, g)
+	}
+	if g, e := nodeIds(tr.Nodes[`c1`].Ancestors()), `t1,g1`; g != e {
+		t.Fatalf("Ancestors should be %s but are %s", e, g)
+	}
+
+	// The two lines of CDS c1 must only link c1 to t1 once
+	if g := len(tr.Nodes[`t1`].ChildNodes); g != 1 {
+		t.Fatalf("t1 should have 1 child node but has %d", g)
+	}
+	if g := len(tr.Nodes[`g1`].Descendants(`CDS`)); g != 2 {
+		t.Fatalf("g1 should have 2 CDS descendants but has %d", g)
+	}
+	if g := len(tr.Nodes[`g1`].Descendants(`exon`)); g != 1 {
+		t.Fatalf("g1 should have 1 exon descendant (shared by t1 and t2) but has %d", g)
+	}
+	if g := len(tr.Nodes[`g1`].Descendants(``)); g != 5 {
+		t.Fatalf("g1 should have 5 descendants but has %d", g)
+	}
+	if g := len(tr.Nodes[`g1`].Features()); g != 6 {
+		t.Fatalf("g1 should have 6 Features but has %d", g)
+	}
+
+	var walked []string
+	tr.Walk(func(n *TreeNode, depth int) bool {
+		walked = append(walked, strings.Repeat(`.`, depth)+n.IdString)
+		return n.Type() != `mRNA`
+	})
+	if g, e := strings.Join(walked, ` `), `g1 .t1 .t2 g2`; g != e {
+		t.Fatalf("Walk should give %s but gave %s", e, g)
+	}
+
+	if ps := tr.Validate(); len(ps) != 0 {
+		t.Fatalf("Validate should find no problems but found %d: %s", len(ps), ps[0])
+	}
+}
+
+func TestTreeValidate(t *testing.T) {
+	tr := treeFromText(t,
+		"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=t1;Parent=missing\n"+
+			"1\tajgo\texon\t100\t200\t.\t+\t.\tParent=missing\n"+
+			"1\tajgo\tgene\t1000\t2000\t.\t+\t.\tID=a;Parent=b\n"+
+			"1\tajgo\tgene\t1000\t2000\t.\t+\t.\tID=b;Parent=a\n"+
+			"1\tajgo\tgene\t3000\t4000\t.\t+\t.\tID=g1\n"+
+			"1\tajgo\tgene\t3000\t4000\t.\t-\t.\tID=g2\n"+
+			"1\tajgo\tmRNA\t3000\t4000\t.\t+\t.\tID=t2;Parent=g1,g2\n"+
+			"1\tajgo\texon\t3000\t3100\t.\t+\t.\tParent=t2\n"+
+			"1\tajgo\tmRNA\t3000\t4000\t.\t+\t.\tID=t3;Parent=g1\n"+
+			"1\tajgo\tmRNA\t3000\t4000\t.\t-\t.\tID=t4;Parent=g2\n"+
+			"1\tajgo\texon\t3000\t3100\t.\t+\t.\tParent=t3,t4\n")
+
+	var got []string
+	for _, p := range tr.Validate() {
+		got = append(got, p.Code+":"+p.Id)
+	}
+	e := []string{
+		ProblemPhantomNode + ":missing",
+		ProblemParentCycle + ":a",
+		ProblemMultipleRoots + ":t2",
+		ProblemMultipleRoots + ":",
+	}
+	if strings.Join(got, ` `) != strings.Join(e, ` `) {
+		t.Fatalf("Validate should report %v but reported %v", e, got)
+	}
+
+	// Traversal must cope with phantoms and cycles
+	if g, e := nodeIds(tr.Roots()), `g1,g2`; g != e {
+		t.Fatalf("Roots should be %s but are %s", e, g)
+	}
+	if g, e := nodeIds(tr.Nodes[`a`].Ancestors()), `b`; g != e {
+		t.Fatalf("Ancestors should be %s but are %s", e, g)
+	}
+	if g := len(tr.Nodes[`a`].Features()); g != 2 {
+		t.Fatalf("a should have 2 Features but has %d", g)
+	}
+	if g := len(tr.Nodes[`missing`].Descendants(``)); g != 2 {
+		t.Fatalf("missing should have 2 descendants but has %d", g)
+	}
+}
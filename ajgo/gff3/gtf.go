@@ -0,0 +1,427 @@
+package gff3
+
+import (
+	"fmt"
+	"strings"
+
+	"ajgo/gtf"
+)
+
+// GTF and GFF3 describe gene models in different ways. GTF has no
+// hierarchy - every line carries gene_id and transcript_id Attributes
+// and genes and transcripts may or may not have lines of their own.
+// GFF3 links Feature with ID and Parent Attributes so the hierarchy is
+// explicit and there must be a Feature for every gene and transcript.
+// Both are 1-based closed so coordinates carry over unchanged and the
+// GTF frame is the GFF3 Phase.
+//
+// FromGtf builds the hierarchy using the Ensembl GFF3 conventions:
+//
+//   gene         ID=gene:<gene_id>, Name=<gene_name>
+//   transcript   ID=transcript:<transcript_id>, Parent=gene:<gene_id>,
+//                Name=<transcript_name>. The Type is mRNA if the
+//                transcript_type (GENCODE) or transcript_biotype
+//                (Ensembl) is protein_coding, or there is no biotype
+//                but there is a CDS, otherwise transcript.
+//   CDS          ID=CDS:<protein_id> if there is a protein_id
+//   others       Parent=transcript:<transcript_id>
+//
+// Gene and transcript Feature are synthesised, spanning their children,
+// if the GTF has no gene or transcript lines. GTF Attributes are
+// carried over except that gene_* Attributes are only kept on genes,
+// transcript_* Attributes are only kept on transcripts and genes, and
+// Attributes that a child shares with its transcript are dropped. The
+// five_prime_utr and three_prime_utr Types from Ensembl become
+// five_prime_UTR and three_prime_UTR and the GENCODE UTR Type is
+// resolved to one or the other using the position of the CDS.
+//
+// Tree.Gtf goes the other way, flattening the tree by writing gene_id
+// and transcript_id on every line. Name, biotype and version on genes
+// become gene_name, gene_biotype and gene_version, and similarly for
+// transcripts. Multi-valued Attributes, e.g. tag, are written as one
+// GTF Attribute per value as GENCODE does.
+
+// FromGtf converts GTF Features into GFF3 Features, synthesising gene
+// and transcript Features as required. Genes are in the order they
+// first appear in the GTF and each gene is followed by its transcripts
+// and each transcript by its children, all in GTF order. It is an
+// error for a GTF Feature to have no gene_id or for a gene or
+// transcript to have more than one line.
+func FromGtf(feats []*gtf.Feature) (*Features, error) {
+	var geneIds []string
+	genes := make(map[string]*gtfGene)
+	for _, f := range feats {
+		gid := gtfValue(f, `gene_id`)
+		if gid == `` {
+			return nil, fmt.Errorf("FromGtf: line %d has no gene_id", f.LineNumber)
+		}
+		g, ok := genes[gid]
+		if !ok {
+			g = &gtfGene{id: gid, txs: make(map[string]*gtfTranscript)}
+			genes[gid] = g
+			geneIds = append(geneIds, gid)
+		}
+		g.span.add(f)
+
+		tid := gtfValue(f, `transcript_id`)
+		switch {
+		case f.Feature == `gene`:
+			if g.row != nil {
+				return nil, fmt.Errorf("FromGtf: gene %s has a second gene line at line %d", gid, f.LineNumber)
+			}
+			g.row = f
+		case tid == ``:
+			g.leaves = append(g.leaves, f)
+		default:
+			tx, ok := g.txs[tid]
+			if !ok {
+				tx = &gtfTranscript{id: tid}
+				g.txs[tid] = tx
+				g.txIds = append(g.txIds, tid)
+			}
+			tx.span.add(f)
+			if f.Feature == `transcript` {
+				if tx.row != nil {
+					return nil, fmt.Errorf("FromGtf: transcript %s has a second transcript line at line %d", tid, f.LineNumber)
+				}
+				tx.row = f
+			} else {
+				tx.children = append(tx.children, f)
+			}
+		}
+	}
+
+	fs := NewFeatures()
+	for _, gid := range geneIds {
+		fs.Features = append(fs.Features, genes[gid].features()...)
+	}
+	return fs, nil
+}
+
+// gtfSpan tracks the extent of a group of GTF Features.
+type gtfSpan struct {
+	first      *gtf.Feature
+	start, end int
+}
+
+func (s *gtfSpan) add(f *gtf.Feature) {
+	if s.first == nil {
+		s.first = f
+		s.start, s.end = int(f.Start), int(f.End)
+		return
+	}
+	if int(f.Start) < s.start {
+		s.start = int(f.Start)
+	}
+	if int(f.End) > s.end {
+		s.end = int(f.End)
+	}
+}
+
+// feature returns a Feature that covers the span with the SeqId, Source
+// and Strand of the first GTF Feature.
+func (s *gtfSpan) feature(typ string) *Feature {
+	f := NewFeature()
+	f.SeqId = s.first.SeqName
+	f.Source = s.first.Source
+	f.Type = typ
+	f.Start = s.start
+	f.End = s.end
+	f.Strand = s.first.Strand
+	return f
+}
+
+type gtfGene struct {
+	id     string
+	row    *gtf.Feature
+	span   gtfSpan
+	leaves []*gtf.Feature // lines with a gene_id but no transcript_id
+	txIds  []string
+	txs    map[string]*gtfTranscript
+}
+
+type gtfTranscript struct {
+	id       string
+	row      *gtf.Feature
+	span     gtfSpan
+	children []*gtf.Feature
+}
+
+// features returns the gene and all of its descendants as GFF3.
+func (g *gtfGene) features() []*Feature {
+	geneId := `gene:` + g.id
+	isGene := func(k string) bool { return strings.HasPrefix(k, `gene_`) }
+	var gf *Feature
+	if g.row != nil {
+		gf = featureFromGtf(g.row, `gene`)
+		addGtfAttributes(gf, g.row, func(k string) bool { return true })
+	} else {
+		gf = g.span.feature(`gene`)
+		addGtfAttributes(gf, g.span.first, isGene)
+	}
+	prependAttributes(gf, [2]string{`ID`, geneId}, [2]string{`Name`, gtfValue(g.span.first, `gene_name`)})
+
+	fs := []*Feature{gf}
+	for _, l := range g.leaves {
+		f := featureFromGtf(l, l.Feature)
+		addGtfAttributes(f, l, func(k string) bool { return !isGene(k) })
+		prependAttributes(f, [2]string{`Parent`, geneId})
+		fs = append(fs, f)
+	}
+	for _, tid := range g.txIds {
+		fs = append(fs, g.txs[tid].features(geneId)...)
+	}
+	return fs
+}
+
+// features returns the transcript and its children as GFF3.
+func (tx *gtfTranscript) features(geneId string) []*Feature {
+	txId := `transcript:` + tx.id
+	isTx := func(k string) bool { return strings.HasPrefix(k, `transcript_`) }
+	isGene := func(k string) bool { return strings.HasPrefix(k, `gene_`) }
+
+	// The start of the CDS is needed to resolve GENCODE UTRs
+	cdsStart := -1
+	for _, c := range tx.children {
+		if c.Feature == `CDS` && (cdsStart < 0 || int(c.Start) < cdsStart) {
+			cdsStart = int(c.Start)
+		}
+	}
+
+	biotype := gtfValue(tx.span.first, `transcript_type`)
+	if biotype == `` {
+		biotype = gtfValue(tx.span.first, `transcript_biotype`)
+	}
+	typ := `transcript`
+	if biotype == `protein_coding` || (biotype == `` && cdsStart > 0) {
+		typ = `mRNA`
+	}
+
+	var tf *Feature
+	if tx.row != nil {
+		tf = featureFromGtf(tx.row, typ)
+		addGtfAttributes(tf, tx.row, func(k string) bool { return !isGene(k) })
+	} else {
+		tf = tx.span.feature(typ)
+		addGtfAttributes(tf, tx.span.first, func(k string) bool { return isTx(k) || k == `tag` })
+	}
+	prependAttributes(tf, [2]string{`ID`, txId}, [2]string{`Parent`, geneId},
+		[2]string{`Name`, gtfValue(tx.span.first, `transcript_name`)})
+
+	fs := []*Feature{tf}
+	for _, c := range tx.children {
+		typ := c.Feature
+		switch {
+		case typ == `five_prime_utr`:
+			typ = `five_prime_UTR`
+		case typ == `three_prime_utr`:
+			typ = `three_prime_UTR`
+		case typ == `UTR` && cdsStart > 0:
+			low := int(c.End) < cdsStart
+			if low == (c.Strand != `-`) {
+				typ = `five_prime_UTR`
+			} else {
+				typ = `three_prime_UTR`
+			}
+		}
+		f := featureFromGtf(c, typ)
+		addGtfAttributes(f, c, func(k string) bool {
+			if isGene(k) || isTx(k) {
+				return false
+			}
+			_, ok := tf.Attributes[k]
+			return !ok || strings.Join(tf.AttributeValues(k), "\t") != strings.Join(c.AttributeValues(k), "\t")
+		})
+		parents := [][2]string{{`Parent`, txId}}
+		if pid := gtfValue(c, `protein_id`); typ == `CDS` && pid != `` {
+			parents = append([][2]string{{`ID`, `CDS:` + pid}}, parents...)
+		}
+		prependAttributes(f, parents...)
+		fs = append(fs, f)
+	}
+	return fs
+}
+
+// featureFromGtf copies everything except the Attributes from a GTF
+// Feature.
+func featureFromGtf(g *gtf.Feature, typ string) *Feature {
+	f := NewFeature()
+	f.SeqId = g.SeqName
+	f.Source = g.Source
+	f.Type = typ
+	f.Start = int(g.Start)
+	f.End = int(g.End)
+	f.Score = g.Score
+	f.Strand = g.Strand
+	f.Phase = g.Frame
+	return f
+}
+
+// addGtfAttributes copies the Attributes that satisfy keep from a GTF
+// Feature, in GTF order. Repeated GTF Attributes become a multi-valued
+// GFF3 Attribute.
+func addGtfAttributes(f *Feature, g *gtf.Feature, keep func(string) bool) {
+	for _, k := range g.AttributeKeys() {
+		if keep(k) {
+			addAttribute(f, k, g.AttributeValues(k)...)
+		}
+	}
+}
+
+// prependAttributes puts Attributes ahead of any already in the
+// Feature. Pairs with an empty value are skipped.
+func prependAttributes(f *Feature, pairs ...[2]string) {
+	existing := f.attrRaw
+	f.attrRaw = nil
+	for _, p := range pairs {
+		if p[1] != `` {
+			addAttribute(f, p[0], p[1])
+		}
+	}
+	f.attrRaw = append(f.attrRaw, existing...)
+}
+
+// addAttribute sets an Attribute and records it so Attributes are
+// written in the order they were added rather than sorted.
+func addAttribute(f *Feature, key string, vals ...string) {
+	f.SetAttributeValues(key, vals...)
+	f.attrRaw = append(f.attrRaw, rawAttr{Key: key, Value: encodeAttribute(f.Attributes[key])})
+}
+
+// gtfValue returns the first value of a GTF Attribute or "".
+func gtfValue(g *gtf.Feature, key string) string {
+	if vals := g.AttributeValues(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ``
+}
+
+// Gtf flattens the Tree into GTF Features. Every gene (see
+// Features.ByAttrIdGene) is written as a gene line followed by, for
+// each of its transcripts, a transcript line and the transcript's
+// children sorted by Start. A gene whose Feature is missing gets no
+// gene line. gene_id and transcript_id come from those Attributes if
+// the gene or transcript has them, otherwise from the ID with any
+// gene:, gene-, transcript: or rna- prefix removed.
+//
+// GTF can only describe genes and their transcripts so the Feature that
+// are not part of a gene, e.g. chromosome and biological_region in an
+// Ensembl GFF3, are returned as the second value.
+func (t *Tree) Gtf() ([]*gtf.Feature, []*Feature) {
+	var gfs []*gtf.Feature
+	done := make(map[*Feature]bool)
+	for _, g := range t.geneNodes() {
+		geneId := g.IdString
+		if !g.IsPhantom() && g.Self[0].Attributes[`gene_id`] != `` {
+			geneId = g.Self[0].Attributes[`gene_id`]
+		}
+		geneId = trimIdPrefix(geneId, `gene:`, `gene-`)
+		ids := [][2]string{{`gene_id`, geneId}}
+		if !g.IsPhantom() {
+			if name := g.Self[0].Attributes[`Name`]; name != `` {
+				ids = append(ids, [2]string{`gene_name`, name})
+			}
+			for _, f := range g.Self {
+				gfs = append(gfs, gtfFromFeature(f, `gene`, `gene_`, ids))
+				done[f] = true
+			}
+		}
+		for _, f := range g.ChildLeaves {
+			gfs = append(gfs, gtfFromFeature(f, f.Type, ``, ids))
+			done[f] = true
+		}
+
+		for _, tx := range g.ChildNodes {
+			if tx.IsPhantom() {
+				continue
+			}
+			txId := tx.Self[0].Attributes[`transcript_id`]
+			if txId == `` {
+				txId = trimIdPrefix(tx.IdString, `transcript:`, `rna-`)
+			}
+			txIds := append(append([][2]string{}, ids...), [2]string{`transcript_id`, txId})
+			for _, f := range tx.Self {
+				gfs = append(gfs, gtfFromFeature(f, `transcript`, `transcript_`, txIds))
+				done[f] = true
+			}
+			for _, f := range tx.children(``) {
+				typ := f.Type
+				switch typ {
+				case `five_prime_UTR`:
+					typ = `five_prime_utr`
+				case `three_prime_UTR`:
+					typ = `three_prime_utr`
+				}
+				gfs = append(gfs, gtfFromFeature(f, typ, ``, txIds))
+				done[f] = true
+			}
+		}
+	}
+
+	var skipped []*Feature
+	for _, f := range t.Orphans {
+		skipped = append(skipped, f)
+	}
+	for _, id := range t.nodeIds() {
+		n := t.Nodes[id]
+		for _, f := range append(append([]*Feature{}, n.Self...), n.ChildLeaves...) {
+			if !done[f] {
+				done[f] = true
+				skipped = append(skipped, f)
+			}
+		}
+	}
+	return gfs, skipped
+}
+
+// gtfFromFeature converts a Feature to GTF. ids are written first and
+// then the Attributes of the Feature except ID and Parent. If prefix is
+// set, Name, biotype and version are renamed with the prefix, e.g.
+// gene_biotype.
+func gtfFromFeature(f *Feature, typ, prefix string, ids [][2]string) *gtf.Feature {
+	g := &gtf.Feature{
+		SeqName:    f.SeqId,
+		Source:     f.Source,
+		Feature:    typ,
+		Start:      gtf.FeatCoord(f.Start),
+		End:        gtf.FeatCoord(f.End),
+		Score:      f.Score,
+		Strand:     f.Strand,
+		Frame:      f.Phase,
+		Attributes: make(map[string]string),
+	}
+	written := make(map[string]bool)
+	for _, id := range ids {
+		g.AddAttribute(id[0], id[1])
+		written[id[0]] = true
+	}
+	for _, k := range f.AttributeKeys() {
+		key := k
+		switch k {
+		case `ID`, `Parent`:
+			continue
+		case `Name`, `biotype`, `version`:
+			if prefix != `` {
+				key = prefix + strings.ToLower(k)
+			}
+		}
+		if written[key] {
+			continue
+		}
+		written[key] = true
+		for _, v := range f.AttributeValues(k) {
+			g.AddAttribute(key, v)
+		}
+	}
+	return g
+}
+
+// trimIdPrefix removes the first matching prefix from an ID.
+func trimIdPrefix(id string, prefixes ...string) string {
+	for _, p := range prefixes {
+		if strings.HasPrefix(id, p) {
+			return strings.TrimPrefix(id, p)
+		}
+	}
+	return id
+}
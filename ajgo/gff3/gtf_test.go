@@ -0,0 +1,113 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+
+	"ajgo/gtf"
+)
+
+func gtfFromText(t *testing.T, text string) []*gtf.Feature {
+	var feats []*gtf.Feature
+	for i, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		f, err := gtf.NewFeatureFromFields(strings.Split(line, "\t"))
+		if err != nil {
+			t.Fatalf("NewFeatureFromFields failed: %v", err)
+		}
+		f.LineNumber = i + 1
+		feats = append(feats, f)
+	}
+	return feats
+}
+
+func TestFromGtf(t *testing.T) {
+	// GENCODE style with no gene or transcript lines and generic UTRs
+	feats := gtfFromText(t,
+		"1\tHAVANA\texon\t100\t200\t.\t-\t.\tgene_id \"G1\"; transcript_id \"T1\"; gene_name \"ONE\"; transcript_type \"protein_coding\"; tag \"basic\"; tag \"CCDS\"; exon_number \"1\";\n"+
+			"1\tHAVANA\tCDS\t150\t200\t.\t-\t0\tgene_id \"G1\"; transcript_id \"T1\"; gene_name \"ONE\"; transcript_type \"protein_coding\"; protein_id \"P1\"; tag \"basic\"; tag \"CCDS\";\n"+
+			"1\tHAVANA\tUTR\t100\t149\t.\t-\t.\tgene_id \"G1\"; transcript_id \"T1\"; gene_name \"ONE\"; transcript_type \"protein_coding\"; tag \"basic\"; tag \"CCDS\";\n"+
+			"1\tHAVANA\texon\t300\t400\t.\t-\t.\tgene_id \"G1\"; transcript_id \"T2\"; gene_name \"ONE\"; transcript_type \"lncRNA\"; exon_number \"1\";\n")
+
+	fs, err := FromGtf(feats)
+	if err != nil {
+		t.Fatalf("FromGtf failed: %v", err)
+	}
+	e := []string{
+		"1\tHAVANA\tgene\t100\t400\t.\t-\t.\tID=gene:G1;Name=ONE;gene_id=G1;gene_name=ONE",
+		"1\tHAVANA\tmRNA\t100\t200\t.\t-\t.\tID=transcript:T1;Parent=gene:G1;transcript_id=T1;transcript_type=protein_coding;tag=basic,CCDS",
+		"1\tHAVANA\texon\t100\t200\t.\t-\t.\tParent=transcript:T1;exon_number=1",
+		"1\tHAVANA\tCDS\t150\t200\t.\t-\t0\tID=CDS:P1;Parent=transcript:T1;protein_id=P1",
+		"1\tHAVANA\tthree_prime_UTR\t100\t149\t.\t-\t.\tParent=transcript:T1",
+		"1\tHAVANA\ttranscript\t300\t400\t.\t-\t.\tID=transcript:T2;Parent=gene:G1;transcript_id=T2;transcript_type=lncRNA",
+		"1\tHAVANA\texon\t300\t400\t.\t-\t.\tParent=transcript:T2;exon_number=1",
+	}
+	if fs.Count() != len(e) {
+		t.Fatalf("FromGtf should give %d Features but gave %d", len(e), fs.Count())
+	}
+	for i, f := range fs.Features {
+		if f.String() != e[i] {
+			t.Fatalf("FromGtf Feature %d should be %q but is %q", i, e[i], f.String())
+		}
+	}
+
+	// The synthesised hierarchy must be usable by the tree modes
+	tr := fs.NewTree()
+	if len(tr.Orphans) != 0 {
+		t.Fatalf("FromGtf should give no orphans but gave %d", len(tr.Orphans))
+	}
+	if ids := nodeIds(tr.Nodes[`gene:G1`].ChildNodes); ids != `transcript:T1,transcript:T2` {
+		t.Fatalf("gene:G1 should have transcripts T1,T2 but has %s", ids)
+	}
+
+	bad := gtfFromText(t, "1\tHAVANA\texon\t100\t200\t.\t-\t.\ttranscript_id \"T1\";\n")
+	if _, err := FromGtf(bad); err == nil {
+		t.Fatalf("FromGtf should fail without gene_id")
+	}
+	bad = gtfFromText(t,
+		"1\tHAVANA\tgene\t100\t200\t.\t-\t.\tgene_id \"G1\";\n"+
+			"1\tHAVANA\tgene\t100\t200\t.\t-\t.\tgene_id \"G1\";\n")
+	if _, err := FromGtf(bad); err == nil {
+		t.Fatalf("FromGtf should fail on a repeated gene line")
+	}
+}
+
+func TestTreeGtf(t *testing.T) {
+	tr := treeFromText(t,
+		"1\tajgo\tchromosome\t1\t5000\t.\t.\t.\tID=chromosome:1\n"+
+			"1\tajgo\tgene\t100\t900\t.\t+\t.\tID=gene:g1;Name=ONE;biotype=protein_coding;description=one%3B two\n"+
+			"1\tajgo\tmRNA\t100\t900\t.\t+\t.\tID=transcript:t1;Parent=gene:g1;tag=basic,Ensembl_canonical\n"+
+			"1\tajgo\tCDS\t150\t200\t.\t+\t0\tID=CDS:p1;Parent=transcript:t1;protein_id=p1\n"+
+			"1\tajgo\texon\t100\t200\t.\t+\t.\tParent=transcript:t1;rank=1\n"+
+			"1\tajgo\tfive_prime_UTR\t100\t149\t.\t+\t.\tParent=transcript:t1\n"+
+			"1\tajgo\texon\t300\t400\t.\t+\t.\tParent=transcript:t9\n")
+
+	gfs, skipped := tr.Gtf()
+	var got []string
+	for _, f := range gfs {
+		got = append(got, f.SpecString())
+	}
+	e := []string{
+		"1\tajgo\tgene\t100\t900\t.\t+\t.\tgene_id \"g1\"; gene_name \"ONE\"; gene_biotype \"protein_coding\"; description \"one; two\";",
+		"1\tajgo\ttranscript\t100\t900\t.\t+\t.\tgene_id \"g1\"; transcript_id \"t1\"; gene_name \"ONE\"; tag \"basic\"; tag \"Ensembl_canonical\";",
+		"1\tajgo\texon\t100\t200\t.\t+\t.\tgene_id \"g1\"; transcript_id \"t1\"; gene_name \"ONE\"; rank \"1\";",
+		"1\tajgo\tfive_prime_utr\t100\t149\t.\t+\t.\tgene_id \"g1\"; transcript_id \"t1\"; gene_name \"ONE\";",
+		"1\tajgo\tCDS\t150\t200\t.\t+\t0\tgene_id \"g1\"; transcript_id \"t1\"; gene_name \"ONE\"; protein_id \"p1\";",
+	}
+	if strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Fatalf("Gtf should be:\n%s\nbut is:\n%s", strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+	if len(skipped) != 2 || skipped[0].Type != `chromosome` || skipped[1].Type != `exon` {
+		t.Fatalf("Gtf should skip the chromosome and the orphan exon but skipped %d", len(skipped))
+	}
+
+	// and back again
+	fs, err := FromGtf(gfs)
+	if err != nil {
+		t.Fatalf("FromGtf failed: %v", err)
+	}
+	if fs.Count() != 5 || fs.Features[0].Attributes[`ID`] != `gene:g1` ||
+		fs.Features[0].AttributeValues(`description`)[0] != `one; two` ||
+		fs.Features[1].Type != `mRNA` || fs.Features[1].Attributes[`tag`] != `basic,Ensembl_canonical` {
+		t.Fatalf("round trip should recover the gene and mRNA but gave %v", fs.Features)
+	}
+}
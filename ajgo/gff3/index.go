@@ -0,0 +1,206 @@
+package gff3
+
+import (
+	"sort"
+)
+
+// Index answers region queries against a set of Feature without a
+// linear scan. It is built once from a *Features and then queried many
+// times, for example when annotating a large number of qpileup regions
+// against a gene model.
+//
+// For each SeqId, the Feature are held in an array sorted by Start
+// which is treated as an implicit balanced binary tree - the root of
+// any range [lo,hi) is the middle element. Each element also records
+// the largest End in its subtree so whole subtrees that end before a
+// query can be skipped. This is an augmented interval tree without any
+// pointers so it is compact and quick to build. A second array sorted
+// by End is kept for Nearest.
+//
+// All coordinates are GFF3 style, i.e. 1-based and closed. The Index
+// holds pointers to the original Feature so if you change the Start,
+// End or SeqId of an indexed Feature, you must build a new Index.
+type Index struct {
+	seqs  map[string]*seqIndex
+	count int
+}
+
+// seqIndex is the Index for a single SeqId.
+type seqIndex struct {
+	byStart []*Feature
+	maxEnd  []int // largest End in the subtree rooted at each element
+	byEnd   []*Feature
+}
+
+// NewIndex builds an *Index from a *Features. The *Features is not
+// changed.
+func NewIndex(fs *Features) *Index {
+	x := &Index{seqs: make(map[string]*seqIndex)}
+	for _, f := range fs.Features {
+		si, ok := x.seqs[f.SeqId]
+		if !ok {
+			si = &seqIndex{}
+			x.seqs[f.SeqId] = si
+		}
+		si.byStart = append(si.byStart, f)
+		x.count++
+	}
+
+	for _, si := range x.seqs {
+		sort.SliceStable(si.byStart, func(i, j int) bool {
+			if si.byStart[i].Start != si.byStart[j].Start {
+				return si.byStart[i].Start < si.byStart[j].Start
+			}
+			return si.byStart[i].End < si.byStart[j].End
+		})
+		si.maxEnd = make([]int, len(si.byStart))
+		si.augment(0, len(si.byStart))
+
+		si.byEnd = append([]*Feature(nil), si.byStart...)
+		sort.SliceStable(si.byEnd, func(i, j int) bool {
+			return si.byEnd[i].End < si.byEnd[j].End
+		})
+	}
+	return x
+}
+
+// Count returns the number of Feature in the Index.
+func (x *Index) Count() int {
+	return x.count
+}
+
+// SeqIds returns a sorted list of the SeqId in the Index.
+func (x *Index) SeqIds() []string {
+	var ids []string
+	for id := range x.seqs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Overlapping returns all Feature on seqid that overlap the closed
+// interval start-end by at least one base. Feature are returned in
+// Start order.
+func (x *Index) Overlapping(seqid string, start, end int) []*Feature {
+	si, ok := x.seqs[seqid]
+	if !ok {
+		return nil
+	}
+	var found []*Feature
+	si.overlapping(0, len(si.byStart), start, end, &found)
+	return found
+}
+
+// Containing returns all Feature on seqid that contain the position
+// pos. Feature are returned in Start order.
+func (x *Index) Containing(seqid string, pos int) []*Feature {
+	return x.Overlapping(seqid, pos, pos)
+}
+
+// Nearest returns the Feature on seqid that are closest to pos. If any
+// Feature contain pos then they are returned, otherwise the Feature
+// with the smallest gap between pos and their Start or End are
+// returned. There may be more than one Feature if there are ties. An
+// empty strand matches any Feature, otherwise only Feature with the
+// same Strand are considered.
+func (x *Index) Nearest(seqid string, pos int, strand string) []*Feature {
+	si, ok := x.seqs[seqid]
+	if !ok {
+		return nil
+	}
+	match := func(f *Feature) bool {
+		return strand == `` || f.Strand == strand
+	}
+
+	var found []*Feature
+	for _, f := range x.Containing(seqid, pos) {
+		if match(f) {
+			found = append(found, f)
+		}
+	}
+	if len(found) > 0 {
+		return found
+	}
+
+	// Closest Feature that end before pos
+	best := -1
+	var up []*Feature
+	i := sort.Search(len(si.byEnd), func(i int) bool { return si.byEnd[i].End >= pos })
+	for i--; i >= 0; i-- {
+		f := si.byEnd[i]
+		if best >= 0 && pos-f.End > best {
+			break
+		}
+		if match(f) {
+			best = pos - f.End
+			up = append(up, f)
+		}
+	}
+
+	// Closest Feature that start after pos
+	var down []*Feature
+	j := sort.Search(len(si.byStart), func(i int) bool { return si.byStart[i].Start > pos })
+	for ; j < len(si.byStart); j++ {
+		f := si.byStart[j]
+		d := f.Start - pos
+		if best >= 0 && d > best {
+			break
+		}
+		if match(f) {
+			if best < 0 || d < best {
+				best = d
+				up = nil
+				down = down[:0]
+			}
+			down = append(down, f)
+		}
+	}
+
+	// up was collected in descending End order
+	for k := len(up) - 1; k >= 0; k-- {
+		found = append(found, up[k])
+	}
+	return append(found, down...)
+}
+
+// augment fills in maxEnd for the subtree covering [lo,hi) and returns
+// the largest End in it.
+func (si *seqIndex) augment(lo, hi int) int {
+	if lo >= hi {
+		return 0
+	}
+	mid := lo + (hi-lo)/2
+	max := si.byStart[mid].End
+	if m := si.augment(lo, mid); m > max {
+		max = m
+	}
+	if m := si.augment(mid+1, hi); m > max {
+		max = m
+	}
+	si.maxEnd[mid] = max
+	return max
+}
+
+// overlapping does an in-order traversal of the subtree covering
+// [lo,hi), skipping subtrees that cannot contain an overlap.
+func (si *seqIndex) overlapping(lo, hi, start, end int, found *[]*Feature) {
+	if lo >= hi {
+		return
+	}
+	mid := lo + (hi-lo)/2
+	// Nothing in this subtree reaches start
+	if si.maxEnd[mid] < start {
+		return
+	}
+	si.overlapping(lo, mid, start, end, found)
+	f := si.byStart[mid]
+	// This Feature, and everything to its right, starts after end
+	if f.Start > end {
+		return
+	}
+	if f.End >= start {
+		*found = append(*found, f)
+	}
+	si.overlapping(mid+1, hi, start, end, found)
+}
@@ -0,0 +1,94 @@
+package gff3
+
+import (
+	"testing"
+)
+
+func TestIndexOverlapping(t *testing.T) {
+	g, err := NewFromFile(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+	x := NewIndex(g.Features)
+
+	e1 := g.FeatureCount()
+	g1 := x.Count()
+	if e1 != g1 {
+		t.Fatalf("Count should be %d but is %d", e1, g1)
+	}
+
+	// Compare the Index against a linear scan for a range of queries
+	// including ones that fall outside every Feature.
+	for _, seqid := range append(g.SeqIds(), `nosuchseq`) {
+		for start := 1; start < 250000000; start += 1234567 {
+			end := start + 50000
+			var e []*Feature
+			for _, f := range g.Features.Features {
+				if f.SeqId == seqid && f.Start <= end && f.End >= start {
+					e = append(e, f)
+				}
+			}
+			got := x.Overlapping(seqid, start, end)
+			if len(e) != len(got) {
+				t.Fatalf("Overlapping(%s,%d,%d) should find %d Feature but found %d",
+					seqid, start, end, len(e), len(got))
+			}
+			for i := 1; i < len(got); i++ {
+				if got[i].Start < got[i-1].Start {
+					t.Fatalf("Overlapping(%s,%d,%d) is not in Start order", seqid, start, end)
+				}
+			}
+		}
+	}
+}
+
+func TestIndexNearest(t *testing.T) {
+	fs := NewFeatures()
+	for _, l := range []string{
+		"1\tajgo\tgene\t100\t200\t.\t+\t.\tID=a",
+		"1\tajgo\tgene\t150\t400\t.\t-\t.\tID=b",
+		"1\tajgo\tgene\t500\t600\t.\t+\t.\tID=c",
+		"1\tajgo\tgene\t700\t800\t.\t-\t.\tID=d",
+		"1\tajgo\tgene\t1\t1000\t.\t.\t.\tID=e",
+	} {
+		f, err := NewFeatureFromLine(l)
+		if err != nil {
+			t.Fatalf("NewFeatureFromLine failed: %v", err)
+		}
+		fs.Features = append(fs.Features, f)
+	}
+	x := NewIndex(fs)
+
+	var tests = []struct {
+		pos    int
+		strand string
+		ids    string
+	}{
+		{160, ``, `e,a,b`},
+		{160, `+`, `a`},
+		{450, `+`, `c`},
+		{450, `-`, `b`},
+		{650, `+`, `c`},
+		{650, `-`, `d`},
+		{450, `?`, ``},
+		{50, `+`, `a`},
+	}
+	for _, tt := range tests {
+		var ids string
+		for i, f := range x.Nearest(`1`, tt.pos, tt.strand) {
+			if i > 0 {
+				ids += `,`
+			}
+			ids += f.Attributes[`ID`]
+		}
+		if tt.ids != ids {
+			t.Fatalf("Nearest(1,%d,%q) should be %q but is %q", tt.pos, tt.strand, tt.ids, ids)
+		}
+	}
+
+	e1 := 2
+	g1 := len(x.Containing(`1`, 750))
+	if e1 != g1 {
+		t.Fatalf("Containing should find %d Feature but found %d", e1, g1)
+	}
+}
@@ -0,0 +1,112 @@
+package gff3
+
+import (
+	"fmt"
+
+	"ajgo/chain"
+)
+
+// Policies for Feature that span gaps in a chain. See Lifter.
+const (
+	LiftoverDrop  = `drop`
+	LiftoverSplit = `split`
+	LiftoverSpan  = `span`
+)
+
+// Reasons that Lifter could not map a Feature, as recorded in the
+// Unmapped Attribute.
+const (
+	UnmappedNoChain = `no_chain` // no chain aligns any base of the Feature
+	UnmappedGap     = `gap`      // the Feature spans a chain gap and the policy is drop
+	UnmappedPartial = `partial`  // less than MinMatch of the Feature was mapped
+)
+
+// Lifter maps Feature from one genome build to another through UCSC
+// chains. Feature must be 1-based closed, as read from a GFF3 without a
+// ##format header.
+//
+// A Feature is mapped through the chain that aligns the most of its
+// bases. If the chain maps to the - strand of the new build, the Strand
+// of the Feature is flipped. Feature that are not entirely inside a
+// single ungapped block of the chain are handled according to Policy:
+//
+//	drop   the Feature is not mapped
+//	split  one Feature is made for each block, all with the same
+//	       Attributes, as for a multi-line GFF3 feature
+//	span   a single Feature is made from the first mapped base to the
+//	       last
+//
+// For split and span, at least MinMatch (a fraction) of the bases of
+// the Feature must be mapped. Phase is copied unchanged so the CDS of a
+// split Feature should be checked, e.g. with genemodel translate.
+type Lifter struct {
+	Chains   *chain.Chains
+	Policy   string
+	MinMatch float64
+}
+
+// NewLifter returns a *Lifter with the split policy and a MinMatch of
+// 0.95, the default of the UCSC liftOver tool.
+func NewLifter(cs *chain.Chains) *Lifter {
+	return &Lifter{Chains: cs, Policy: LiftoverSplit, MinMatch: 0.95}
+}
+
+// CheckPolicy returns an error if p is not one of the Lifter policies.
+func CheckPolicy(p string) error {
+	switch p {
+	case LiftoverDrop, LiftoverSplit, LiftoverSpan:
+		return nil
+	}
+	return fmt.Errorf("liftover policy not recognised: %s", p)
+}
+
+// Lift maps a Feature and returns the new Feature, which are always
+// new copies. If the Feature cannot be mapped, it returns nil and the
+// reason, e.g. UnmappedGap.
+func (l *Lifter) Lift(f *Feature) ([]*Feature, string) {
+	m := l.Chains.Map(f.SeqId, f.Start-1, f.End)
+	if m == nil {
+		return nil, UnmappedNoChain
+	}
+	if l.Policy == LiftoverDrop {
+		if len(m.Segments) != 1 || m.Mapped != f.Length() {
+			return nil, UnmappedGap
+		}
+	} else if float64(m.Mapped) < l.MinMatch*float64(f.Length()) {
+		return nil, UnmappedPartial
+	}
+
+	strand := f.Strand
+	if m.Reverse() {
+		switch strand {
+		case `+`:
+			strand = `-`
+		case `-`:
+			strand = `+`
+		}
+	}
+
+	// Segments are in target order so on the - strand they run from
+	// high to low positions. Make the output run from low to high.
+	segs := m.Segments
+	if m.Reverse() {
+		segs = make([]chain.Segment, len(m.Segments))
+		for i, s := range m.Segments {
+			segs[len(segs)-1-i] = s
+		}
+	}
+	if l.Policy == LiftoverSpan {
+		segs = []chain.Segment{{Start: segs[0].Start, End: segs[len(segs)-1].End}}
+	}
+
+	var lifted []*Feature
+	for _, s := range segs {
+		n := f.Clone()
+		n.SeqId = m.Name()
+		n.Start = s.Start + 1
+		n.End = s.End
+		n.Strand = strand
+		lifted = append(lifted, n)
+	}
+	return lifted, ``
+}
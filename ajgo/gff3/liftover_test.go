@@ -0,0 +1,57 @@
+package gff3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"ajgo/chain"
+)
+
+func TestLift(t *testing.T) {
+	cs, err := chain.NewFromReader(strings.NewReader(
+		"chain 5000 chr1 1000 + 100 200 1 2000 + 1000 1110 1\n50 10 20\n40\n\n" +
+			"chain 100 chr1 1000 + 300 340 2 1000 - 10 50 2\n10 5 5\n25\n"))
+	if err != nil {
+		t.Fatalf("chain.NewFromReader failed: %v", err)
+	}
+	fs := featuresFromText(t,
+		"chr1\ta\texon\t111\t120\t.\t+\t.\tID=e1\n"+
+			"chr1\ta\texon\t141\t170\t.\t+\t.\tID=e2\n"+
+			"chr1\ta\texon\t301\t325\t.\t+\t.\tID=e3\n"+
+			"chr1\ta\texon\t501\t510\t.\t+\t.\tID=e4\n")
+
+	tests := []struct {
+		policy   string
+		minMatch float64
+		lifted   []string
+	}{
+		{LiftoverDrop, 0.95, []string{"1:1011-1020 +", "gap", "gap", "no_chain"}},
+		{LiftoverSplit, 0.95, []string{"1:1011-1020 +", "partial", "partial", "no_chain"}},
+		{LiftoverSplit, 0.5, []string{"1:1011-1020 +", "1:1041-1050 + 1:1071-1080 +",
+			"2:966-975 - 2:981-990 -", "no_chain"}},
+		{LiftoverSpan, 0.5, []string{"1:1011-1020 +", "1:1041-1080 +", "2:966-990 -", "no_chain"}},
+	}
+	for _, tt := range tests {
+		l := NewLifter(cs)
+		l.Policy = tt.policy
+		l.MinMatch = tt.minMatch
+		for i, f := range fs.Features {
+			lifted, reason := l.Lift(f)
+			var got []string
+			for _, n := range lifted {
+				got = append(got, fmt.Sprintf("%s:%d-%d %s", n.SeqId, n.Start, n.End, n.Strand))
+				if n.Attributes["ID"] != f.Attributes["ID"] {
+					t.Fatalf("Lift should keep the Attributes")
+				}
+			}
+			if reason != "" {
+				got = append(got, reason)
+			}
+			if strings.Join(got, " ") != tt.lifted[i] {
+				t.Fatalf("%s %.2f: %s should lift to %s but lifts to %s",
+					tt.policy, tt.minMatch, featureLabel(f), tt.lifted[i], strings.Join(got, " "))
+			}
+		}
+	}
+}
@@ -0,0 +1,566 @@
+package gff3
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MergeOptions control how close Feature must be to be joined by
+// Consolidate, PrudentMergeByType and Merger, and which of the merged
+// Feature are kept. A nil *MergeOptions is the same as the zero value,
+// which leaves the merges working as they always have.
+//
+// When Adjacent or MaxGap is set, merged Feature made from more than
+// one input Feature get a Joined Attribute with the number of input
+// Feature that went into them. If an input Feature has a Joined
+// Attribute, its count is used so Joined is still right after a merge
+// of merges.
+//
+// GroupBy keeps merging within groups, e.g. one strand or one gene, so
+// overlapping exons from genes on opposite strands stay separate, and
+// Aggregate keeps the Attributes of the merged Feature.
+type MergeOptions struct {
+	// Adjacent joins Feature that meet, i.e. the End of one is the
+	// Start of the next. Consolidate always does this.
+	Adjacent bool
+	// MaxGap joins Feature with up to MaxGap bases between them. A
+	// MaxGap above 0 implies Adjacent.
+	MaxGap int
+	// MinLength drops any merged Feature shorter than MinLength. For
+	// prudent merging, the pieces of a merge that meet each other are
+	// measured together, so a short overlap between two long Feature is
+	// kept.
+	MinLength int
+	// GroupBy lists selector subjects, e.g. strand or attr.gene_id (see
+	// IsFeatureSubject), and only Feature that have the same values for
+	// all of them are merged.
+	GroupBy []string
+	// Aggregate combines the values of Attributes that differ between
+	// the merged Feature, e.g. Name=BRCA1,NBR2, instead of deleting them.
+	Aggregate bool
+}
+
+// check returns an error if the options are not usable.
+func (o *MergeOptions) check() error {
+	if o == nil {
+		return nil
+	}
+	for _, s := range o.GroupBy {
+		if !IsFeatureSubject(s) {
+			return fmt.Errorf("group by subject not recognised: %s", s)
+		}
+	}
+	return nil
+}
+
+// isZero returns true if o is nil or the zero value.
+func (o *MergeOptions) isZero() bool {
+	return o == nil || (!o.Adjacent && o.MaxGap <= 0 && o.MinLength <= 0 &&
+		len(o.GroupBy) == 0 && !o.Aggregate)
+}
+
+// grouped returns true if Feature are only merged within groups.
+func (o *MergeOptions) grouped() bool {
+	return o != nil && len(o.GroupBy) > 0
+}
+
+// aggregates returns true if Attributes are combined when merging.
+func (o *MergeOptions) aggregates() bool {
+	return o != nil && o.Aggregate
+}
+
+// groupKey returns the GroupBy values of a Feature as a single string.
+func (o *MergeOptions) groupKey(f *Feature) string {
+	if !o.grouped() {
+		return ``
+	}
+	keys := make([]string, len(o.GroupBy))
+	for i, s := range o.GroupBy {
+		keys[i] = strings.Join(f.SubjectValues(s), attrSep)
+	}
+	return strings.Join(keys, "\t")
+}
+
+// gap returns the largest gap between Feature that will be joined or
+// -1 if Feature must overlap to be joined.
+func (o *MergeOptions) gap() int {
+	switch {
+	case o == nil:
+		return -1
+	case o.MaxGap > 0:
+		return o.MaxGap
+	case o.Adjacent:
+		return 0
+	}
+	return -1
+}
+
+// tooShort returns true if a merged Feature should be dropped.
+func (o *MergeOptions) tooShort(f *Feature) bool {
+	return o != nil && f.End-f.Start < o.MinLength
+}
+
+// joinedCount returns the number of input Feature in a Feature.
+func joinedCount(f *Feature) int {
+	if n, err := strconv.Atoi(f.Attributes[`Joined`]); err == nil && n > 0 {
+		return n
+	}
+	return 1
+}
+
+// FeatureReader is anything that returns Feature one at a time and a
+// nil *Feature and io.EOF at the end, e.g. *Reader.
+type FeatureReader interface {
+	Read() (*Feature, error)
+}
+
+// NewFeaturesReader returns a FeatureReader over the Feature in fs in
+// their current order.
+func NewFeaturesReader(fs *Features) FeatureReader {
+	return &featuresReader{feats: fs.Features}
+}
+
+type featuresReader struct {
+	feats []*Feature
+	next  int
+}
+
+func (r *featuresReader) Read() (*Feature, error) {
+	if r.next >= len(r.feats) {
+		return nil, io.EOF
+	}
+	r.next++
+	return r.feats[r.next-1], nil
+}
+
+// Merger does a single pass, sweep-line merge of any number of inputs.
+// The result is the same as prudent merging all of the inputs with
+// PrudentMergeByType (see ajgo merge-gff3) - wherever two or more
+// Feature overlap, the overlap becomes a new Feature with Source
+// ajgo-merge and Sources, Types and IDs Attributes from all of the
+// Feature that cover it, and the parts of a Feature that do not overlap
+// anything are Clones of the Feature with adjusted Start and End. The
+// Feature returned by Read are sorted and do not overlap.
+//
+// If Options has GroupBy, each group is merged separately so Feature
+// from different groups can overlap. Every input is read in full on the
+// first call to Read so a grouped merge does not stream. With GroupBy
+// or Aggregate, merged Feature keep the Strand of their inputs if they
+// all share it and with Aggregate, the other Attributes of the inputs
+// are combined as for MergeOptions Aggregate.
+//
+// Options can be set before the first call to Read to join sections
+// that meet or are separated by small gaps and to drop short sections.
+// A group of joined sections becomes a single ajgo-merge Feature with
+// Sources, Types and IDs from all of the Feature in the group, and a
+// Joined Attribute.
+//
+// Each input must be sorted by SeqId, in the order given by the
+// Merger's SeqOrder, and then by Start, e.g. with Features.Sort, and
+// Start and End are treated as half-open. Because the inputs are only
+// read as far as is needed to decide what comes next, only the Feature
+// that overlap the current position are held in memory so arbitrarily
+// large GFF3s can be merged by passing a *Reader for each file. Read
+// returns an error if an input turns out not to be sorted.
+type Merger struct {
+	Options *MergeOptions
+
+	order  *SeqOrder
+	inputs []*mergeInput
+	seqid  string          // SeqId being swept
+	swept  map[string]bool // SeqIds already finished
+	pos    int             // sweep position on seqid
+	active []*Feature      // Feature that cover pos
+	out    []*Feature      // merged Feature waiting for Read
+	group  *mergeGroup     // sections being joined
+	run    mergeRun        // merged Feature that meet, for MinLength
+	drops  int
+	split  bool // GroupBy has been applied
+	err    error
+}
+
+// mergeGroup is a run of sections that are close enough to be joined.
+type mergeGroup struct {
+	first  *Feature // merged Feature for the first section
+	end    int
+	inputs []*Feature // input Feature, in the order they were seen
+	seen   map[*Feature]bool
+	count  int // sections in the group
+}
+
+// mergeRun is a run of merged Feature that meet each other. The
+// Feature are held back until the run is MinLength long, after which
+// the rest of the run goes straight to Read.
+type mergeRun struct {
+	seqid string
+	start int
+	end   int
+	long  bool
+	feats []*Feature
+}
+
+type mergeInput struct {
+	r    FeatureReader
+	head *Feature // next Feature from r, nil once r is exhausted
+	last *Feature
+}
+
+// NewMerger returns a *Merger that will merge the Feature from inputs.
+// order must be the SeqId order the inputs are sorted in - nil is
+// lexical.
+func NewMerger(order *SeqOrder, inputs ...FeatureReader) *Merger {
+	m := &Merger{order: order, swept: make(map[string]bool)}
+	for _, r := range inputs {
+		m.inputs = append(m.inputs, &mergeInput{r: r})
+	}
+	for i, in := range m.inputs {
+		if err := m.advance(in); err != nil {
+			m.err = fmt.Errorf("Merger: input %d: %w", i, err)
+			break
+		}
+	}
+	return m
+}
+
+// Read returns the next merged Feature. At the end of the inputs, it
+// returns a nil *Feature and io.EOF. Any other error is permanent.
+func (m *Merger) Read() (*Feature, error) {
+	for len(m.out) == 0 {
+		if m.err != nil {
+			return nil, m.err
+		}
+		m.err = m.step()
+	}
+	f := m.out[0]
+	m.out = m.out[1:]
+	return f, nil
+}
+
+// ReadAll reads all of the merged Feature.
+func (m *Merger) ReadAll() (*Features, error) {
+	nfs := NewFeatures()
+	nfs.SeqOrder = m.order
+	for {
+		f, err := m.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		nfs.Features = append(nfs.Features, f)
+	}
+	nfs.IsSorted = true
+	return nfs, nil
+}
+
+// step moves the sweep on to the next position where the set of
+// Feature covering the sweep changes, queueing a merged Feature for
+// the section that was swept over.
+func (m *Merger) step() error {
+	if m.Options.grouped() && !m.split {
+		m.split = true
+		if err := m.mergeGroups(); err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	if len(m.active) == 0 {
+		// Jump to the next Feature, possibly on a new SeqId
+		var next *Feature
+		for _, in := range m.inputs {
+			if in.head != nil && (next == nil || m.before(in.head, next)) {
+				next = in.head
+			}
+		}
+		if next == nil {
+			m.flush()
+			m.endRun()
+			return io.EOF
+		}
+		if next.SeqId != m.seqid {
+			m.flush()
+			m.endRun()
+			m.swept[m.seqid] = true
+			m.seqid = next.SeqId
+		}
+		m.pos = next.Start
+		return m.activate()
+	}
+
+	// The section ends where the first active Feature ends or the next
+	// Feature starts, whichever is first.
+	end := m.active[0].End
+	for _, f := range m.active[1:] {
+		if f.End < end {
+			end = f.End
+		}
+	}
+	for _, in := range m.inputs {
+		if in.head != nil && in.head.SeqId == m.seqid && in.head.Start < end {
+			end = in.head.Start
+		}
+	}
+
+	m.section(m.active, m.pos, end)
+	m.pos = end
+	var active []*Feature
+	for _, f := range m.active {
+		if f.End > m.pos {
+			active = append(active, f)
+		}
+	}
+	m.active = active
+	return m.activate()
+}
+
+// activate moves every input Feature that starts at the sweep position
+// into active.
+func (m *Merger) activate() error {
+	for i, in := range m.inputs {
+		for in.head != nil && in.head.SeqId == m.seqid && in.head.Start == m.pos {
+			m.active = append(m.active, in.head)
+			if err := m.advance(in); err != nil {
+				return fmt.Errorf("Merger: input %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// advance reads the next Feature from an input and checks that the
+// input is sorted.
+func (m *Merger) advance(in *mergeInput) error {
+	f, err := in.r.Read()
+	if err == io.EOF {
+		in.head = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if f.End <= f.Start {
+		return fmt.Errorf("Feature has End <= Start: %s", featureLabel(f))
+	}
+	if m.swept[f.SeqId] || (in.last != nil &&
+		(m.order.Less(f.SeqId, in.last.SeqId) || (f.SeqId == in.last.SeqId && f.Start < in.last.Start))) {
+		return fmt.Errorf("input is not sorted (SeqId order %s): %s follows %s",
+			m.order, featureLabel(f), featureLabel(in.last))
+	}
+	in.head = f
+	in.last = f
+	return nil
+}
+
+// before returns true if a comes before b in the merge order.
+func (m *Merger) before(a, b *Feature) bool {
+	if a.SeqId != b.SeqId {
+		return m.order.Less(a.SeqId, b.SeqId)
+	}
+	return a.Start < b.Start
+}
+
+// Dropped returns the number of merged Feature that were dropped
+// because they, and any merged Feature they meet, were shorter than
+// Options.MinLength.
+func (m *Merger) Dropped() int {
+	return m.drops
+}
+
+// section handles a swept section, adding it to the current group if
+// it is close enough to be joined.
+func (m *Merger) section(covering []*Feature, start, end int) {
+	gap := m.Options.gap()
+	if gap < 0 {
+		m.keep(m.mergedSection(covering, start, end))
+		return
+	}
+	g := m.group
+	if g == nil || start-g.end > gap {
+		m.flush()
+		g = &mergeGroup{first: m.mergedSection(covering, start, end), seen: make(map[*Feature]bool)}
+		m.group = g
+	}
+	g.end = end
+	g.count++
+	for _, f := range covering {
+		if !g.seen[f] {
+			g.seen[f] = true
+			g.inputs = append(g.inputs, f)
+		}
+	}
+}
+
+// flush finishes the current group. A group of one section is the
+// section unchanged.
+func (m *Merger) flush() {
+	g := m.group
+	if g == nil {
+		return
+	}
+	m.group = nil
+	if g.count == 1 {
+		m.keep(g.first)
+		return
+	}
+	f := m.mergedSection(g.inputs, g.first.Start, g.end)
+	var n int
+	for _, in := range g.inputs {
+		n += joinedCount(in)
+	}
+	f.Attributes[`Joined`] = strconv.Itoa(n)
+	m.keep(f)
+}
+
+// keep queues a merged Feature for Read unless it is part of a run of
+// merged Feature that is too short.
+func (m *Merger) keep(f *Feature) {
+	if m.Options == nil || m.Options.MinLength <= 0 {
+		m.out = append(m.out, f)
+		return
+	}
+	r := &m.run
+	if (r.feats == nil && !r.long) || f.SeqId != r.seqid || f.Start != r.end {
+		m.endRun()
+		r.seqid = f.SeqId
+		r.start = f.Start
+	}
+	r.end = f.End
+	if r.long {
+		m.out = append(m.out, f)
+		return
+	}
+	r.feats = append(r.feats, f)
+	if r.end-r.start >= m.Options.MinLength {
+		r.long = true
+		m.out = append(m.out, r.feats...)
+		r.feats = nil
+	}
+}
+
+// endRun drops the current run of merged Feature if it never got to
+// be MinLength long.
+func (m *Merger) endRun() {
+	m.drops += len(m.run.feats)
+	m.run = mergeRun{}
+}
+
+// mergedSection returns the Feature for a section covered by one or
+// more Feature.
+func (m *Merger) mergedSection(covering []*Feature, start, end int) *Feature {
+	var f *Feature
+	if len(covering) == 1 {
+		f = covering[0].Clone()
+		f.barrierAfter = false
+	} else {
+		f = newOverlapFeature(covering...)
+		if m.Options.grouped() || m.Options.aggregates() {
+			f.Strand = covering[0].Strand
+			for _, c := range covering[1:] {
+				if c.Strand != f.Strand {
+					f.Strand = `.`
+				}
+			}
+		}
+		if m.Options.aggregates() {
+			aggregateInto(f, covering)
+		}
+	}
+	f.Start = start
+	f.End = end
+	return f
+}
+
+// aggregateInto adds the Attribute values of feats to an ajgo-merge
+// Feature, apart from the Attributes that newOverlapFeature sets.
+func aggregateInto(f *Feature, feats []*Feature) {
+	for _, in := range feats {
+		for _, k := range in.AttributeKeys() {
+			switch k {
+			case `ID`, `IDs`, `Sources`, `Types`, `Joined`:
+				continue
+			}
+			for _, v := range in.AttributeValues(k) {
+				f.AddAttributeValue(k, v)
+			}
+		}
+	}
+}
+
+// mergeGroups reads all of the inputs, splits them by Options.GroupBy
+// and merges each group with its own Merger.
+func (m *Merger) mergeGroups() error {
+	if err := m.Options.check(); err != nil {
+		return fmt.Errorf("Merger: %w", err)
+	}
+
+	// Keep each group's Feature apart by input so every input to a
+	// group Merger is still sorted.
+	var keys []string
+	groups := make(map[string][]*Features)
+	for i, in := range m.inputs {
+		for in.head != nil {
+			k := m.Options.groupKey(in.head)
+			fss, ok := groups[k]
+			if !ok {
+				fss = make([]*Features, len(m.inputs))
+				groups[k] = fss
+				keys = append(keys, k)
+			}
+			if fss[i] == nil {
+				fss[i] = NewFeatures()
+			}
+			fss[i].Features = append(fss[i].Features, in.head)
+			if err := m.advance(in); err != nil {
+				return fmt.Errorf("Merger: input %d: %w", i, err)
+			}
+		}
+	}
+
+	var merged []*Feature
+	for _, k := range keys {
+		var inputs []FeatureReader
+		for _, fs := range groups[k] {
+			if fs != nil {
+				inputs = append(inputs, NewFeaturesReader(fs))
+			}
+		}
+		gm := NewMerger(m.order, inputs...)
+		gm.Options = m.Options
+		gm.split = true
+		nfs, err := gm.ReadAll()
+		if err != nil {
+			return err
+		}
+		m.drops += gm.drops
+		merged = append(merged, nfs.Features...)
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].SeqId != merged[j].SeqId || merged[i].Start != merged[j].Start {
+			return m.before(merged[i], merged[j])
+		}
+		return merged[i].End < merged[j].End
+	})
+	m.out = merged
+	return nil
+}
+
+// MergeAll merges any number of Features in a single pass with a
+// Merger. The inputs are not changed and need not be sorted. The
+// returned Features is sorted using order. o may be nil.
+func MergeAll(order *SeqOrder, o *MergeOptions, fss ...*Features) (*Features, error) {
+	var inputs []FeatureReader
+	for _, fs := range fss {
+		sfs := &Features{Features: fs.Features, SeqOrder: order}
+		sfs.Sort()
+		inputs = append(inputs, NewFeaturesReader(sfs))
+	}
+	m := NewMerger(order, inputs...)
+	m.Options = o
+	return m.ReadAll()
+}
@@ -0,0 +1,319 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+)
+
+// mergedString describes merged Feature as Start-End plus either the
+// ID or, for an overlap, the IDs Attribute.
+func mergedString(fs *Features) string {
+	var s []string
+	for _, f := range fs.Features {
+		label := f.Attributes[`ID`]
+		if f.Source == `ajgo-merge` {
+			label = `[` + f.Attributes[`IDs`] + `]`
+		}
+		s = append(s, spansString(&Features{Features: []*Feature{f}})+`=`+label)
+	}
+	return strings.Join(s, " ")
+}
+
+func TestMergeAll(t *testing.T) {
+	a := featuresFromText(t,
+		"1\ta\tr\t100\t200\t.\t.\t.\tID=a1\n"+
+			"1\ta\tr\t200\t300\t.\t.\t.\tID=a2\n"+
+			"2\ta\tr\t100\t200\t.\t.\t.\tID=a3\n")
+	b := featuresFromText(t,
+		"1\tb\tr\t150\t250\t.\t.\t.\tID=b1\n"+
+			"1\tb\tr\t200\t300\t.\t.\t.\tID=b2\n")
+	c := featuresFromText(t,
+		"2\tc\tx\t100\t120\t.\t.\t.\tID=c1\n"+
+			"1\tc\tx\t90\t400\t.\t.\t.\tID=c2\n")
+
+	m, err := MergeAll(nil, nil, a, b, c)
+	if err != nil {
+		t.Fatalf("MergeAll failed: %v", err)
+	}
+	e := "1:90-100=c2 1:100-150=[a1,c2] 1:150-200=[a1,b1,c2] " +
+		"1:200-250=[a2,b1,b2,c2] 1:250-300=[a2,b2,c2] 1:300-400=c2 " +
+		"2:100-120=[a3,c1] 2:120-200=a3"
+	if got := mergedString(m); got != e {
+		t.Fatalf("MergeAll should give\n%s\nbut gave\n%s", e, got)
+	}
+	if o := m.Features[3]; o.Attributes[`Sources`] != `a,b,c` || o.Attributes[`Types`] != `r,x` {
+		t.Fatalf("overlap should have Sources a,b,c and Types r,x but has %s and %s",
+			o.Attributes[`Sources`], o.Attributes[`Types`])
+	}
+	if a.Features[0].Start != 100 || a.Features[0].End != 200 {
+		t.Fatalf("MergeAll should not change its inputs")
+	}
+
+	// Feature that meet are not merged or lost
+	m, _ = MergeAll(nil, nil, featuresFromText(t,
+		"1\ta\tr\t1\t101\t.\t.\t.\tID=a1\n1\ta\tr\t101\t201\t.\t.\t.\tID=a2\n"),
+		featuresFromText(t, "1\tb\tr\t1\t101\t.\t.\t.\tID=b1\n1\tb\tr\t101\t201\t.\t.\t.\tID=b2\n"))
+	if got, e := mergedString(m), "1:1-101=[a1,b1] 1:101-201=[a2,b2]"; got != e {
+		t.Fatalf("MergeAll of meeting Feature should give %s but gave %s", e, got)
+	}
+
+	// Merging an earlier merge gives the same as merging in one go
+	ab, _ := MergeAll(nil, nil, a, b)
+	m2, _ := MergeAll(nil, nil, ab, c)
+	m, _ = MergeAll(nil, nil, a, b, c)
+	if mergedString(m2) != mergedString(m) {
+		t.Fatalf("MergeAll of a merge should give\n%s\nbut gave\n%s", mergedString(m), mergedString(m2))
+	}
+}
+
+// The sweep must agree with pairwise prudent merging.
+func TestMergerMatchesPrudentMerge(t *testing.T) {
+	text := "1\ta\tr\t100\t200\t.\t.\t.\tID=x1\n" +
+		"1\ta\tr\t100\t150\t.\t.\t.\tID=x2\n" +
+		"1\tb\tr\t120\t130\t.\t.\t.\tID=x3\n" +
+		"1\tb\tr\t150\t260\t.\t.\t.\tID=x4\n" +
+		"1\ta\ts\t260\t300\t.\t.\t.\tID=x5\n" +
+		"1\ta\ts\t400\t500\t.\t.\t.\tID=x6\n" +
+		"1\tb\ts\t400\t500\t.\t.\t.\tID=x7\n"
+
+	pm := featuresFromText(t, text)
+	pm.Sort()
+	if err := pm.PrudentMergeByType(nil); err != nil {
+		t.Fatalf("PrudentMergeByType failed: %v", err)
+	}
+	m, err := NewMerger(nil, NewFeaturesReader(featuresFromText(t, text))).ReadAll()
+	if err != nil {
+		t.Fatalf("Merger failed: %v", err)
+	}
+	if mergedString(m) != mergedString(pm) {
+		t.Fatalf("Merger should give\n%s\nbut gave\n%s", mergedString(pm), mergedString(m))
+	}
+}
+
+func TestMergerStreaming(t *testing.T) {
+	r1, err := NewReader(strings.NewReader("##gff-version 3\n" +
+		"chr2\ta\tr\t100\t200\t.\t.\t.\tID=a1\n" +
+		"chr10\ta\tr\t100\t200\t.\t.\t.\tID=a2\n"))
+	if err != nil {
+		t.Fatalf("NewReader failed: %v", err)
+	}
+	r2, _ := NewReader(strings.NewReader("##gff-version 3\n" +
+		"chr1\tb\tr\t100\t200\t.\t.\t.\tID=b1\n" +
+		"chr10\tb\tr\t150\t200\t.\t.\t.\tID=b2\n"))
+	m, err := NewMerger(NaturalSeqOrder(), r1, r2).ReadAll()
+	if err != nil {
+		t.Fatalf("Merger failed: %v", err)
+	}
+	e := "chr1:100-200=b1 chr2:100-200=a1 chr10:100-150=a2 chr10:150-200=[a2,b2]"
+	if got := mergedString(m); got != e {
+		t.Fatalf("Merger should give %s but gave %s", e, got)
+	}
+
+	// The same input is not sorted in lexical order
+	r1, _ = NewReader(strings.NewReader("##gff-version 3\n" +
+		"chr2\ta\tr\t100\t200\t.\t.\t.\tID=a1\n" +
+		"chr10\ta\tr\t100\t200\t.\t.\t.\tID=a2\n"))
+	if _, err := NewMerger(nil, r1).ReadAll(); err == nil {
+		t.Fatalf("Merger should fail on an unsorted input")
+	}
+}
+
+func TestMergeOptions(t *testing.T) {
+	text := "1\ta\tr\t100\t200\t.\t.\t.\tID=x1\n" +
+		"1\tb\tr\t150\t200\t.\t.\t.\tID=x2\n" +
+		"1\ta\tr\t200\t210\t.\t.\t.\tID=x3\n" +
+		"1\ta\tr\t215\t300\t.\t.\t.\tID=x4\n" +
+		"1\tb\tr\t400\t402\t.\t.\t.\tID=x5\n"
+
+	var tests = []struct {
+		name string
+		o    *MergeOptions
+		e    string
+	}{
+		{"nil", nil,
+			"1:100-150=x1 1:150-200=[x1,x2] 1:200-210=x3 1:215-300=x4 1:400-402=x5"},
+		{"adjacent", &MergeOptions{Adjacent: true},
+			"1:100-210=[x1,x2,x3] 1:215-300=x4 1:400-402=x5"},
+		{"gap", &MergeOptions{MaxGap: 5},
+			"1:100-300=[x1,x2,x3,x4] 1:400-402=x5"},
+		{"min-length", &MergeOptions{MinLength: 20},
+			"1:100-150=x1 1:150-200=[x1,x2] 1:200-210=x3 1:215-300=x4"},
+		{"gap+min-length", &MergeOptions{MaxGap: 5, MinLength: 3},
+			"1:100-300=[x1,x2,x3,x4]"},
+	}
+	for _, tt := range tests {
+		m, err := MergeAll(nil, tt.o, featuresFromText(t, text))
+		if err != nil {
+			t.Fatalf("%s: MergeAll failed: %v", tt.name, err)
+		}
+		if got := mergedString(m); got != tt.e {
+			t.Fatalf("%s: MergeAll should give\n%s\nbut gave\n%s", tt.name, tt.e, got)
+		}
+
+		pm := featuresFromText(t, text)
+		pm.Sort()
+		if err := pm.PrudentMergeByType(tt.o); err != nil {
+			t.Fatalf("%s: PrudentMergeByType failed: %v", tt.name, err)
+		}
+		if got := mergedString(pm); got != tt.e {
+			t.Fatalf("%s: PrudentMergeByType should give\n%s\nbut gave\n%s", tt.name, tt.e, got)
+		}
+	}
+
+	// Joined counts the inputs, including those in an earlier merge
+	m, _ := MergeAll(nil, &MergeOptions{Adjacent: true}, featuresFromText(t, text))
+	if j := m.Features[0].Attributes[`Joined`]; j != `3` {
+		t.Fatalf("Joined should be 3 but is %q", j)
+	}
+	m, _ = MergeAll(nil, &MergeOptions{MaxGap: 5}, m)
+	if j := m.Features[0].Attributes[`Joined`]; j != `4` {
+		t.Fatalf("Joined after a second merge should be 4 but is %q", j)
+	}
+
+	// Consolidate always joins Feature that meet
+	for _, tt := range []struct {
+		o *MergeOptions
+		e string
+	}{
+		{nil, "1:100-210 1:215-300 1:400-402"},
+		{&MergeOptions{MaxGap: 5}, "1:100-300 1:400-402"},
+		{&MergeOptions{MinLength: 3}, "1:100-210 1:215-300"},
+	} {
+		fs := featuresFromText(t, text)
+		fs.Sort()
+		if err := fs.Consolidate(tt.o); err != nil {
+			t.Fatalf("Consolidate failed: %v", err)
+		}
+		if got := spansString(fs); got != tt.e {
+			t.Fatalf("Consolidate(%+v) should give %s but gave %s", tt.o, tt.e, got)
+		}
+		if tt.o != nil && tt.o.MaxGap > 0 && fs.Features[0].Attributes[`Joined`] != `4` {
+			t.Fatalf("Consolidate should set Joined to 4 but set %q", fs.Features[0].Attributes[`Joined`])
+		}
+	}
+}
+
+func TestMergeOptionsZero(t *testing.T) {
+	text := "1\ta\tr\t100\t200\t.\t.\t.\tID=x1\n" +
+		"1\tb\tr\t150\t250\t.\t.\t.\tID=x2\n" +
+		"1\ta\tr\t250\t300\t.\t.\t.\tID=x3\n"
+
+	// The zero value works the same as nil
+	for _, o := range []*MergeOptions{nil, {}} {
+		fs := featuresFromText(t, text)
+		fs.Sort()
+		if err := fs.Consolidate(o); err != nil {
+			t.Fatalf("Consolidate failed: %v", err)
+		}
+		if j, ok := fs.Features[0].Attributes[`Joined`]; ok {
+			t.Fatalf("Consolidate(%+v) should not set Joined but set %q", o, j)
+		}
+
+		pm := featuresFromText(t, text)
+		pm.Sort()
+		if err := pm.PrudentMergeByType(o); err != nil {
+			t.Fatalf("PrudentMergeByType failed: %v", err)
+		}
+		e := "1:100-150=x1 1:150-200=[x1,x2] 1:200-250=x2 1:250-300=x3"
+		if got := mergedString(pm); got != e {
+			t.Fatalf("PrudentMergeByType(%+v) should give %s but gave %s", o, e, got)
+		}
+	}
+}
+
+func TestMergeMinLengthOverlap(t *testing.T) {
+	// Two long Feature that overlap by less than MinLength keep their
+	// overlap but an isolated short Feature is dropped.
+	a := featuresFromText(t, "1\ta\tr\t1\t100\t.\t.\t.\tID=y1\n"+
+		"1\ta\tr\t300\t305\t.\t.\t.\tID=y3\n")
+	b := featuresFromText(t, "1\tb\tr\t95\t200\t.\t.\t.\tID=y2\n")
+	m := NewMerger(nil, NewFeaturesReader(a), NewFeaturesReader(b))
+	m.Options = &MergeOptions{MinLength: 10}
+	fs, err := m.ReadAll()
+	if err != nil {
+		t.Fatalf("Merger failed: %v", err)
+	}
+	e := "1:1-95=y1 1:95-100=[y1,y2] 1:100-200=y2"
+	if got := mergedString(fs); got != e {
+		t.Fatalf("Merger should give %s but gave %s", e, got)
+	}
+	if m.Dropped() != 1 {
+		t.Fatalf("Merger should drop 1 Feature but dropped %d", m.Dropped())
+	}
+}
+
+func TestMergeGroupAggregate(t *testing.T) {
+	// Overlapping exons from two genes on opposite strands
+	text := "1\ta\texon\t100\t200\t.\t+\t.\tID=e1;Name=BRCA1;gene_id=g1\n" +
+		"1\ta\texon\t150\t250\t.\t-\t.\tID=e2;Name=NBR2;gene_id=g2\n" +
+		"1\ta\texon\t180\t300\t.\t+\t.\tID=e3;Name=BRCA1;gene_id=g1\n"
+
+	var tests = []struct {
+		name string
+		o    *MergeOptions
+		e    string
+	}{
+		{"nil", nil, "1:100-300"},
+		{"strand", &MergeOptions{GroupBy: []string{`strand`}}, "1:100-300 1:150-250"},
+		{"gene", &MergeOptions{GroupBy: []string{`attr.gene_id`}}, "1:100-300 1:150-250"},
+	}
+	for _, tt := range tests {
+		fs := featuresFromText(t, text)
+		fs.Sort()
+		if err := fs.Consolidate(tt.o); err != nil {
+			t.Fatalf("%s: Consolidate failed: %v", tt.name, err)
+		}
+		if got := spansString(fs); got != tt.e {
+			t.Fatalf("%s: Consolidate should give %s but gave %s", tt.name, tt.e, got)
+		}
+	}
+
+	// Consolidate deletes Attributes that differ unless they are aggregated
+	fs := featuresFromText(t, text)
+	fs.Sort()
+	fs.Consolidate(nil)
+	if f := fs.Features[0]; f.Strand != `.` || f.Attributes[`Name`] != `` || f.Attributes[`ID`] != `` {
+		t.Fatalf("Consolidate should delete Strand, Name and ID but gave %s", f)
+	}
+	fs = featuresFromText(t, text)
+	fs.Sort()
+	fs.Consolidate(&MergeOptions{Aggregate: true})
+	if f := fs.Features[0]; f.Attributes[`Name`] != `BRCA1,NBR2` ||
+		f.Attributes[`gene_id`] != `g1,g2` || f.Attributes[`IDs`] != `e1,e2,e3` {
+		t.Fatalf("Consolidate should aggregate Name, gene_id and IDs but gave %s", f)
+	}
+	fs = featuresFromText(t, text)
+	fs.Sort()
+	fs.Consolidate(&MergeOptions{GroupBy: []string{`strand`}, Aggregate: true})
+	if f := fs.Features[0]; f.Strand != `+` || f.Attributes[`Name`] != `BRCA1` || f.Attributes[`IDs`] != `e1,e3` {
+		t.Fatalf("Consolidate by strand should keep Strand + and Name BRCA1 but gave %s", f)
+	}
+
+	// Merger keeps the groups apart and the Strand they share
+	m, err := MergeAll(nil, &MergeOptions{GroupBy: []string{`strand`}, Aggregate: true},
+		featuresFromText(t, text))
+	if err != nil {
+		t.Fatalf("MergeAll failed: %v", err)
+	}
+	e := "1:100-180=e1 1:150-250=e2 1:180-200=[e1,e3] 1:200-300=e3"
+	if got := mergedString(m); got != e {
+		t.Fatalf("MergeAll by strand should give\n%s\nbut gave\n%s", e, got)
+	}
+	if o := m.Features[2]; o.Strand != `+` || o.Attributes[`Name`] != `BRCA1` || o.Attributes[`gene_id`] != `g1` {
+		t.Fatalf("grouped overlap should have Strand + and Name BRCA1 but gave %s", o)
+	}
+	m, _ = MergeAll(nil, &MergeOptions{Aggregate: true}, featuresFromText(t, text))
+	if o := m.Features[1]; o.Strand != `.` || o.Attributes[`Name`] != `BRCA1,NBR2` {
+		t.Fatalf("overlap should have Strand . and Name BRCA1,NBR2 but gave %s", o)
+	}
+
+	bad := &MergeOptions{GroupBy: []string{`colour`}}
+	if _, err := MergeAll(nil, bad, featuresFromText(t, text)); err == nil {
+		t.Fatalf("MergeAll should fail on an unknown GroupBy subject")
+	}
+	fs = featuresFromText(t, text)
+	fs.Sort()
+	if err := fs.Consolidate(bad); err == nil {
+		t.Fatalf("Consolidate should fail on an unknown GroupBy subject")
+	}
+}
@@ -0,0 +1,279 @@
+package gff3
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+// Reader reads a GFF3 one Feature at a time. This is the alternative to
+// NewFromFile for GFF3 files that are too large to comfortably hold in
+// memory, e.g. a full gene model or the multi-million record GFF3s
+// from the qpileup and genome modes.
+//
+// The header lines are read (and the gff-version checked) when the
+// Reader is created so they are available in Header before the first
+// call to Read. Any comment lines found between Feature records are
+// appended to Header as they are encountered so Header is only
+// complete once Read has returned io.EOF.
+//
+// A ### directive is recorded against the Feature that precedes it so
+// that Writer can put it back in the same place. Reading stops at a
+// ##FASTA directive (or the first line starting with >) and any
+// sequences that follow are parsed into Sequences which, like Header,
+// is only complete once Read has returned io.EOF.
+type Reader struct {
+	File      string
+	Header    []string
+	Sequences []*genome.Sequence
+
+	scanner *bufio.Scanner
+	closers []io.Closer
+	lctr    int
+	last    *Feature // last Feature returned by Read
+	next    *Feature // first Feature, read while gathering headers
+	nextErr error    // error from parsing the first Feature
+	err     error
+}
+
+// NewReader creates a *Reader from an io.Reader. It reads and checks
+// the header lines so it will return ErrNoGff3Headers if the input does
+// not start with header lines or an error if the first header line is
+// not a GFF3 version 3 identifier.
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	// GFF3 attribute columns can be very long so allow for big lines.
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	return newReaderFromScanner(scanner)
+}
+
+// NewReaderFromFile opens a file and creates a *Reader. Files with a
+// .gz extension are gunzipped on-the-fly. The caller must call Close
+// when finished with the Reader.
+func NewReaderFromFile(file string) (*Reader, error) {
+	ff, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Based on file extension, handle gzip files
+	var r io.Reader = ff
+	closers := []io.Closer{ff}
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		ff.Close()
+		return nil, fmt.Errorf("NewReaderFromFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz, err := gzip.NewReader(ff)
+		if err != nil {
+			ff.Close()
+			return nil, fmt.Errorf("NewReaderFromFile: error opening gzip file %s: %w", file, err)
+		}
+		r = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	gr, err := NewReader(r)
+	if err != nil {
+		for _, c := range closers {
+			c.Close()
+		}
+		return nil, fmt.Errorf("NewReaderFromFile: %s: %w", file, err)
+	}
+	gr.File = file
+	gr.closers = closers
+	return gr, nil
+}
+
+// newReaderFromScanner does the work for NewReader and also lets
+// NewFromScanner share the header logic.
+func newReaderFromScanner(scanner *bufio.Scanner) (*Reader, error) {
+	r := &Reader{scanner: scanner}
+
+	// Unnecessary but explicit
+	r.scanner.Split(bufio.ScanLines)
+
+	// Read header lines up to and including the first Feature.
+	for r.scanner.Scan() {
+		line := strings.TrimSuffix(r.scanner.Text(), "\n")
+		r.lctr++
+		if isFastaStart(line) {
+			r.readFasta(line)
+			break
+		}
+		if r.addIfHeader(line) {
+			continue
+		}
+		f, err := NewFeatureFromLine(line)
+		if err != nil {
+			// Hold the error so it is returned by the first Read
+			r.nextErr = &ParseError{LineNumber: r.lctr, Line: line, Err: err}
+			break
+		}
+		f.LineNumber = r.lctr
+		r.next = f
+		break
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("NewReader: error scanning: %w", err)
+	}
+
+	// If there are no Headers then it can't be a gff3 because the
+	// gff-version on the first line is mandatory.
+	if len(r.Header) == 0 {
+		return nil, ErrNoGff3Headers
+	}
+
+	// If the very first line is not the gff3 identifier then we exit
+	// immediately.
+	if !rexGffVersion.MatchString(r.Header[0]) {
+		return nil, fmt.Errorf("NewReader: file is not a gff3, first line is: %s", r.Header[0])
+	}
+
+	return r, nil
+}
+
+// ParseError is returned by Read when a line cannot be parsed into a
+// Feature. It is not fatal - Read can be called again to continue with
+// the next line, which is useful when validating a file.
+type ParseError struct {
+	LineNumber int
+	Line       string
+	Err        error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error creating Feature at line %d: %v", e.LineNumber, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Read returns the next Feature. At the end of the input, it returns
+// a nil *Feature and io.EOF. If a line cannot be parsed, a *ParseError
+// is returned and reading may continue. Any other error is permanent
+// and every subsequent call will return the same error.
+func (r *Reader) Read() (*Feature, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.next != nil {
+		f := r.next
+		r.next = nil
+		r.last = f
+		return f, nil
+	}
+	if r.nextErr != nil {
+		err := r.nextErr
+		r.nextErr = nil
+		return nil, err
+	}
+
+	for r.scanner.Scan() {
+		line := strings.TrimSuffix(r.scanner.Text(), "\n")
+		r.lctr++
+		if isFastaStart(line) {
+			r.readFasta(line)
+			break
+		}
+		if r.addIfHeader(line) {
+			continue
+		}
+		f, err := NewFeatureFromLine(line)
+		if err != nil {
+			return nil, &ParseError{LineNumber: r.lctr, Line: line, Err: err}
+		}
+		f.LineNumber = r.lctr
+		r.last = f
+		return f, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		r.err = fmt.Errorf("Read: error scanning: %w", err)
+		return nil, r.err
+	}
+
+	r.err = io.EOF
+	return nil, r.err
+}
+
+// Close closes any files opened by NewReaderFromFile. It is safe to
+// call Close on a Reader created by NewReader.
+func (r *Reader) Close() error {
+	var first error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	r.closers = nil
+	return first
+}
+
+// addIfHeader returns true if line is a comment line. Comment lines
+// other than ### are added to Header. A ### is recorded against the
+// last Feature read.
+func (r *Reader) addIfHeader(line string) bool {
+	if !strings.HasPrefix(line, `#`) {
+		return false
+	}
+	if strings.TrimSpace(line) == `###` {
+		if r.last != nil {
+			r.last.barrierAfter = true
+		}
+		return true
+	}
+	r.Header = append(r.Header, line)
+	return true
+}
+
+// isFastaStart returns true if line starts the FASTA section of a GFF3.
+func isFastaStart(line string) bool {
+	return strings.HasPrefix(line, `##FASTA`) || strings.HasPrefix(line, `>`)
+}
+
+// readFasta reads the rest of the input as FASTA sequences. The line
+// that started the FASTA section is passed in because it may be the
+// first sequence header.
+func (r *Reader) readFasta(first string) {
+	var seq *genome.Sequence
+	var b strings.Builder
+	finish := func() {
+		if seq != nil {
+			seq.Sequence = b.String()
+			r.Sequences = append(r.Sequences, seq)
+		}
+		b.Reset()
+	}
+
+	line := first
+	for {
+		switch {
+		case strings.HasPrefix(line, `>`):
+			finish()
+			// genome.NewSequence is fatal on a header with no name
+			if strings.TrimSpace(strings.TrimLeft(line, " >")) == `` {
+				seq = &genome.Sequence{Header: line}
+			} else {
+				seq = genome.NewSequence(line)
+			}
+		case strings.HasPrefix(line, `#`):
+			// ##FASTA itself or a stray comment
+		default:
+			b.WriteString(strings.TrimSpace(line))
+		}
+		if !r.scanner.Scan() {
+			break
+		}
+		line = strings.TrimSuffix(r.scanner.Text(), "\n")
+		r.lctr++
+	}
+	finish()
+}
@@ -0,0 +1,111 @@
+package gff3
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+var reader_1 = `##gff-version	3
+##sequence-region	1 1 1000
+1	ensembl	exon	1	10	.	.	.	ID=1
+###
+1	ensembl	exon	5	20	.	.	.	ID=2
+# a comment between records
+2	ensembl	exon	21	23	.	.	.	ID=3
+`
+
+func TestReader(t *testing.T) {
+	r, err := NewReader(strings.NewReader(reader_1))
+	if err != nil {
+		t.Fatalf("NewReader should not have failed: %v", err)
+	}
+
+	e1 := 2
+	g1 := len(r.Header)
+	if e1 != g1 {
+		t.Fatalf("Header count before Read should be %d but is %d", e1, g1)
+	}
+
+	var feats []*Feature
+	for {
+		f, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read should not have failed: %v", err)
+		}
+		feats = append(feats, f)
+	}
+
+	e2 := 3
+	g2 := len(feats)
+	if e2 != g2 {
+		t.Fatalf("Feature count should be %d but is %d", e2, g2)
+	}
+
+	e3 := 5
+	g3 := feats[1].LineNumber
+	if e3 != g3 {
+		t.Fatalf("LineNumber of Feature 1 should be %d but is %d", e3, g3)
+	}
+
+	// ### is dropped but the comment is kept
+	e4 := 3
+	g4 := len(r.Header)
+	if e4 != g4 {
+		t.Fatalf("Header count after Read should be %d but is %d", e4, g4)
+	}
+
+	// Once EOF is reached, it keeps being returned
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("Read after EOF should return io.EOF but returned %v", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	_, err := NewReader(strings.NewReader("1\tensembl\texon\t1\t10\t.\t.\t.\tID=1\n"))
+	if err != ErrNoGff3Headers {
+		t.Fatalf("NewReader should have returned ErrNoGff3Headers but returned %v", err)
+	}
+
+	_, err = NewReader(strings.NewReader("##gff-version 2\n1\tensembl\texon\t1\t10\t.\t.\t.\tID=1\n"))
+	if err == nil {
+		t.Fatalf("NewReader should have failed - wrong gff version")
+	}
+
+	r, err := NewReader(strings.NewReader("##gff-version 3\n1\tensembl\texon\t1\t10\n"))
+	if err == nil {
+		_, err = r.Read()
+	}
+	if err == nil {
+		t.Fatalf("reading a Feature with 5 fields should have failed")
+	}
+}
+
+func TestReaderFromFile(t *testing.T) {
+	f1 := `testdata/test1.gff3.gz`
+	r, err := NewReaderFromFile(f1)
+	if err != nil {
+		t.Fatalf("error opening %s: %v", f1, err)
+	}
+	defer r.Close()
+
+	var ctr int
+	for {
+		_, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("error reading %s: %v", f1, err)
+		}
+		ctr++
+	}
+
+	e1 := 1150
+	if e1 != ctr {
+		t.Fatalf("%s should have %d Feature but has %d", f1, e1, ctr)
+	}
+}
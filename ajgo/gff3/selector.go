@@ -0,0 +1,142 @@
+package gff3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ajgo/selector"
+)
+
+// The subjects that a FeatureSelector understands. Attribute subjects
+// are written attr.<key>, e.g. attr.biotype.
+const attrSubjectPrefix = selector.AttrPrefix
+
+// FeatureSelector is a selector expression (selector.Expr) that has
+// been checked against the subjects of a GFF3 Feature so it can be
+// applied to Feature one at a time, for example while streaming Feature
+// through a Reader and Writer.
+//
+// The subjects are the GFF3 columns seqid, source, type, score, strand
+// and phase, length (End-Start+1) and attr.<key> for any Attribute.
+// An Attribute with multiple values passes a test if any one of its
+// values passes. A Feature without the Attribute fails every test.
+type FeatureSelector struct {
+	Selector *selector.Selector // nil unless from a three-part Selector
+	Expr     selector.Expr
+}
+
+// NewFeatureSelector checks that the Operation and Subject of a
+// selector.Selector are valid for GFF3 Feature and compiles the Pattern.
+func NewFeatureSelector(sel *selector.Selector) (*FeatureSelector, error) {
+	switch sel.Operation {
+	case `keep`, `delete`:
+	default:
+		return nil, fmt.Errorf("NewFeatureSelector: selector operation not recognised in: %s", sel)
+	}
+	if !IsFeatureSubject(sel.Subject) {
+		return nil, fmt.Errorf("NewFeatureSelector: selector subject not recognised in: %s", sel)
+	}
+
+	e, err := sel.Expr()
+	if err != nil {
+		return nil, fmt.Errorf("NewFeatureSelector: %w", err)
+	}
+	return &FeatureSelector{Selector: sel, Expr: e}, nil
+}
+
+// NewFeatureSelectors calls NewFeatureSelector on a list of
+// selector.Selector.
+func NewFeatureSelectors(sels []*selector.Selector) ([]*FeatureSelector, error) {
+	var fsels []*FeatureSelector
+	for _, sel := range sels {
+		fsel, err := NewFeatureSelector(sel)
+		if err != nil {
+			return fsels, err
+		}
+		fsels = append(fsels, fsel)
+	}
+	return fsels, nil
+}
+
+// NewFeatureSelectorFromString parses a selector expression, or a
+// three-part selector, and checks that all of its subjects are valid
+// for GFF3 Feature.
+func NewFeatureSelectorFromString(s string) (*FeatureSelector, error) {
+	e, err := selector.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("NewFeatureSelectorFromString: %w", err)
+	}
+	if err = selector.CheckSubjects(e, IsFeatureSubject); err != nil {
+		return nil, fmt.Errorf("NewFeatureSelectorFromString: %w", err)
+	}
+	return &FeatureSelector{Expr: e}, nil
+}
+
+// NewFeatureSelectorsFromStrings calls NewFeatureSelectorFromString on
+// a list of strings.
+func NewFeatureSelectorsFromStrings(ss []string) ([]*FeatureSelector, error) {
+	var fsels []*FeatureSelector
+	for _, s := range ss {
+		fsel, err := NewFeatureSelectorFromString(s)
+		if err != nil {
+			return fsels, err
+		}
+		fsels = append(fsels, fsel)
+	}
+	return fsels, nil
+}
+
+// String returns the selector in canonical expression form.
+func (s *FeatureSelector) String() string {
+	return s.Expr.String()
+}
+
+// Keep returns true if the Feature survives the selector, i.e. it
+// passes the expression. For a three-part selector that means it
+// matches a keep selector or does not match a delete selector.
+func (s *FeatureSelector) Keep(f *Feature) bool {
+	return s.Expr.Eval(f)
+}
+
+// KeepAll returns true if the Feature survives every one of the
+// selectors, applied in order.
+func KeepAll(sels []*FeatureSelector, f *Feature) bool {
+	for _, s := range sels {
+		if !s.Keep(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsFeatureSubject returns true if s is a subject that a
+// FeatureSelector understands.
+func IsFeatureSubject(s string) bool {
+	return selector.IsFeatureSubject(s)
+}
+
+// SubjectValues returns the value(s) from the Feature for a selector
+// subject. It makes Feature a selector.Record.
+func (f *Feature) SubjectValues(subject string) []string {
+	switch subject {
+	case `seqid`:
+		return []string{f.SeqId}
+	case `source`:
+		return []string{f.Source}
+	case `type`:
+		return []string{f.Type}
+	case `score`:
+		return []string{f.Score}
+	case `strand`:
+		return []string{f.Strand}
+	case `phase`:
+		return []string{f.Phase}
+	case `length`:
+		return []string{strconv.Itoa(f.Length())}
+	}
+	if !strings.HasPrefix(subject, attrSubjectPrefix) {
+		return nil
+	}
+	return f.AttributeValues(strings.TrimPrefix(subject, attrSubjectPrefix))
+}
@@ -0,0 +1,121 @@
+package gff3
+
+import (
+	"testing"
+
+	"ajgo/selector"
+)
+
+func TestApplySelector(t *testing.T) {
+	isUtr := func(f *Feature) bool {
+		return f.Type == `five_prime_UTR` || f.Type == `three_prime_UTR`
+	}
+	var tests = []struct {
+		sel  string
+		keep func(*Feature) bool
+	}{
+		{`delete:type:.*_UTR`, func(f *Feature) bool { return !isUtr(f) }},
+		{`keep:type:^CDS$`, func(f *Feature) bool { return f.Type == `CDS` }},
+		{`keep:attr.biotype:^protein_coding$`, func(f *Feature) bool { return f.Attributes[`biotype`] == `protein_coding` }},
+		{`delete:attr.biotype:.`, func(f *Feature) bool { _, ok := f.Attributes[`biotype`]; return !ok }},
+		{`keep:strand:^\.$`, func(f *Feature) bool { return f.Strand == `.` }},
+		{`keep:length:^1$`, func(f *Feature) bool { return f.End == f.Start }},
+		{`keep:source:^havana$`, func(f *Feature) bool { return f.Source == `havana` }},
+	}
+
+	g, err := NewFromFile(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		sel, err := selector.NewFromString(tt.sel)
+		if err != nil {
+			t.Fatalf("NewFromString(%s) failed: %v", tt.sel, err)
+		}
+		fs := g.Features.Clone()
+		if err = fs.ApplySelector(sel); err != nil {
+			t.Fatalf("ApplySelector(%s) failed: %v", tt.sel, err)
+		}
+
+		var e int
+		for _, f := range g.Features.Features {
+			if tt.keep(f) {
+				e++
+			}
+		}
+		if e == 0 || e == g.FeatureCount() {
+			t.Fatalf("ApplySelector(%s) is not a useful test - it keeps %d Feature", tt.sel, e)
+		}
+		if e != fs.Count() {
+			t.Fatalf("ApplySelector(%s) should keep %d Feature but kept %d", tt.sel, e, fs.Count())
+		}
+	}
+
+	for _, s := range []string{`keep:attr.:x`, `keep:colour:x`, `copy:seqid:x`, `keep:seqid:(`} {
+		sel, _ := selector.NewFromString(s)
+		if _, err := NewFeatureSelector(sel); err == nil {
+			t.Fatalf("NewFeatureSelector(%s) should have failed", s)
+		}
+	}
+}
+
+func TestFeatureSelectorMultiValue(t *testing.T) {
+	f, err := NewFeatureFromLine("1\tajgo\tmRNA\t1\t10\t.\t+\t.\tID=t1;tag=basic,Ensembl_canonical")
+	if err != nil {
+		t.Fatalf("NewFeatureFromLine failed: %v", err)
+	}
+	sel, _ := selector.NewFromString(`keep:attr.tag:^Ensembl_canonical$`)
+	fsel, err := NewFeatureSelector(sel)
+	if err != nil {
+		t.Fatalf("NewFeatureSelector failed: %v", err)
+	}
+	if !fsel.Keep(f) {
+		t.Fatalf("%s should keep Feature with tag=basic,Ensembl_canonical", sel)
+	}
+}
+
+func TestApplySelectorString(t *testing.T) {
+	var tests = []struct {
+		sel  string
+		keep func(*Feature) bool
+	}{
+		{`type == CDS and length >= 100`, func(f *Feature) bool { return f.Type == `CDS` && f.Length() >= 100 }},
+		{`type in [five_prime_UTR, three_prime_UTR] or attr.biotype == protein_coding`, func(f *Feature) bool {
+			return f.Type == `five_prime_UTR` || f.Type == `three_prime_UTR` || f.Attributes[`biotype`] == `protein_coding`
+		}},
+		{`not (seqid == 1 or strand == '-')`, func(f *Feature) bool { return f.SeqId != `1` && f.Strand != `-` }},
+		{`delete:type:.*_UTR`, func(f *Feature) bool { return f.Type != `five_prime_UTR` && f.Type != `three_prime_UTR` }},
+	}
+
+	g, err := NewFromFile(`testdata/test1.gff3.gz`)
+	if err != nil {
+		t.Fatalf("NewFromFile failed: %v", err)
+	}
+
+	for _, tt := range tests {
+		fs := g.Features.Clone()
+		if err = fs.ApplySelectorString(tt.sel); err != nil {
+			t.Fatalf("ApplySelectorString(%s) failed: %v", tt.sel, err)
+		}
+
+		var e int
+		for _, f := range g.Features.Features {
+			if tt.keep(f) {
+				e++
+			}
+		}
+		if e == 0 || e == g.FeatureCount() {
+			t.Fatalf("ApplySelectorString(%s) is not a useful test - it keeps %d Feature", tt.sel, e)
+		}
+		if e != fs.Count() {
+			t.Fatalf("ApplySelectorString(%s) should keep %d Feature but kept %d", tt.sel, e, fs.Count())
+		}
+	}
+
+	for _, s := range []string{`attr. == x`, `colour ~ x`, `copy:seqid:x`, `seqid ~ (`} {
+		if _, err := NewFeatureSelectorFromString(s); err == nil {
+			t.Fatalf("NewFeatureSelectorFromString(%s) should have failed", s)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package gff3
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+// SeqOrder defines the order of SeqIds used by Features.Sort and
+// Features.SeqIds. By default SeqIds are sorted lexically (chr1, chr10,
+// chr11, ... chr2) which is rarely what anyone wants so there are also:
+//
+//   - natural ("karyotypic") ordering where numbered chromosomes come
+//     first in numeric order, then X, Y and the mitochondrial
+//     sequence, then everything else in natural alphanumeric order,
+//     e.g. chr1, chr2, ... chr22, chrX, chrY, chrM, chrUn_gl000220.
+//   - list ordering where the order is taken from a list of sequence
+//     names such as the sequences in an ajgo serialised genome or the
+//     @SQ lines of a SAM sequence dictionary, i.e. the order of a BAM
+//     header. SeqIds that are not in the list come after those that
+//     are, in natural order.
+//
+// A nil *SeqOrder is valid and is lexical ordering.
+type SeqOrder struct {
+	Name    string
+	natural bool
+	rank    map[string]int
+}
+
+// LexicalSeqOrder returns a *SeqOrder that sorts SeqIds as strings.
+func LexicalSeqOrder() *SeqOrder {
+	return &SeqOrder{Name: `lexical`}
+}
+
+// NaturalSeqOrder returns a *SeqOrder that sorts SeqIds in karyotypic
+// order.
+func NaturalSeqOrder() *SeqOrder {
+	return &SeqOrder{Name: `natural`, natural: true}
+}
+
+// NewSeqOrder returns a *SeqOrder that sorts SeqIds in the same order
+// as seqids. The name is used to describe the ordering in logs and
+// headers.
+func NewSeqOrder(name string, seqids []string) *SeqOrder {
+	o := &SeqOrder{Name: name, natural: true, rank: make(map[string]int)}
+	for _, s := range seqids {
+		if _, ok := o.rank[s]; !ok {
+			o.rank[s] = len(o.rank)
+		}
+	}
+	return o
+}
+
+// SeqOrderFromGenome returns a *SeqOrder based on the order of the
+// sequences in a genome.
+func SeqOrderFromGenome(g *genome.Genome) *SeqOrder {
+	var seqids []string
+	for _, s := range g.Sequences {
+		seqids = append(seqids, s.Name)
+	}
+	return NewSeqOrder(`genome `+g.Name, seqids)
+}
+
+// SeqOrderFromDict returns a *SeqOrder based on the order of the @SQ
+// lines in a SAM sequence dictionary (.dict) file or SAM header.
+func SeqOrderFromDict(file string) (*SeqOrder, error) {
+	ff, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("SeqOrderFromDict: %w", err)
+	}
+	defer ff.Close()
+
+	var seqids []string
+	scanner := bufio.NewScanner(ff)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, `@SQ`) {
+			continue
+		}
+		for _, field := range strings.Split(line, "\t")[1:] {
+			if strings.HasPrefix(field, `SN:`) {
+				seqids = append(seqids, field[3:])
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("SeqOrderFromDict: error reading %s: %w", file, err)
+	}
+	if len(seqids) == 0 {
+		return nil, fmt.Errorf("SeqOrderFromDict: no @SQ lines with SN: tag found in %s", file)
+	}
+	return NewSeqOrder(`dict `+file, seqids), nil
+}
+
+// String returns the name of the ordering.
+func (o *SeqOrder) String() string {
+	if o == nil {
+		return `lexical`
+	}
+	return o.Name
+}
+
+// Less returns true if SeqId a sorts before SeqId b.
+func (o *SeqOrder) Less(a, b string) bool {
+	if o == nil || (!o.natural && o.rank == nil) {
+		return a < b
+	}
+	if o.rank != nil {
+		ra, aok := o.rank[a]
+		rb, bok := o.rank[b]
+		switch {
+		case aok && bok:
+			return ra < rb
+		case aok:
+			return true
+		case bok:
+			return false
+		}
+	}
+	return naturalLess(a, b)
+}
+
+// Sort sorts a list of SeqIds in place.
+func (o *SeqOrder) Sort(seqids []string) {
+	sort.SliceStable(seqids, func(i, j int) bool {
+		return o.Less(seqids[i], seqids[j])
+	})
+}
+
+// karyotypeClass puts a SeqId into a class for natural ordering.
+// Numbered chromosomes are 0, X is 1, Y is 2, the mitochondrial
+// sequence is 3 and everything else is 4. The chr prefix is ignored.
+func karyotypeClass(s string) (int, int) {
+	if len(s) > 3 && strings.EqualFold(s[:3], `chr`) {
+		s = s[3:]
+	}
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return 0, n
+	}
+	switch strings.ToUpper(s) {
+	case `X`:
+		return 1, 0
+	case `Y`:
+		return 2, 0
+	case `M`, `MT`:
+		return 3, 0
+	}
+	return 4, 0
+}
+
+// naturalLess compares SeqIds in karyotypic order.
+func naturalLess(a, b string) bool {
+	ca, na := karyotypeClass(a)
+	cb, nb := karyotypeClass(b)
+	if ca != cb {
+		return ca < cb
+	}
+	if ca == 0 && na != nb {
+		return na < nb
+	}
+	if ca == 4 {
+		if c := alnumCompare(a, b); c != 0 {
+			return c < 0
+		}
+	}
+	return a < b
+}
+
+// alnumCompare compares strings so that runs of digits are compared as
+// numbers, e.g. contig9 sorts before contig10.
+func alnumCompare(a, b string) int {
+	for a != `` && b != `` {
+		da, db := isDigit(a[0]), isDigit(b[0])
+		if da && db {
+			ia, ib := digitRun(a), digitRun(b)
+			na := strings.TrimLeft(a[:ia], `0`)
+			nb := strings.TrimLeft(b[:ib], `0`)
+			if len(na) != len(nb) {
+				return len(na) - len(nb)
+			}
+			if na != nb {
+				return strings.Compare(na, nb)
+			}
+			a, b = a[ia:], b[ib:]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// digitRun returns the length of the run of digits at the start of s.
+func digitRun(s string) int {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return i
+}
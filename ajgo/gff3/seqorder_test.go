@@ -0,0 +1,89 @@
+package gff3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSeqOrder(t *testing.T) {
+	ids := []string{`chr10`, `chrUn_gl000220`, `chrM`, `chr2`, `chrY`,
+		`chr1`, `chrX`, `chr1_gl000191_random`, `chr22`}
+
+	var tests = []struct {
+		order *SeqOrder
+		e     string
+	}{
+		{nil, `chr1 chr10 chr1_gl000191_random chr2 chr22 chrM chrUn_gl000220 chrX chrY`},
+		{LexicalSeqOrder(), `chr1 chr10 chr1_gl000191_random chr2 chr22 chrM chrUn_gl000220 chrX chrY`},
+		{NaturalSeqOrder(), `chr1 chr2 chr10 chr22 chrX chrY chrM chr1_gl000191_random chrUn_gl000220`},
+		{NewSeqOrder(`test`, []string{`chrM`, `chr2`, `chr1`}),
+			`chrM chr2 chr1 chr10 chr22 chrX chrY chr1_gl000191_random chrUn_gl000220`},
+	}
+	for _, tt := range tests {
+		sorted := append([]string(nil), ids...)
+		tt.order.Sort(sorted)
+		g := strings.Join(sorted, ` `)
+		if tt.e != g {
+			t.Fatalf("%s order should be %q but is %q", tt.order, tt.e, g)
+		}
+	}
+}
+
+func TestSeqOrderFromDict(t *testing.T) {
+	dict := "@HD\tVN:1.6\n" +
+		"@SQ\tSN:chrM\tLN:16571\tM5:d2ed829b8a1628d16cbeee88e88e39eb\n" +
+		"@SQ\tSN:chr1\tLN:249250621\n" +
+		"@SQ\tLN:243199373\tSN:chr2\n"
+	file := filepath.Join(t.TempDir(), `test.dict`)
+	if err := os.WriteFile(file, []byte(dict), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	o, err := SeqOrderFromDict(file)
+	if err != nil {
+		t.Fatalf("SeqOrderFromDict failed: %v", err)
+	}
+	ids := []string{`chr2`, `chr1`, `chrM`}
+	o.Sort(ids)
+	e1 := `chrM chr1 chr2`
+	g1 := strings.Join(ids, ` `)
+	if e1 != g1 {
+		t.Fatalf("dict order should be %q but is %q", e1, g1)
+	}
+}
+
+func TestSortWithSeqOrder(t *testing.T) {
+	fs := featuresFromText(t,
+		"chr10\tajgo\tgene\t5\t10\t.\t+\t.\tID=a\n"+
+			"chr2\tajgo\tgene\t5\t20\t.\t+\t.\tID=b\n"+
+			"chr2\tajgo\tgene\t5\t10\t.\t+\t.\tID=c\n"+
+			"chr2\tajgo\tgene\t1\t10\t.\t+\t.\tID=d\n")
+	fs.SeqOrder = NaturalSeqOrder()
+	fs.Sort()
+
+	var ids []string
+	for _, f := range fs.Features {
+		if f == nil {
+			t.Fatalf("Sort should not create nil Feature")
+		}
+		ids = append(ids, f.Attributes[`ID`])
+	}
+	e1 := `d c b a`
+	g1 := strings.Join(ids, ` `)
+	if e1 != g1 {
+		t.Fatalf("sorted IDs should be %q but are %q", e1, g1)
+	}
+
+	// SetSeqOrder moves SeqIds but keeps the order within each SeqId
+	fs.SetSeqOrder(LexicalSeqOrder())
+	ids = nil
+	for _, f := range fs.Features {
+		ids = append(ids, f.Attributes[`ID`])
+	}
+	e2 := `a d c b`
+	g2 := strings.Join(ids, ` `)
+	if e2 != g2 {
+		t.Fatalf("reordered IDs should be %q but are %q", e2, g2)
+	}
+}
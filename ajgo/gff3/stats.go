@@ -0,0 +1,217 @@
+package gff3
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Stats is a summary of a Features that can be written as TSV or JSON
+// so releases of a GFF3 (e.g. a mask) can be tracked over time. Stats
+// are given for all of the Feature, for each SeqId, and for each
+// combination of SeqId, Type and Source.
+type Stats struct {
+	All        *StatsGroup    `json:"all"`
+	BySeqId    []*StatsGroup  `json:"bySeqId"`
+	Groups     []*StatsGroup  `json:"bySeqIdTypeSource"`
+	Attributes map[string]int `json:"attributes"` // see Features.Attributes
+}
+
+// StatsGroup summarises a group of Feature. Bases is the sum of the
+// Feature lengths and Covered is the number of bases covered by at
+// least one Feature so Bases - Covered is the number of bases in
+// overlaps. Plus, Minus and Unstranded count the Feature on each strand
+// with Unstranded including both . and ?.
+type StatsGroup struct {
+	Level      string      `json:"-"` // all, seqid or group
+	SeqId      string      `json:"seqId,omitempty"`
+	Type       string      `json:"type,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	Count      int         `json:"count"`
+	Bases      int         `json:"bases"`
+	Covered    int         `json:"covered"`
+	Lengths    LengthStats `json:"lengths"`
+	Plus       int         `json:"plus"`
+	Minus      int         `json:"minus"`
+	Unstranded int         `json:"unstranded"`
+}
+
+// LengthStats describes the distribution of Feature lengths. Median is
+// the lower median for an even number of Feature and N50 is the length
+// such that Feature of that length or longer hold at least half of the
+// bases.
+type LengthStats struct {
+	Min    int `json:"min"`
+	Median int `json:"median"`
+	N50    int `json:"n50"`
+	Max    int `json:"max"`
+}
+
+// Stats summarises the Feature. Lengths and coverage use Length so the
+// Feature must be 1-based closed, as read from a GFF3 without a ##format
+// header. SeqIds are ordered by the SeqOrder of fs and Types and
+// Sources are sorted. fs is not changed.
+func (fs *Features) Stats() *Stats {
+	bySeqId := make(map[string][]*Feature)
+	byGroup := make(map[[3]string][]*Feature)
+	var seqids []string
+	var groups [][3]string
+	for _, f := range fs.Features {
+		if _, ok := bySeqId[f.SeqId]; !ok {
+			seqids = append(seqids, f.SeqId)
+		}
+		bySeqId[f.SeqId] = append(bySeqId[f.SeqId], f)
+		k := [3]string{f.SeqId, f.Type, f.Source}
+		if _, ok := byGroup[k]; !ok {
+			groups = append(groups, k)
+		}
+		byGroup[k] = append(byGroup[k], f)
+	}
+	sort.Slice(seqids, func(i, j int) bool { return fs.SeqOrder.Less(seqids[i], seqids[j]) })
+	sort.Slice(groups, func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		switch {
+		case a[0] != b[0]:
+			return fs.SeqOrder.Less(a[0], b[0])
+		case a[1] != b[1]:
+			return a[1] < b[1]
+		}
+		return a[2] < b[2]
+	})
+
+	s := &Stats{Attributes: fs.Attributes()}
+	s.All = newStatsGroup(`all`, fs.Features)
+	for _, seqid := range seqids {
+		g := newStatsGroup(`seqid`, bySeqId[seqid])
+		g.SeqId = seqid
+		s.BySeqId = append(s.BySeqId, g)
+		s.All.Covered += g.Covered
+	}
+	for _, k := range groups {
+		g := newStatsGroup(`group`, byGroup[k])
+		g.SeqId, g.Type, g.Source = k[0], k[1], k[2]
+		s.Groups = append(s.Groups, g)
+	}
+	return s
+}
+
+// newStatsGroup summarises a list of Feature. Covered is only counted
+// within a SeqId so it is left at 0 for Feature on more than one SeqId.
+func newStatsGroup(level string, feats []*Feature) *StatsGroup {
+	g := &StatsGroup{Level: level, Count: len(feats)}
+	lengths := make([]int, len(feats))
+	for i, f := range feats {
+		lengths[i] = f.Length()
+		g.Bases += lengths[i]
+		switch f.Strand {
+		case `+`:
+			g.Plus++
+		case `-`:
+			g.Minus++
+		default:
+			g.Unstranded++
+		}
+	}
+	g.Lengths = newLengthStats(lengths, g.Bases)
+	if level != `all` {
+		g.Covered = coveredBases(feats)
+	}
+	return g
+}
+
+// newLengthStats works out the LengthStats for a list of lengths that
+// add up to total. lengths is sorted.
+func newLengthStats(lengths []int, total int) LengthStats {
+	var ls LengthStats
+	if len(lengths) == 0 {
+		return ls
+	}
+	sort.Ints(lengths)
+	ls.Min = lengths[0]
+	ls.Max = lengths[len(lengths)-1]
+	ls.Median = lengths[(len(lengths)-1)/2]
+	var sum int
+	for i := len(lengths) - 1; i >= 0; i-- {
+		sum += lengths[i]
+		if 2*sum >= total {
+			ls.N50 = lengths[i]
+			break
+		}
+	}
+	return ls
+}
+
+// coveredBases returns the number of bases covered by at least one of
+// a list of 1-based closed Feature on the same SeqId.
+func coveredBases(feats []*Feature) int {
+	spans := make([][2]int, len(feats))
+	for i, f := range feats {
+		spans[i] = [2]int{f.Start, f.End}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	var covered int
+	start, end := spans[0][0], spans[0][1]
+	for _, s := range spans[1:] {
+		if s[0] > end {
+			covered += end - start + 1
+			start, end = s[0], s[1]
+		} else if s[1] > end {
+			end = s[1]
+		}
+	}
+	return covered + end - start + 1
+}
+
+// StatsTsvHeader is the header line for the TSV written by
+// StatsGroup.TsvString.
+func StatsTsvHeader() string {
+	return strings.Join([]string{`Level`, `SeqId`, `Type`, `Source`, `Count`, `Bases`, `Covered`,
+		`MinLength`, `MedianLength`, `N50`, `MaxLength`, `Plus`, `Minus`, `Unstranded`}, "\t")
+}
+
+// TsvString gives a tab-separated version of a StatsGroup with . for
+// the SeqId, Type and Source that do not apply to the Level. See
+// StatsTsvHeader for the columns.
+func (g *StatsGroup) TsvString() string {
+	return strings.Join([]string{
+		g.Level,
+		orMissing(g.SeqId),
+		orMissing(g.Type),
+		orMissing(g.Source),
+		strconv.Itoa(g.Count),
+		strconv.Itoa(g.Bases),
+		strconv.Itoa(g.Covered),
+		strconv.Itoa(g.Lengths.Min),
+		strconv.Itoa(g.Lengths.Median),
+		strconv.Itoa(g.Lengths.N50),
+		strconv.Itoa(g.Lengths.Max),
+		strconv.Itoa(g.Plus),
+		strconv.Itoa(g.Minus),
+		strconv.Itoa(g.Unstranded)}, "\t")
+}
+
+// TsvLines returns the StatsTsvHeader followed by the All, BySeqId and
+// Groups StatsGroups.
+func (s *Stats) TsvLines() []string {
+	lines := []string{StatsTsvHeader(), s.All.TsvString()}
+	for _, g := range append(append([]*StatsGroup{}, s.BySeqId...), s.Groups...) {
+		lines = append(lines, g.TsvString())
+	}
+	return lines
+}
+
+// AttributeTsvLines returns the Attribute key tallies as TSV with a
+// Key and Count header, sorted by key.
+func (s *Stats) AttributeTsvLines() []string {
+	var keys []string
+	for k := range s.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	lines := []string{"Key\tCount"}
+	for _, k := range keys {
+		lines = append(lines, k+"\t"+strconv.Itoa(s.Attributes[k]))
+	}
+	return lines
+}
@@ -0,0 +1,41 @@
+package gff3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	fs := featuresFromText(t,
+		"2\ta\trepeat\t1\t10\t.\t+\t.\tID=r1;Name=x\n"+
+			"2\ta\trepeat\t6\t15\t.\t-\t.\tID=r2\n"+
+			"2\tb\trepeat\t100\t199\t.\t.\t.\tID=r3\n"+
+			"10\ta\tgap\t1\t50\t.\t+\t.\tName=y\n")
+	fs.SeqOrder = NaturalSeqOrder()
+
+	s := fs.Stats()
+	e := []string{
+		StatsTsvHeader(),
+		"all\t.\t.\t.\t4\t170\t165\t10\t10\t100\t100\t2\t1\t1",
+		"seqid\t2\t.\t.\t3\t120\t115\t10\t10\t100\t100\t1\t1\t1",
+		"seqid\t10\t.\t.\t1\t50\t50\t50\t50\t50\t50\t1\t0\t0",
+		"group\t2\trepeat\ta\t2\t20\t15\t10\t10\t10\t10\t1\t1\t0",
+		"group\t2\trepeat\tb\t1\t100\t100\t100\t100\t100\t100\t0\t0\t1",
+		"group\t10\tgap\ta\t1\t50\t50\t50\t50\t50\t50\t1\t0\t0",
+	}
+	if got := s.TsvLines(); strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Fatalf("Stats should be:\n%s\nbut are:\n%s", strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+	if got := strings.Join(s.AttributeTsvLines(), ";"); got != "Key\tCount;ID\t3;Name\t2" {
+		t.Fatalf("Stats attributes are wrong: %q", got)
+	}
+
+	ls := newLengthStats([]int{2, 3, 5, 10}, 20)
+	if ls.Min != 2 || ls.Median != 3 || ls.N50 != 10 || ls.Max != 10 {
+		t.Fatalf("LengthStats should be 2/3/10/10 but are %+v", ls)
+	}
+	ls = newLengthStats([]int{4, 4, 3, 3, 2}, 16)
+	if ls.Median != 3 || ls.N50 != 4 {
+		t.Fatalf("LengthStats median and N50 should be 3 and 4 but are %+v", ls)
+	}
+}
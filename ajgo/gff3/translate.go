@@ -0,0 +1,236 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"ajgo/seq"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+// Problems that Translator finds in a CDS. See Translation.Problems.
+const (
+	TranslationNoStart       = `no_start`
+	TranslationNoStop        = `no_stop`
+	TranslationInternalStop  = `internal_stop`
+	TranslationNotMultiple   = `not_multiple_of_3`
+	TranslationPhaseMismatch = `phase_mismatch`
+)
+
+// Translation is the translated CDS of a transcript and the results of
+// checking it against the genome.
+type Translation struct {
+	Transcript *Feature
+	Table      *seq.CodonTable
+	Protein    *seq.Record
+
+	Phase             int    // Phase of the 5' CDS, i.e. bases before the first codon
+	CdsLength         int    // bases in the CDS after Phase
+	FirstCodon        string // empty if the CDS has no complete codon
+	LastCodon         string // empty if CdsLength is not a multiple of 3
+	Start             bool   // the CDS starts with an initiation codon
+	Stop              bool   // the CDS ends with a stop codon
+	InternalStops     int
+	FirstInternalStop int // 1-based position in Protein, 0 if none
+	PhaseMismatches   int // CDS with a Phase that does not follow on from the CDS before
+}
+
+// Translator translates the spliced CDS of each transcript in a Tree
+// and checks it for the usual signs that a gene model does not match
+// the genome build: a missing start or stop codon, internal stop
+// codons, a length that is not a multiple of 3, and CDS Phases that
+// are not consistent with each other.
+//
+// Table is used for every SeqId that is not in SeqIdTables, so the
+// mitochondrial code can be used for chrM or MT. Header makes the
+// header of each protein - see ParseHeaderTemplate. Feature must be
+// 1-based closed, as for Extractor.
+type Translator struct {
+	Table       *seq.CodonTable
+	SeqIdTables map[string]*seq.CodonTable
+	Header      *HeaderTemplate // nil uses DefaultHeaderTemplate
+
+	e *Extractor
+}
+
+// NewTranslator returns a *Translator for the sequences of a genome.
+func NewTranslator(g *genome.Genome, table *seq.CodonTable) *Translator {
+	return &Translator{
+		Table:       table,
+		SeqIdTables: make(map[string]*seq.CodonTable),
+		e:           NewExtractor(g),
+	}
+}
+
+// Translate returns a Translation for every TreeNode in t that has CDS
+// children, in the order the TreeNodes appeared in the Gff3. The
+// translation starts after the Phase of the 5' CDS. Some gene models
+// (e.g. GENCODE) leave the stop codon out of the CDS and give it as a
+// separate stop_codon feature so stop_codon children are added to the
+// CDS unless they are already inside it. The terminal stop codon is not
+// included in Protein but internal stops are, as *. If the CDS starts
+// with an initiation codon, the first amino acid is M as it would be in
+// the protein, even for alternative starts such as CTG.
+func (tr *Translator) Translate(t *Tree) ([]*Translation, error) {
+	tr.e.Header = tr.Header
+	var trs []*Translation
+	for _, id := range t.ids {
+		n := t.Nodes[id]
+		cds := n.children(`CDS`)
+		if len(cds) == 0 {
+			continue
+		}
+		x, err := tr.translate(n.Self[0], cds, n.children(`stop_codon`))
+		if err != nil {
+			return nil, err
+		}
+		trs = append(trs, x)
+	}
+	return trs, nil
+}
+
+// translate translates and checks the CDS of a single transcript.
+func (tr *Translator) translate(f *Feature, cds, stops []*Feature) (*Translation, error) {
+	table := tr.Table
+	if st, ok := tr.SeqIdTables[f.SeqId]; ok {
+		table = st
+	}
+
+	parts := append([]*Feature{}, cds...)
+	for _, s := range stops {
+		inside := false
+		for _, c := range cds {
+			if s.Start >= c.Start && s.End <= c.End {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			parts = append(parts, s)
+		}
+	}
+
+	r, err := tr.e.extract(f, parts)
+	if err != nil {
+		return nil, fmt.Errorf("Translator: %w", err)
+	}
+
+	// Order the parts 5' to 3' to find the Phase of the first CDS and
+	// to check that each Phase follows on from the bases before it.
+	sort.Slice(parts, func(i, j int) bool {
+		if f.Strand == `-` {
+			return parts[i].End > parts[j].End
+		}
+		return parts[i].Start < parts[j].Start
+	})
+	x := &Translation{Transcript: f, Table: table}
+	x.Phase, _ = parsePhase(parts[0].Phase)
+	var bases int
+	for _, p := range parts {
+		if phase, ok := parsePhase(p.Phase); ok {
+			if want := ((x.Phase-bases)%3 + 3) % 3; phase != want {
+				x.PhaseMismatches++
+			}
+		}
+		bases += p.Length()
+	}
+
+	coding := r.Sequence
+	if x.Phase < len(coding) {
+		coding = coding[x.Phase:]
+	} else {
+		coding = ``
+	}
+	x.CdsLength = len(coding)
+	protein := []byte(table.Translate(coding))
+
+	if len(coding) >= 3 {
+		x.FirstCodon = strings.ToUpper(coding[:3])
+		x.Start = x.Phase == 0 && table.IsStart(x.FirstCodon)
+		if x.Start {
+			protein[0] = 'M'
+		}
+	}
+	if len(coding) >= 3 && len(coding)%3 == 0 {
+		x.LastCodon = strings.ToUpper(coding[len(coding)-3:])
+		if x.Stop = table.IsStop(x.LastCodon); x.Stop {
+			protein = protein[:len(protein)-1]
+		}
+	}
+	for i, aa := range protein {
+		if aa == '*' {
+			x.InternalStops++
+			if x.FirstInternalStop == 0 {
+				x.FirstInternalStop = i + 1
+			}
+		}
+	}
+
+	x.Protein = &seq.Record{Header: r.Header, Sequence: string(protein)}
+	return x, nil
+}
+
+// parsePhase returns the Phase of a Feature and false if the Phase is
+// missing or is not 0, 1 or 2.
+func parsePhase(s string) (int, bool) {
+	p, err := strconv.Atoi(s)
+	if err != nil || p < 0 || p > 2 {
+		return 0, false
+	}
+	return p, true
+}
+
+// Problems returns the problems found in the CDS, e.g. no_start and
+// internal_stop. A CDS with no problems returns nil.
+func (x *Translation) Problems() []string {
+	var ps []string
+	if !x.Start {
+		ps = append(ps, TranslationNoStart)
+	}
+	if !x.Stop {
+		ps = append(ps, TranslationNoStop)
+	}
+	if x.InternalStops > 0 {
+		ps = append(ps, TranslationInternalStop)
+	}
+	if x.CdsLength%3 != 0 {
+		ps = append(ps, TranslationNotMultiple)
+	}
+	if x.PhaseMismatches > 0 {
+		ps = append(ps, TranslationPhaseMismatch)
+	}
+	return ps
+}
+
+// TranslationTsvHeader returns the header line for
+// Translation.TsvString.
+func TranslationTsvHeader() string {
+	return strings.Join([]string{`Transcript`, `Parent`, `SeqId`, `Start`,
+		`End`, `Strand`, `CodonTable`, `Phase`, `CdsLength`, `FirstCodon`,
+		`LastCodon`, `InternalStops`, `FirstInternalStop`,
+		`PhaseMismatches`, `Problems`}, "\t")
+}
+
+// TsvString returns the Translation as a single line of TSV with . for
+// missing values.
+func (x *Translation) TsvString() string {
+	f := x.Transcript
+	id := f.Attributes[`ID`]
+	if id == `` {
+		id = featureLabel(f)
+	}
+	first := strconv.Itoa(x.FirstInternalStop)
+	if x.FirstInternalStop == 0 {
+		first = `.`
+	}
+	return strings.Join([]string{id, orMissing(f.Attributes[`Parent`]),
+		f.SeqId, strconv.Itoa(f.Start), strconv.Itoa(f.End), f.Strand,
+		strconv.Itoa(x.Table.Id), strconv.Itoa(x.Phase),
+		strconv.Itoa(x.CdsLength), orMissing(x.FirstCodon),
+		orMissing(x.LastCodon), strconv.Itoa(x.InternalStops), first,
+		strconv.Itoa(x.PhaseMismatches),
+		orMissing(strings.Join(x.Problems(), `,`))}, "\t")
+}
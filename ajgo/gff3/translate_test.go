@@ -0,0 +1,75 @@
+package gff3
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"ajgo/seq"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+func TestTranslate(t *testing.T) {
+	g := genome.NewGenome("test")
+	g.Sequences = append(g.Sequences,
+		&genome.Sequence{Name: "1", Sequence: "ATGAAAGTAAGTTTTAACCC"},
+		&genome.Sequence{Name: "2", Sequence: "CTAGGGTCACAT"},
+		&genome.Sequence{Name: "MT", Sequence: "ATATGGTAG"})
+
+	gff, err := NewFromScanner(bufio.NewScanner(strings.NewReader("##gff-version 3\n" +
+		"1\ta\tmRNA\t1\t17\t.\t+\t.\tID=t1;Parent=g1\n" +
+		"1\ta\tCDS\t1\t6\t.\t+\t0\tParent=t1\n" +
+		"1\ta\tCDS\t12\t14\t.\t+\t0\tParent=t1\n" +
+		"1\ta\tstop_codon\t15\t17\t.\t+\t0\tParent=t1\n" +
+		"2\ta\tmRNA\t1\t12\t.\t-\t.\tID=t2\n" +
+		"2\ta\tCDS\t1\t12\t.\t-\t0\tParent=t2\n" +
+		"1\ta\tmRNA\t1\t17\t.\t+\t.\tID=t3\n" +
+		"1\ta\tCDS\t1\t5\t.\t+\t0\tParent=t3\n" +
+		"1\ta\tCDS\t12\t17\t.\t+\t0\tParent=t3\n" +
+		"MT\ta\tmRNA\t1\t9\t.\t+\t.\tID=t4\n" +
+		"MT\ta\tCDS\t1\t9\t.\t+\t0\tParent=t4\n")))
+	if err != nil {
+		t.Fatalf("NewFromScanner failed: %v", err)
+	}
+
+	std, _ := seq.NewCodonTable(1)
+	mt, _ := seq.NewCodonTable(2)
+	tr := NewTranslator(g, std)
+	tr.SeqIdTables["MT"] = mt
+	trs, err := tr.Translate(gff.NewTree())
+	if err != nil {
+		t.Fatalf("Translate failed: %v", err)
+	}
+
+	e := []struct {
+		protein  string
+		problems string
+	}{
+		{"MKF", ""},
+		{"M*P", "internal_stop"},
+		{"MNF", "no_stop,not_multiple_of_3,phase_mismatch"},
+		{"MW", ""},
+	}
+	if len(trs) != len(e) {
+		t.Fatalf("Translate should return %d Translations but returned %d", len(e), len(trs))
+	}
+	for i, x := range trs {
+		if x.Protein.Sequence != e[i].protein || strings.Join(x.Problems(), ",") != e[i].problems {
+			t.Fatalf("Translation %d should be %s [%s] but is %s %v",
+				i, e[i].protein, e[i].problems, x.Protein.Sequence, x.Problems())
+		}
+	}
+
+	got := trs[1].TsvString()
+	want := "t2\t.\t2\t1\t12\t-\t1\t0\t12\tATG\tTAG\t1\t2\t0\tinternal_stop"
+	if got != want {
+		t.Fatalf("TsvString should be %q but is %q", want, got)
+	}
+
+	// With the standard code ATA is not a start and TAG is still a stop.
+	delete(tr.SeqIdTables, "MT")
+	if trs, _ = tr.Translate(gff.NewTree()); strings.Join(trs[3].Problems(), ",") != "no_start" {
+		t.Fatalf("Translation with table 1 should be no_start but is %v", trs[3].Problems())
+	}
+}
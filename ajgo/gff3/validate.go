@@ -0,0 +1,228 @@
+package gff3
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Severity is how serious a validation Problem is.
+type Severity int
+
+const (
+	SeverityWarning Severity = iota + 1
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return `WARNING`
+	case SeverityError:
+		return `ERROR`
+	}
+	return `UNKNOWN`
+}
+
+// Problem codes reported by Validate. The codes are stable so they can
+// be used to filter the TSV report.
+const (
+	ProblemUnparseable           = `unparseable-line`
+	ProblemStartAfterEnd         = `start-after-end`
+	ProblemIllegalStrand         = `illegal-strand`
+	ProblemIllegalPhase          = `illegal-phase`
+	ProblemCdsMissingPhase       = `cds-missing-phase`
+	ProblemDuplicateId           = `duplicate-id`
+	ProblemUnknownParent         = `unknown-parent`
+	ProblemOutsideSequenceRegion = `outside-sequence-region`
+	ProblemNoSequenceRegion      = `no-sequence-region`
+
+	// Reported by Tree.Validate
+	ProblemPhantomNode   = `phantom-node`
+	ProblemParentCycle   = `parent-cycle`
+	ProblemMultipleRoots = `multiple-roots`
+)
+
+// Problem is a single issue found by Validate. LineNumber is the
+// line within the GFF3 file (Feature.LineNumber) and will be 0 for
+// Feature that were not read from a file.
+type Problem struct {
+	LineNumber int
+	Severity   Severity
+	Code       string
+	SeqId      string
+	Id         string
+	Message    string
+}
+
+// String gives a human-readable version of a Problem.
+func (p *Problem) String() string {
+	return fmt.Sprintf("line %d: %s: %s: %s", p.LineNumber, p.Severity, p.Code, p.Message)
+}
+
+// ProblemsTsvHeader is the header line for the TSV written by TsvString.
+func ProblemsTsvHeader() string {
+	return strings.Join([]string{`LineNumber`, `Severity`, `Code`, `SeqId`, `ID`, `Message`}, "\t")
+}
+
+// TsvString gives a tab-separated version of a Problem for machine
+// processing. See ProblemsTsvHeader for the columns.
+func (p *Problem) TsvString() string {
+	return strings.Join([]string{
+		strconv.Itoa(p.LineNumber),
+		p.Severity.String(),
+		p.Code,
+		p.SeqId,
+		p.Id,
+		p.Message}, "\t")
+}
+
+// Validator checks Feature against the GFF3 spec. Feature are added one
+// at a time so a Validator can be used on a stream of Feature from a
+// Reader. Checks that need to see the whole file, such as whether
+// Parent references resolve, are done by Finish. For in-memory GFF3s,
+// Validate is simpler to use.
+type Validator struct {
+	regions  map[string]*SequenceRegion
+	problems []*Problem
+	ids      map[string]*Feature // first Feature seen with each ID
+	parents  []*Feature          // Feature with Parent attributes
+}
+
+// NewValidator creates a *Validator. The header lines are used to find
+// the ##sequence-region directives that Feature are checked against.
+func NewValidator(headers []string) *Validator {
+	regions := make(map[string]*SequenceRegion)
+	for _, r := range SequenceRegionsFromHeaders(headers) {
+		regions[r.SeqId] = r
+	}
+	return &Validator{
+		regions: regions,
+		ids:     make(map[string]*Feature),
+	}
+}
+
+// AddParseError records a line that could not be parsed as a Feature.
+func (v *Validator) AddParseError(e *ParseError) {
+	v.problems = append(v.problems, &Problem{
+		LineNumber: e.LineNumber,
+		Severity:   SeverityError,
+		Code:       ProblemUnparseable,
+		Message:    e.Err.Error(),
+	})
+}
+
+// Add checks a single Feature.
+func (v *Validator) Add(f *Feature) {
+	id := f.Attributes[`ID`]
+	add := func(sev Severity, code, format string, a ...interface{}) {
+		v.problems = append(v.problems, &Problem{
+			LineNumber: f.LineNumber,
+			Severity:   sev,
+			Code:       code,
+			SeqId:      f.SeqId,
+			Id:         id,
+			Message:    fmt.Sprintf(format, a...),
+		})
+	}
+
+	if f.Start > f.End {
+		add(SeverityError, ProblemStartAfterEnd, "Start %d is greater than End %d", f.Start, f.End)
+	}
+
+	switch f.Strand {
+	case `+`, `-`, `.`, `?`:
+	default:
+		add(SeverityError, ProblemIllegalStrand, "Strand must be one of + - . ? but is %q", f.Strand)
+	}
+
+	switch f.Phase {
+	case `0`, `1`, `2`:
+	case `.`:
+		if f.Type == `CDS` {
+			add(SeverityError, ProblemCdsMissingPhase, "CDS Feature must have a Phase of 0, 1 or 2")
+		}
+	default:
+		add(SeverityError, ProblemIllegalPhase, "Phase must be one of 0 1 2 . but is %q", f.Phase)
+	}
+
+	if len(v.regions) > 0 {
+		if r, ok := v.regions[f.SeqId]; ok {
+			if f.Start < r.Start || f.End > r.End {
+				add(SeverityError, ProblemOutsideSequenceRegion,
+					"Feature %d-%d is outside ##sequence-region %s %d %d",
+					f.Start, f.End, r.SeqId, r.Start, r.End)
+			}
+		} else {
+			add(SeverityWarning, ProblemNoSequenceRegion,
+				"SeqId %s has no ##sequence-region directive", f.SeqId)
+		}
+	}
+
+	// Multiple Feature may share an ID only if they are the parts of a
+	// single discontiguous Feature so they must agree on SeqId, Type
+	// and Strand.
+	if id != `` {
+		if first, ok := v.ids[id]; ok {
+			if first.SeqId != f.SeqId || first.Type != f.Type || first.Strand != f.Strand {
+				add(SeverityError, ProblemDuplicateId,
+					"ID %s was already used at line %d by a Feature that is not part of the same discontiguous Feature",
+					id, first.LineNumber)
+			}
+		} else {
+			v.ids[id] = f
+		}
+	}
+
+	if _, ok := f.Attributes[`Parent`]; ok {
+		v.parents = append(v.parents, f)
+	}
+}
+
+// Finish does the checks that need all Feature to have been added and
+// returns all of the Problems found, sorted by LineNumber.
+func (v *Validator) Finish() []*Problem {
+	for _, f := range v.parents {
+		for _, p := range f.Parents() {
+			if _, ok := v.ids[p]; !ok {
+				v.problems = append(v.problems, &Problem{
+					LineNumber: f.LineNumber,
+					Severity:   SeverityError,
+					Code:       ProblemUnknownParent,
+					SeqId:      f.SeqId,
+					Id:         f.Attributes[`ID`],
+					Message:    fmt.Sprintf("Parent %s does not match the ID of any Feature", p),
+				})
+			}
+		}
+	}
+	v.parents = nil
+
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		return v.problems[i].LineNumber < v.problems[j].LineNumber
+	})
+	return v.problems
+}
+
+// Validate checks all of the Feature in a Gff3 and returns any
+// Problems found, sorted by LineNumber.
+func (g *Gff3) Validate() []*Problem {
+	v := NewValidator(g.Header)
+	for _, f := range g.Features.Features {
+		v.Add(f)
+	}
+	return v.Finish()
+}
+
+// MaxSeverity returns the most serious Severity in a list of Problems
+// or 0 if the list is empty.
+func MaxSeverity(problems []*Problem) Severity {
+	var max Severity
+	for _, p := range problems {
+		if p.Severity > max {
+			max = p.Severity
+		}
+	}
+	return max
+}
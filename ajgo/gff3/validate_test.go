@@ -0,0 +1,64 @@
+package gff3
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+var validate_1 = `##gff-version 3
+##sequence-region 1 1 1000
+1	ensembl	gene	1	100	.	+	.	ID=g1
+1	ensembl	mRNA	1	100	.	+	.	ID=t1;Parent=g1
+1	ensembl	CDS	10	20	.	+	0	ID=c1;Parent=t1
+1	ensembl	CDS	30	40	.	+	2	ID=c1;Parent=t1
+1	ensembl	CDS	50	60	.	+	.	ID=c2;Parent=t1
+1	ensembl	exon	80	70	.	*	3	Parent=t2
+1	ensembl	exon	900	1100	.	+	.	ID=g1;Parent=t1
+2	ensembl	exon	1	10	.	+	.	Parent=t1
+`
+
+func TestValidate(t *testing.T) {
+	g, err := NewFromScanner(bufio.NewScanner(strings.NewReader(validate_1)))
+	if err != nil {
+		t.Fatalf("NewFromScanner should not have failed: %v", err)
+	}
+
+	problems := g.Validate()
+
+	expected := []struct {
+		line int
+		code string
+	}{
+		{7, ProblemCdsMissingPhase},
+		{8, ProblemStartAfterEnd},
+		{8, ProblemIllegalStrand},
+		{8, ProblemIllegalPhase},
+		{8, ProblemUnknownParent},
+		{9, ProblemOutsideSequenceRegion},
+		{9, ProblemDuplicateId},
+		{10, ProblemNoSequenceRegion},
+	}
+
+	if len(expected) != len(problems) {
+		for _, p := range problems {
+			t.Log(p)
+		}
+		t.Fatalf("should have found %d problems but found %d", len(expected), len(problems))
+	}
+	for i, e := range expected {
+		p := problems[i]
+		if e.line != p.LineNumber || e.code != p.Code {
+			t.Fatalf("problem %d should be line %d %s but is %s", i, e.line, e.code, p)
+		}
+	}
+
+	e1 := SeverityError
+	g1 := MaxSeverity(problems)
+	if e1 != g1 {
+		t.Fatalf("MaxSeverity should be %s but is %s", e1, g1)
+	}
+	if MaxSeverity(nil) != 0 {
+		t.Fatalf("MaxSeverity of no problems should be 0")
+	}
+}
@@ -0,0 +1,228 @@
+package gff3
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/grendeloz/ngs/genome"
+)
+
+// fastaLineWidth is the number of bases per line in a ##FASTA section.
+const fastaLineWidth = 60
+
+// Writer writes a GFF3 one Feature at a time so a GFF3 can be written
+// without ever holding all of the Feature in memory.
+//
+// Header lines are held in Header until the first Feature is written
+// (or WriteHeader is called) at which point they are written out and
+// Header can no longer be changed. Writer makes sure that the first
+// header line is the mandatory gff-version line and that each header
+// line is terminated by exactly one newline, so headers can be added
+// with or without a trailing newline.
+//
+// A Feature that was followed by a ### directive when it was read is
+// followed by ### when it is written. FASTA sequences can be appended
+// with WriteFasta once all of the Feature have been written.
+type Writer struct {
+	File   string
+	Header []string
+
+	w             *bufio.Writer
+	closers       []io.Closer
+	headerWritten bool
+	count         int
+}
+
+// NewWriter creates a *Writer that writes to an io.Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// NewWriterToFile creates a file and returns a *Writer for it. Files
+// with a .gz extension are gzipped on-the-fly. The caller must call
+// Close to flush the output and close the file.
+func NewWriterToFile(file string) (*Writer, error) {
+	ff, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = ff
+	closers := []io.Closer{ff}
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		ff.Close()
+		return nil, fmt.Errorf("NewWriterToFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz := gzip.NewWriter(ff)
+		w = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	gw := NewWriter(w)
+	gw.File = file
+	gw.closers = closers
+	return gw, nil
+}
+
+// AddHeaders appends header lines. It returns an error if the headers
+// have already been written.
+func (w *Writer) AddHeaders(headers ...string) error {
+	if w.headerWritten {
+		return fmt.Errorf("AddHeaders: headers already written to %s", w.File)
+	}
+	w.Header = append(w.Header, headers...)
+	return nil
+}
+
+// WriteHeader writes the header lines. It is called automatically by
+// the first call to Write so you only need to call it directly if you
+// want a GFF3 that has headers but no Feature. Calling WriteHeader more
+// than once is harmless.
+func (w *Writer) WriteHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	w.headerWritten = true
+
+	headers := cleanHeaders(w.Header)
+	if len(headers) == 0 || !rexGffVersion.MatchString(headers[0]) {
+		headers = append([]string{`##gff-version 3`}, headers...)
+	}
+
+	// Headers still have their ##/#! prefixes
+	for _, h := range headers {
+		if _, err := w.w.WriteString(h + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write writes a single Feature, writing the headers first if that has
+// not already been done.
+func (w *Writer) Write(f *Feature) error {
+	if !w.headerWritten {
+		if err := w.WriteHeader(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.w.WriteString(f.String() + "\n"); err != nil {
+		return err
+	}
+	if f.barrierAfter {
+		if _, err := w.w.WriteString("###\n"); err != nil {
+			return err
+		}
+	}
+	w.count++
+	return nil
+}
+
+// WriteComments writes comment lines between Feature, e.g. the comment
+// lines that a Reader finds between Feature records, writing the
+// headers first if that has not already been done. As for headers, each
+// line is terminated by exactly one newline.
+func (w *Writer) WriteComments(lines ...string) error {
+	if err := w.WriteHeader(); err != nil {
+		return err
+	}
+	for _, l := range cleanHeaders(lines) {
+		if _, err := w.w.WriteString(l + "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFasta writes a ##FASTA directive followed by the sequences. It
+// must be the last thing written because the GFF3 spec says that
+// everything after ##FASTA is sequence. Sequence lines are wrapped at
+// fastaLineWidth bases.
+func (w *Writer) WriteFasta(seqs []*genome.Sequence) error {
+	if len(seqs) == 0 {
+		return nil
+	}
+	if err := w.WriteHeader(); err != nil {
+		return err
+	}
+	if _, err := w.w.WriteString("##FASTA\n"); err != nil {
+		return err
+	}
+	for _, s := range seqs {
+		header := s.Header
+		if header == `` {
+			header = `>` + s.Name
+		}
+		if _, err := w.w.WriteString(header + "\n"); err != nil {
+			return err
+		}
+		for i := 0; i < len(s.Sequence); i += fastaLineWidth {
+			end := i + fastaLineWidth
+			if end > len(s.Sequence) {
+				end = len(s.Sequence)
+			}
+			if _, err := w.w.WriteString(s.Sequence[i:end] + "\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteFeatures writes all of the Feature in a *Features.
+func (w *Writer) WriteFeatures(fs *Features) error {
+	for _, f := range fs.Features {
+		if err := w.Write(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Count returns the number of Feature written so far.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Flush writes any buffered output to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close writes the headers (if not already written), flushes buffered
+// output and closes any files opened by NewWriterToFile.
+func (w *Writer) Close() error {
+	first := w.WriteHeader()
+	if err := w.Flush(); err != nil && first == nil {
+		first = err
+	}
+	for _, c := range w.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	w.closers = nil
+	return first
+}
+
+// cleanHeaders splits any multi-line header strings and strips line
+// endings. Empty lines are dropped.
+func cleanHeaders(headers []string) []string {
+	var cleaned []string
+	for _, h := range headers {
+		for _, l := range strings.Split(h, "\n") {
+			l = strings.TrimSuffix(l, "\r")
+			if l != "" {
+				cleaned = append(cleaned, l)
+			}
+		}
+	}
+	return cleaned
+}
@@ -0,0 +1,56 @@
+package gff3
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b)
+
+	// gff-version is missing and the second header has a newline
+	w.AddHeaders("##content test\n", "##format test")
+
+	f := NewFeature()
+	f.SeqId = `1`
+	f.Start = 1
+	f.End = 10
+	f.Attributes[`ID`] = `a`
+	if err := w.Write(f); err != nil {
+		t.Fatalf("Write should not have failed: %v", err)
+	}
+
+	if err := w.AddHeaders("##too late"); err == nil {
+		t.Fatalf("AddHeaders after Write should have failed")
+	}
+	if err := w.WriteComments("# between\n"); err != nil {
+		t.Fatalf("WriteComments should not have failed: %v", err)
+	}
+	f.Start = 20
+	f.End = 30
+	if err := w.Write(f); err != nil {
+		t.Fatalf("Write should not have failed: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close should not have failed: %v", err)
+	}
+
+	e1 := "##gff-version 3\n" +
+		"##content test\n" +
+		"##format test\n" +
+		"1\tajgo\tSO:0000110\t1\t10\t.\t.\t.\tID=a\n" +
+		"# between\n" +
+		"1\tajgo\tSO:0000110\t20\t30\t.\t.\t.\tID=a\n"
+	g1 := b.String()
+	if e1 != g1 {
+		t.Fatalf("output should be\n%v\nbut is\n%v", e1, g1)
+	}
+
+	e2 := 2
+	g2 := w.Count()
+	if e2 != g2 {
+		t.Fatalf("Count should be %d but is %d", e2, g2)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 
+	"ajgo/selector"
+
 	"github.com/grendeloz/interval"
 )
 
@@ -47,6 +49,18 @@ type Feature struct {
 	Attributes map[string]string
 	Original   string
 	LineNumber int
+
+	attrs []Attribute // Attributes as read or added, including repeats
+}
+
+// Attribute is a single key/value pair from column 9. A key may appear
+// more than once in a GTF line, e.g. GENCODE uses a separate tag
+// attribute for each tag, so Feature keeps the Attributes in order as
+// well as in the Attributes map which only holds one value per key.
+// Values are as they appear in the file, i.e. usually double-quoted.
+type Attribute struct {
+	Key   string
+	Value string
 }
 
 type sortFeat struct {
@@ -296,17 +310,27 @@ func (fs *SeqFeat) Sort() (bool, error) {
 // This process is destructive! Genes with many transcripts often share
 // exons across multiple transcripts so removal of the duplicates means it
 // is no longer possible to work on transcripts. It will also merge
-// overlapping exons from different genes on opposite strands.
+// overlapping exons from different genes on opposite strands unless o
+// has a GroupBy that keeps them apart, e.g. strand or attr.gene_id.
 //
 // Despite the caveats, there are use cases where this behaviour is
 // exactly what is needed, for example if you are constructing a mask to
 // work out which genomic positions are part within the exons of a gene
 // or set of genes. For that use case, the Prune* family of functions
 // may also be useful.
-func (fs *SeqFeat) Consolidate() (int, error) {
-	var count int
+//
+// o may be nil. See ConsolidateOptions.
+func (fs *SeqFeat) Consolidate(o *ConsolidateOptions) (int, error) {
 	if !fs.IsSorted {
-		return count, fmt.Errorf("Consolidate: cannot call on an unsorted SeqFeat")
+		return 0, fmt.Errorf("Consolidate: cannot call on an unsorted SeqFeat")
+	}
+	if o == nil {
+		o = &ConsolidateOptions{}
+	}
+	for _, s := range o.GroupBy {
+		if !selector.IsFeatureSubject(s) {
+			return 0, fmt.Errorf("Consolidate: group by subject not recognised: %s", s)
+		}
 	}
 
 	// Consolidating an empty list of Features is legal but obviously
@@ -315,6 +339,46 @@ func (fs *SeqFeat) Consolidate() (int, error) {
 		return 0, nil
 	}
 
+	var keys []string
+	groups := make(map[string][]*Feature)
+	for _, f := range fs.Features {
+		k := o.groupKey(f)
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], f)
+	}
+
+	var count int
+	var feats []*Feature
+	for _, k := range keys {
+		keepers, n, err := fs.consolidate(groups[k], o.Aggregate)
+		if err != nil {
+			return count, err
+		}
+		count += n
+		feats = append(feats, keepers...)
+	}
+	if len(keys) > 1 {
+		sort.SliceStable(feats, func(i, j int) bool {
+			if feats[i].Start != feats[j].Start {
+				return feats[i].Start < feats[j].Start
+			}
+			return feats[i].End < feats[j].End
+		})
+	}
+
+	// Attach the list of keepers to fs
+	fs.Features = feats
+	return count, nil
+}
+
+// consolidate does the work of Consolidate for a sorted list of
+// Features and returns the consolidated Features and how many were
+// merged.
+func (fs *SeqFeat) consolidate(features []*Feature, aggregate bool) ([]*Feature, int, error) {
+	var count int
+
 	// This is a bit tricky but we will always be comparing the last
 	// Feature in the keepers list against the next Feature on the full
 	// list. This will let the keeper Feature merge with as many records
@@ -323,17 +387,17 @@ func (fs *SeqFeat) Consolidate() (int, error) {
 	// away we go again merging onto the new "last" keeper Feature.
 
 	var keepers []*Feature
-	keepers = append(keepers, fs.Features[0])
+	keepers = append(keepers, features[0])
 
-	for i := 1; i < len(fs.Features); i++ {
+	for i := 1; i < len(features); i++ {
 		keepidx := len(keepers) - 1
-		allen := interval.Compare(keepers[keepidx], fs.Features[i])
+		allen := interval.Compare(keepers[keepidx], features[i])
 
 		//log.Infof("%s[%d,%d]%d vs %s[%d,%d]%d = %d",
 		//	keepers[keepidx].SeqName, keepers[keepidx].Start,
 		//    keepers[keepidx].End, keepidx,
-		//	fs.Features[i].SeqName, fs.Features[i].Start,
-		//    fs.Features[i].End, i,
+		//	features[i].SeqName, features[i].Start,
+		//    features[i].End, i,
 		//	allen)
 
 		// 1. Return error on AllenR of Unknown
@@ -342,26 +406,55 @@ func (fs *SeqFeat) Consolidate() (int, error) {
 		// 3. Append to the keepers list if PrecedesB
 		// 2. Otherwise merge.
 		if allen == interval.Unknown {
-			return count, fmt.Errorf("Consolidate: Allen Relationship is Unknown for {%+v} vs {%+v}",
-				keepers[keepidx], fs.Features[i])
+			return nil, count, fmt.Errorf("Consolidate: Allen Relationship is Unknown for {%+v} vs {%+v}",
+				keepers[keepidx], features[i])
 		} else if allen == interval.FinishesB ||
 			allen == interval.IsContainedByB ||
 			allen == interval.IsOverlappedByB ||
 			allen == interval.IsMetByB ||
 			allen == interval.IsPrecededByB {
-			return count, fmt.Errorf("Consolidate: {%+v} vs {%+v} means SeqFeat %s is unsorted",
-				keepers[keepidx], fs.Features[i], fs.SeqName)
+			return nil, count, fmt.Errorf("Consolidate: {%+v} vs {%+v} means SeqFeat %s is unsorted",
+				keepers[keepidx], features[i], fs.SeqName)
 		} else if allen == interval.PrecedesB {
-			keepers = append(keepers, fs.Features[i])
+			keepers = append(keepers, features[i])
 		} else {
-			keepers[keepidx].Merge(fs.Features[i])
+			var agg []Attribute
+			if aggregate {
+				agg = keepers[keepidx].aggregatedAttributes(features[i])
+			}
+			keepers[keepidx].Merge(features[i])
+			for _, a := range agg {
+				keepers[keepidx].setAttribute(a.Key, a.Value)
+			}
 			count++
 		}
 	}
 
-	// Attach the list of keepers to fs
-	fs.Features = keepers
-	return count, nil
+	return keepers, count, nil
+}
+
+// ConsolidateOptions change what Consolidate merges. A nil
+// *ConsolidateOptions merges every Feature that touches or overlaps
+// another and deletes the Attributes that differ.
+type ConsolidateOptions struct {
+	// GroupBy lists selector subjects, e.g. strand or attr.gene_id
+	// (see selector.IsFeatureSubject), and only Features that have the
+	// same values for all of them are merged. The subjects are the same
+	// as for gff3.MergeOptions.
+	GroupBy []string
+	// Aggregate keeps the Attributes that differ between merged
+	// Features with all of their values, e.g. gene_name "BRCA1,NBR2",
+	// instead of deleting them.
+	Aggregate bool
+}
+
+// groupKey returns the GroupBy values of a Feature as a single string.
+func (o *ConsolidateOptions) groupKey(f *Feature) string {
+	keys := make([]string, len(o.GroupBy))
+	for i, s := range o.GroupBy {
+		keys[i] = strings.Join(f.SubjectValues(s), ",")
+	}
+	return strings.Join(keys, "\t")
 }
 
 // KeepByFeatures takes a list of strings and all Features in the SeqFeat
@@ -416,30 +509,28 @@ func NewFeatureFromFields(fields []string) (*Feature, error) {
 	// upset everything including splitting on space so get ready for
 	// what appears to be an inordinate level of TrimSpace use.
 	feat.Attributes = make(map[string]string)
-	splitable := strings.TrimSpace(fields[8])
+	var splitable string
+	if len(fields) > 8 {
+		splitable = strings.TrimSpace(fields[8])
+	}
 	if splitable != "" {
-		attributes := strings.Split(splitable, ";")
-		for _, a := range attributes {
+		for _, a := range splitAttributes(splitable) {
+			// Much more space trimming required here
+			a := strings.TrimSpace(a)
 			// There is often a trailing empty attribute and we don't want
 			// empty stuff in the map so skip any empty attributes
 			if a == "" {
 				continue
 			}
-			// Much more space trimming required here
-			a := strings.TrimSpace(a)
 			subs := strings.SplitN(a, " ", 2)
 			// The split doesn't remove white space
+			key := strings.TrimSpace(subs[0])
+			val := ""
 			if len(subs) == 2 {
-				key := strings.TrimSpace(subs[0])
-				val := strings.TrimSpace(subs[1])
-				feat.Attributes[key] = val
-			} else if len(subs) == 1 {
-				key := strings.TrimSpace(subs[0])
-				feat.Attributes[key] = ""
-			} else {
-				// This should be impossible because we already checked
-				// that field[8] != ""
+				val = strings.TrimSpace(subs[1])
 			}
+			feat.Attributes[key] = val
+			feat.attrs = append(feat.attrs, Attribute{Key: key, Value: val})
 		}
 	}
 	return &feat, nil
@@ -479,16 +570,17 @@ func ParseGtfModelFile(file string) ([]*Feature, error) {
 	// Unnecessary but explicit
 	scanner.Split(bufio.ScanLines)
 
-	// Pattern for track lines
-	rex := regexp.MustCompile(`^track`)
+	// Pattern for track and comment lines, e.g. the #! and ## headers
+	// at the top of Ensembl and GENCODE GTFs
+	rex := regexp.MustCompile(`^(track|#)`)
 
 	// Read the file
 	lctr := 0
 	for scanner.Scan() {
 		line := strings.TrimSuffix(scanner.Text(), "\n")
 		lctr++
-		if rex.MatchString(line) {
-			// We are skipping track lines, if any
+		if rex.MatchString(line) || strings.TrimSpace(line) == "" {
+			// We are skipping track lines, comments and blank lines
 		} else {
 			fields := strings.Split(line, "\t")
 			f, err := NewFeatureFromFields(fields)
@@ -500,6 +592,10 @@ func ParseGtfModelFile(file string) ([]*Feature, error) {
 		}
 	}
 
+	if err := scanner.Err(); err != nil {
+		return feats, fmt.Errorf("ParseGtfModelFile: error scanning %s: %w", file, err)
+	}
+
 	return feats, nil
 }
 
@@ -608,9 +704,17 @@ func (a *Feature) Merge(b *Feature) error {
 }
 
 func (f *Feature) String() string {
-	// Attributes are ;-separated
-	attrString := f.AttributesString()
+	return f.line(f.AttributesString())
+}
+
+// SpecString is a variant of String that writes the Attributes with
+// SpecAttributesString, as WriteFeatures does.
+func (f *Feature) SpecString() string {
+	return f.line(f.SpecAttributesString())
+}
 
+// line joins the fields of a Feature and an attributes column.
+func (f *Feature) line(attrString string) string {
 	// Fields are tab-separated
 	output := strings.Join([]string{
 		f.SeqName,
@@ -669,6 +773,218 @@ func (f *Feature) AttributesString() string {
 	return attrString
 }
 
+// SpecAttributesString is a variant of AttributesString that follows
+// the GTF spec. gene_id and transcript_id come first and the remaining
+// Attributes follow in the order they were read or added, with any
+// Attributes that were only set in the Attributes map after them,
+// sorted by key name. A key that was read more than once (e.g. tag) is
+// written with all of its values unless its value in the Attributes map
+// has been changed. Every Attribute is terminated by ; as the spec
+// requires.
+func (f *Feature) SpecAttributesString() string {
+	var attrStrings []string
+	for _, k := range f.AttributeKeys() {
+		vals := f.rawValues(k)
+		if len(vals) == 0 || vals[len(vals)-1] != f.Attributes[k] {
+			vals = []string{f.Attributes[k]}
+		}
+		for _, v := range vals {
+			attrStrings = append(attrStrings, k+" "+v+";")
+		}
+	}
+
+	// Attributes are ;-separated
+	attrString := strings.Join(attrStrings, " ")
+
+	return attrString
+}
+
+// AttributeKeys returns the keys of the Attributes in the order they
+// are written by SpecAttributesString.
+func (f *Feature) AttributeKeys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(k string) {
+		if _, ok := f.Attributes[k]; ok && !seen[k] {
+			keys = append(keys, k)
+			seen[k] = true
+		}
+	}
+	add(`gene_id`)
+	add(`transcript_id`)
+	for _, a := range f.attrs {
+		add(a.Key)
+	}
+	var added []string
+	for k := range f.Attributes {
+		if !seen[k] {
+			added = append(added, k)
+		}
+	}
+	sort.Strings(added)
+	return append(keys, added...)
+}
+
+// SubjectValues returns the value(s) from the Feature for a selector
+// subject (see selector.IsFeatureSubject). The GTF feature and frame
+// columns are the type and phase subjects as they are in GFF3.
+func (f *Feature) SubjectValues(subject string) []string {
+	switch subject {
+	case `seqid`:
+		return []string{f.SeqName}
+	case `source`:
+		return []string{f.Source}
+	case `type`:
+		return []string{f.Feature}
+	case `score`:
+		return []string{f.Score}
+	case `strand`:
+		return []string{f.Strand}
+	case `phase`:
+		return []string{f.Frame}
+	case `length`:
+		return []string{strconv.Itoa(int(f.End - f.Start + 1))}
+	}
+	if !strings.HasPrefix(subject, selector.AttrPrefix) {
+		return nil
+	}
+	return f.AttributeValues(strings.TrimPrefix(subject, selector.AttrPrefix))
+}
+
+// AttributeValues returns all of the values of an Attribute with any
+// surrounding double quotes removed. Most Attributes have a single value
+// but some, e.g. tag in GENCODE, may appear many times. It returns nil
+// if the Attribute is not present.
+func (f *Feature) AttributeValues(key string) []string {
+	v, ok := f.Attributes[key]
+	if !ok {
+		return nil
+	}
+	vals := f.rawValues(key)
+	if len(vals) == 0 || vals[len(vals)-1] != v {
+		vals = []string{v}
+	}
+	unquoted := make([]string, len(vals))
+	for i, v := range vals {
+		unquoted[i] = unquote(v)
+	}
+	return unquoted
+}
+
+// AddAttribute adds a value to an Attribute. The value is double-quoted
+// so it should not already have quotes. If the key already exists, the
+// new value is added after the existing values, as for tag in GENCODE.
+func (f *Feature) AddAttribute(key, val string) {
+	if f.Attributes == nil {
+		f.Attributes = make(map[string]string)
+	}
+	if _, ok := f.Attributes[key]; ok && len(f.rawValues(key)) == 0 {
+		// Set directly in the map so keep the existing value
+		f.attrs = append(f.attrs, Attribute{Key: key, Value: f.Attributes[key]})
+	}
+	val = `"` + val + `"`
+	f.Attributes[key] = val
+	f.attrs = append(f.attrs, Attribute{Key: key, Value: val})
+}
+
+// aggregatedAttributes returns the Attributes that differ between a
+// and b, each with all of the values from both Features as a single
+// unquoted comma-separated value, e.g. gene_name BRCA1,NBR2. Values
+// that already contain commas, from an earlier aggregation, are split
+// so each value only appears once.
+func (a *Feature) aggregatedAttributes(b *Feature) []Attribute {
+	var agg []Attribute
+	keys := a.AttributeKeys()
+	for _, k := range b.AttributeKeys() {
+		if _, ok := a.Attributes[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if a.Attributes[k] == b.Attributes[k] {
+			continue
+		}
+		var vals []string
+		seen := make(map[string]bool)
+		for _, v := range append(a.AttributeValues(k), b.AttributeValues(k)...) {
+			for _, s := range strings.Split(v, ",") {
+				if !seen[s] {
+					seen[s] = true
+					vals = append(vals, s)
+				}
+			}
+		}
+		agg = append(agg, Attribute{Key: k, Value: strings.Join(vals, ",")})
+	}
+	return agg
+}
+
+// setAttribute sets an Attribute to a single value, replacing any
+// existing values but keeping its place in the Attribute order. The
+// value is double-quoted so it should not already have quotes.
+func (f *Feature) setAttribute(key, val string) {
+	if f.Attributes == nil {
+		f.Attributes = make(map[string]string)
+	}
+	val = `"` + val + `"`
+	var attrs []Attribute
+	var found bool
+	for _, a := range f.attrs {
+		if a.Key == key {
+			if found {
+				continue
+			}
+			a.Value = val
+			found = true
+		}
+		attrs = append(attrs, a)
+	}
+	if !found {
+		attrs = append(attrs, Attribute{Key: key, Value: val})
+	}
+	f.attrs = attrs
+	f.Attributes[key] = val
+}
+
+// rawValues returns the values of a key from the ordered Attributes.
+func (f *Feature) rawValues(key string) []string {
+	var vals []string
+	for _, a := range f.attrs {
+		if a.Key == key {
+			vals = append(vals, a.Value)
+		}
+	}
+	return vals
+}
+
+// splitAttributes splits the attributes column on ; except where the ;
+// is inside a quoted value, e.g. an Ensembl description.
+func splitAttributes(s string) []string {
+	var attrs []string
+	var quoted bool
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				attrs = append(attrs, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(attrs, s[start:])
+}
+
+// unquote removes surrounding double quotes from a value.
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
 func (g *Gtf) Write(file string) error {
 	f, err := os.Create(file)
 	if err != nil {
@@ -693,6 +1009,54 @@ func (g *Gtf) Write(file string) error {
 	return nil
 }
 
+// WriteFeatures writes header lines and then Features, in the order
+// given, to a GTF file. Unlike Gtf.Write, Attributes are written as the
+// GTF spec requires, see SpecAttributesString. Files with a .gz
+// extension are gzipped. Header lines should start with # and any line
+// endings are stripped.
+func WriteFeatures(file string, headers []string, feats []*Feature) error {
+	ff, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer ff.Close()
+
+	var w *bufio.Writer
+	var gz *gzip.Writer
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		return fmt.Errorf("WriteFeatures: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz = gzip.NewWriter(ff)
+		w = bufio.NewWriter(gz)
+	} else {
+		w = bufio.NewWriter(ff)
+	}
+
+	for _, h := range headers {
+		h = strings.TrimRight(h, "\r\n")
+		if h == "" {
+			continue
+		}
+		if _, err = w.WriteString(h + "\n"); err != nil {
+			return err
+		}
+	}
+	for _, f := range feats {
+		if _, err = w.WriteString(f.SpecString() + "\n"); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
+}
+
 // SeqFeatNames returns sorted list of the SeqFeat names in the
 // Gtf. This is useful anywhere that you want consistent ordering.
 func (g *Gtf) SeqFeatNames() []string {
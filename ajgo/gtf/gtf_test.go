@@ -0,0 +1,95 @@
+package gtf
+
+import (
+	"strings"
+	"testing"
+)
+
+// Two overlapping genes on opposite strands and a third gene that
+// overlaps the second on the same strand.
+var consolidate_1 = []string{
+	"17\tensembl\texon\t100\t200\t.\t-\t.\tgene_id \"g1\"; gene_name \"BRCA1\"; tag \"basic\"; tag \"CCDS\";",
+	"17\tensembl\texon\t150\t300\t.\t+\t.\tgene_id \"g2\"; gene_name \"NBR2\"; tag \"basic\"; tag \"MANE\";",
+	"17\tensembl\texon\t250\t400\t.\t+\t.\tgene_id \"g3\"; gene_name \"NBR2\"; tag \"basic\"; tag \"MANE\";",
+}
+
+// newTestSeqFeat returns a sorted SeqFeat from GTF lines.
+func newTestSeqFeat(t *testing.T, lines []string) *SeqFeat {
+	fs := &SeqFeat{SeqName: `17`}
+	for _, l := range lines {
+		f, err := NewFeatureFromFields(strings.Split(l, "\t"))
+		if err != nil {
+			t.Fatalf("NewFeatureFromFields should not have failed: %v", err)
+		}
+		fs.Features = append(fs.Features, f)
+	}
+	if _, err := fs.Sort(); err != nil {
+		t.Fatalf("Sort should not have failed: %v", err)
+	}
+	return fs
+}
+
+func testConsolidate(t *testing.T, o *ConsolidateOptions, n int, e []string) {
+	t.Helper()
+	fs := newTestSeqFeat(t, consolidate_1)
+	count, err := fs.Consolidate(o)
+	if err != nil {
+		t.Fatalf("Consolidate should not have failed: %v", err)
+	}
+	if count != n {
+		t.Errorf("Consolidate count should be %d but is %d", n, count)
+	}
+	var got []string
+	for _, f := range fs.Features {
+		got = append(got, f.SpecString())
+	}
+	if strings.Join(got, "\n") != strings.Join(e, "\n") {
+		t.Errorf("Features should be:\n%s\nbut are:\n%s",
+			strings.Join(e, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	// Everything overlaps so there is a single Feature and the
+	// Attributes that differ, including the tags, are deleted.
+	testConsolidate(t, nil, 2, []string{
+		"17\tensembl\texon\t100\t400\t.\t.\t.\t",
+	})
+}
+
+func TestConsolidateGroupByStrand(t *testing.T) {
+	testConsolidate(t, &ConsolidateOptions{GroupBy: []string{`strand`}}, 1, []string{
+		"17\tensembl\texon\t100\t200\t.\t-\t.\tgene_id \"g1\"; gene_name \"BRCA1\"; tag \"basic\"; tag \"CCDS\";",
+		"17\tensembl\texon\t150\t400\t.\t+\t.\tgene_name \"NBR2\"; tag \"basic\"; tag \"MANE\";",
+	})
+}
+
+func TestConsolidateGroupByGeneId(t *testing.T) {
+	// Nothing shares a gene_id so nothing is merged.
+	testConsolidate(t, &ConsolidateOptions{GroupBy: []string{`attr.gene_id`}}, 0, consolidate_1)
+}
+
+func TestConsolidateGroupByLength(t *testing.T) {
+	// Only g2 and g3 are both 151 bases long
+	testConsolidate(t, &ConsolidateOptions{GroupBy: []string{`length`}}, 1, []string{
+		"17\tensembl\texon\t100\t200\t.\t-\t.\tgene_id \"g1\"; gene_name \"BRCA1\"; tag \"basic\"; tag \"CCDS\";",
+		"17\tensembl\texon\t150\t400\t.\t+\t.\tgene_name \"NBR2\"; tag \"basic\"; tag \"MANE\";",
+	})
+}
+
+func TestConsolidateAggregate(t *testing.T) {
+	testConsolidate(t, &ConsolidateOptions{Aggregate: true}, 2, []string{
+		"17\tensembl\texon\t100\t400\t.\t.\t.\tgene_id \"g1,g2,g3\"; gene_name \"BRCA1,NBR2\"; tag \"basic,CCDS,MANE\";",
+	})
+	testConsolidate(t, &ConsolidateOptions{GroupBy: []string{`strand`}, Aggregate: true}, 1, []string{
+		"17\tensembl\texon\t100\t200\t.\t-\t.\tgene_id \"g1\"; gene_name \"BRCA1\"; tag \"basic\"; tag \"CCDS\";",
+		"17\tensembl\texon\t150\t400\t.\t+\t.\tgene_id \"g2,g3\"; gene_name \"NBR2\"; tag \"basic\"; tag \"MANE\";",
+	})
+}
+
+func TestConsolidateBadGroupBy(t *testing.T) {
+	fs := newTestSeqFeat(t, consolidate_1)
+	if _, err := fs.Consolidate(&ConsolidateOptions{GroupBy: []string{`attr.`}}); err == nil {
+		t.Errorf("Consolidate should have failed for GroupBy attr.")
+	}
+}
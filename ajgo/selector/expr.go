@@ -0,0 +1,612 @@
+package selector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The three-part operation:subject:pattern Selector can only express a
+// single regex test so complex selections need several passes. An
+// Expr is a boolean expression that is evaluated against a record and
+// returns true if the record should be kept. The syntax is:
+//
+//	expr       := term { (or | ||) term }
+//	term       := factor { (and | &&) factor }
+//	factor     := (not | !) factor | ( expr ) | condition
+//	condition  := subject operator value
+//	            | subject in @file
+//	            | subject in [ value {, value} ]
+//
+// The operators are:
+//
+//	~  !~          regex match, regex does not match
+//	== !=          string equal, not equal
+//	<  <= >  >=    numeric comparison
+//	in             set membership - from a file (one value per line)
+//	               or from a list in square brackets
+//
+// Values can be bare words or quoted with ' or ". A value that contains
+// whitespace or any of ( ) [ ] , < > = ! ~ & | must be quoted. The
+// quote character itself can be escaped with a backslash inside a quoted
+// value. The keywords and, or, not and in are case-insensitive. For example:
+//
+//	type == gene and attr.biotype == protein_coding and
+//	  seqid ~ '^(chr)?([1-9]|1[0-9]|2[0-2])$' and not attr.Name ~ readthrough
+//
+// A subject may have more than one value (e.g. a multi-valued GFF3
+// Attribute) in which case the positive operators are true if any value
+// passes and the negative operators (!~ and !=) are the negation of
+// their positive form. A subject with no value (e.g. a missing
+// Attribute) fails every positive test. A numeric test fails for a
+// value that is not a number, e.g. a GFF3 Score of ".".
+//
+// The three-part form is still accepted as a shorthand, i.e.
+// keep:type:gene is the expression type ~ gene and delete:type:gene is
+// the expression not type ~ gene.
+
+// Record is anything that an Expr can be evaluated against.
+// SubjectValues returns the value(s) of a subject for the record.
+type Record interface {
+	SubjectValues(subject string) []string
+}
+
+// Expr is a parsed selector expression.
+type Expr interface {
+	// Eval returns true if the record passes the expression.
+	Eval(r Record) bool
+	// String returns the expression in canonical form.
+	String() string
+	// subjects adds the subjects used by the expression to a set.
+	subjects(map[string]bool)
+}
+
+// Subjects returns a sorted list of the subjects used in an Expr so a
+// caller can check that it knows how to supply all of them.
+func Subjects(e Expr) []string {
+	set := make(map[string]bool)
+	e.subjects(set)
+	var subjects []string
+	for s := range set {
+		subjects = append(subjects, s)
+	}
+	sort.Strings(subjects)
+	return subjects
+}
+
+// CheckSubjects returns an error if the Expr uses a subject for which
+// valid returns false.
+func CheckSubjects(e Expr, valid func(string) bool) error {
+	for _, s := range Subjects(e) {
+		if !valid(s) {
+			return fmt.Errorf("selector subject not recognised: %s in: %s", s, e)
+		}
+	}
+	return nil
+}
+
+// IsShorthand returns true if s is a three-part keep/delete Selector
+// rather than an expression. A caller that needs to treat the
+// shorthand differently, e.g. to map subjects from older versions of
+// ajgo, can use NewFromString and Selector.Expr instead of Parse.
+func IsShorthand(s string) bool {
+	return rexShorthand.MatchString(s)
+}
+
+// Parse parses a selector expression or a three-part shorthand Selector.
+func Parse(s string) (Expr, error) {
+	if IsShorthand(s) {
+		sel, err := NewFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		return sel.Expr()
+	}
+
+	p := &parser{input: s}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return e, nil
+}
+
+// ParseAll parses a list of selector expressions and combines them
+// with and, i.e. a record must pass all of them. This is equivalent to
+// applying each expression one after another.
+func ParseAll(ss []string) (Expr, error) {
+	var exprs []Expr
+	for _, s := range ss {
+		e, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &andExpr{exprs: exprs}, nil
+}
+
+// Expr converts a three-part Selector into the equivalent Expr.
+func (s Selector) Expr() (Expr, error) {
+	rex, err := regexp.Compile(s.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling pattern in %s: %w", s, err)
+	}
+	c := &condExpr{subject: s.Subject, op: `~`, value: s.Pattern, rex: rex}
+	switch s.Operation {
+	case `keep`:
+		return c, nil
+	case `delete`:
+		return &notExpr{expr: c}, nil
+	}
+	return nil, fmt.Errorf("selector operation not recognised in: %s", s)
+}
+
+// rexShorthand matches the three-part keep/delete form.
+var rexShorthand = regexp.MustCompile(`^(keep|delete):[^:\s]*:`)
+
+type orExpr struct {
+	exprs []Expr
+}
+
+func (e *orExpr) Eval(r Record) bool {
+	for _, x := range e.exprs {
+		if x.Eval(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *orExpr) String() string {
+	return joinExprs(e.exprs, ` or `)
+}
+
+func (e *orExpr) subjects(set map[string]bool) {
+	for _, x := range e.exprs {
+		x.subjects(set)
+	}
+}
+
+type andExpr struct {
+	exprs []Expr
+}
+
+func (e *andExpr) Eval(r Record) bool {
+	for _, x := range e.exprs {
+		if !x.Eval(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *andExpr) String() string {
+	return joinExprs(e.exprs, ` and `)
+}
+
+func (e *andExpr) subjects(set map[string]bool) {
+	for _, x := range e.exprs {
+		x.subjects(set)
+	}
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+func (e *notExpr) Eval(r Record) bool {
+	return !e.expr.Eval(r)
+}
+
+func (e *notExpr) String() string {
+	return `not ` + wrapExpr(e.expr)
+}
+
+func (e *notExpr) subjects(set map[string]bool) {
+	e.expr.subjects(set)
+}
+
+// condExpr is a single test of a subject against a value.
+type condExpr struct {
+	subject string
+	op      string
+	value   string
+	rex     *regexp.Regexp  // for ~ and !~
+	num     float64         // for < <= > >=
+	set     map[string]bool // for in
+}
+
+func (e *condExpr) Eval(r Record) bool {
+	vals := r.SubjectValues(e.subject)
+	switch e.op {
+	case `!~`:
+		return !e.any(vals, `~`)
+	case `!=`:
+		return !e.any(vals, `==`)
+	}
+	return e.any(vals, e.op)
+}
+
+// any returns true if any value passes the (positive) operator.
+func (e *condExpr) any(vals []string, op string) bool {
+	for _, v := range vals {
+		var pass bool
+		switch op {
+		case `~`:
+			pass = e.rex.MatchString(v)
+		case `==`:
+			pass = v == e.value
+		case `in`:
+			pass = e.set[v]
+		default:
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			switch op {
+			case `<`:
+				pass = n < e.num
+			case `<=`:
+				pass = n <= e.num
+			case `>`:
+				pass = n > e.num
+			case `>=`:
+				pass = n >= e.num
+			}
+		}
+		if pass {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *condExpr) String() string {
+	if e.op == `in` {
+		return e.subject + ` in ` + e.value
+	}
+	return e.subject + ` ` + e.op + ` ` + quoteValue(e.value)
+}
+
+func (e *condExpr) subjects(set map[string]bool) {
+	set[e.subject] = true
+}
+
+func joinExprs(exprs []Expr, sep string) string {
+	var ss []string
+	for _, x := range exprs {
+		ss = append(ss, wrapExpr(x))
+	}
+	return strings.Join(ss, sep)
+}
+
+// wrapExpr puts brackets around and/or expressions so String output
+// parses back to the same Expr.
+func wrapExpr(e Expr) string {
+	switch e.(type) {
+	case *andExpr, *orExpr:
+		return `(` + e.String() + `)`
+	}
+	return e.String()
+}
+
+// quoteValue quotes a value if it would not lex as a single bare word.
+func quoteValue(v string) string {
+	bare := v != `` && !strings.ContainsAny(v, lexSpecial)
+	for _, k := range []string{`and`, `or`, `not`, `in`} {
+		if strings.EqualFold(v, k) {
+			bare = false
+		}
+	}
+	if bare {
+		return v
+	}
+	if !strings.Contains(v, `'`) {
+		return `'` + v + `'`
+	}
+	return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+}
+
+// lexSpecial are the characters that end a bare word.
+const lexSpecial = " \t\n\r()[],<>=!~&|'\""
+
+// token types
+const (
+	tokWord   = iota // bare word - may be a keyword
+	tokString        // quoted string
+	tokOp            // comparison operator
+	tokPunct         // ( ) [ ] , ! && ||
+)
+
+type token struct {
+	kind int
+	text string
+	pos  int
+}
+
+type parser struct {
+	input  string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) errorf(format string, a ...interface{}) error {
+	at := len(p.input)
+	if p.pos < len(p.tokens) {
+		at = p.tokens[p.pos].pos
+	}
+	return fmt.Errorf("selector: %s at position %d in: %s", fmt.Sprintf(format, a...), at+1, p.input)
+}
+
+// lex splits the input into tokens.
+func (p *parser) lex() error {
+	s := p.input
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']' || c == ',':
+			p.tokens = append(p.tokens, token{tokPunct, s[i : i+1], i})
+			i++
+		case strings.HasPrefix(s[i:], `&&`) || strings.HasPrefix(s[i:], `||`):
+			p.tokens = append(p.tokens, token{tokPunct, s[i : i+2], i})
+			i += 2
+		case strings.HasPrefix(s[i:], `!~`) || strings.HasPrefix(s[i:], `!=`) ||
+			strings.HasPrefix(s[i:], `==`) || strings.HasPrefix(s[i:], `<=`) ||
+			strings.HasPrefix(s[i:], `>=`):
+			p.tokens = append(p.tokens, token{tokOp, s[i : i+2], i})
+			i += 2
+		case c == '~' || c == '<' || c == '>':
+			p.tokens = append(p.tokens, token{tokOp, s[i : i+1], i})
+			i++
+		case c == '!':
+			p.tokens = append(p.tokens, token{tokPunct, `!`, i})
+			i++
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != c; j++ {
+				if s[j] == '\\' && j+1 < len(s) && s[j+1] == c {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return fmt.Errorf("selector: unterminated string at position %d in: %s", i+1, s)
+			}
+			p.tokens = append(p.tokens, token{tokString, b.String(), i})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(lexSpecial, rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return fmt.Errorf("selector: unexpected %q at position %d in: %s", s[i:i+1], i+1, s)
+			}
+			p.tokens = append(p.tokens, token{tokWord, s[i:j], i})
+			i = j
+		}
+	}
+	return nil
+}
+
+// peek returns the next token or an empty token at the end of input.
+func (p *parser) peek() token {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return token{kind: -1}
+}
+
+// isKeyword returns true if the token is the (case-insensitive) keyword
+// or one of the symbolic alternatives.
+func isKeyword(t token, keyword string, symbols ...string) bool {
+	if t.kind == tokWord && strings.EqualFold(t.text, keyword) {
+		return true
+	}
+	if t.kind == tokPunct {
+		for _, s := range symbols {
+			if t.text == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	e, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{e}
+	for isKeyword(p.peek(), `or`, `||`) {
+		p.pos++
+		e, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &orExpr{exprs: exprs}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	e, err := p.parseFactor()
+	if err != nil {
+		return nil, err
+	}
+	exprs := []Expr{e}
+	for isKeyword(p.peek(), `and`, `&&`) {
+		p.pos++
+		e, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, e)
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return &andExpr{exprs: exprs}, nil
+}
+
+func (p *parser) parseFactor() (Expr, error) {
+	t := p.peek()
+	switch {
+	case isKeyword(t, `not`, `!`):
+		p.pos++
+		e, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: e}, nil
+	case t.kind == tokPunct && t.text == `(`:
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t.kind != tokPunct || t.text != `)` {
+			return nil, p.errorf("expected )")
+		}
+		p.pos++
+		return e, nil
+	case t.kind == tokWord:
+		return p.parseCondition()
+	case t.kind == -1:
+		return nil, p.errorf("unexpected end of expression")
+	}
+	return nil, p.errorf("unexpected %q", t.text)
+}
+
+func (p *parser) parseCondition() (Expr, error) {
+	subject := p.peek().text
+	p.pos++
+
+	t := p.peek()
+	if isKeyword(t, `in`) {
+		p.pos++
+		return p.parseIn(subject)
+	}
+	if t.kind != tokOp {
+		return nil, p.errorf("expected an operator after %s", subject)
+	}
+	op := t.text
+	p.pos++
+
+	t = p.peek()
+	if t.kind != tokWord && t.kind != tokString {
+		return nil, p.errorf("expected a value after %s %s", subject, op)
+	}
+	p.pos++
+
+	c := &condExpr{subject: subject, op: op, value: t.text}
+	switch op {
+	case `~`, `!~`:
+		rex, err := regexp.Compile(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("selector: error compiling pattern %s in: %s: %w", t.text, p.input, err)
+		}
+		c.rex = rex
+	case `==`, `!=`:
+	default:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			p.pos--
+			return nil, p.errorf("%s needs a number but got %s", op, t.text)
+		}
+		c.num = n
+	}
+	return c, nil
+}
+
+// parseIn parses the value of an in condition which is either @file or
+// a [ ] list.
+func (p *parser) parseIn(subject string) (Expr, error) {
+	c := &condExpr{subject: subject, op: `in`, set: make(map[string]bool)}
+	t := p.peek()
+	switch {
+	case (t.kind == tokWord || t.kind == tokString) && strings.HasPrefix(t.text, `@`):
+		p.pos++
+		vals, err := setFromFile(t.text[1:])
+		if err != nil {
+			return nil, fmt.Errorf("selector: in: %s: %w", p.input, err)
+		}
+		for _, v := range vals {
+			c.set[v] = true
+		}
+		c.value = quoteValue(t.text)
+		return c, nil
+	case t.kind == tokPunct && t.text == `[`:
+		p.pos++
+		var vals []string
+		for {
+			t = p.peek()
+			if t.kind != tokWord && t.kind != tokString {
+				return nil, p.errorf("expected a value in [ ] list")
+			}
+			p.pos++
+			c.set[t.text] = true
+			vals = append(vals, quoteValue(t.text))
+			t = p.peek()
+			if t.kind == tokPunct && t.text == `,` {
+				p.pos++
+				continue
+			}
+			if t.kind == tokPunct && t.text == `]` {
+				p.pos++
+				break
+			}
+			return nil, p.errorf("expected , or ] in list")
+		}
+		c.value = `[` + strings.Join(vals, `, `) + `]`
+		return c, nil
+	}
+	return nil, p.errorf("in needs @file or a [ ] list")
+}
+
+// setFromFile reads the values for an in condition. Each line is one
+// value. Leading and trailing whitespace is removed and empty lines and
+// lines starting with # are ignored.
+func setFromFile(file string) ([]string, error) {
+	ff, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer ff.Close()
+
+	var vals []string
+	scanner := bufio.NewScanner(ff)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == `` || strings.HasPrefix(line, `#`) {
+			continue
+		}
+		vals = append(vals, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
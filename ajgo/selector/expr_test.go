@@ -0,0 +1,136 @@
+package selector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// record is a selector.Record for testing.
+type record map[string][]string
+
+func (r record) SubjectValues(subject string) []string {
+	return r[subject]
+}
+
+func TestParse(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, `genes.txt`)
+	if err := os.WriteFile(file, []byte("# genes\nBRCA1\n\n  TP53  \n"), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	r := record{
+		`type`:   {`gene`},
+		`length`: {`150`},
+		`score`:  {`.`},
+		`name`:   {`TP53`},
+		`tag`:    {`basic`, `CCDS`},
+	}
+
+	var tests = []struct {
+		expr string
+		e    bool
+	}{
+		{`type ~ ^gene$`, true},
+		{`type ~ ^mRNA$`, false},
+		{`type !~ ^mRNA$`, true},
+		{`type == gene`, true},
+		{`type != gene`, false},
+		{`length>=100`, true},
+		{`length > 150`, false},
+		{`length <= 150 && length < 151`, true},
+		{`score < 0.5`, false},
+		{`not score < 0.5`, true},
+		{`tag == CCDS`, true},
+		{`tag != CCDS`, false},
+		{`missing ~ .`, false},
+		{`missing !~ .`, true},
+		{`name in @` + file, true},
+		{`name in ['BRCA2', "TP53"]`, true},
+		{`name in [BRCA2]`, false},
+		{`type == mRNA or type == gene and length > 100`, true},
+		{`(type == mRNA or type == gene) and length > 200`, false},
+		{`NOT (type == mRNA OR length < 100)`, true},
+		{`! ! type == gene`, true},
+		{`type ~ "a b|gene"`, true},
+		{`keep:type:^gene$`, true},
+		{`delete:type:^gene$`, false},
+		// the pattern of a three-part selector is everything after the
+		// second colon
+		{`keep:type:gene and length > 200`, false},
+	}
+
+	for _, tt := range tests {
+		x, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", tt.expr, err)
+		}
+		if g := x.Eval(r); g != tt.e {
+			t.Fatalf("Parse(%s).Eval should be %v but is %v", tt.expr, tt.e, g)
+		}
+		// String must give an expression that parses to the same result
+		y, err := Parse(x.String())
+		if err != nil {
+			t.Fatalf("Parse(%s) of String from %s failed: %v", x, tt.expr, err)
+		}
+		if g := y.Eval(r); g != tt.e {
+			t.Fatalf("Parse(%s).Eval of String from %s should be %v but is %v", x, tt.expr, tt.e, g)
+		}
+	}
+
+	bad := []string{
+		``,
+		`type`,
+		`type ==`,
+		`type ~ (`,
+		`length >= many`,
+		`(type == gene`,
+		`type == gene)`,
+		`type == gene and`,
+		`type == 'gene`,
+		`name in [a b]`,
+		`name in @` + filepath.Join(dir, `missing.txt`),
+		`name in genes.txt`,
+		`copy:type:gene`,
+	}
+	for _, s := range bad {
+		if _, err := Parse(s); err == nil {
+			t.Fatalf("Parse(%s) should have failed", s)
+		}
+	}
+}
+
+func TestIsShorthand(t *testing.T) {
+	for s, e := range map[string]bool{
+		`keep:seqid:^chr`:          true,
+		`delete::_random`:          true,
+		`name ~ '^chr'`:            false,
+		`name ~ 'keep:seqid:^chr'`: false,
+		`copy:seqid:^chr`:          false,
+	} {
+		if g := IsShorthand(s); g != e {
+			t.Fatalf("IsShorthand(%q) should be %v but is %v", s, e, g)
+		}
+	}
+}
+
+func TestSubjects(t *testing.T) {
+	x, err := ParseAll([]string{`type == gene or attr.Name ~ x`, `delete:length:^1$`})
+	if err != nil {
+		t.Fatalf("ParseAll failed: %v", err)
+	}
+	g := Subjects(x)
+	e := []string{`attr.Name`, `length`, `type`}
+	if len(g) != len(e) {
+		t.Fatalf("Subjects should be %v but is %v", e, g)
+	}
+	for i := range e {
+		if g[i] != e[i] {
+			t.Fatalf("Subjects should be %v but is %v", e, g)
+		}
+	}
+	if err = CheckSubjects(x, func(s string) bool { return s != `length` }); err == nil {
+		t.Fatalf("CheckSubjects should have failed on length")
+	}
+}
@@ -51,3 +51,18 @@ func NewFromStrings(selects []string) ([]*Selector, error) {
 	}
 	return sels, nil
 }
+
+// AttrPrefix starts the subjects that name an Attribute of a GFF3 or
+// GTF record, e.g. attr.gene_id.
+const AttrPrefix = `attr.`
+
+// IsFeatureSubject returns true if s is one of the subjects shared by
+// GFF3 and GTF records - the columns seqid, source, type, score, strand
+// and phase, length (End-Start+1) and attr.<key> for any Attribute.
+func IsFeatureSubject(s string) bool {
+	switch s {
+	case `seqid`, `source`, `type`, `score`, `strand`, `phase`, `length`:
+		return true
+	}
+	return strings.HasPrefix(s, AttrPrefix) && len(s) > len(AttrPrefix)
+}
@@ -40,3 +40,16 @@ func TestNewFromString(t *testing.T) {
 		}
 	}
 }
+
+func TestIsFeatureSubject(t *testing.T) {
+	for _, s := range []string{`seqid`, `type`, `phase`, `length`, `attr.gene_id`} {
+		if !IsFeatureSubject(s) {
+			t.Fatalf("IsFeatureSubject(%q) should be true", s)
+		}
+	}
+	for _, s := range []string{``, `attr.`, `feature`, `frame`, `header`} {
+		if IsFeatureSubject(s) {
+			t.Fatalf("IsFeatureSubject(%q) should be false", s)
+		}
+	}
+}
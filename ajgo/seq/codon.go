@@ -0,0 +1,164 @@
+package seq
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// standardAminoAcids is NCBI table 1 in the NCBI order of codons: the
+// bases TCAG in the first, second and third positions with the third
+// position changing fastest, i.e. TTT, TTC, TTA, TTG, TCT ... GGG.
+const standardAminoAcids = `FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG`
+
+// CodonTable is one of the NCBI genetic codes. Id is the NCBI
+// translation table number, e.g. 1 for the standard code or 2 for the
+// vertebrate mitochondrial code.
+type CodonTable struct {
+	Id   int
+	Name string
+
+	aminoAcids [64]byte
+	starts     [64]bool
+}
+
+// codonTables are defined by how they differ from the standard code,
+// as listed at https://www.ncbi.nlm.nih.gov/Taxonomy/Utils/wprintgc.cgi
+var codonTables = map[int]struct {
+	name    string
+	changes map[string]byte
+	starts  []string
+}{
+	1: {`Standard`, nil,
+		[]string{`TTG`, `CTG`, `ATG`}},
+	2: {`Vertebrate Mitochondrial`,
+		map[string]byte{`AGA`: '*', `AGG`: '*', `ATA`: 'M', `TGA`: 'W'},
+		[]string{`ATT`, `ATC`, `ATA`, `ATG`, `GTG`}},
+	3: {`Yeast Mitochondrial`,
+		map[string]byte{`ATA`: 'M', `CTT`: 'T', `CTC`: 'T', `CTA`: 'T', `CTG`: 'T', `TGA`: 'W'},
+		[]string{`ATA`, `ATG`, `GTG`}},
+	4: {`Mold, Protozoan, and Coelenterate Mitochondrial and Mycoplasma/Spiroplasma`,
+		map[string]byte{`TGA`: 'W'},
+		[]string{`TTA`, `TTG`, `CTG`, `ATT`, `ATC`, `ATA`, `ATG`, `GTG`}},
+	5: {`Invertebrate Mitochondrial`,
+		map[string]byte{`AGA`: 'S', `AGG`: 'S', `ATA`: 'M', `TGA`: 'W'},
+		[]string{`TTG`, `ATT`, `ATC`, `ATA`, `ATG`, `GTG`}},
+	6: {`Ciliate, Dasycladacean and Hexamita Nuclear`,
+		map[string]byte{`TAA`: 'Q', `TAG`: 'Q'},
+		[]string{`ATG`}},
+	9: {`Echinoderm and Flatworm Mitochondrial`,
+		map[string]byte{`AAA`: 'N', `AGA`: 'S', `AGG`: 'S', `TGA`: 'W'},
+		[]string{`ATG`, `GTG`}},
+	10: {`Euplotid Nuclear`,
+		map[string]byte{`TGA`: 'C'},
+		[]string{`ATG`}},
+	11: {`Bacterial, Archaeal and Plant Plastid`, nil,
+		[]string{`TTG`, `CTG`, `ATT`, `ATC`, `ATA`, `ATG`, `GTG`}},
+	12: {`Alternative Yeast Nuclear`,
+		map[string]byte{`CTG`: 'S'},
+		[]string{`CTG`, `ATG`}},
+	13: {`Ascidian Mitochondrial`,
+		map[string]byte{`AGA`: 'G', `AGG`: 'G', `ATA`: 'M', `TGA`: 'W'},
+		[]string{`TTG`, `ATA`, `ATG`, `GTG`}},
+	14: {`Alternative Flatworm Mitochondrial`,
+		map[string]byte{`AAA`: 'N', `AGA`: 'S', `AGG`: 'S', `TAA`: 'Y', `TGA`: 'W'},
+		[]string{`ATG`}},
+	16: {`Chlorophycean Mitochondrial`,
+		map[string]byte{`TAG`: 'L'},
+		[]string{`ATG`}},
+	21: {`Trematode Mitochondrial`,
+		map[string]byte{`AAA`: 'N', `AGA`: 'S', `AGG`: 'S', `ATA`: 'M', `TGA`: 'W'},
+		[]string{`ATG`, `GTG`}},
+	22: {`Scenedesmus obliquus Mitochondrial`,
+		map[string]byte{`TAG`: 'L', `TCA`: '*'},
+		[]string{`ATG`}},
+	23: {`Thraustochytrium Mitochondrial`,
+		map[string]byte{`TTA`: '*'},
+		[]string{`ATT`, `ATG`, `GTG`}},
+}
+
+// codonIndex returns the position of a codon in the NCBI order or -1
+// if the codon contains anything other than ACGT (either case) or U.
+func codonIndex(c string) int {
+	if len(c) != 3 {
+		return -1
+	}
+	var i int
+	for j := 0; j < 3; j++ {
+		var b int
+		switch c[j] {
+		case 'T', 't', 'U', 'u':
+			b = 0
+		case 'C', 'c':
+			b = 1
+		case 'A', 'a':
+			b = 2
+		case 'G', 'g':
+			b = 3
+		default:
+			return -1
+		}
+		i = i*4 + b
+	}
+	return i
+}
+
+// NewCodonTable returns the NCBI translation table with number id.
+func NewCodonTable(id int) (*CodonTable, error) {
+	d, ok := codonTables[id]
+	if !ok {
+		return nil, fmt.Errorf("NewCodonTable: translation table %d is not supported - use one of %v", id, CodonTableIds())
+	}
+	t := &CodonTable{Id: id, Name: d.name}
+	copy(t.aminoAcids[:], standardAminoAcids)
+	for c, aa := range d.changes {
+		t.aminoAcids[codonIndex(c)] = aa
+	}
+	for _, c := range d.starts {
+		t.starts[codonIndex(c)] = true
+	}
+	return t, nil
+}
+
+// CodonTableIds returns the numbers of the supported NCBI translation
+// tables in ascending order.
+func CodonTableIds() []int {
+	var ids []int
+	for id := range codonTables {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// AminoAcid returns the one-letter amino acid code for a codon, * for a
+// stop codon, or X if the codon contains an ambiguous base such as N.
+func (t *CodonTable) AminoAcid(codon string) byte {
+	i := codonIndex(codon)
+	if i < 0 {
+		return 'X'
+	}
+	return t.aminoAcids[i]
+}
+
+// IsStart returns true if codon is an initiation codon in this table.
+func (t *CodonTable) IsStart(codon string) bool {
+	i := codonIndex(codon)
+	return i >= 0 && t.starts[i]
+}
+
+// IsStop returns true if codon is a stop codon in this table.
+func (t *CodonTable) IsStop(codon string) bool {
+	return t.AminoAcid(codon) == '*'
+}
+
+// Translate translates a nucleotide sequence from its first base. Any
+// incomplete codon at the end is ignored and translation does not stop
+// at stop codons, which are translated as *.
+func (t *CodonTable) Translate(s string) string {
+	var b strings.Builder
+	for i := 0; i+3 <= len(s); i += 3 {
+		b.WriteByte(t.AminoAcid(s[i : i+3]))
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package seq
+
+import "testing"
+
+func TestCodonTable(t *testing.T) {
+	std, err := NewCodonTable(1)
+	if err != nil {
+		t.Fatalf("NewCodonTable failed: %v", err)
+	}
+	if got := std.Translate("ATGGCCtgaTAAAGANNNGGGT"); got != "MA**RXG" {
+		t.Fatalf("Translate should be MA**RXG but is %s", got)
+	}
+	if !std.IsStart("CTG") || std.IsStart("ATA") || !std.IsStop("TAG") {
+		t.Fatalf("standard table has the wrong start or stop codons")
+	}
+
+	mt, err := NewCodonTable(2)
+	if err != nil {
+		t.Fatalf("NewCodonTable failed: %v", err)
+	}
+	if got := mt.Translate("ATATGAAGAAGG"); got != "MW**" {
+		t.Fatalf("Translate should be MW** but is %s", got)
+	}
+	if !mt.IsStart("ATA") || mt.IsStart("TTG") {
+		t.Fatalf("mitochondrial table has the wrong start codons")
+	}
+
+	if _, err = NewCodonTable(7); err == nil {
+		t.Fatalf("NewCodonTable should fail for table 7")
+	}
+	for _, id := range CodonTableIds() {
+		if _, err = NewCodonTable(id); err != nil {
+			t.Fatalf("NewCodonTable failed for table %d: %v", id, err)
+		}
+	}
+}
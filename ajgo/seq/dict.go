@@ -0,0 +1,68 @@
+package seq
+
+import (
+	"crypto/md5"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DictHeader is the @HD line of a SAM sequence dictionary (.dict).
+const DictHeader = "@HD\tVN:1.6"
+
+// DictRecord is an @SQ line of a SAM sequence dictionary as written by
+// Picard CreateSequenceDictionary or samtools dict. URI is optional.
+type DictRecord struct {
+	Name   string
+	Length int
+	MD5    string
+	URI    string
+}
+
+// NewDictRecord returns a *DictRecord for a sequence. If file is not
+// empty, it is made absolute and used as a file: URI.
+func NewDictRecord(name, sequence, file string) (*DictRecord, error) {
+	r := &DictRecord{Name: name, Length: len(sequence), MD5: SequenceMD5(sequence)}
+	if file != `` {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, fmt.Errorf("NewDictRecord: %w", err)
+		}
+		r.URI = `file:` + abs
+	}
+	return r, nil
+}
+
+// String returns the DictRecord as an @SQ line without the newline.
+func (r *DictRecord) String() string {
+	fields := []string{`@SQ`, `SN:` + r.Name, `LN:` + strconv.Itoa(r.Length), `M5:` + r.MD5}
+	if r.URI != `` {
+		fields = append(fields, `UR:`+r.URI)
+	}
+	return strings.Join(fields, "\t")
+}
+
+// SequenceMD5 returns the MD5 of a sequence as used in the M5 tag of
+// SAM @SQ lines: the sequence is uppercased and any characters outside
+// of ! to ~ (e.g. whitespace) are removed before the MD5 is calculated.
+func SequenceMD5(s string) string {
+	h := md5.New()
+	buf := make([]byte, 0, 64*1024)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' {
+			continue
+		}
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		buf = append(buf, c)
+		if len(buf) == cap(buf) {
+			h.Write(buf)
+			buf = buf[:0]
+		}
+	}
+	h.Write(buf)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package seq
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FaiRecord is one line of a samtools FASTA index (.fai). Offset is the
+// byte position of the first base of the sequence in the FASTA file,
+// LineBases the number of bases on each line and LineWidth the number
+// of bytes on each line including the line ending.
+//
+// See http://www.htslib.org/doc/faidx.html
+type FaiRecord struct {
+	Name      string
+	Length    int
+	Offset    int64
+	LineBases int
+	LineWidth int
+}
+
+// String returns the FaiRecord as a line of a .fai file without the
+// newline.
+func (r *FaiRecord) String() string {
+	return strings.Join([]string{r.Name, strconv.Itoa(r.Length),
+		strconv.FormatInt(r.Offset, 10), strconv.Itoa(r.LineBases),
+		strconv.Itoa(r.LineWidth)}, "\t")
+}
+
+// IndexFastaFile indexes a FASTA file. As for samtools, gzipped files
+// cannot be indexed because offsets into the compressed data are of
+// no use.
+func IndexFastaFile(file string) ([]*FaiRecord, error) {
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		return nil, fmt.Errorf("IndexFastaFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		return nil, fmt.Errorf("IndexFastaFile: cannot index gzipped FASTA: %s", file)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	recs, err := IndexFasta(f)
+	if err != nil {
+		return nil, fmt.Errorf("IndexFastaFile: %s: %w", file, err)
+	}
+	return recs, nil
+}
+
+// IndexFasta reads FASTA and returns a FaiRecord for each sequence.
+// The name of a sequence is the first word of its header. Every line of
+// a sequence except the last must have the same length, otherwise the
+// sequence cannot be indexed, and sequence names must be unique.
+func IndexFasta(r io.Reader) ([]*FaiRecord, error) {
+	br := bufio.NewReader(r)
+	var recs []*FaiRecord
+	var rec *FaiRecord
+	names := make(map[string]bool)
+	var offset int64
+	var lctr int
+	short := false // rec has had a line shorter than LineBases
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == `` {
+			break
+		}
+		lctr++
+		width := len(line)
+		offset += int64(width)
+		bases := len(strings.TrimRight(line, "\r\n"))
+
+		if strings.HasPrefix(line, `>`) {
+			fields := strings.Fields(line[1:])
+			if len(fields) == 0 {
+				return nil, fmt.Errorf("line %d: header has no sequence name", lctr)
+			}
+			if names[fields[0]] {
+				return nil, fmt.Errorf("line %d: sequence name %s is not unique", lctr, fields[0])
+			}
+			names[fields[0]] = true
+			rec = &FaiRecord{Name: fields[0], Offset: offset}
+			recs = append(recs, rec)
+			short = false
+			continue
+		}
+
+		if rec == nil {
+			if bases == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: sequence before the first header", lctr)
+		}
+		if bases == 0 {
+			short = true
+			continue
+		}
+		switch {
+		case short:
+			return nil, fmt.Errorf("line %d: sequence %s has lines of different lengths", lctr, rec.Name)
+		case rec.LineBases == 0:
+			rec.LineBases = bases
+			rec.LineWidth = width
+		case bases > rec.LineBases:
+			return nil, fmt.Errorf("line %d: sequence %s has lines of different lengths", lctr, rec.Name)
+		// A full-length last line at the end of the file need not have a
+		// line ending so only lines that have one are checked.
+		case bases == rec.LineBases && width != rec.LineWidth && strings.HasSuffix(line, "\n"):
+			return nil, fmt.Errorf("line %d: sequence %s has lines of different lengths", lctr, rec.Name)
+		case bases < rec.LineBases:
+			short = true
+		}
+		rec.Length += bases
+	}
+	return recs, nil
+}
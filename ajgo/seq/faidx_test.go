@@ -0,0 +1,54 @@
+package seq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIndexFasta(t *testing.T) {
+	recs, err := IndexFasta(strings.NewReader(">s1 first\nACGT\nACGT\nAC\n>s2\r\nAAA\r\nA\r\n\n>s3\n"))
+	if err != nil {
+		t.Fatalf("IndexFasta failed: %v", err)
+	}
+	var got []string
+	for _, r := range recs {
+		got = append(got, r.String())
+	}
+	e := "s1\t10\t10\t4\t5;s2\t4\t28\t3\t5;s3\t0\t41\t0\t0"
+	if strings.Join(got, ";") != e {
+		t.Fatalf("IndexFasta should give %q but gave %q", e, strings.Join(got, ";"))
+	}
+
+	// The last line can be full length with no newline
+	recs, err = IndexFasta(strings.NewReader(">a\nACGT\nACGT"))
+	if err != nil || len(recs) != 1 || recs[0].String() != "a\t8\t3\t4\t5" {
+		t.Fatalf("IndexFasta should give a\t8\t3\t4\t5 for a last line with no newline but gave %v %v", recs, err)
+	}
+
+	bad := []string{
+		">s1\nACG\nACGT\n",
+		">s1\nACGT\nAC\nACGT\n",
+		">s1\nACGT\n\nACGT\n",
+		">s1\nA\n>s1\nA\n",
+		"ACGT\n",
+	}
+	for _, b := range bad {
+		if _, err = IndexFasta(strings.NewReader(b)); err == nil {
+			t.Fatalf("IndexFasta should fail for %q", b)
+		}
+	}
+}
+
+func TestSequenceMD5(t *testing.T) {
+	// MD5 of ACGT
+	if got := SequenceMD5("ac gt\n"); got != "f1f8f4bf413b16ad135722aa4591043e" {
+		t.Fatalf("SequenceMD5 should be f1f8f4bf413b16ad135722aa4591043e but is %s", got)
+	}
+	r, err := NewDictRecord("chr1", "ACGT", "")
+	if err != nil {
+		t.Fatalf("NewDictRecord failed: %v", err)
+	}
+	if got := r.String(); got != "@SQ\tSN:chr1\tLN:4\tM5:f1f8f4bf413b16ad135722aa4591043e" {
+		t.Fatalf("DictRecord is wrong: %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package seq
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// DefaultLineWidth is the number of bases per line used by Writer
+// unless LineWidth is changed.
+const DefaultLineWidth = 60
+
+// Record is a single FASTA record. Header does not include the >.
+type Record struct {
+	Header   string
+	Sequence string
+}
+
+// Writer writes FASTA one Record at a time with the sequence wrapped
+// at LineWidth bases per line. A LineWidth of 0 or less writes each
+// sequence on a single line.
+type Writer struct {
+	File      string
+	LineWidth int
+
+	w       *bufio.Writer
+	closers []io.Closer
+	count   int
+}
+
+// NewWriter creates a *Writer that writes to an io.Writer.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), LineWidth: DefaultLineWidth}
+}
+
+// NewWriterToFile creates a file and returns a *Writer for it. Files
+// with a .gz extension are gzipped on-the-fly. The caller must call
+// Close to flush the output and close the file.
+func NewWriterToFile(file string) (*Writer, error) {
+	ff, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = ff
+	closers := []io.Closer{ff}
+	found, err := regexp.MatchString(`\.[gG][zZ]$`, file)
+	if err != nil {
+		ff.Close()
+		return nil, fmt.Errorf("NewWriterToFile: error matching gzip file pattern against %s: %w", file, err)
+	}
+	if found {
+		gz := gzip.NewWriter(ff)
+		w = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	fw := NewWriter(w)
+	fw.File = file
+	fw.closers = closers
+	return fw, nil
+}
+
+// Write writes a single Record.
+func (w *Writer) Write(r *Record) error {
+	if _, err := w.w.WriteString(">" + r.Header + "\n"); err != nil {
+		return err
+	}
+	s := r.Sequence
+	for len(s) > 0 {
+		n := len(s)
+		if w.LineWidth > 0 && n > w.LineWidth {
+			n = w.LineWidth
+		}
+		if _, err := w.w.WriteString(s[:n] + "\n"); err != nil {
+			return err
+		}
+		s = s[n:]
+	}
+	w.count++
+	return nil
+}
+
+// Count returns the number of Records written so far.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+// Flush writes any buffered output to the underlying io.Writer.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Close flushes buffered output and closes any files opened by
+// NewWriterToFile.
+func (w *Writer) Close() error {
+	first := w.Flush()
+	for _, c := range w.closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
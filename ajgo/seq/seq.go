@@ -0,0 +1,36 @@
+// Package seq has the nucleotide sequence operations that ajgo needs on
+// top of github.com/grendeloz/ngs/genome - reverse complementing,
+// translating with the NCBI codon tables, writing FASTA records and
+// making FASTA indexes and sequence dictionaries.
+package seq
+
+import "strings"
+
+// complement maps each IUPAC nucleotide code to its complement. Case
+// is kept and anything not in the map (e.g. - or *) is left alone.
+var complement = func() [256]byte {
+	var c [256]byte
+	for i := range c {
+		c[i] = byte(i)
+	}
+	pairs := []string{`AT`, `CG`, `RY`, `KM`, `BV`, `DH`, `SS`, `WW`, `NN`}
+	for _, p := range pairs {
+		for _, q := range []string{p, strings.ToLower(p)} {
+			c[q[0]] = q[1]
+			c[q[1]] = q[0]
+		}
+	}
+	c['U'], c['u'] = 'A', 'a'
+	return c
+}()
+
+// ReverseComplement returns the reverse complement of a nucleotide
+// sequence. IUPAC ambiguity codes are complemented (e.g. R and Y) and
+// U is complemented to A.
+func ReverseComplement(s string) string {
+	b := make([]byte, len(s))
+	for i := 0; i < len(s); i++ {
+		b[len(s)-1-i] = complement[s[i]]
+	}
+	return string(b)
+}
@@ -0,0 +1,30 @@
+package seq
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReverseComplement(t *testing.T) {
+	if got := ReverseComplement("ACGTNacgtnRYU-"); got != "-ARYnacgtNACGT" {
+		t.Fatalf("ReverseComplement should be -ARYnacgtNACGT but is %s", got)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	w.LineWidth = 4
+	for _, r := range []*Record{{"s1 x", "ACGTACGTA"}, {"s2", "ACGT"}} {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	e := ">s1 x\nACGT\nACGT\nA\n>s2\nACGT\n"
+	if b.String() != e || w.Count() != 2 {
+		t.Fatalf("Writer should write %q but wrote %q", e, b.String())
+	}
+}